@@ -34,6 +34,10 @@ type EntryOptions struct {
 	metaData     map[string]string
 	// fromService 来源服务，如果为空或者为default则意味着没有设置
 	fromService string
+	// entrance 调用链路的入口资源
+	entrance string
+	// targetAddress 本次调用选中的目标地址
+	targetAddress string
 }
 
 func (o *EntryOptions) Reset() {
@@ -49,6 +53,9 @@ func (o *EntryOptions) Reset() {
 	o.cookies = nil
 	o.body = nil
 	o.metaData = nil
+	o.fromService = ""
+	o.entrance = ""
+	o.targetAddress = ""
 }
 
 type EntryOption func(options *EntryOptions)
@@ -132,6 +139,22 @@ func WithHeaders(headers map[string][]string) EntryOption {
 	}
 }
 
+// WithCookies sets the resource entry with the given cookies.
+// mainly for adapter web gray
+func WithCookies(cookies map[string][]string) EntryOption {
+	return func(options *EntryOptions) {
+		options.cookies = cookies
+	}
+}
+
+// WithBody sets the resource entry with the given body params.
+// mainly for adapter web gray
+func WithBody(body map[string][]string) EntryOption {
+	return func(options *EntryOptions) {
+		options.body = body
+	}
+}
+
 // WithMetaData sets the resource entry with the given context key value.
 // mainly for adapter micro
 func WithMetaData(metaData map[string]string) EntryOption {
@@ -147,6 +170,29 @@ func WithFromService(fromService string) EntryOption {
 	}
 }
 
+// WithEntrance 设置调用链路的入口资源，用于链路(ChainResource)流控
+func WithEntrance(entrance string) EntryOption {
+	return func(options *EntryOptions) {
+		options.entrance = entrance
+	}
+}
+
+// WithTargetAddress 设置本次调用选中的目标地址，用于按照下游地址熔断
+func WithTargetAddress(address string) EntryOption {
+	return func(options *EntryOptions) {
+		options.targetAddress = address
+	}
+}
+
+// WithParentEntry 以父entry的入口资源作为当前entry的入口资源，用于嵌套调用时传递链路入口
+func WithParentEntry(parent *base.SeaEntry) EntryOption {
+	return func(options *EntryOptions) {
+		if parent != nil {
+			options.entrance = parent.Entrance()
+		}
+	}
+}
+
 // Entry 基础API.
 func Entry(resource string, opts ...EntryOption) (*base.SeaEntry, *base.BlockError) {
 	options := entryOptsPool.Get().(*EntryOptions)
@@ -168,7 +214,9 @@ func entry(resource string, options *EntryOptions) (*base.SeaEntry, *base.BlockE
 	rw := base.NewResourceWrapper(resource, options.resourceType, options.entryType)
 	sc := options.slotChain
 	if sc == nil {
-		return base.NewSeaEntry(nil, rw, nil), nil
+		e := base.NewSeaEntry(nil, rw, nil)
+		e.SetEntrance(options.entrance)
+		return e, nil
 	}
 
 	// Get context from pool.
@@ -177,6 +225,7 @@ func entry(resource string, options *EntryOptions) (*base.SeaEntry, *base.BlockE
 	ctx.Input.BatchCount = options.batchCount
 	ctx.Input.Flag = options.flag
 	ctx.FromService = options.fromService
+	ctx.Entrance = options.entrance
 	if len(options.args) != 0 {
 		ctx.Input.Args = options.args
 	}
@@ -195,10 +244,12 @@ func entry(resource string, options *EntryOptions) (*base.SeaEntry, *base.BlockE
 	if len(options.metaData) != 0 {
 		ctx.Input.MetaData = options.metaData
 	}
+	ctx.Input.TargetAddress = options.targetAddress
 	if len(options.rsps) != 0 {
 		ctx.Output.Rsps = options.rsps
 	}
 	e := base.NewSeaEntry(ctx, rw, sc)
+	e.SetEntrance(options.entrance)
 	ctx.SetEntry(e)
 	r := sc.Entry(ctx)
 	if r == nil {
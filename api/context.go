@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+type entryCtxKey struct{}
+
+// ContextWithEntry 将entry放入context，下游通过WithParentContext传递调用链路入口
+func ContextWithEntry(ctx context.Context, entry *base.SeaEntry) context.Context {
+	if entry == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, entryCtxKey{}, entry)
+}
+
+// EntryFromContext 返回context中的entry，不存在时返回nil
+func EntryFromContext(ctx context.Context) *base.SeaEntry {
+	if ctx == nil {
+		return nil
+	}
+	entry, _ := ctx.Value(entryCtxKey{}).(*base.SeaEntry)
+	return entry
+}
+
+// WithParentContext 以context中entry的入口资源作为当前entry的入口资源
+func WithParentContext(ctx context.Context) EntryOption {
+	return WithParentEntry(EntryFromContext(ctx))
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/hotspot"
 	"github.com/liuhailove/gmiter/core/log/metric"
 	"github.com/liuhailove/gmiter/core/system_metric"
 	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
@@ -121,6 +122,10 @@ func initCoreComponents() error {
 		system_metric.InitMemoryCollector(memStatInterval)
 	}
 
+	if config.HotParamsSnapshotIntervalMs() > 0 {
+		hotspot.InitTopParamsTask(config.HotParamsSnapshotIntervalMs(), config.HotParamsTopN())
+	}
+
 	if config.UseCacheTime() {
 		util.StartTimeTicker()
 	}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/retry"
+	"github.com/liuhailove/gmiter/core/retry/context"
+	"github.com/liuhailove/gmiter/core/retry/rule"
+	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
+)
+
+const (
+	RetryResultSuccess   = "success"
+	RetryResultExhausted = "exhausted"
+	RetryResultBlocked   = "blocked"
+)
+
+var (
+	retryAttemptCounter = metric_exporter.NewCounter(
+		"retry_attempt_total",
+		"Total retry attempt count, not including the first call",
+		[]string{"resource"})
+
+	retryResultCounter = metric_exporter.NewCounter(
+		"retry_result_total",
+		"Total result count of the retried call",
+		[]string{"resource", "result"})
+)
+
+func init() {
+	metric_exporter.Register(retryAttemptCounter)
+	metric_exporter.Register(retryResultCounter)
+}
+
+// RetryFunc 具有重试语意的业务回调，可以通过 ctx.GetRetryCount() 获取已重试次数
+type RetryFunc func(ctx retry.RtyContext) (interface{}, error)
+
+// EntryWithRetry 按照资源配置的重试规则执行 fn。
+// 每次尝试都会单独走一遍 Entry/Exit，因此每次的错误和RT都会记录到资源的统计节点上；
+// 某次尝试被规则阻塞时停止重试，返回 *base.BlockError。
+// 资源没有配置重试规则时，只执行一次。
+func EntryWithRetry(resource string, fn RetryFunc, opts ...EntryOption) (interface{}, error) {
+	callback := &entryRetryCallback{resource: resource, fn: fn, opts: opts}
+	template := rule.GetRetryTemplateOfResource(resource)
+	if template == nil {
+		return callback.doOnce(&context.RtyContextSupport{})
+	}
+	result, err := template.Execute(callback)
+	if err == nil {
+		retryResultCounter.Add(1, resource, RetryResultSuccess)
+	} else if callback.blockErr != nil {
+		retryResultCounter.Add(1, resource, RetryResultBlocked)
+		return nil, callback.blockErr
+	} else {
+		retryResultCounter.Add(1, resource, RetryResultExhausted)
+	}
+	return result, err
+}
+
+type entryRetryCallback struct {
+	resource string
+	fn       RetryFunc
+	opts     []EntryOption
+	blockErr *base.BlockError
+}
+
+func (c *entryRetryCallback) DoWithRetry(ctx retry.RtyContext) interface{} {
+	if ctx.GetRetryCount() > 0 {
+		retryAttemptCounter.Add(1, c.resource)
+	}
+	result, err := c.doOnce(ctx)
+	if c.blockErr != nil {
+		// 被规则阻塞后重试没有意义，直接结束
+		ctx.SetExhaustedOnly()
+	}
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func (c *entryRetryCallback) doOnce(ctx retry.RtyContext) (interface{}, error) {
+	entry, blockErr := Entry(c.resource, c.opts...)
+	if blockErr != nil {
+		c.blockErr = blockErr
+		return nil, blockErr
+	}
+	defer entry.Exit()
+
+	result, err := c.fn(ctx)
+	if err != nil {
+		TraceError(entry, err)
+	}
+	return result, err
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/liuhailove/gmiter/core/authority"
 	"github.com/liuhailove/gmiter/core/base"
 	"github.com/liuhailove/gmiter/core/circuitbreaker"
 	"github.com/liuhailove/gmiter/core/flow"
@@ -23,6 +24,7 @@ func BuildDefaultSlotChain() *base.SlotChain {
 	sc := base.NewSlotChain()
 	sc.AddStatPrepareSlot(stat.DefaultResourceNodePrepareSlot)
 
+	sc.AddRuleCheckSlot(authority.DefaultSlot)
 	sc.AddRuleCheckSlot(system.DefaultAdaptiveSlot)
 	sc.AddRuleCheckSlot(flow.DefaultSlot)
 	sc.AddRuleCheckSlot(isolation.DefaultSlot)
@@ -34,6 +36,7 @@ func BuildDefaultSlotChain() *base.SlotChain {
 	sc.AddStatSlot(stat.DefaultSlot)
 	sc.AddStatSlot(log.DefaultSlot)
 	sc.AddStatSlot(flow.DefaultStandaloneStatSlot)
+	sc.AddStatSlot(isolation.DefaultStatSlot)
 	sc.AddStatSlot(hotspot.DefaultConcurrencyStatSlot)
 	sc.AddStatSlot(circuitbreaker.DefaultMetricStatSlot)
 
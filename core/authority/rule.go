@@ -0,0 +1,72 @@
+package authority
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/liuhailove/gmiter/util"
+	"strings"
+)
+
+// Strategy 授权策略
+type Strategy int32
+
+const (
+	// WhiteList 白名单，只有 LimitApp 中的来源可以访问
+	WhiteList Strategy = iota
+	// BlackList 黑名单，LimitApp 中的来源禁止访问
+	BlackList
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case WhiteList:
+		return "WhiteList"
+	case BlackList:
+		return "BlackList"
+	default:
+		return "Undefined"
+	}
+}
+
+// Rule 描述授权（黑白名单）规则
+type Rule struct {
+	// ID 规则唯一ID（可选）
+	ID string `json:"id,omitempty"`
+	// Resource 目标资源
+	Resource string `json:"resource"`
+	// LimitApp 来源应用列表，多个来源以逗号（','）分隔，
+	// 来源取自 EntryContext.FromService
+	LimitApp string `json:"limitApp"`
+	// Strategy 授权策略，白名单或黑名单
+	Strategy Strategy `json:"strategy"`
+}
+
+func (r *Rule) String() string {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	b, err := json.Marshal(r)
+	if err != nil {
+		// Return the fallback string
+		return fmt.Sprintf("{Id=%s, Resource=%s, LimitApp=%s, Strategy=%s}", r.ID, r.Resource, r.LimitApp, r.Strategy.String())
+	}
+	return string(b)
+}
+
+func (r *Rule) ResourceName() string {
+	return r.Resource
+}
+
+// LimitApps 返回拆分后的来源应用列表
+func (r *Rule) LimitApps() []string {
+	apps := make([]string, 0, 4)
+	for _, app := range strings.Split(r.LimitApp, ",") {
+		if app = strings.TrimSpace(app); app != "" {
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// containsApp 判断 origin 是否在 LimitApp 列表中
+func (r *Rule) containsApp(origin string) bool {
+	return util.Contains(origin, r.LimitApps())
+}
@@ -0,0 +1,210 @@
+package authority
+
+import (
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+	"github.com/pkg/errors"
+	"reflect"
+	"sync"
+)
+
+var (
+	ruleMap       = make(map[string][]*Rule)
+	rwMux         = &sync.RWMutex{}
+	currentRules  = make(map[string][]*Rule, 0)
+	updateRuleMux = new(sync.Mutex)
+)
+
+// LoadRules loads the given authority rules to the rule manager, while all previous rules will be replaced.
+// the first returned value indicates whether you do real load operation, if the rules is the same with previous rules, return false
+func LoadRules(rules []*Rule) (bool, error) {
+	resRulesMap := make(map[string][]*Rule, 16)
+	for _, rule := range rules {
+		resRules, exist := resRulesMap[rule.Resource]
+		if !exist {
+			resRules = make([]*Rule, 0, 1)
+		}
+		resRulesMap[rule.Resource] = append(resRules, rule)
+	}
+	updateRuleMux.Lock()
+	defer updateRuleMux.Unlock()
+	isEqual := reflect.DeepEqual(currentRules, resRulesMap)
+	if isEqual {
+		logging.Info("[Authority] Load rules is the same with current rules, so ignore load operation.")
+		return false, nil
+	}
+	err := onRuleUpdate(resRulesMap)
+	return true, err
+}
+
+func onRuleUpdate(rawResRulesMap map[string][]*Rule) (err error) {
+	validResRulesMap := make(map[string][]*Rule, len(rawResRulesMap))
+	for res, rules := range rawResRulesMap {
+		validResRules := make([]*Rule, 0, len(rules))
+		for _, rule := range rules {
+			if err := IsValidRule(rule); err != nil {
+				logging.Warn("[Authority onRuleUpdate] Ignoring invalid authority rule", "rule", rule, "reason", err.Error())
+				continue
+			}
+			validResRules = append(validResRules, rule)
+		}
+		if len(validResRules) > 0 {
+			validResRulesMap[res] = validResRules
+		}
+	}
+	start := util.CurrentTimeNano()
+	rwMux.Lock()
+	ruleMap = validResRulesMap
+	rwMux.Unlock()
+	currentRules = rawResRulesMap
+	logging.Debug("[Authority onRuleUpdate] Time statistic(ns) for updating authority rule", "timeCost", util.CurrentTimeNano()-start)
+	logRuleUpdate(validResRulesMap)
+	return
+}
+
+// LoadRulesOfResource loads the given resource's authority rules to the rule manager, while all previous resource's rules will be replaced.
+// the first returned value indicates whether you do real load operation, if the rules is the same with previous resource's rules, return false
+func LoadRulesOfResource(res string, rules []*Rule) (bool, error) {
+	if len(res) == 0 {
+		return false, errors.New("empty resource")
+	}
+	updateRuleMux.Lock()
+	defer updateRuleMux.Unlock()
+	// clear resource rules
+	if len(rules) == 0 {
+		// clear resource's currentRules
+		delete(currentRules, res)
+		// clear ruleMap
+		rwMux.Lock()
+		delete(ruleMap, res)
+		rwMux.Unlock()
+		logging.Info("[Authority] clear resource level rules", "resource", res)
+		return true, nil
+	}
+	// load resource level rules
+	isEqual := reflect.DeepEqual(currentRules[res], rules)
+	if isEqual {
+		logging.Info("[Authority] Load resource level rules is the same with current resource level rules, so ignore load operation.")
+		return false, nil
+	}
+	err := onResourceUpdate(res, rules)
+	return true, err
+}
+
+func onResourceUpdate(res string, rawResRules []*Rule) (err error) {
+	validResRules := make([]*Rule, 0, len(rawResRules))
+	for _, rule := range rawResRules {
+		if err := IsValidRule(rule); err != nil {
+			logging.Warn("[Authority onResourceRuleUpdate] Ignoring invalid authority rule", "rule", rule, "reason", err.Error())
+			continue
+		}
+		validResRules = append(validResRules, rule)
+	}
+
+	start := util.CurrentTimeNano()
+	rwMux.Lock()
+	if len(validResRules) == 0 {
+		delete(ruleMap, res)
+	} else {
+		ruleMap[res] = validResRules
+	}
+	rwMux.Unlock()
+	currentRules[res] = rawResRules
+	logging.Debug("[Authority onResourceRuleUpdate] Time statistic(ns) for updating authority rule", "timeCost", util.CurrentTimeNano()-start)
+	logging.Info("[Authority] load resource level rules", "resource", res, "validResRules", validResRules)
+	return nil
+}
+
+// ClearRules clears all the rules in authority module.
+func ClearRules() error {
+	_, err := LoadRules(nil)
+	return err
+}
+
+// GetRules returns all the rules based on copy.
+// It doesn't take effect for authority module if user changes the rule.
+func GetRules() []Rule {
+	rules := getRules()
+	ret := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		ret = append(ret, *rule)
+	}
+	return ret
+}
+
+// GetRulesOfResource returns specific resource's rules based on copy.
+// It doesn't take effect for authority module if user changes the rule.
+func GetRulesOfResource(res string) []Rule {
+	rules := getRulesOfResource(res)
+	ret := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		ret = append(ret, *rule)
+	}
+	return ret
+}
+
+// getRules returns all the rules。Any changes of rules take effect for authority module
+// getRules is an internal interface.
+func getRules() []*Rule {
+	rwMux.RLock()
+	defer rwMux.RUnlock()
+	return rulesFrom(ruleMap)
+}
+
+// getRulesOfResource returns specific resource's rules。Any changes of rules take effect for authority module
+// getRulesOfResource is an internal interface.
+func getRulesOfResource(res string) []*Rule {
+	rwMux.RLock()
+	defer rwMux.RUnlock()
+
+	resRules, exist := ruleMap[res]
+	if !exist {
+		return nil
+	}
+	ret := make([]*Rule, 0, len(resRules))
+	for _, r := range resRules {
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func rulesFrom(m map[string][]*Rule) []*Rule {
+	rules := make([]*Rule, 0, 8)
+	if len(m) == 0 {
+		return rules
+	}
+	for _, rs := range m {
+		for _, r := range rs {
+			if r != nil {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules
+}
+
+func logRuleUpdate(m map[string][]*Rule) {
+	rs := rulesFrom(m)
+	if len(rs) == 0 {
+		logging.Info("[AuthorityRuleManager] Authority rules were cleared")
+	} else {
+		logging.Info("[AuthorityRuleManager] Authority rules were loaded", "rules", rs)
+	}
+}
+
+// IsValidRule checks whether the given Rule is valid.
+func IsValidRule(r *Rule) error {
+	if r == nil {
+		return errors.New("nil authority rule")
+	}
+	if len(r.Resource) == 0 {
+		return errors.New("empty resource of authority rule")
+	}
+	if len(r.LimitApps()) == 0 {
+		return errors.New("empty limitApp of authority rule")
+	}
+	if r.Strategy != WhiteList && r.Strategy != BlackList {
+		return errors.Errorf("unsupported strategy: %d", r.Strategy)
+	}
+	return nil
+}
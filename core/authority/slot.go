@@ -0,0 +1,62 @@
+package authority
+
+import (
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	RuleCheckSlotOrder = 500
+)
+
+var (
+	DefaultSlot = &Slot{}
+)
+
+// Slot 授权规则检查槽，在所有规则检查中最先执行
+type Slot struct {
+}
+
+func (s *Slot) Order() uint32 {
+	return RuleCheckSlotOrder
+}
+
+// Initial
+//
+// 初始化，如果有初始化工作放入其中
+func (s *Slot) Initial() {
+}
+
+func (s *Slot) Check(ctx *base.EntryContext) *base.TokenResult {
+	resource := ctx.Resource.Name()
+	result := ctx.RuleCheckResult
+	if len(resource) == 0 {
+		return result
+	}
+	if passed, rule := checkPass(ctx); !passed {
+		msg := "origin is not authorized"
+		if result == nil {
+			result = base.NewTokenResultBlockedWithCause(base.BlockTypeAuthority, msg, rule, ctx.FromService)
+		} else {
+			result.ResetToBlockedWithCause(base.BlockTypeAuthority, msg, rule, ctx.FromService)
+		}
+	}
+	return result
+}
+
+// checkPass 来源为空时直接通过，否则依次校验资源上的黑白名单
+func checkPass(ctx *base.EntryContext) (bool, *Rule) {
+	origin := ctx.FromService
+	if len(origin) == 0 {
+		return true, nil
+	}
+	for _, rule := range getRulesOfResource(ctx.Resource.Name()) {
+		contains := rule.containsApp(origin)
+		if rule.Strategy == BlackList && contains {
+			return false, rule
+		}
+		if rule.Strategy == WhiteList && !contains {
+			return false, rule
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,48 @@
+package authority
+
+import (
+	"testing"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEntryContext(res, origin string) *base.EntryContext {
+	ctx := base.NewEmptyEntryContext()
+	ctx.Resource = base.NewResourceWrapper(res, base.ResTypeCommon, base.Inbound)
+	ctx.FromService = origin
+	return ctx
+}
+
+func TestSlot_Check(t *testing.T) {
+	_, err := LoadRules([]*Rule{
+		{Resource: "white", LimitApp: "appA, appB", Strategy: WhiteList},
+		{Resource: "black", LimitApp: "appA", Strategy: BlackList},
+	})
+	assert.Nil(t, err)
+	defer ClearRules()
+
+	t.Run("WhiteList", func(t *testing.T) {
+		assert.Nil(t, DefaultSlot.Check(newEntryContext("white", "appB")))
+		r := DefaultSlot.Check(newEntryContext("white", "appC"))
+		assert.True(t, r.IsBlocked())
+		assert.Equal(t, base.BlockTypeAuthority, r.BlockError().BlockType())
+	})
+
+	t.Run("BlackList", func(t *testing.T) {
+		assert.Nil(t, DefaultSlot.Check(newEntryContext("black", "appAA")))
+		r := DefaultSlot.Check(newEntryContext("black", "appA"))
+		assert.True(t, r.IsBlocked())
+	})
+
+	t.Run("EmptyOrigin", func(t *testing.T) {
+		assert.Nil(t, DefaultSlot.Check(newEntryContext("white", "")))
+	})
+}
+
+func TestIsValidRule(t *testing.T) {
+	assert.NotNil(t, IsValidRule(nil))
+	assert.NotNil(t, IsValidRule(&Rule{Resource: "abc", LimitApp: " , "}))
+	assert.NotNil(t, IsValidRule(&Rule{Resource: "abc", LimitApp: "a", Strategy: 3}))
+	assert.Nil(t, IsValidRule(&Rule{Resource: "abc", LimitApp: "a", Strategy: BlackList}))
+}
@@ -22,6 +22,8 @@ const (
 	ContextDefault_Name    = "sea_default_context"
 	CpuUsageResourceName   = "__cpu_usage__"
 	SystemLoadResourceName = "__system_load__"
+	// HotParamResourceNamePrefix 热点参数值在指标日志中的资源名称前缀
+	HotParamResourceNamePrefix = "__hot_param__:"
 )
 
 var (
@@ -16,6 +16,10 @@ type EntryContext struct {
 
 	Resource *ResourceWrapper
 	StatNode StatNode
+	// Entrance 调用链路的入口资源，为空表示当前资源即为入口
+	Entrance string
+	// EntranceStatNode 入口资源下当前资源的统计节点，仅在Entrance不为空时存在
+	EntranceStatNode StatNode
 
 	// 输入
 	Input *seaInput
@@ -92,6 +96,8 @@ type seaInput struct {
 	MetaData map[string]string
 	// store some values in this context when calling context in slot.
 	Attachments map[interface{}]interface{}
+	// TargetAddress 本次调用选中的目标地址，用于按照下游地址熔断
+	TargetAddress string
 }
 
 func (i *seaInput) reset() {
@@ -115,6 +121,7 @@ func (i *seaInput) reset() {
 	if len(i.Attachments) != 0 {
 		i.Attachments = map[interface{}]interface{}{}
 	}
+	i.TargetAddress = ""
 }
 
 // seaOutput The output data of sea
@@ -138,6 +145,8 @@ func (ctx *EntryContext) Reset() {
 	ctx.rt = 0
 	ctx.Resource = nil
 	ctx.StatNode = nil
+	ctx.Entrance = ""
+	ctx.EntranceStatNode = nil
 	ctx.Input.reset()
 	ctx.Output.reset()
 	if ctx.RuleCheckResult == nil {
@@ -16,6 +16,8 @@ type SeaEntry struct {
 	grayTag      string
 	grayAddress  []string
 	grayMatchRes string
+	// entrance 调用链路的入口资源
+	entrance string
 
 	// one entry bounds with one context
 	ctx *EntryContext
@@ -54,6 +56,22 @@ func (e *SeaEntry) SetError(err error) {
 		e.ctx.SetError(err)
 	}
 }
+
+// Entrance 返回调用链路的入口资源，当前资源为入口时返回当前资源名称
+func (e *SeaEntry) Entrance() string {
+	if e.entrance != "" {
+		return e.entrance
+	}
+	if e.res == nil {
+		return ""
+	}
+	return e.res.Name()
+}
+
+func (e *SeaEntry) SetEntrance(entrance string) {
+	e.entrance = entrance
+}
+
 func (e *SeaEntry) Context() *EntryContext {
 	return e.ctx
 }
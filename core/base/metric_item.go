@@ -31,6 +31,11 @@ type MetricItem struct {
 	BlockedNumBySystem          uint64 `json:"blockedNumBySystem"`          // 被系统限流阻塞数
 	BlockedNumByHotspotParam    uint64 `json:"blockedNumByHotspotParam"`    // 被热点限流阻塞数
 	BlockedNumByMock            uint64 `json:"blockedNumByMock"`            // 被Mock阻塞数
+
+	// 分位RT
+	P50Rt uint64 `json:"p50Rt"`
+	P90Rt uint64 `json:"p90Rt"`
+	P99Rt uint64 `json:"p99Rt"`
 }
 
 type MetricItemRetriever interface {
@@ -42,12 +47,13 @@ func (m *MetricItem) ToFatString() (string, error) {
 	timeStr := util.FormatTimeMillis(m.Timestamp)
 	// 所有“|”资源名称中的内容将替换为“_”
 	finalName := strings.ReplaceAll(m.Resource, "|", "-")
-	_, err := fmt.Fprintf(&b, "%d|%s|%s|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d",
+	_, err := fmt.Fprintf(&b, "%d|%s|%s|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d",
 		m.Timestamp, timeStr, finalName, m.PassQps,
 		m.BlockQps, m.CompleteQps, m.ErrorQps, m.AvgRt,
 		m.OccupiedPassQps, m.Concurrency, m.Classification,
 		m.BlockedNumByFlow, m.BlockedNumByIsolation, m.BlockedNumByCircuitBreaking,
-		m.BlockedNumBySystem, m.BlockedNumByHotspotParam, m.BlockedNumByMock)
+		m.BlockedNumBySystem, m.BlockedNumByHotspotParam, m.BlockedNumByMock,
+		m.P50Rt, m.P90Rt, m.P99Rt)
 	if err != nil {
 		return "", err
 	}
@@ -57,12 +63,13 @@ func (m *MetricItem) ToFatString() (string, error) {
 func (m *MetricItem) ToThinString() (string, error) {
 	b := strings.Builder{}
 	finalName := strings.ReplaceAll(m.Resource, "|", "-")
-	_, err := fmt.Fprintf(&b, "%d|%s|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d",
+	_, err := fmt.Fprintf(&b, "%d|%s|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d|%d",
 		m.Timestamp, finalName, m.PassQps,
 		m.BlockQps, m.CompleteQps, m.ErrorQps, m.AvgRt,
 		m.OccupiedPassQps, m.Concurrency, m.Classification,
 		m.BlockedNumByFlow, m.BlockedNumByIsolation, m.BlockedNumByCircuitBreaking,
-		m.BlockedNumBySystem, m.BlockedNumByHotspotParam, m.BlockedNumByMock)
+		m.BlockedNumBySystem, m.BlockedNumByHotspotParam, m.BlockedNumByMock,
+		m.P50Rt, m.P90Rt, m.P99Rt)
 	if err != nil {
 		return "", err
 	}
@@ -175,5 +182,20 @@ func MetricItemFromFatString(line string) (*MetricItem, error) {
 		}
 		item.BlockedNumByMock = blockedNumByMock
 	}
+	if len(arr) >= 20 {
+		p50Rt, err := strconv.ParseUint(arr[17], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		p90Rt, err := strconv.ParseUint(arr[18], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		p99Rt, err := strconv.ParseUint(arr[19], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		item.P50Rt, item.P90Rt, item.P99Rt = p50Rt, p90Rt, p99Rt
+	}
 	return item, nil
 }
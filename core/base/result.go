@@ -19,6 +19,7 @@ const (
 	BlockTypeMockRequest    // mock请求，代表请求替换
 	BlockTypeMockCtxTimeout // mock请求，修改ctx超时时间
 	BlockTypeGray           // 灰度错误
+	BlockTypeAuthority      // 授权（黑白名单）校验不通过
 )
 
 var (
@@ -32,6 +33,7 @@ var (
 		BlockTypeMock:             "BlockTypeMock",
 		BlockTypeMockError:        "BlockTypeMockError",
 		BlockTypeMockRequest:      "BlockTypeMockRequest",
+		BlockTypeAuthority:        "BlockTypeAuthority",
 	}
 	blockTypeExisted = fmt.Errorf("block type existed")
 )
@@ -68,6 +68,8 @@ type ReadStat interface {
 
 	MinRT() float64
 	AvgRT() float64
+	// PercentileRT 返回统计窗口内percentile(取值范围(0, 100])分位的RT，如99表示P99
+	PercentileRT(percentile float64) float64
 }
 
 func NopReadStat() *nopReadStat {
@@ -97,6 +99,10 @@ func (n nopReadStat) AvgRT() float64 {
 	return 0.0
 }
 
+func (n nopReadStat) PercentileRT(_ float64) float64 {
+	return 0.0
+}
+
 func (n nopReadStat) GetSumWithTime(_ uint64, _ MetricEvent) int64 {
 	return 0
 }
@@ -35,3 +35,42 @@ type TokenService interface {
 	// @param tokenId 全局唯一tokenId
 	ReleaseConcurrentToken(rule string, tokenId string)
 }
+
+// CircuitBreakerTokenService 集群熔断服务，由支持集群熔断的TokenService可选实现，
+// 集群模式的熔断规则通过该接口在实例间共享熔断状态及错误统计
+type CircuitBreakerTokenService interface {
+
+	// SyncCircuitBreaker 上报本地的请求数及错误数，返回集群熔断状态，
+	// 关闭状态下集群统计达到阈值时切换为打开状态
+	//
+	// @param rule 规则信息
+	// @param totalCount 上次同步后完成的请求数
+	// @param errorCount 上次同步后的错误(慢调用)数
+	// @return 集群熔断状态
+	//
+	SyncCircuitBreaker(rule string, totalCount, errorCount uint64) (*CircuitBreakerStatus, error)
+
+	// AcquireCircuitBreakerProbe 打开状态到达重试时间后竞选探测者，集群内只有一个实例能够成功，
+	// 成功后集群状态切换为半开，探测者超过探测超时时间未上报结果时重新竞选
+	//
+	// @param rule 规则信息
+	// @return 是否当选为探测者
+	//
+	AcquireCircuitBreakerProbe(rule string) (bool, error)
+
+	// ReportCircuitBreakerProbe 探测者上报探测结果，探测成功数达到ProbeNum时关闭熔断器，失败时重新打开
+	//
+	// @param rule 规则信息
+	// @param success 探测是否成功
+	// @return 集群熔断状态
+	//
+	ReportCircuitBreakerProbe(rule string, success bool) (*CircuitBreakerStatus, error)
+}
+
+// CircuitBreakerStatus 集群熔断状态
+type CircuitBreakerStatus struct {
+	// State 熔断状态，取值与circuitbreaker.State一致：0-Closed，1-HalfOpen，2-Open
+	State int32 `json:"state"`
+	// NextRetryTimestampMs 打开状态下允许探测的时间
+	NextRetryTimestampMs uint64 `json:"nextRetryTimestampMs"`
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/liuhailove/gmiter/logging"
 	"github.com/liuhailove/gmiter/util"
 	"github.com/pkg/errors"
+	"math"
+	"math/rand"
 	"reflect"
 	"sync/atomic"
 )
@@ -128,6 +130,10 @@ type circuitBreakerBase struct {
 	curProbeNumber uint64
 	// state is the state machine of circuit breaker
 	state *State
+	// backoffLevel 半开探测连续失败的次数，用于计算指数退避的熔断时长
+	backoffLevel uint32
+	// closedTimestampMs 最近一次由半开恢复为关闭的时间
+	closedTimestampMs uint64
 }
 
 func (b *circuitBreakerBase) BoundRule() *Rule {
@@ -143,7 +149,61 @@ func (b *circuitBreakerBase) retryTimeoutArrived() bool {
 }
 
 func (b *circuitBreakerBase) updateNextRetryTimestamp() {
-	atomic.StoreUint64(&b.nextRetryTimestampMs, util.CurrentTimeMillis()+uint64(b.retryTimeoutMs))
+	atomic.StoreUint64(&b.nextRetryTimestampMs, util.CurrentTimeMillis()+b.currentRetryTimeoutMs())
+}
+
+// currentRetryTimeoutMs 返回本次熔断的时长，开启指数退避时为retryTimeoutMs*multiplier^backoffLevel，且不超过MaxRetryTimeoutMs
+func (b *circuitBreakerBase) currentRetryTimeoutMs() uint64 {
+	timeout := uint64(b.retryTimeoutMs)
+	if b.rule == nil || b.rule.MaxRetryTimeoutMs <= b.retryTimeoutMs {
+		return timeout
+	}
+	maxTimeout := uint64(b.rule.MaxRetryTimeoutMs)
+	multiplier := b.rule.RetryTimeoutBackoffMultiplier
+	if multiplier <= 1.0 {
+		multiplier = DefaultRetryTimeoutBackoffMultiplier
+	}
+	backoff := float64(timeout) * math.Pow(multiplier, float64(atomic.LoadUint32(&b.backoffLevel)))
+	if backoff >= float64(maxTimeout) {
+		return maxTimeout
+	}
+	return uint64(backoff)
+}
+
+// resetBackoffIfHealthy 恢复后持续健康超过BackoffResetMs时重置退避
+func (b *circuitBreakerBase) resetBackoffIfHealthy() {
+	if b.rule == nil || atomic.LoadUint32(&b.backoffLevel) == 0 {
+		return
+	}
+	resetMs := uint64(b.rule.BackoffResetMs)
+	if resetMs == 0 {
+		resetMs = uint64(b.rule.MaxRetryTimeoutMs)
+	}
+	if util.CurrentTimeMillis() >= atomic.LoadUint64(&b.closedTimestampMs)+resetMs {
+		atomic.StoreUint32(&b.backoffLevel, 0)
+	}
+}
+
+// slowStartPass 关闭状态下的慢启动检查，恢复后SlowStartWindowMs内放行比例由SlowStartMinRatio线性增长到100%
+func (b *circuitBreakerBase) slowStartPass() bool {
+	if b.rule == nil || b.rule.SlowStartWindowMs == 0 {
+		return true
+	}
+	closedTimestampMs := atomic.LoadUint64(&b.closedTimestampMs)
+	if closedTimestampMs == 0 {
+		return true
+	}
+	curMs := util.CurrentTimeMillis()
+	windowMs := uint64(b.rule.SlowStartWindowMs)
+	if curMs >= closedTimestampMs+windowMs || curMs < closedTimestampMs {
+		return true
+	}
+	minRatio := b.rule.SlowStartMinRatio
+	if minRatio <= 0 {
+		minRatio = DefaultSlowStartMinRatio
+	}
+	ratio := minRatio + (1.0-minRatio)*float64(curMs-closedTimestampMs)/float64(windowMs)
+	return rand.Float64() < ratio
 }
 
 func (b *circuitBreakerBase) addCurProbeNum() {
@@ -158,6 +218,7 @@ func (b *circuitBreakerBase) resetCurProbeNum() {
 // Return true only if current goroutine successfully accomplished the transformation.
 func (b *circuitBreakerBase) fromClosedToOpen(snapshot interface{}) bool {
 	if b.state.cas(Closed, Open) {
+		b.resetBackoffIfHealthy()
 		b.updateNextRetryTimestamp()
 		for _, listener := range stateChangeListeners {
 			listener.OnTransformToOpen(Closed, *b.rule, snapshot)
@@ -207,6 +268,8 @@ func (b *circuitBreakerBase) fromOpenToHalfOpen(ctx *base.EntryContext) bool {
 func (b *circuitBreakerBase) fromHalfOpenToOpen(snapshot interface{}) bool {
 	if b.state.cas(HalfOpen, Open) {
 		b.resetCurProbeNum()
+		// 半开探测失败，熔断时长指数增长
+		atomic.AddUint32(&b.backoffLevel, 1)
 		b.updateNextRetryTimestamp()
 		for _, listener := range stateChangeListeners {
 			listener.OnTransformToOpen(HalfOpen, *b.rule, snapshot)
@@ -224,6 +287,7 @@ func (b *circuitBreakerBase) fromHalfOpenToOpen(snapshot interface{}) bool {
 func (b *circuitBreakerBase) fromHalfOpenToClosed() bool {
 	if b.state.cas(HalfOpen, Closed) {
 		b.resetCurProbeNum()
+		atomic.StoreUint64(&b.closedTimestampMs, util.CurrentTimeMillis())
 		for _, listener := range stateChangeListeners {
 			listener.OnTransformToClosed(HalfOpen, *b.rule)
 		}
@@ -280,7 +344,7 @@ func (b *slowRtCircuitBreaker) BoundStat() interface{} {
 func (b *slowRtCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
 	curStatus := b.CurrentState()
 	if curStatus == Closed {
-		return true
+		return b.slowStartPass()
 	}
 	if curStatus == Open {
 		// switch state to half-open to probe if retry timeout
@@ -468,7 +532,7 @@ func (b *errorRatioCircuitBreaker) BoundStat() interface{} {
 func (b *errorRatioCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
 	curStatus := b.CurrentState()
 	if curStatus == Closed {
-		return true
+		return b.slowStartPass()
 	}
 	if curStatus == Open {
 		// switch state to half-open to probe if retry timeout
@@ -653,7 +717,7 @@ func (b *errorCountCircuitBreaker) BoundStat() interface{} {
 func (b *errorCountCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
 	curStatus := b.CurrentState()
 	if curStatus == Closed {
-		return true
+		return b.slowStartPass()
 	}
 	if curStatus == Open {
 		// switch state to half-open to probe if retry timeout
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type CircuitBreakerMock struct {
@@ -320,3 +321,189 @@ func TestErrorCountCircuitBreaker_OnRequestComplete(t *testing.T) {
 		assert.True(t, b.curProbeNumber == 0)
 	})
 }
+
+func newProbeEntryContext(res string) *base.EntryContext {
+	ctx := base.NewEmptyEntryContext()
+	ctx.Resource = base.NewResourceWrapper(res, base.ResTypeCommon, base.Inbound)
+	ctx.SetEntry(base.NewSeaEntry(ctx, ctx.Resource, nil))
+	return ctx
+}
+
+func TestRetryTimeoutBackoff(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	r := &Rule{
+		Resource:          "abc",
+		Strategy:          ErrorCount,
+		RetryTimeoutMs:    1000,
+		MinRequestAmount:  1,
+		StatIntervalMs:    1000,
+		Threshold:         1,
+		ProbeNum:          1,
+		MaxRetryTimeoutMs: 5000,
+		BackoffResetMs:    10000,
+	}
+	assert.Nil(t, IsValidRule(r))
+	b, err := newErrorCountCircuitBreaker(r)
+	assert.Nil(t, err)
+	ctx := newProbeEntryContext("abc")
+	openDuration := func() uint64 {
+		return atomic.LoadUint64(&b.nextRetryTimestampMs) - util.CurrentTimeMillis()
+	}
+
+	b.OnRequestComplete(0, errors.New("biz error"))
+	assert.Equal(t, Open, b.CurrentState())
+	assert.Equal(t, uint64(1000), openDuration())
+
+	// 半开探测连续失败，熔断时长指数增长直到上限
+	for _, expected := range []uint64{2000, 4000, 5000, 5000} {
+		util.Sleep(time.Duration(openDuration()-1) * time.Millisecond)
+		assert.False(t, b.TryPass(ctx))
+		util.Sleep(time.Millisecond)
+		assert.True(t, b.TryPass(ctx))
+		assert.Equal(t, HalfOpen, b.CurrentState())
+		b.OnRequestComplete(0, errors.New("biz error"))
+		assert.Equal(t, Open, b.CurrentState())
+		assert.Equal(t, expected, openDuration())
+	}
+
+	// 探测成功后恢复，短时间内再次熔断不会重置退避
+	util.Sleep(5 * time.Second)
+	assert.True(t, b.TryPass(ctx))
+	b.OnRequestComplete(0, nil)
+	assert.Equal(t, Closed, b.CurrentState())
+	b.OnRequestComplete(0, errors.New("biz error"))
+	assert.Equal(t, Open, b.CurrentState())
+	assert.Equal(t, uint64(5000), openDuration())
+
+	// 持续健康超过BackoffResetMs后重置退避
+	util.Sleep(5 * time.Second)
+	assert.True(t, b.TryPass(ctx))
+	b.OnRequestComplete(0, nil)
+	util.Sleep(10 * time.Second)
+	b.OnRequestComplete(0, errors.New("biz error"))
+	assert.Equal(t, Open, b.CurrentState())
+	assert.Equal(t, uint64(1000), openDuration())
+}
+
+func TestSlowStartAfterRecovery(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	r := &Rule{
+		Resource:          "abc",
+		Strategy:          ErrorCount,
+		RetryTimeoutMs:    1000,
+		MinRequestAmount:  1,
+		StatIntervalMs:    1000,
+		Threshold:         1,
+		ProbeNum:          1,
+		SlowStartWindowMs: 1000,
+		SlowStartMinRatio: 0.2,
+	}
+	assert.Nil(t, IsValidRule(r))
+	b, err := newErrorCountCircuitBreaker(r)
+	assert.Nil(t, err)
+	ctx := newProbeEntryContext("abc")
+	passRatio := func() float64 {
+		passed := 0
+		for i := 0; i < 4000; i++ {
+			if b.TryPass(ctx) {
+				passed++
+			}
+		}
+		return float64(passed) / 4000
+	}
+
+	// 未经历熔断时不限制
+	assert.Equal(t, 1.0, passRatio())
+
+	b.OnRequestComplete(0, errors.New("biz error"))
+	util.Sleep(time.Second)
+	assert.True(t, b.TryPass(ctx))
+	b.OnRequestComplete(0, nil)
+	assert.Equal(t, Closed, b.CurrentState())
+
+	// 放行比例由SlowStartMinRatio线性增长到100%
+	assert.InDelta(t, 0.2, passRatio(), 0.05)
+	util.Sleep(500 * time.Millisecond)
+	assert.InDelta(t, 0.6, passRatio(), 0.05)
+	util.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 1.0, passRatio())
+}
+
+func TestIsValidRuleOfBackoff(t *testing.T) {
+	r := &Rule{
+		Resource:       "abc",
+		Strategy:       ErrorCount,
+		RetryTimeoutMs: 1000,
+		StatIntervalMs: 1000,
+		Threshold:      1,
+	}
+	r.RetryTimeoutBackoffMultiplier = -1
+	assert.NotNil(t, IsValidRule(r))
+	r.RetryTimeoutBackoffMultiplier = 0
+	r.SlowStartMinRatio = 1.5
+	assert.NotNil(t, IsValidRule(r))
+}
+
+func TestSlowRtPercentileCircuitBreaker(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	r := &Rule{
+		Resource:         "abc",
+		Strategy:         SlowRequestPercentile,
+		RetryTimeoutMs:   1000,
+		MinRequestAmount: 10,
+		StatIntervalMs:   10000,
+		Threshold:        100,
+		Percentile:       99,
+		ProbeNum:         1,
+	}
+	assert.Nil(t, IsValidRule(r))
+	cb, err := cbGenFuncMap[SlowRequestPercentile](r, nil)
+	assert.Nil(t, err)
+	b := cb.(*slowRtPercentileCircuitBreaker)
+	ctx := newProbeEntryContext("abc")
+
+	// 平均RT很低但P99超过阈值时熔断
+	for i := 0; i < 99; i++ {
+		b.OnRequestComplete(10, nil)
+	}
+	b.OnRequestComplete(500, nil)
+	assert.Equal(t, Closed, b.CurrentState())
+	b.OnRequestComplete(500, nil)
+	assert.Equal(t, Open, b.CurrentState())
+	assert.False(t, b.TryPass(ctx))
+
+	// 探测请求RT超过阈值时重新熔断
+	util.Sleep(time.Second)
+	assert.True(t, b.TryPass(ctx))
+	b.OnRequestComplete(200, nil)
+	assert.Equal(t, Open, b.CurrentState())
+
+	// 探测成功后恢复并清空统计
+	util.Sleep(time.Second)
+	assert.True(t, b.TryPass(ctx))
+	b.OnRequestComplete(50, nil)
+	assert.Equal(t, Closed, b.CurrentState())
+	for i := 0; i < 20; i++ {
+		b.OnRequestComplete(80, nil)
+	}
+	assert.Equal(t, Closed, b.CurrentState())
+
+	r.Percentile = 0
+	assert.NotNil(t, IsValidRule(r))
+}
+
+// 桶过期时原地清空直方图
+func TestRtHistogramLeapArray_ResetBucketTo(t *testing.T) {
+	stat := &rtHistogramLeapArray{}
+	bw := &sbase.BucketWrap{BucketStart: 1000}
+	bw.Value.Store(stat.NewEmptyBucket())
+	histogram := bw.Value.Load().(*sbase.RtHistogram)
+	histogram.Add(10)
+	assert.Equal(t, int64(1), histogram.Count())
+
+	stat.ResetBucketTo(bw, 2000)
+	assert.Equal(t, uint64(2000), bw.BucketStart)
+	assert.Same(t, histogram, bw.Value.Load())
+	assert.Equal(t, int64(0), histogram.Count())
+	assert.Equal(t, 0.0, sbase.PercentileRtOf([]*sbase.RtHistogram{histogram}, 99))
+}
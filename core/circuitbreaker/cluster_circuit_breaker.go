@@ -0,0 +1,262 @@
+package circuitbreaker
+
+import (
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/spi"
+	"github.com/liuhailove/gmiter/util"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// circuitBreakerTokenService 获取支持集群熔断的RedisTokenService，未注册或者未实现时返回nil
+func circuitBreakerTokenService() base.CircuitBreakerTokenService {
+	var inst = spi.GetRegisterTokenServiceInst(constants.RedisTokenServiceType)
+	if inst == nil {
+		return nil
+	}
+	var tokenService, _ = inst.GetTokenService().(base.CircuitBreakerTokenService)
+	return tokenService
+}
+
+// ================================= clusterCircuitBreaker ====================================
+// clusterCircuitBreaker 集群熔断器，熔断状态以TokenService中共享的集群状态为准：
+// 本地完成的请求数及错误数按照SyncIntervalMs批量上报，同时同步集群状态；
+// 打开状态到达重试时间后各实例竞选探测者，半开状态下只有探测者放行请求并上报探测结果。
+// TokenService不可用时回退到本地熔断器，本地熔断器始终记录统计，保证回退后立即可用
+type clusterCircuitBreaker struct {
+	// local 本地熔断器
+	local          CircuitBreaker
+	rule           *Rule
+	ruleJson       string
+	syncIntervalMs uint64
+
+	// state 最近一次同步的集群熔断状态
+	state                *State
+	nextRetryTimestampMs uint64
+	// available TokenService是否可用，不可用时使用本地熔断器
+	available util.AtomicBool
+	// degraded 是否曾因TokenService不可用而回退到本地熔断器
+	degraded util.AtomicBool
+	// prober 当前实例是否为半开状态的探测者
+	prober     util.AtomicBool
+	syncing    util.AtomicBool
+	lastSyncMs uint64
+	// pendingTotal、pendingError 未上报的请求数及错误数
+	pendingTotal uint64
+	pendingError uint64
+}
+
+func newClusterCircuitBreaker(r *Rule, local CircuitBreaker) *clusterCircuitBreaker {
+	var syncIntervalMs uint64 = DefaultClusterSyncIntervalMs
+	if r.ClusterConfig != nil && r.ClusterConfig.SyncIntervalMs > 0 {
+		syncIntervalMs = uint64(r.ClusterConfig.SyncIntervalMs)
+	}
+	data, err := jsonTraffic.Marshal(r)
+	if err != nil {
+		logging.Error(err, "Fail to marshal circuit breaking rule in newClusterCircuitBreaker()", "rule", r)
+	}
+	return &clusterCircuitBreaker{
+		local:          local,
+		rule:           r,
+		ruleJson:       string(data),
+		syncIntervalMs: syncIntervalMs,
+		state:          newState(),
+	}
+}
+
+func (b *clusterCircuitBreaker) BoundRule() *Rule {
+	return b.rule
+}
+
+func (b *clusterCircuitBreaker) BoundStat() interface{} {
+	return b.local.BoundStat()
+}
+
+func (b *clusterCircuitBreaker) CurrentState() State {
+	if !b.available.Get() {
+		return b.local.CurrentState()
+	}
+	return b.state.get()
+}
+
+func (b *clusterCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
+	var tokenService = b.tokenService()
+	if tokenService == nil {
+		return b.local.TryPass(ctx)
+	}
+	b.syncIfNeeded(tokenService)
+	if !b.available.Get() {
+		return b.local.TryPass(ctx)
+	}
+	switch b.state.get() {
+	case Closed:
+		return true
+	case HalfOpen:
+		return b.prober.Get()
+	default:
+		if util.CurrentTimeMillis() < atomic.LoadUint64(&b.nextRetryTimestampMs) {
+			return false
+		}
+		elected, err := tokenService.AcquireCircuitBreakerProbe(b.ruleJson)
+		if err != nil {
+			b.markUnavailable(err)
+			return b.local.TryPass(ctx)
+		}
+		if !elected {
+			// 其他实例正在探测，下次同步前不再竞选
+			b.updateState(HalfOpen, atomic.LoadUint64(&b.nextRetryTimestampMs), nil)
+			return false
+		}
+		b.prober.Set(true)
+		b.updateState(HalfOpen, atomic.LoadUint64(&b.nextRetryTimestampMs), nil)
+		b.reportProbeWhenBlocked(ctx, tokenService)
+		return true
+	}
+}
+
+// reportProbeWhenBlocked 探测请求被后续的规则拦截时上报探测失败，避免集群长时间停留在半开状态
+func (b *clusterCircuitBreaker) reportProbeWhenBlocked(ctx *base.EntryContext, tokenService base.CircuitBreakerTokenService) {
+	entry := ctx.Entry()
+	if entry == nil {
+		return
+	}
+	entry.WhenExit(func(entry *base.SeaEntry, ctx *base.EntryContext) error {
+		if ctx.IsBlocked() && b.prober.Get() {
+			b.reportProbe(tokenService, false)
+		}
+		return nil
+	})
+}
+
+func (b *clusterCircuitBreaker) OnRequestComplete(rt uint64, err error) {
+	// 本地熔断器始终记录统计，TokenService不可用时直接使用
+	b.local.OnRequestComplete(rt, err)
+
+	var tokenService = b.tokenService()
+	if tokenService == nil {
+		return
+	}
+	if !b.available.Get() {
+		// 不可用期间的统计只记录在本地熔断器中
+		b.syncIfNeeded(tokenService)
+		return
+	}
+	failed := b.isFailure(rt, err)
+	if b.prober.Get() {
+		b.reportProbe(tokenService, !failed)
+		return
+	}
+	atomic.AddUint64(&b.pendingTotal, 1)
+	if failed {
+		atomic.AddUint64(&b.pendingError, 1)
+	}
+	b.syncIfNeeded(tokenService)
+}
+
+func (b *clusterCircuitBreaker) tokenService() base.CircuitBreakerTokenService {
+	if len(b.ruleJson) == 0 {
+		return nil
+	}
+	return circuitBreakerTokenService()
+}
+
+// isFailure 判断请求是否计入错误数，慢调用比例策略下为慢调用，其他策略下为出错的请求
+func (b *clusterCircuitBreaker) isFailure(rt uint64, err error) bool {
+	if b.rule.Strategy == SlowRequestRatio {
+		return rt > b.rule.MaxAllowedRtMs
+	}
+	return err != nil
+}
+
+// syncIfNeeded 距离上次同步超过SyncIntervalMs时上报统计并同步集群状态，同一时刻只有一个goroutine执行同步。
+// TokenService不可用时同步同时作为探活，恢复后重新使用集群状态
+func (b *clusterCircuitBreaker) syncIfNeeded(tokenService base.CircuitBreakerTokenService) {
+	curMs := util.CurrentTimeMillis()
+	if curMs < atomic.LoadUint64(&b.lastSyncMs)+b.syncIntervalMs {
+		return
+	}
+	if !b.syncing.CompareAndSet(false, true) {
+		return
+	}
+	defer b.syncing.Set(false)
+	atomic.StoreUint64(&b.lastSyncMs, curMs)
+
+	totalCount := atomic.SwapUint64(&b.pendingTotal, 0)
+	errorCount := atomic.SwapUint64(&b.pendingError, 0)
+	status, err := tokenService.SyncCircuitBreaker(b.ruleJson, totalCount, errorCount)
+	if err != nil {
+		// 未上报的统计已经记录在本地熔断器中，此处直接丢弃
+		b.markUnavailable(err)
+		return
+	}
+	b.available.Set(true)
+	if b.degraded.CompareAndSet(true, false) {
+		logging.Info("[CircuitBreaker] Cluster circuit breaker token service recovered", "rule", b.rule)
+	}
+	b.applyStatus(status, nil)
+}
+
+// reportProbe 上报探测结果，探测结束(集群状态不再是半开)后放弃探测者身份
+func (b *clusterCircuitBreaker) reportProbe(tokenService base.CircuitBreakerTokenService, success bool) {
+	status, err := tokenService.ReportCircuitBreakerProbe(b.ruleJson, success)
+	if err != nil {
+		b.prober.Set(false)
+		b.markUnavailable(err)
+		return
+	}
+	var snapshot interface{}
+	if !success {
+		snapshot = 1.0
+	}
+	b.applyStatus(status, snapshot)
+}
+
+func (b *clusterCircuitBreaker) applyStatus(status *base.CircuitBreakerStatus, snapshot interface{}) {
+	if status == nil {
+		return
+	}
+	if State(status.State) != HalfOpen {
+		b.prober.Set(false)
+	}
+	b.updateState(State(status.State), status.NextRetryTimestampMs, snapshot)
+}
+
+func (b *clusterCircuitBreaker) markUnavailable(err error) {
+	b.available.Set(false)
+	if b.degraded.CompareAndSet(false, true) {
+		logging.Warn("[CircuitBreaker] Cluster circuit breaker token service is unavailable, fallback to local circuit breaker",
+			"rule", b.rule, "err", err.Error())
+	}
+	// 集群状态作废，恢复后重新同步
+	b.prober.Set(false)
+}
+
+// updateState 更新集群熔断状态，状态变化时通知监听器
+func (b *clusterCircuitBreaker) updateState(update State, nextRetryTimestampMs uint64, snapshot interface{}) {
+	atomic.StoreUint64(&b.nextRetryTimestampMs, nextRetryTimestampMs)
+	prev := b.state.get()
+	if prev == update || !b.state.cas(prev, update) {
+		return
+	}
+	for _, listener := range stateChangeListeners {
+		switch update {
+		case Closed:
+			listener.OnTransformToClosed(prev, *b.rule)
+		case HalfOpen:
+			listener.OnTransformToHalfOpen(prev, *b.rule)
+		case Open:
+			listener.OnTransformToOpen(prev, *b.rule, snapshot)
+		}
+	}
+	if stateChangedCounter != nil {
+		stateChangedCounter.Add(float64(1), b.rule.Resource, prev.String(), update.String())
+	}
+}
@@ -0,0 +1,214 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/spi"
+	"github.com/liuhailove/gmiter/util"
+)
+
+// fakeClusterTokenService 内存中模拟的集群熔断状态，错误数达到1时打开，探测成功一次后关闭
+type fakeClusterTokenService struct {
+	base.TokenService
+	mux        sync.Mutex
+	status     base.CircuitBreakerStatus
+	errorCount uint64
+	err        error
+}
+
+func (f *fakeClusterTokenService) reset() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.status = base.CircuitBreakerStatus{}
+	f.errorCount = 0
+	f.err = nil
+}
+
+func (f *fakeClusterTokenService) setErr(err error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.err = err
+}
+
+func (f *fakeClusterTokenService) SyncCircuitBreaker(rule string, totalCount, errorCount uint64) (*base.CircuitBreakerStatus, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.errorCount += errorCount
+	if State(f.status.State) == Closed && f.errorCount >= 1 {
+		f.status = base.CircuitBreakerStatus{State: int32(Open), NextRetryTimestampMs: util.CurrentTimeMillis() + 1000}
+	}
+	status := f.status
+	return &status, nil
+}
+
+func (f *fakeClusterTokenService) AcquireCircuitBreakerProbe(rule string) (bool, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	if State(f.status.State) != Open || util.CurrentTimeMillis() < f.status.NextRetryTimestampMs {
+		return false, nil
+	}
+	f.status.State = int32(HalfOpen)
+	return true, nil
+}
+
+func (f *fakeClusterTokenService) ReportCircuitBreakerProbe(rule string, success bool) (*base.CircuitBreakerStatus, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if success {
+		f.status = base.CircuitBreakerStatus{State: int32(Closed)}
+		f.errorCount = 0
+	} else {
+		f.status = base.CircuitBreakerStatus{State: int32(Open), NextRetryTimestampMs: util.CurrentTimeMillis() + 1000}
+	}
+	status := f.status
+	return &status, nil
+}
+
+type fakeClusterTokenServiceInitFunc struct {
+	tokenService *fakeClusterTokenService
+}
+
+func (f *fakeClusterTokenServiceInitFunc) Initial() error             { return nil }
+func (f *fakeClusterTokenServiceInitFunc) Order() int                 { return 1000 }
+func (f *fakeClusterTokenServiceInitFunc) ImmediatelyLoadOnce() error { return nil }
+func (f *fakeClusterTokenServiceInitFunc) GetRegisterType() constants.RegisterType {
+	return constants.RedisTokenServiceType
+}
+func (f *fakeClusterTokenServiceInitFunc) GetTokenService() base.TokenService { return f.tokenService }
+func (f *fakeClusterTokenServiceInitFunc) ReInitial() error                   { return nil }
+
+var clusterTokenService = &fakeClusterTokenService{}
+
+func newTestClusterCircuitBreakers(t *testing.T, n int) []*clusterCircuitBreaker {
+	spi.Register(&fakeClusterTokenServiceInitFunc{tokenService: clusterTokenService})
+	clusterTokenService.reset()
+	r := &Rule{
+		Id:               "cluster-cb",
+		Resource:         "abc",
+		Strategy:         ErrorCount,
+		RetryTimeoutMs:   1000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   1000,
+		Threshold:        1,
+		ClusterMode:      true,
+	}
+	assert.Nil(t, IsValidRule(r))
+	cbs := make([]*clusterCircuitBreaker, 0, n)
+	for i := 0; i < n; i++ {
+		local, err := newErrorCountCircuitBreaker(r)
+		assert.Nil(t, err)
+		cbs = append(cbs, newClusterCircuitBreaker(r, local))
+	}
+	return cbs
+}
+
+func TestClusterCircuitBreaker_SharedState(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	cbs := newTestClusterCircuitBreakers(t, 2)
+	a, b := cbs[0], cbs[1]
+
+	assert.True(t, a.TryPass(newProbeEntryContext("abc")))
+	assert.True(t, b.TryPass(newProbeEntryContext("abc")))
+	a.OnRequestComplete(1, errors.New("biz error"))
+
+	// 实例a上报的错误打开集群熔断器，实例b同步后同样被熔断
+	util.Sleep(100 * time.Millisecond)
+	assert.False(t, a.TryPass(newProbeEntryContext("abc")))
+	assert.False(t, b.TryPass(newProbeEntryContext("abc")))
+	assert.Equal(t, Open, a.CurrentState())
+	assert.Equal(t, Open, b.CurrentState())
+	// 实例b本地没有错误，本地熔断器仍为关闭状态
+	assert.Equal(t, Closed, b.local.CurrentState())
+
+	// 到达重试时间后只有一个实例当选为探测者
+	util.Sleep(time.Second)
+	assert.True(t, a.TryPass(newProbeEntryContext("abc")))
+	assert.False(t, b.TryPass(newProbeEntryContext("abc")))
+	assert.Equal(t, HalfOpen, a.CurrentState())
+	assert.Equal(t, HalfOpen, b.CurrentState())
+
+	// 探测成功后集群熔断器关闭
+	a.OnRequestComplete(1, nil)
+	assert.Equal(t, Closed, a.CurrentState())
+	assert.True(t, a.TryPass(newProbeEntryContext("abc")))
+	util.Sleep(100 * time.Millisecond)
+	assert.True(t, b.TryPass(newProbeEntryContext("abc")))
+	assert.Equal(t, Closed, b.CurrentState())
+}
+
+func TestClusterCircuitBreaker_ProbeFailed(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	cbs := newTestClusterCircuitBreakers(t, 1)
+	cb := cbs[0]
+
+	assert.True(t, cb.TryPass(newProbeEntryContext("abc")))
+	cb.OnRequestComplete(1, errors.New("biz error"))
+	util.Sleep(100 * time.Millisecond)
+	assert.False(t, cb.TryPass(newProbeEntryContext("abc")))
+
+	util.Sleep(time.Second)
+	assert.True(t, cb.TryPass(newProbeEntryContext("abc")))
+	cb.OnRequestComplete(1, errors.New("biz error"))
+	assert.Equal(t, Open, cb.CurrentState())
+	assert.False(t, cb.prober.Get())
+	assert.False(t, cb.TryPass(newProbeEntryContext("abc")))
+}
+
+func TestClusterCircuitBreaker_FallbackToLocal(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	cbs := newTestClusterCircuitBreakers(t, 1)
+	cb := cbs[0]
+
+	assert.True(t, cb.TryPass(newProbeEntryContext("abc")))
+	assert.True(t, cb.available.Get())
+
+	// TokenService不可用时使用本地熔断器
+	clusterTokenService.setErr(errors.New("connection refused"))
+	util.Sleep(100 * time.Millisecond)
+	assert.True(t, cb.TryPass(newProbeEntryContext("abc")))
+	assert.False(t, cb.available.Get())
+	cb.OnRequestComplete(1, errors.New("biz error"))
+	assert.Equal(t, Open, cb.CurrentState())
+	assert.False(t, cb.TryPass(newProbeEntryContext("abc")))
+
+	// TokenService恢复后重新使用集群状态，本地统计的错误不会补报
+	clusterTokenService.setErr(nil)
+	util.Sleep(100 * time.Millisecond)
+	assert.True(t, cb.TryPass(newProbeEntryContext("abc")))
+	assert.True(t, cb.available.Get())
+	assert.Equal(t, Closed, cb.CurrentState())
+}
+
+func TestIsValidRuleOfClusterMode(t *testing.T) {
+	r := &Rule{
+		Resource:         "abc",
+		Strategy:         ErrorCount,
+		RetryTimeoutMs:   1000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   1000,
+		Threshold:        1,
+		ClusterMode:      true,
+	}
+	assert.NotNil(t, IsValidRule(r))
+	r.Id = "cluster-cb"
+	assert.Nil(t, IsValidRule(r))
+	r.Strategy = SlowRequestPercentile
+	r.Percentile = 99
+	assert.NotNil(t, IsValidRule(r))
+}
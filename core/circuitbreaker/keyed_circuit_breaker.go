@@ -0,0 +1,186 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/hotspot"
+	"github.com/liuhailove/gmiter/core/hotspot/cache"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+// keyedCircuitBreakerKey EntryContext.Data 中保存本次请求命中的键，请求完成时据此找到键对应的熔断器
+type keyedCircuitBreakerKey struct {
+	breaker *keyedCircuitBreaker
+}
+
+// KeyState 按键熔断器中某个键的熔断状态
+type KeyState struct {
+	Resource string `json:"resource"`
+	RuleId   string `json:"ruleId"`
+	KeyMode  string `json:"keyMode"`
+	Key      string `json:"key"`
+	State    string `json:"state"`
+}
+
+// ================================= keyedCircuitBreaker ====================================
+// keyedCircuitBreaker 按键熔断器，每个键(参数值或者目标地址)拥有一个由规则策略生成的独立熔断器，
+// 熔断器保存在容量为KeysMaxCapacity的LRU中，淘汰的键重新访问时从关闭状态开始统计
+type keyedCircuitBreaker struct {
+	rule      *Rule
+	generator CircuitBreakerGenFunc
+
+	mux sync.Mutex
+	// breakers 键到熔断器的映射
+	breakers *cache.LRU
+}
+
+func newKeyedCircuitBreaker(r *Rule, generator CircuitBreakerGenFunc) (*keyedCircuitBreaker, error) {
+	capacity := r.KeysMaxCapacity
+	if capacity <= 0 {
+		capacity = DefaultKeysMaxCapacity
+	}
+	breakers, err := cache.NewLRU(capacity, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &keyedCircuitBreaker{
+		rule:      r,
+		generator: generator,
+		breakers:  breakers,
+	}, nil
+}
+
+func (b *keyedCircuitBreaker) BoundRule() *Rule {
+	return b.rule
+}
+
+// BoundStat 每个键的统计相互独立，不支持复用
+func (b *keyedCircuitBreaker) BoundStat() interface{} {
+	return nil
+}
+
+// CurrentState 资源整体不会被熔断，始终返回Closed，各个键的状态通过keyStates获取
+func (b *keyedCircuitBreaker) CurrentState() State {
+	return Closed
+}
+
+func (b *keyedCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
+	key := b.extractKey(ctx)
+	if key == nil {
+		return true
+	}
+	cb := b.breakerOf(key)
+	if cb == nil {
+		return true
+	}
+	if ctx.Data == nil {
+		ctx.Data = make(map[interface{}]interface{})
+	}
+	ctx.Data[keyedCircuitBreakerKey{breaker: b}] = key
+	return cb.TryPass(ctx)
+}
+
+// OnRequestComplete 无法得知请求对应的键，统计由onRequestCompleteOf完成
+func (b *keyedCircuitBreaker) OnRequestComplete(_ uint64, _ error) {
+}
+
+// onRequestCompleteOf 将请求结果记录到TryPass时命中的键对应的熔断器
+func (b *keyedCircuitBreaker) onRequestCompleteOf(ctx *base.EntryContext, rt uint64, err error) {
+	key, ok := ctx.Data[keyedCircuitBreakerKey{breaker: b}]
+	if !ok {
+		return
+	}
+	b.mux.Lock()
+	value, found := b.breakers.Peek(key)
+	b.mux.Unlock()
+	if !found {
+		return
+	}
+	value.(CircuitBreaker).OnRequestComplete(rt, err)
+}
+
+// extractKey 抽取请求的键，抽取不到或者键不可比较时返回nil
+func (b *keyedCircuitBreaker) extractKey(ctx *base.EntryContext) interface{} {
+	var key interface{}
+	switch b.rule.KeyMode {
+	case KeyByParam:
+		key = hotspot.ExtractParam(ctx, b.rule.ParamSource, b.rule.ParamIdx, b.rule.ParamKey, b.rule.ParamKind)
+	case KeyByTargetAddress:
+		if ctx.Input != nil && len(ctx.Input.TargetAddress) > 0 {
+			key = ctx.Input.TargetAddress
+		}
+	default:
+	}
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		return nil
+	}
+	return key
+}
+
+// breakerOf 获取键对应的熔断器，不存在时生成
+func (b *keyedCircuitBreaker) breakerOf(key interface{}) CircuitBreaker {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if value, ok := b.breakers.Get(key); ok {
+		return value.(CircuitBreaker)
+	}
+	cb, err := b.generator(b.rule, nil)
+	if cb == nil || err != nil {
+		logging.Warn("[CircuitBreaker keyedCircuitBreaker] Fail to generate circuit breaker of key", "rule", b.rule, "key", key, "err", err)
+		return nil
+	}
+	b.breakers.Add(key, cb)
+	return cb
+}
+
+// keyStates 返回处于打开或者半开状态的键
+func (b *keyedCircuitBreaker) keyStates() []KeyState {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	ret := make([]KeyState, 0)
+	for _, key := range b.breakers.Keys() {
+		value, ok := b.breakers.Peek(key)
+		if !ok {
+			continue
+		}
+		state := value.(CircuitBreaker).CurrentState()
+		if state == Closed {
+			continue
+		}
+		ret = append(ret, KeyState{
+			Resource: b.rule.Resource,
+			RuleId:   b.rule.Id,
+			KeyMode:  b.rule.KeyMode.String(),
+			Key:      fmt.Sprint(key),
+			State:    state.String(),
+		})
+	}
+	return ret
+}
+
+// GetOpenKeys 返回resource下按键熔断器中处于打开或者半开状态的键，resource为空时返回全部资源
+func GetOpenKeys(resource string) []KeyState {
+	updateMux.RLock()
+	resources := make([]string, 0, len(breakers))
+	for res := range breakers {
+		if len(resource) == 0 || res == resource {
+			resources = append(resources, res)
+		}
+	}
+	updateMux.RUnlock()
+	sort.Strings(resources)
+
+	ret := make([]KeyState, 0)
+	for _, res := range resources {
+		for _, cb := range getBreakersOfResource(res) {
+			if keyed, ok := cb.(*keyedCircuitBreaker); ok {
+				ret = append(ret, keyed.keyStates()...)
+			}
+		}
+	}
+	return ret
+}
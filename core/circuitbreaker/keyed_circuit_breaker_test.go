@@ -0,0 +1,139 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+func newKeyedEntryContext(res string, merchantId string, address string) *base.EntryContext {
+	ctx := base.NewSlotChain().GetPooledContext()
+	ctx.Resource = base.NewResourceWrapper(res, base.ResTypeCommon, base.Inbound)
+	ctx.SetEntry(base.NewSeaEntry(ctx, ctx.Resource, nil))
+	if len(merchantId) > 0 {
+		ctx.Input.Attachments["merchantId"] = merchantId
+	}
+	ctx.Input.TargetAddress = address
+	return ctx
+}
+
+// doKeyedRequest 模拟一次请求经过熔断检查及统计，返回是否通过
+func doKeyedRequest(ctx *base.EntryContext, err error) bool {
+	result := DefaultSlot.Check(ctx)
+	if result != nil && result.IsBlocked() {
+		return false
+	}
+	ctx.SetError(err)
+	DefaultMetricStatSlot.OnCompleted(ctx)
+	return true
+}
+
+func TestKeyedCircuitBreaker_KeyByParam(t *testing.T) {
+	defer func() { _ = ClearRules() }()
+	r := &Rule{
+		Resource:         "keyed-param",
+		Strategy:         ErrorCount,
+		RetryTimeoutMs:   10000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        1,
+		KeyMode:          KeyByParam,
+		ParamKey:         "merchantId",
+		ParamKind:        hotspot.KindString,
+	}
+	_, err := LoadRules([]*Rule{r})
+	assert.Nil(t, err)
+
+	// 商户m1出错后被熔断
+	assert.True(t, doKeyedRequest(newKeyedEntryContext("keyed-param", "m1", ""), errors.New("biz error")))
+	assert.False(t, doKeyedRequest(newKeyedEntryContext("keyed-param", "m1", ""), nil))
+	// 其他商户及没有商户ID的请求不受影响
+	assert.True(t, doKeyedRequest(newKeyedEntryContext("keyed-param", "m2", ""), nil))
+	assert.True(t, doKeyedRequest(newKeyedEntryContext("keyed-param", "", ""), errors.New("biz error")))
+	assert.True(t, doKeyedRequest(newKeyedEntryContext("keyed-param", "m2", ""), nil))
+
+	keys := GetOpenKeys("keyed-param")
+	assert.Equal(t, []KeyState{{Resource: "keyed-param", KeyMode: "KeyByParam", Key: "m1", State: "Open"}}, keys)
+	assert.Equal(t, keys, GetOpenKeys(""))
+	assert.Empty(t, GetOpenKeys("other"))
+}
+
+func TestKeyedCircuitBreaker_KeyByTargetAddress(t *testing.T) {
+	defer func() { _ = ClearRules() }()
+	r := &Rule{
+		Resource:         "keyed-address",
+		Strategy:         SlowRequestRatio,
+		RetryTimeoutMs:   10000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		MaxAllowedRtMs:   10,
+		Threshold:        0.5,
+		KeyMode:          KeyByTargetAddress,
+	}
+	_, err := LoadRules([]*Rule{r})
+	assert.Nil(t, err)
+
+	ctx := newKeyedEntryContext("keyed-address", "", "10.0.0.1:8080")
+	assert.True(t, DefaultSlot.Check(ctx).IsPass())
+	ctx.PutRt(100)
+	DefaultMetricStatSlot.OnCompleted(ctx)
+
+	assert.False(t, doKeyedRequest(newKeyedEntryContext("keyed-address", "", "10.0.0.1:8080"), nil))
+	assert.True(t, doKeyedRequest(newKeyedEntryContext("keyed-address", "", "10.0.0.2:8080"), nil))
+	assert.Equal(t, 1, len(GetOpenKeys("keyed-address")))
+}
+
+func TestKeyedCircuitBreaker_Capacity(t *testing.T) {
+	r := &Rule{
+		Resource:         "abc",
+		Strategy:         ErrorCount,
+		RetryTimeoutMs:   10000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        1,
+		KeyMode:          KeyByParam,
+		ParamKey:         "merchantId",
+		KeysMaxCapacity:  2,
+	}
+	assert.Nil(t, IsValidRule(r))
+	cb, err := newKeyedCircuitBreaker(r, cbGenFuncMap[r.Strategy])
+	assert.Nil(t, err)
+
+	ctx := newKeyedEntryContext("abc", "m1", "")
+	assert.True(t, cb.TryPass(ctx))
+	cb.onRequestCompleteOf(ctx, 1, errors.New("biz error"))
+	assert.False(t, cb.TryPass(newKeyedEntryContext("abc", "m1", "")))
+	assert.Equal(t, Closed, cb.CurrentState())
+
+	// 超过容量后淘汰最久未访问的键，重新访问时从关闭状态开始
+	assert.True(t, cb.TryPass(newKeyedEntryContext("abc", "m2", "")))
+	assert.True(t, cb.TryPass(newKeyedEntryContext("abc", "m3", "")))
+	assert.Equal(t, 2, cb.breakers.Len())
+	assert.True(t, cb.TryPass(newKeyedEntryContext("abc", "m1", "")))
+}
+
+func TestIsValidRuleOfKeyMode(t *testing.T) {
+	r := &Rule{
+		Id:               "keyed",
+		Resource:         "abc",
+		Strategy:         ErrorCount,
+		RetryTimeoutMs:   1000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   1000,
+		Threshold:        1,
+		KeyMode:          KeyByTargetAddress,
+	}
+	assert.Nil(t, IsValidRule(r))
+	r.KeyMode = KeyByTargetAddress + 1
+	assert.NotNil(t, IsValidRule(r))
+	r.KeyMode = KeyByParam
+	r.ClusterMode = true
+	assert.NotNil(t, IsValidRule(r))
+	r.ClusterMode = false
+	r.KeysMaxCapacity = -1
+	assert.NotNil(t, IsValidRule(r))
+}
@@ -0,0 +1,184 @@
+package circuitbreaker
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"github.com/liuhailove/gmiter/core/base"
+	sbase "github.com/liuhailove/gmiter/core/stat/base"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/pkg/errors"
+)
+
+// ================================= slowRtPercentileCircuitBreaker ====================================
+// slowRtPercentileCircuitBreaker 统计窗口内Percentile分位的RT超过Threshold(ms)时熔断，
+// 分位RT取自对数分桶的直方图，为所在桶的上界，相对误差不超过12.5%
+type slowRtPercentileCircuitBreaker struct {
+	circuitBreakerBase
+	stat             *rtHistogramLeapArray
+	percentile       float64
+	maxAllowedRt     float64
+	minRequestAmount uint64
+}
+
+func newSlowRtPercentileCircuitBreakerWithStat(r *Rule, stat *rtHistogramLeapArray) *slowRtPercentileCircuitBreaker {
+	return &slowRtPercentileCircuitBreaker{
+		circuitBreakerBase: circuitBreakerBase{
+			rule:                 r,
+			retryTimeoutMs:       r.RetryTimeoutMs,
+			nextRetryTimestampMs: 0,
+			probeNumber:          r.ProbeNum,
+			state:                newState(),
+		},
+		stat:             stat,
+		percentile:       r.Percentile,
+		maxAllowedRt:     r.Threshold,
+		minRequestAmount: r.MinRequestAmount,
+	}
+}
+
+func newSlowRtPercentileCircuitBreaker(r *Rule) (*slowRtPercentileCircuitBreaker, error) {
+	interval := r.StatIntervalMs
+	bucketCount := getRuleStatSlidingWindowBucketCount(r)
+	stat := &rtHistogramLeapArray{}
+	leapArray, err := sbase.NewLeapArray(bucketCount, interval, stat)
+	if err != nil {
+		return nil, err
+	}
+	stat.data = leapArray
+	return newSlowRtPercentileCircuitBreakerWithStat(r, stat), nil
+}
+
+func (b *slowRtPercentileCircuitBreaker) BoundStat() interface{} {
+	return b.stat
+}
+
+func (b *slowRtPercentileCircuitBreaker) TryPass(ctx *base.EntryContext) bool {
+	curStatus := b.CurrentState()
+	if curStatus == Closed {
+		return b.slowStartPass()
+	}
+	if curStatus == Open {
+		// switch state to half-open to probe if retry timeout
+		if b.retryTimeoutArrived() && b.fromOpenToHalfOpen(ctx) {
+			return true
+		}
+	}
+	if curStatus == HalfOpen && b.probeNumber > 0 {
+		return true
+	}
+	return false
+}
+
+func (b *slowRtPercentileCircuitBreaker) OnRequestComplete(rt uint64, _ error) {
+	histogram, curErr := b.stat.currentHistogram()
+	if curErr != nil {
+		logging.Error(curErr, "Fail to get current histogram in slowRtPercentileCircuitBreaker#OnRequestComplete().",
+			"rule", b.rule)
+		return
+	}
+	histogram.Add(int64(rt))
+
+	// handleStateChange
+	curStatus := b.CurrentState()
+	if curStatus == Open {
+		return
+	}
+	if curStatus == HalfOpen {
+		if float64(rt) > b.maxAllowedRt {
+			// fail to probe
+			b.fromHalfOpenToOpen(1.0)
+		} else {
+			b.addCurProbeNum()
+			if b.probeNumber == 0 || atomic.LoadUint64(&b.curProbeNumber) >= b.probeNumber {
+				// succeed to probe
+				b.fromHalfOpenToClosed()
+				b.resetMetric()
+			}
+		}
+		return
+	}
+	// current state is CLOSED
+	histograms := b.stat.allHistograms()
+	totalCount := uint64(0)
+	for _, h := range histograms {
+		totalCount += uint64(h.Count())
+	}
+	if totalCount < b.minRequestAmount {
+		return
+	}
+	percentileRt := sbase.PercentileRtOf(histograms, b.percentile)
+	if percentileRt > b.maxAllowedRt {
+		curStatus = b.CurrentState()
+		switch curStatus {
+		case Closed:
+			b.fromClosedToOpen(percentileRt)
+		case HalfOpen:
+			b.fromHalfOpenToOpen(percentileRt)
+		default:
+		}
+	}
+}
+
+func (b *slowRtPercentileCircuitBreaker) resetMetric() {
+	for _, h := range b.stat.allHistograms() {
+		h.Reset()
+	}
+}
+
+type rtHistogramLeapArray struct {
+	data *sbase.LeapArray
+}
+
+func (s *rtHistogramLeapArray) NewEmptyBucket() interface{} {
+	return sbase.NewRtHistogram()
+}
+
+// ResetBucketTo 原地清空桶内的直方图，避免每个统计周期重新分配
+func (s *rtHistogramLeapArray) ResetBucketTo(bw *sbase.BucketWrap, startTime uint64) *sbase.BucketWrap {
+	atomic.StoreUint64(&bw.BucketStart, startTime)
+	if histogram, ok := bw.Value.Load().(*sbase.RtHistogram); ok {
+		histogram.Reset()
+	} else {
+		bw.Value.Store(sbase.NewRtHistogram())
+	}
+	return bw
+}
+
+func (s *rtHistogramLeapArray) currentHistogram() (*sbase.RtHistogram, error) {
+	curBucket, err := s.data.CurrentBucket(s)
+	if err != nil {
+		return nil, err
+	}
+	if curBucket == nil {
+		return nil, errors.New("nil BucketWrap")
+	}
+	mb := curBucket.Value.Load()
+	if mb == nil {
+		return nil, errors.New("nil RtHistogram")
+	}
+	histogram, ok := mb.(*sbase.RtHistogram)
+	if !ok {
+		return nil, errors.Errorf("bucket fail to do type assert, expect: *RtHistogram, in fact: %s", reflect.TypeOf(mb).Name())
+	}
+	return histogram, nil
+}
+
+func (s *rtHistogramLeapArray) allHistograms() []*sbase.RtHistogram {
+	buckets := s.data.Values()
+	ret := make([]*sbase.RtHistogram, 0, len(buckets))
+	for _, b := range buckets {
+		mb := b.Value.Load()
+		if mb == nil {
+			logging.Error(errors.New("current bucket atomic Value is nil"), "Current bucket atomic Value is nil in rtHistogramLeapArray.allHistograms()")
+			continue
+		}
+		histogram, ok := mb.(*sbase.RtHistogram)
+		if !ok {
+			logging.Error(errors.New("bucket data type error"), "Bucket data type error in rtHistogramLeapArray.allHistograms()", "expect type", "*RtHistogram", "actual type", reflect.TypeOf(mb).Name())
+			continue
+		}
+		ret = append(ret, histogram)
+	}
+	return ret
+}
@@ -2,6 +2,8 @@ package circuitbreaker
 
 import (
 	"fmt"
+
+	"github.com/liuhailove/gmiter/core/hotspot"
 	"github.com/liuhailove/gmiter/util"
 )
 
@@ -16,6 +18,8 @@ const (
 	ErrorRatio
 	// ErrorCount strategy changes the circuit breaker state based on error amount
 	ErrorCount
+	// SlowRequestPercentile strategy changes the circuit breaker state based on the percentile of response time
+	SlowRequestPercentile
 )
 
 func (s Strategy) String() string {
@@ -26,11 +30,50 @@ func (s Strategy) String() string {
 		return "ErrorRatio"
 	case ErrorCount:
 		return "ErrorCount"
+	case SlowRequestPercentile:
+		return "SlowRequestPercentile"
+	default:
+		return "Undefined"
+	}
+}
+
+// KeyMode 熔断的维度
+type KeyMode uint32
+
+const (
+	// KeyByResource 按照资源熔断
+	KeyByResource KeyMode = iota
+	// KeyByParam 按照参数值熔断，参数的抽取方式与热点参数规则一致
+	KeyByParam
+	// KeyByTargetAddress 按照调用的目标地址熔断，目标地址通过api.WithTargetAddress设置，
+	// nethttp、grpc及micro客户端适配器分别以请求的host、连接的target及client.WithAddress指定的地址作为目标地址
+	KeyByTargetAddress
+)
+
+func (m KeyMode) String() string {
+	switch m {
+	case KeyByResource:
+		return "KeyByResource"
+	case KeyByParam:
+		return "KeyByParam"
+	case KeyByTargetAddress:
+		return "KeyByTargetAddress"
 	default:
 		return "Undefined"
 	}
 }
 
+const (
+	// DefaultRetryTimeoutBackoffMultiplier 默认的熔断时长增长倍数
+	DefaultRetryTimeoutBackoffMultiplier = 2.0
+	// DefaultSlowStartMinRatio 默认的慢启动初始放行比例
+	DefaultSlowStartMinRatio = 0.1
+	// DefaultClusterSyncIntervalMs 集群模式下默认的状态同步间隔
+	DefaultClusterSyncIntervalMs = 100
+	// DefaultKeysMaxCapacity 按键熔断时默认最多保留的键数
+	DefaultKeysMaxCapacity = 1000
+)
+
 // Rule encompasses the fields of circuit breaking rule.
 type Rule struct {
 	// unique id
@@ -75,12 +118,61 @@ type Rule struct {
 	// for SlowRequestRatio, it represents the max slow request ratio
 	// for ErrorRatio, it represents the max error request ratio
 	// for ErrorCount, it represents the max error request count
+	// for SlowRequestPercentile, it represents the max allowed response time (in ms) of the Percentile
 	Threshold float64 `json:"threshold"`
+	// Percentile 分位，取值范围(0, 100]，如99表示P99，仅在SlowRequestPercentile策略下生效
+	Percentile float64 `json:"percentile"`
 	//ProbeNum is number of probes required when the circuit breaker is half-open.
 	//when the probe num are set  and circuit breaker in the half-open state.
 	//if err occurs during the probe, the circuit breaker is opened immediately.
 	//otherwise,the circuit breaker is closed only after the number of probes is reached
 	ProbeNum uint64 `json:"probeNum"`
+	// MaxRetryTimeoutMs 熔断时长的上限，大于RetryTimeoutMs时开启指数退避：
+	// 半开探测连续失败时熔断时长按照RetryTimeoutBackoffMultiplier倍数增长，最大不超过MaxRetryTimeoutMs，
+	// 为0或者不大于RetryTimeoutMs时熔断时长固定为RetryTimeoutMs
+	MaxRetryTimeoutMs uint32 `json:"maxRetryTimeoutMs"`
+	// RetryTimeoutBackoffMultiplier 熔断时长的增长倍数，不大于1时取DefaultRetryTimeoutBackoffMultiplier
+	RetryTimeoutBackoffMultiplier float64 `json:"retryTimeoutBackoffMultiplier"`
+	// BackoffResetMs 熔断器恢复后持续健康(未再次熔断)超过该时长时，熔断时长重置为RetryTimeoutMs，
+	// 为0时取MaxRetryTimeoutMs
+	BackoffResetMs uint32 `json:"backoffResetMs"`
+	// SlowStartWindowMs 熔断器恢复后的慢启动窗口，窗口内放行的请求比例由SlowStartMinRatio线性增长到100%，为0时不开启慢启动
+	SlowStartWindowMs uint32 `json:"slowStartWindowMs"`
+	// SlowStartMinRatio 慢启动开始时的放行比例，取值范围[0.0, 1.0]，为0时取DefaultSlowStartMinRatio
+	SlowStartMinRatio float64 `json:"slowStartMinRatio"`
+	// ClusterMode 是否为集群模式，集群模式下熔断状态及错误统计通过RedisTokenService在实例间共享，
+	// 半开状态下由竞选出的一个实例探测，RedisTokenService不可用时回退到本地熔断
+	ClusterMode bool `json:"clusterMode"`
+	// ClusterConfig 集群配置，为空时使用默认配置
+	ClusterConfig *ClusterConfig `json:"clusterConfig"`
+	// KeyMode 熔断的维度，默认按照资源熔断；按键熔断时每个键(参数值或者目标地址)拥有独立的熔断器，
+	// 某个键熔断不影响其他键的请求，抽取不到键的请求不做熔断检查
+	KeyMode KeyMode `json:"keyMode"`
+	// ParamSource 参数来源，KeyMode为KeyByParam时生效，含义与hotspot.Rule一致
+	ParamSource hotspot.ParameterSourceType `json:"paramSource"`
+	// ParamIdx 参数在Args中的索引，KeyMode为KeyByParam时生效，含义与hotspot.Rule一致
+	ParamIdx int `json:"paramIdx"`
+	// ParamKey 参数的key，KeyMode为KeyByParam时生效，含义与hotspot.Rule一致
+	ParamKey string `json:"paramKey"`
+	// ParamKind 参数类型，KeyMode为KeyByParam时生效，含义与hotspot.Rule一致
+	ParamKind hotspot.ParamKind `json:"paramKind"`
+	// KeysMaxCapacity 按键熔断时最多保留的键数，超过后淘汰最久未访问的键，为0时取DefaultKeysMaxCapacity
+	KeysMaxCapacity int `json:"keysMaxCapacity"`
+}
+
+// ClusterConfig 集群熔断配置
+type ClusterConfig struct {
+	// SyncIntervalMs 向RedisTokenService上报统计并同步熔断状态的间隔，为0时取DefaultClusterSyncIntervalMs
+	SyncIntervalMs uint32 `json:"syncIntervalMs"`
+	// ProbeTimeoutMs 探测者的最长探测时间，超时未上报探测结果时由其他实例重新竞选，为0时取RetryTimeoutMs
+	ProbeTimeoutMs uint32 `json:"probeTimeoutMs"`
+}
+
+func (c *ClusterConfig) isEqualTo(newConfig *ClusterConfig) bool {
+	if c == nil || newConfig == nil {
+		return c == newConfig
+	}
+	return *c == *newConfig
 }
 
 func (r *Rule) String() string {
@@ -93,7 +185,7 @@ func (r *Rule) isStatReusable(newRule *Rule) bool {
 	if newRule == nil {
 		return false
 	}
-	return r.Resource == newRule.Resource && r.Strategy == newRule.Strategy &&
+	return r.Resource == newRule.Resource && r.Strategy == newRule.Strategy && r.KeyMode == newRule.KeyMode &&
 		r.StatIntervalMs == newRule.StatIntervalMs && r.StatSlidingWindowBucketCount == newRule.StatSlidingWindowBucketCount
 }
 
@@ -107,7 +199,13 @@ func (r *Rule) isEqualsToBase(newRule *Rule) bool {
 	}
 	return r.Resource == newRule.Resource && r.Strategy == newRule.Strategy && r.RetryTimeoutMs == newRule.RetryTimeoutMs &&
 		r.MinRequestAmount == newRule.MinRequestAmount && r.StatIntervalMs == newRule.StatIntervalMs && r.StatSlidingWindowBucketCount == newRule.StatSlidingWindowBucketCount &&
-		r.LimitApp == newRule.LimitApp
+		r.LimitApp == newRule.LimitApp && r.MaxRetryTimeoutMs == newRule.MaxRetryTimeoutMs &&
+		util.Float64Equals(r.RetryTimeoutBackoffMultiplier, newRule.RetryTimeoutBackoffMultiplier) &&
+		r.BackoffResetMs == newRule.BackoffResetMs && r.SlowStartWindowMs == newRule.SlowStartWindowMs &&
+		util.Float64Equals(r.SlowStartMinRatio, newRule.SlowStartMinRatio) &&
+		r.ClusterMode == newRule.ClusterMode && r.ClusterConfig.isEqualTo(newRule.ClusterConfig) &&
+		r.KeyMode == newRule.KeyMode && r.ParamSource == newRule.ParamSource && r.ParamIdx == newRule.ParamIdx &&
+		r.ParamKey == newRule.ParamKey && r.ParamKind == newRule.ParamKind && r.KeysMaxCapacity == newRule.KeysMaxCapacity
 }
 
 func (r *Rule) isEqualTo(newRule *Rule) bool {
@@ -121,6 +219,8 @@ func (r *Rule) isEqualTo(newRule *Rule) bool {
 		return util.Float64Equals(r.Threshold, newRule.Threshold)
 	case ErrorCount:
 		return util.Float64Equals(r.Threshold, newRule.Threshold)
+	case SlowRequestPercentile:
+		return util.Float64Equals(r.Threshold, newRule.Threshold) && util.Float64Equals(r.Percentile, newRule.Percentile)
 	default:
 		return false
 	}
@@ -68,6 +68,21 @@ func init() {
 		}
 		return newErrorCountCircuitBreakerWithStat(r, stat), nil
 	}
+
+	cbGenFuncMap[SlowRequestPercentile] = func(r *Rule, reuseStat interface{}) (CircuitBreaker, error) {
+		if r == nil {
+			return nil, errors.New("nil rule")
+		}
+		if reuseStat == nil {
+			return newSlowRtPercentileCircuitBreaker(r)
+		}
+		stat, ok := reuseStat.(*rtHistogramLeapArray)
+		if !ok || stat == nil {
+			logging.Warn("[CircuitBreaker RuleManager] Expect to generate circuit breaker with reuse statistic, but fail to do type assertion, expect:*rtHistogramLeapArray", "statType", reflect.TypeOf(stat).Name())
+			return newSlowRtPercentileCircuitBreaker(r)
+		}
+		return newSlowRtPercentileCircuitBreakerWithStat(r, stat), nil
+	}
 }
 
 // GetRulesOfResource returns specific resource's rules based on copy.
@@ -351,7 +366,7 @@ func SetCircuitBreakerGenerator(s Strategy, generator CircuitBreakerGenFunc) err
 	if generator == nil {
 		return errors.New("nil generator")
 	}
-	if s <= ErrorCount {
+	if s <= SlowRequestPercentile {
 		return errors.New("not allowed to replace the generator for default circuit breaking strategies")
 	}
 	updateMux.Lock()
@@ -362,7 +377,7 @@ func SetCircuitBreakerGenerator(s Strategy, generator CircuitBreakerGenFunc) err
 }
 
 func RemoveCircuitBreakerGenerator(s Strategy) error {
-	if s <= ErrorCount {
+	if s <= SlowRequestPercentile {
 		return errors.New("not allowed to remove the generator for default circuit breaking strategies")
 	}
 	updateMux.Lock()
@@ -406,7 +421,9 @@ func buildResourceCircuitBreaker(res string, rulesOfRes []*Rule, oldResCbs []Cir
 
 		var cb CircuitBreaker
 		var e error
-		if reuseStatIdx >= 0 {
+		if r.KeyMode != KeyByResource {
+			cb, e = newKeyedCircuitBreaker(r, generator)
+		} else if reuseStatIdx >= 0 {
 			cb, e = generator(r, oldResCbs[reuseStatIdx].BoundStat())
 		} else {
 			cb, e = generator(r, nil)
@@ -416,6 +433,9 @@ func buildResourceCircuitBreaker(res string, rulesOfRes []*Rule, oldResCbs []Cir
 			continue
 		}
 
+		if r.ClusterMode {
+			cb = newClusterCircuitBreaker(r, cb)
+		}
 		if reuseStatIdx >= 0 {
 			oldResCbs = append(oldResCbs[:reuseStatIdx], oldResCbs[reuseStatIdx+1:]...)
 		}
@@ -446,6 +466,34 @@ func IsValidRule(r *Rule) error {
 	if r.Strategy == ErrorRatio && r.Threshold > 1.0 {
 		return errors.New("invalid error ratio threshold (valid range: [0.0, 1.0])")
 	}
+	if r.Strategy == SlowRequestPercentile && (r.Percentile <= 0.0 || r.Percentile > 100.0) {
+		return errors.New("invalid percentile (valid range: (0.0, 100.0])")
+	}
+	if r.RetryTimeoutBackoffMultiplier < 0 {
+		return errors.New("invalid RetryTimeoutBackoffMultiplier")
+	}
+	if r.SlowStartMinRatio < 0.0 || r.SlowStartMinRatio > 1.0 {
+		return errors.New("invalid SlowStartMinRatio (valid range: [0.0, 1.0])")
+	}
+	if r.ClusterMode {
+		if len(r.Id) == 0 {
+			return errors.New("empty id of cluster mode rule")
+		}
+		if r.Strategy == SlowRequestPercentile {
+			return errors.New("cluster mode is not supported by SlowRequestPercentile strategy")
+		}
+	}
+	if r.KeyMode > KeyByTargetAddress {
+		return errors.New("invalid KeyMode")
+	}
+	if r.KeyMode != KeyByResource {
+		if r.ClusterMode {
+			return errors.New("cluster mode is not supported by keyed circuit breaker")
+		}
+		if r.KeysMaxCapacity < 0 {
+			return errors.New("invalid KeysMaxCapacity")
+		}
+	}
 	if r.StatSlidingWindowBucketCount != 0 && r.StatIntervalMs%r.StatSlidingWindowBucketCount != 0 {
 		logging.Warn("[CircuitBreaker IsValidRule] The following must be true: StatIntervalMs % StatSlidingWindowBucketCount == 0. StatSlidingWindowBucketCount will be replaced by 1", "rule", r)
 	}
@@ -41,6 +41,10 @@ func (m MetricStatSlot) OnCompleted(ctx *base.EntryContext) {
 	err := ctx.Err()
 	rt := ctx.Rt()
 	for _, cb := range getBreakersOfResource(res) {
+		if keyed, ok := cb.(*keyedCircuitBreaker); ok {
+			keyed.onRequestCompleteOf(ctx, rt, err)
+			continue
+		}
 		cb.OnRequestComplete(rt, err)
 	}
 }
@@ -229,6 +229,14 @@ func MemoryStatCollectIntervalMs() uint32 {
 	return globalCfg.MemoryStatCollectIntervalMs()
 }
 
+func HotParamsSnapshotIntervalMs() uint32 {
+	return globalCfg.HotParamsSnapshotIntervalMs()
+}
+
+func HotParamsTopN() uint32 {
+	return globalCfg.HotParamsTopN()
+}
+
 func UseCacheTime() bool {
 	return globalCfg.UseCacheTime()
 }
@@ -441,6 +449,16 @@ func ClientMaxAllowQps() float64 {
 	return globalCfg.Conf.ClusterConfig.ClientMaxAllowQps
 }
 
+// TokenServerPort 内嵌TokenServer的TCP监听端口
+func TokenServerPort() int32 {
+	return globalCfg.Conf.ClusterConfig.TokenServerPort
+}
+
+// TokenServerSecret TokenServer与客户端之间的共享密钥
+func TokenServerSecret() string {
+	return globalCfg.Conf.ClusterConfig.TokenServerSecret
+}
+
 func EtcdDatasourceEndpoints() string {
 	return globalCfg.Conf.EtcdV3DatasourceConfig.Endpoints
 }
@@ -473,6 +491,106 @@ func ApolloDatasourceSecret() string {
 	return globalCfg.Conf.ApolloDatasourceConfig.Secret
 }
 
+func ApolloDatasourceBackupConfigPath() string {
+	return globalCfg.Conf.ApolloDatasourceConfig.BackupConfigPath
+}
+
+func ConsulDatasourceAddress() string {
+	return globalCfg.Conf.ConsulDatasourceConfig.Address
+}
+
+func ConsulDatasourceToken() string {
+	return globalCfg.Conf.ConsulDatasourceConfig.Token
+}
+
+func ConsulDatasourceDatacenter() string {
+	return globalCfg.Conf.ConsulDatasourceConfig.Datacenter
+}
+
+func ConsulDatasourceKeyPrefix() string {
+	return globalCfg.Conf.ConsulDatasourceConfig.KeyPrefix
+}
+
+func ConsulDatasourceIsBackupConfig() bool {
+	return globalCfg.Conf.ConsulDatasourceConfig.IsBackupConfig
+}
+
+func ConsulDatasourceBackupConfigPath() string {
+	return globalCfg.Conf.ConsulDatasourceConfig.BackupConfigPath
+}
+
+func NacosDatasourceServerAddr() string {
+	return globalCfg.Conf.NacosDatasourceConfig.ServerAddr
+}
+
+func NacosDatasourceNamespaceId() string {
+	return globalCfg.Conf.NacosDatasourceConfig.NamespaceId
+}
+
+func NacosDatasourceGroup() string {
+	return globalCfg.Conf.NacosDatasourceConfig.Group
+}
+
+func NacosDatasourceUsername() string {
+	return globalCfg.Conf.NacosDatasourceConfig.Username
+}
+
+func NacosDatasourcePassword() string {
+	return globalCfg.Conf.NacosDatasourceConfig.Password
+}
+
+func NacosDatasourceIsBackupConfig() bool {
+	return globalCfg.Conf.NacosDatasourceConfig.IsBackupConfig
+}
+
+func NacosDatasourceBackupConfigPath() string {
+	return globalCfg.Conf.NacosDatasourceConfig.BackupConfigPath
+}
+
+func DbDatasourceDriverName() string {
+	return globalCfg.Conf.DbDatasourceConfig.DriverName
+}
+
+func DbDatasourceDsn() string {
+	return globalCfg.Conf.DbDatasourceConfig.Dsn
+}
+
+func DbDatasourceTableName() string {
+	return globalCfg.Conf.DbDatasourceConfig.TableName
+}
+
+func DbDatasourcePollIntervalMs() uint32 {
+	return globalCfg.Conf.DbDatasourceConfig.PollIntervalMs
+}
+
+func K8sDatasourceApiServer() string {
+	return globalCfg.Conf.K8sDatasourceConfig.ApiServer
+}
+
+func K8sDatasourceTokenFile() string {
+	return globalCfg.Conf.K8sDatasourceConfig.TokenFile
+}
+
+func K8sDatasourceNamespace() string {
+	return globalCfg.Conf.K8sDatasourceConfig.Namespace
+}
+
+func K8sDatasourceLabelSelector() string {
+	return globalCfg.Conf.K8sDatasourceConfig.LabelSelector
+}
+
+func ZkDatasourceServers() string {
+	return globalCfg.Conf.ZkDatasourceConfig.Servers
+}
+
+func ZkDatasourceRootPath() string {
+	return globalCfg.Conf.ZkDatasourceConfig.RootPath
+}
+
+func ZkDatasourceSessionTimeoutMs() int64 {
+	return globalCfg.Conf.ZkDatasourceConfig.SessionTimeoutMs
+}
+
 // RedisClusterHost redis host
 func RedisClusterHost() string {
 	return globalCfg.Conf.RedisClusterConfig.Host
@@ -21,6 +21,8 @@ const (
 	DefaultLoadStatCollectIntervalMs   uint32 = 1000
 	DefaultCpuStatCollectIntervalMs    uint32 = 1000
 	DefaultMemoryStatCollectIntervalMs uint32 = 150
+	DefaultHotParamsSnapshotIntervalMs uint32 = 1000
+	DefaultHotParamsTopN               uint32 = 10
 	DefaultWarmUpColdFactor            uint32 = 3
 
 	DefaultDashServer          = "127.0.0.1:8080"
@@ -82,6 +84,30 @@ const (
 	// DefaultClientMaxAllowQps 单个客户端默认请求的最大QPS为1万
 	DefaultClientMaxAllowQps = 10000.0
 
+	// DefaultTokenServerPort 内嵌TokenServer默认的TCP监听端口
+	DefaultTokenServerPort = 18730
+
 	// DefaultEtcdV3Prefix 默认的EtcdV3前缀
 	DefaultEtcdV3Prefix = "gmiter_etcd_v3"
+
+	// DefaultZkRootPath 默认的zk规则根路径
+	DefaultZkRootPath = "/gmiter"
+	// DefaultZkSessionTimeoutMs 默认的zk会话超时时间
+	DefaultZkSessionTimeoutMs = 10000
+
+	// DefaultNacosGroup 默认的nacos配置分组
+	DefaultNacosGroup = "DEFAULT_GROUP"
+
+	// DefaultApolloCluster 默认的apollo集群
+	DefaultApolloCluster = "default"
+	// DefaultApolloNamespaceName 默认的apollo命名空间
+	DefaultApolloNamespaceName = "application"
+
+	// DefaultConsulKeyPrefix 默认的consul规则key前缀
+	DefaultConsulKeyPrefix = "gmiter"
+
+	// DefaultDbTableName 默认的规则表名
+	DefaultDbTableName = "gmiter_rule"
+	// DefaultDbPollIntervalMs 默认的规则版本轮询间隔
+	DefaultDbPollIntervalMs = 3000
 )
@@ -63,10 +63,70 @@ type EtcdV3DatasourceConfig struct {
 
 // ZkDatasourceConfig zk持久化存储配置
 type ZkDatasourceConfig struct {
+	// Servers zk地址列表，多个逗号分割
+	Servers string `yaml:"servers"`
+	// RootPath 规则的根路径，规则路径为{rootPath}/{appName}/{ruleName}
+	RootPath string `yaml:"rootPath"`
+	// SessionTimeoutMs 会话超时时间，单位毫秒
+	SessionTimeoutMs int64 `yaml:"sessionTimeoutMs"`
 }
 
 // NacosDatasourceConfig nacos持久化存储配置
 type NacosDatasourceConfig struct {
+	// ServerAddr nacos地址列表，多个逗号分割
+	ServerAddr string `yaml:"serverAddr"`
+	// NamespaceId 命名空间ID
+	NamespaceId string `yaml:"namespaceId"`
+	// Group 配置分组，规则的dataId为{appName}-{ruleName}
+	Group string `yaml:"group"`
+	// Username 用户名
+	Username string `yaml:"username"`
+	// Password 密码
+	Password string `yaml:"password"`
+	// IsBackupConfig 是否备份配置
+	IsBackupConfig bool `yaml:"isBackupConfig"`
+	// BackupConfigPath 备份配置目录，为空时使用日志目录下的config-cache
+	BackupConfigPath string `yaml:"backupConfigPath"`
+}
+
+// DbDatasourceConfig 数据库持久化存储配置
+type DbDatasourceConfig struct {
+	// DriverName database/sql驱动名称，如mysql，驱动需由应用自行import
+	DriverName string `yaml:"driverName"`
+	// Dsn 数据源连接串
+	Dsn string `yaml:"dsn"`
+	// TableName 规则表名
+	TableName string `yaml:"tableName"`
+	// PollIntervalMs 轮询规则版本的间隔，单位毫秒
+	PollIntervalMs uint32 `yaml:"pollIntervalMs"`
+}
+
+// K8sDatasourceConfig k8s CRD持久化存储配置
+type K8sDatasourceConfig struct {
+	// ApiServer api server地址，为空时使用集群内配置
+	ApiServer string `yaml:"apiServer"`
+	// TokenFile ServiceAccount token文件，为空时使用集群内默认路径
+	TokenFile string `yaml:"tokenFile"`
+	// Namespace 规则CR所在的命名空间，为空时使用当前Pod所在的命名空间
+	Namespace string `yaml:"namespace"`
+	// LabelSelector 规则CR的标签选择器，为空时使用app={appName}
+	LabelSelector string `yaml:"labelSelector"`
+}
+
+// ConsulDatasourceConfig consul持久化存储配置
+type ConsulDatasourceConfig struct {
+	// Address consul地址
+	Address string `yaml:"address"`
+	// Token ACL token
+	Token string `yaml:"token"`
+	// Datacenter 数据中心，为空时使用agent所在的数据中心
+	Datacenter string `yaml:"datacenter"`
+	// KeyPrefix 规则key前缀，规则key为{keyPrefix}/{appName}/{ruleName}
+	KeyPrefix string `yaml:"keyPrefix"`
+	// IsBackupConfig 是否备份配置
+	IsBackupConfig bool `yaml:"isBackupConfig"`
+	// BackupConfigPath 备份配置目录，为空时使用日志目录下的config-cache
+	BackupConfigPath string `yaml:"backupConfigPath"`
 }
 
 // ApolloDatasourceConfig apollo持久化存储配置
@@ -83,6 +143,8 @@ type ApolloDatasourceConfig struct {
 	IsBackupConfig bool `default:"true" yaml:"isBackupConfig"`
 	// Secret 和 server 交互密钥
 	Secret string `yaml:"secret"`
+	// BackupConfigPath 备份配置目录，为空时使用日志目录下的config-cache
+	BackupConfigPath string `yaml:"backupConfigPath"`
 }
 
 // ClusterConfig 集群配置
@@ -95,6 +157,10 @@ type ClusterConfig struct {
 	MaxAllowQps float64 `yaml:"maxAllowQps"`
 	// ClientMaxAllowQps 作为请求客户端AcquireToken的最大QPS，超过此值则降级为单机限流
 	ClientMaxAllowQps float64 `yaml:"clientMaxAllowQps"`
+	// TokenServerPort 内嵌TokenServer的TCP监听端口，选主模式下客户端通过此端口连接master
+	TokenServerPort int32 `yaml:"tokenServerPort"`
+	// TokenServerSecret TokenServer与客户端之间的共享密钥，不为空时TokenServer拒绝密钥不一致的请求
+	TokenServerSecret string `yaml:"tokenServerSecret"`
 }
 
 // RedisClusterConfig redis集群配置，当前主要用于限流
@@ -201,6 +267,12 @@ type GConfig struct {
 	NacosDatasourceConfig NacosDatasourceConfig `yaml:"nacosDatasourceConfig"`
 	// ApolloDatasourceConfig 持久化存储配置
 	ApolloDatasourceConfig ApolloDatasourceConfig `yaml:"apolloDatasourceConfig"`
+	// ConsulDatasourceConfig 持久化存储配置
+	ConsulDatasourceConfig ConsulDatasourceConfig `yaml:"consulDatasourceConfig"`
+	// K8sDatasourceConfig 持久化存储配置
+	K8sDatasourceConfig K8sDatasourceConfig `yaml:"k8sDatasourceConfig"`
+	// DbDatasourceConfig 持久化存储配置
+	DbDatasourceConfig DbDatasourceConfig `yaml:"dbDatasourceConfig"`
 	// ClusterConfig 集群配置
 	ClusterConfig ClusterConfig `yaml:"clusterConfig"`
 	// RedisClusterConfig 集群配置
@@ -253,6 +325,9 @@ type StatConfig struct {
 	MetricStatisticIntervalMs  uint32 `yaml:"metricStatisticIntervalMs"`
 
 	System SystemStatConfig `yaml:"system"`
+
+	// HotParams 热点参数值TopN统计配置
+	HotParams HotParamsStatConfig `yaml:"hotParams"`
 }
 
 // SystemStatConfig represents the configuration items of system statistics.
@@ -267,6 +342,14 @@ type SystemStatConfig struct {
 	CollectMemoryIntervalMs uint32 `yaml:"collectMemoryIntervalMs"`
 }
 
+// HotParamsStatConfig 热点参数值TopN统计配置
+type HotParamsStatConfig struct {
+	// SnapshotIntervalMs 生成TopN快照的周期，即热点参数值的统计周期，为0时不生成快照
+	SnapshotIntervalMs uint32 `yaml:"snapshotIntervalMs"`
+	// TopN 每条热点规则每个统计周期保留的参数值个数
+	TopN uint32 `yaml:"topN"`
+}
+
 // NewDefaultConfig creates a new default config entity.
 func NewDefaultConfig() *Entity {
 	return &Entity{
@@ -393,6 +476,10 @@ func NewDefaultConfig() *Entity {
 					CollectCpuIntervalMs:    DefaultCpuStatCollectIntervalMs,
 					CollectMemoryIntervalMs: DefaultMemoryStatCollectIntervalMs,
 				},
+				HotParams: HotParamsStatConfig{
+					SnapshotIntervalMs: DefaultHotParamsSnapshotIntervalMs,
+					TopN:               DefaultHotParamsTopN,
+				},
 			},
 			UseCacheTime:       false,
 			RulePersistentMode: FileMode,
@@ -420,6 +507,7 @@ func NewDefaultConfig() *Entity {
 				ClientNamespace:   ClientDefaultNameSpace,
 				MaxAllowQps:       DefaultMaxAllowQps,
 				ClientMaxAllowQps: DefaultClientMaxAllowQps,
+				TokenServerPort:   DefaultTokenServerPort,
 			},
 			// EtcdV3DatasourceConfig etcdv3持久化存储配置
 			EtcdV3DatasourceConfig: EtcdV3DatasourceConfig{
@@ -428,6 +516,27 @@ func NewDefaultConfig() *Entity {
 				// etcd地址列表，多个逗号分割
 				Endpoints: "",
 			},
+			ZkDatasourceConfig: ZkDatasourceConfig{
+				RootPath:         DefaultZkRootPath,
+				SessionTimeoutMs: DefaultZkSessionTimeoutMs,
+			},
+			NacosDatasourceConfig: NacosDatasourceConfig{
+				Group:          DefaultNacosGroup,
+				IsBackupConfig: true,
+			},
+			ApolloDatasourceConfig: ApolloDatasourceConfig{
+				Cluster:        DefaultApolloCluster,
+				NamespaceName:  DefaultApolloNamespaceName,
+				IsBackupConfig: true,
+			},
+			ConsulDatasourceConfig: ConsulDatasourceConfig{
+				KeyPrefix:      DefaultConsulKeyPrefix,
+				IsBackupConfig: true,
+			},
+			DbDatasourceConfig: DbDatasourceConfig{
+				TableName:      DefaultDbTableName,
+				PollIntervalMs: DefaultDbPollIntervalMs,
+			},
 			RedisClusterConfig: RedisClusterConfig{
 				// 是否为集群，默认为真
 				IsCluster: true,
@@ -464,6 +573,9 @@ func checkConfValid(conf *GConfig) error {
 		conf.Stat.GlobalStatisticSampleCountTotal, conf.Stat.GlobalStatisticIntervalMsTotal); err != nil {
 		return err
 	}
+	if hc := conf.Stat.HotParams; hc.SnapshotIntervalMs > 0 && hc.TopN == 0 {
+		return errors.New("Illegal hot params stat globalCfg: topN == 0")
+	}
 	return nil
 }
 
@@ -545,6 +657,14 @@ func (entity *Entity) MemoryStatCollectIntervalMs() uint32 {
 	return entity.Conf.Stat.System.CollectMemoryIntervalMs
 }
 
+func (entity *Entity) HotParamsSnapshotIntervalMs() uint32 {
+	return entity.Conf.Stat.HotParams.SnapshotIntervalMs
+}
+
+func (entity *Entity) HotParamsTopN() uint32 {
+	return entity.Conf.Stat.HotParams.TopN
+}
+
 func (entity *Entity) GlobalStatisticIntervalMsTotal() uint32 {
 	return entity.Conf.Stat.GlobalStatisticIntervalMsTotal
 }
@@ -0,0 +1,53 @@
+package election
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Member 参与选主的实例，Port为实例的命令端口，与flow.ClusterConfig.TokenServerMasterPort含义一致
+type Member struct {
+	Host string `json:"host"`
+	Port int32  `json:"port"`
+}
+
+func (m Member) IsEmpty() bool {
+	return m.Host == "" || m.Port <= 0
+}
+
+func (m Member) String() string {
+	return net.JoinHostPort(m.Host, strconv.Itoa(int(m.Port)))
+}
+
+// Backend 选主后端，同一个命名空间内最多只有一个master
+type Backend interface {
+	// Campaign 竞选master：当前没有master时成为master，自身为master时续约，返回当前的master
+	Campaign(ctx context.Context, namespace string, self Member, ttl time.Duration) (Member, error)
+	// Master 获取当前的master，没有master时返回空Member
+	Master(ctx context.Context, namespace string) (Member, error)
+	// Resign 自身为master时主动放弃，以便其他实例尽快接管
+	Resign(ctx context.Context, namespace string, self Member) error
+}
+
+// StaticBackend 静态指定master，不发生选举
+type StaticBackend struct {
+	master Member
+}
+
+func NewStaticBackend(master Member) *StaticBackend {
+	return &StaticBackend{master: master}
+}
+
+func (s *StaticBackend) Campaign(ctx context.Context, namespace string, self Member, ttl time.Duration) (Member, error) {
+	return s.master, nil
+}
+
+func (s *StaticBackend) Master(ctx context.Context, namespace string) (Member, error) {
+	return s.master, nil
+}
+
+func (s *StaticBackend) Resign(ctx context.Context, namespace string, self Member) error {
+	return nil
+}
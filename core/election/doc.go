@@ -0,0 +1,8 @@
+// Package election 实现集群流控TokenServerStrategyNodeSelectMaster模式下的选主。
+//
+// 同一个命名空间(config.Namespace())内的实例通过Backend竞选master，master周期性续约，
+// master失联超过TTL后由其他实例接管。Backend可插拔，内置StaticBackend(静态指定master)，
+// etcd租约及Redis锁的实现分别位于election/etcdv3及token_service/redis中。
+//
+// 启动选主后，集群流控客户端会使用选出的master作为TokenServer地址，未启动或没有master时使用规则中静态配置的master。
+package election
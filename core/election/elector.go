@@ -0,0 +1,246 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/logging"
+	transportConfig "github.com/liuhailove/gmiter/transport/common/transport/config"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	// DefaultTTL master的租约时间，master失联超过此时间后由其他实例接管
+	DefaultTTL = 10 * time.Second
+)
+
+var (
+	ErrElectorStarted = errors.New("elector already started")
+	ErrNilBackend     = errors.New("election backend is nil")
+)
+
+// RoleChangeListener 当前实例角色或master变化时回调
+type RoleChangeListener func(isMaster bool, master Member)
+
+// Option Elector的配置项
+type Option func(*Elector)
+
+// WithNamespace 设置参与选主的命名空间，默认为config.Namespace()
+func WithNamespace(namespace string) Option {
+	return func(e *Elector) {
+		e.namespace = namespace
+	}
+}
+
+// WithSelf 设置当前实例，默认为本机IP及命令端口
+func WithSelf(self Member) Option {
+	return func(e *Elector) {
+		e.self = self
+	}
+}
+
+// WithTTL 设置master的租约时间，续约间隔为TTL的1/3
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+// WithCandidate 设置是否参与竞选，不参与时只跟随当前的master
+func WithCandidate(candidate bool) Option {
+	return func(e *Elector) {
+		e.candidate = candidate
+	}
+}
+
+// WithListener 添加角色变化监听
+func WithListener(listener RoleChangeListener) Option {
+	return func(e *Elector) {
+		if listener != nil {
+			e.listeners = append(e.listeners, listener)
+		}
+	}
+}
+
+// Elector 周期性地通过Backend竞选或获取master
+type Elector struct {
+	backend   Backend
+	namespace string
+	self      Member
+	ttl       time.Duration
+	candidate bool
+	listeners []RoleChangeListener
+
+	mux      sync.RWMutex
+	master   Member
+	isMaster bool
+	// lastRefresh 上次成功访问Backend的时间(毫秒)
+	lastRefresh uint64
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewElector(backend Backend, opts ...Option) *Elector {
+	e := &Elector{
+		backend:   backend,
+		namespace: config.Namespace(),
+		ttl:       DefaultTTL,
+		candidate: true,
+		stopCh:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.self.IsEmpty() {
+		port, _ := strconv.ParseInt(transportConfig.GetPort(), 10, 32)
+		e.self = Member{Host: util.GetIP(), Port: int32(port)}
+	}
+	return e
+}
+
+// Start 立即执行一次选主，之后周期性续约
+func (e *Elector) Start() {
+	e.refresh()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.refresh()
+			case <-e.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止选主，自身为master时主动放弃
+func (e *Elector) Stop() {
+	close(e.stopCh)
+	e.wg.Wait()
+	if e.IsMaster() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+		defer cancel()
+		if err := e.backend.Resign(ctx, e.namespace, e.self); err != nil {
+			logging.Warn("[Elector] Fail to resign", "namespace", e.namespace, "self", e.self.String(), "err", err)
+		}
+	}
+	e.update(Member{})
+}
+
+// Master 当前的master
+func (e *Elector) Master() (Member, bool) {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	return e.master, !e.master.IsEmpty()
+}
+
+// IsMaster 当前实例是否为master
+func (e *Elector) IsMaster() bool {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	return e.isMaster
+}
+
+func (e *Elector) Namespace() string {
+	return e.namespace
+}
+
+func (e *Elector) Self() Member {
+	return e.self
+}
+
+func (e *Elector) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	var master Member
+	var err error
+	if e.candidate {
+		master, err = e.backend.Campaign(ctx, e.namespace, e.self, e.ttl)
+	} else {
+		master, err = e.backend.Master(ctx, e.namespace)
+	}
+	if err != nil {
+		logging.Warn("[Elector] Fail to refresh master", "namespace", e.namespace, "err", err)
+		// 超过TTL未能访问Backend时，原master可能已被其他实例取代，不再信任本地记录的master
+		if util.CurrentTimeMillis()-e.lastRefresh > uint64(e.ttl.Milliseconds()) {
+			e.update(Member{})
+		}
+		return
+	}
+	e.lastRefresh = util.CurrentTimeMillis()
+	e.update(master)
+}
+
+func (e *Elector) update(master Member) {
+	isMaster := !master.IsEmpty() && master == e.self
+	e.mux.Lock()
+	changed := e.master != master || e.isMaster != isMaster
+	e.master = master
+	e.isMaster = isMaster
+	e.mux.Unlock()
+	if !changed {
+		return
+	}
+	logging.Info("[Elector] Master changed", "namespace", e.namespace, "master", master.String(), "isMaster", isMaster)
+	for _, listener := range e.listeners {
+		listener(isMaster, master)
+	}
+}
+
+var (
+	defaultElector *Elector
+	defaultMux     = new(sync.RWMutex)
+)
+
+// Start 启动全局的Elector，集群流控选主模式下使用其选出的master作为TokenServer
+func Start(backend Backend, opts ...Option) error {
+	if backend == nil {
+		return ErrNilBackend
+	}
+	defaultMux.Lock()
+	defer defaultMux.Unlock()
+	if defaultElector != nil {
+		return ErrElectorStarted
+	}
+	defaultElector = NewElector(backend, opts...)
+	defaultElector.Start()
+	return nil
+}
+
+// Stop 停止全局的Elector
+func Stop() {
+	defaultMux.Lock()
+	elector := defaultElector
+	defaultElector = nil
+	defaultMux.Unlock()
+	if elector != nil {
+		elector.Stop()
+	}
+}
+
+// GetElector 获取全局的Elector，未启动时返回nil
+func GetElector() *Elector {
+	defaultMux.RLock()
+	defer defaultMux.RUnlock()
+	return defaultElector
+}
+
+// CurrentMaster 全局Elector选出的master，未启动选主或当前没有master时返回false
+func CurrentMaster() (Member, bool) {
+	elector := GetElector()
+	if elector == nil {
+		return Member{}, false
+	}
+	return elector.Master()
+}
@@ -0,0 +1,134 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryBackend 内存实现的选主后端，master不会自动过期，由测试控制
+type memoryBackend struct {
+	mux     sync.Mutex
+	masters map[string]Member
+	err     error
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{masters: make(map[string]Member)}
+}
+
+func (m *memoryBackend) Campaign(ctx context.Context, namespace string, self Member, ttl time.Duration) (Member, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.err != nil {
+		return Member{}, m.err
+	}
+	if master, ok := m.masters[namespace]; ok {
+		return master, nil
+	}
+	m.masters[namespace] = self
+	return self, nil
+}
+
+func (m *memoryBackend) Master(ctx context.Context, namespace string) (Member, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.masters[namespace], m.err
+}
+
+func (m *memoryBackend) Resign(ctx context.Context, namespace string, self Member) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.masters[namespace] == self {
+		delete(m.masters, namespace)
+	}
+	return nil
+}
+
+func (m *memoryBackend) expire(namespace string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.masters, namespace)
+}
+
+func TestElector_Failover(t *testing.T) {
+	backend := newMemoryBackend()
+	a := Member{Host: "10.0.0.1", Port: 8719}
+	b := Member{Host: "10.0.0.2", Port: 8719}
+	var roles []bool
+	electorA := NewElector(backend, WithNamespace("ns"), WithSelf(a), WithTTL(time.Minute))
+	electorB := NewElector(backend, WithNamespace("ns"), WithSelf(b), WithTTL(time.Minute), WithListener(func(isMaster bool, master Member) {
+		roles = append(roles, isMaster)
+	}))
+	follower := NewElector(backend, WithNamespace("ns"), WithSelf(Member{Host: "10.0.0.3", Port: 8719}), WithCandidate(false))
+
+	electorA.Start()
+	electorB.Start()
+	follower.Start()
+	defer electorB.Stop()
+	defer follower.Stop()
+
+	assert.True(t, electorA.IsMaster())
+	assert.False(t, electorB.IsMaster())
+	master, ok := electorB.Master()
+	assert.True(t, ok)
+	assert.Equal(t, a, master)
+	master, _ = follower.Master()
+	assert.Equal(t, a, master)
+
+	// master主动放弃后由其他实例接管
+	electorA.Stop()
+	assert.False(t, electorA.IsMaster())
+	electorB.refresh()
+	assert.True(t, electorB.IsMaster())
+	follower.refresh()
+	master, _ = follower.Master()
+	assert.Equal(t, b, master)
+	assert.Equal(t, []bool{false, true}, roles)
+
+	// master租约过期后被其他实例抢占
+	backend.expire("ns")
+	_, _ = backend.Campaign(context.Background(), "ns", a, time.Minute)
+	electorB.refresh()
+	assert.False(t, electorB.IsMaster())
+	assert.Equal(t, []bool{false, true, false}, roles)
+}
+
+func TestElector_BackendError(t *testing.T) {
+	backend := newMemoryBackend()
+	self := Member{Host: "10.0.0.1", Port: 8719}
+	elector := NewElector(backend, WithNamespace("ns"), WithSelf(self), WithTTL(30*time.Millisecond))
+	elector.refresh()
+	assert.True(t, elector.IsMaster())
+
+	// 短暂的错误不影响当前master
+	backend.err = errors.New("unavailable")
+	elector.refresh()
+	assert.True(t, elector.IsMaster())
+
+	// 超过TTL仍无法访问Backend时不再信任本地记录的master
+	time.Sleep(50 * time.Millisecond)
+	elector.refresh()
+	assert.False(t, elector.IsMaster())
+	_, ok := elector.Master()
+	assert.False(t, ok)
+}
+
+func TestStart(t *testing.T) {
+	_, ok := CurrentMaster()
+	assert.False(t, ok)
+	assert.Equal(t, ErrNilBackend, Start(nil))
+
+	master := Member{Host: "10.0.0.9", Port: 8719}
+	assert.Nil(t, Start(NewStaticBackend(master), WithSelf(Member{Host: "10.0.0.1", Port: 8719})))
+	defer Stop()
+	assert.Equal(t, ErrElectorStarted, Start(NewStaticBackend(master)))
+	current, ok := CurrentMaster()
+	assert.True(t, ok)
+	assert.Equal(t, master, current)
+	assert.False(t, GetElector().IsMaster())
+}
@@ -0,0 +1,119 @@
+package etcdv3
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/election"
+	etcdClient "github.com/liuhailove/gmiter/util/etcd"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// Backend 基于etcd租约的选主后端，master写入绑定租约的key，租约过期后key被删除，由其他实例竞选
+type Backend struct {
+	client *etcdClient.Client
+	prefix string
+
+	mux sync.Mutex
+	// leaseId 当前持有的租约，0表示未持有
+	leaseId int64
+}
+
+// NewBackend 创建etcd选主后端，endpoints为逗号分隔的地址列表，key前缀为config.EtcdDatasourceKeyPrefix()
+func NewBackend(endpoints string) (*Backend, error) {
+	client, err := etcdClient.NewClient(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	return NewBackendWithClient(client, config.EtcdDatasourceKeyPrefix()), nil
+}
+
+func NewBackendWithClient(client *etcdClient.Client, prefix string) *Backend {
+	return &Backend{client: client, prefix: prefix}
+}
+
+func (b *Backend) key(namespace string) string {
+	return b.prefix + "/election/" + namespace
+}
+
+func (b *Backend) Campaign(ctx context.Context, namespace string, self election.Member, ttl time.Duration) (election.Member, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	kv, _, err := b.client.Get(ctx, b.key(namespace))
+	if err != nil {
+		return election.Member{}, err
+	}
+	if kv != nil {
+		master, err := decodeMember(kv.Value)
+		if err != nil || master != self {
+			return master, err
+		}
+		// 进程重启后不再持有原租约，等待原key过期后重新竞选
+		if b.leaseId == 0 {
+			return master, nil
+		}
+		if err = b.client.KeepAliveOnce(ctx, b.leaseId); err != nil {
+			b.leaseId = 0
+			return election.Member{}, err
+		}
+		return master, nil
+	}
+	if b.leaseId == 0 {
+		if b.leaseId, err = b.client.Grant(ctx, int64(math.Ceil(ttl.Seconds()))); err != nil {
+			return election.Member{}, err
+		}
+	}
+	value, err := jsonTraffic.Marshal(self)
+	if err != nil {
+		return election.Member{}, err
+	}
+	ok, current, err := b.client.PutIfAbsent(ctx, b.key(namespace), value, b.leaseId)
+	if err != nil {
+		// 租约可能已过期，下次竞选时重新申请
+		b.leaseId = 0
+		return election.Member{}, err
+	}
+	if ok {
+		return self, nil
+	}
+	if current == nil {
+		return election.Member{}, nil
+	}
+	return decodeMember(current.Value)
+}
+
+func (b *Backend) Master(ctx context.Context, namespace string) (election.Member, error) {
+	kv, _, err := b.client.Get(ctx, b.key(namespace))
+	if err != nil || kv == nil {
+		return election.Member{}, err
+	}
+	return decodeMember(kv.Value)
+}
+
+func (b *Backend) Resign(ctx context.Context, namespace string, self election.Member) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	value, err := jsonTraffic.Marshal(self)
+	if err != nil {
+		return err
+	}
+	b.leaseId = 0
+	_, err = b.client.DeleteIfEqual(ctx, b.key(namespace), value)
+	return err
+}
+
+func decodeMember(data []byte) (election.Member, error) {
+	var member election.Member
+	if err := jsonTraffic.Unmarshal(data, &member); err != nil {
+		return election.Member{}, err
+	}
+	return member, nil
+}
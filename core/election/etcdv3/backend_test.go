@@ -0,0 +1,167 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/election"
+	etcdClient "github.com/liuhailove/gmiter/util/etcd"
+)
+
+// fakeEtcd 模拟 etcd v3 gRPC-gateway 中选主用到的接口
+type fakeEtcd struct {
+	mux       sync.Mutex
+	kvs       map[string]string
+	keyLeases map[string]int64
+	leases    map[int64]bool
+	nextLease int64
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string]string), keyLeases: make(map[string]int64), leases: make(map[int64]bool)}
+}
+
+// expireLeases 所有租约过期，绑定租约的key被删除
+func (f *fakeEtcd) expireLeases() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for key, lease := range f.keyLeases {
+		if lease != 0 {
+			delete(f.kvs, key)
+			delete(f.keyLeases, key)
+		}
+	}
+	f.leases = make(map[int64]bool)
+}
+
+func decodeKey(v interface{}) string {
+	b, _ := base64.StdEncoding.DecodeString(v.(string))
+	return string(b)
+}
+
+func (f *fakeEtcd) rangeResult(key string) map[string]interface{} {
+	rsp := map[string]interface{}{}
+	if value, ok := f.kvs[key]; ok {
+		rsp["kvs"] = []map[string]interface{}{{"key": []byte(key), "value": []byte(value)}}
+	}
+	return rsp
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	var rsp interface{}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		rsp = f.rangeResult(decodeKey(req["key"]))
+	case "/v3/lease/grant":
+		f.nextLease++
+		f.leases[f.nextLease] = true
+		rsp = map[string]string{"ID": strconv.FormatInt(f.nextLease, 10), "TTL": "1"}
+	case "/v3/lease/keepalive":
+		id := int64(req["ID"].(float64))
+		ttl := "0"
+		if f.leases[id] {
+			ttl = "1"
+		}
+		rsp = map[string]interface{}{"result": map[string]string{"ID": strconv.FormatInt(id, 10), "TTL": ttl}}
+	case "/v3/kv/txn":
+		cmp := req["compare"].([]interface{})[0].(map[string]interface{})
+		key := decodeKey(cmp["key"])
+		value, exists := f.kvs[key]
+		var succeeded bool
+		switch cmp["target"] {
+		case "CREATE":
+			succeeded = !exists
+		case "VALUE":
+			succeeded = exists && value == decodeKey(cmp["value"])
+		}
+		if !succeeded {
+			rsp = map[string]interface{}{"succeeded": false, "responses": []map[string]interface{}{{"response_range": f.rangeResult(key)}}}
+			break
+		}
+		op := req["success"].([]interface{})[0].(map[string]interface{})
+		if put, ok := op["request_put"].(map[string]interface{}); ok {
+			lease, _ := put["lease"].(float64)
+			if lease != 0 && !f.leases[int64(lease)] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.kvs[key] = decodeKey(put["value"])
+			f.keyLeases[key] = int64(lease)
+		} else {
+			delete(f.kvs, key)
+			delete(f.keyLeases, key)
+		}
+		rsp = map[string]interface{}{"succeeded": true}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(rsp)
+}
+
+func TestBackend_Campaign(t *testing.T) {
+	etcd := newFakeEtcd()
+	server := httptest.NewServer(etcd)
+	defer server.Close()
+	newBackend := func() *Backend {
+		client, err := etcdClient.NewClient(server.URL)
+		assert.Nil(t, err)
+		return NewBackendWithClient(client, "test")
+	}
+	backendA, backendB := newBackend(), newBackend()
+	a := election.Member{Host: "10.0.0.1", Port: 8719}
+	b := election.Member{Host: "10.0.0.2", Port: 8719}
+	ctx := context.Background()
+
+	master, err := backendA.Campaign(ctx, "ns", a, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+	master, err = backendB.Campaign(ctx, "ns", b, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+	// master续约
+	master, err = backendA.Campaign(ctx, "ns", a, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+	master, err = backendB.Master(ctx, "ns")
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+
+	t.Run("LeaseExpired", func(t *testing.T) {
+		etcd.expireLeases()
+		master, err = backendB.Campaign(ctx, "ns", b, 3*time.Second)
+		assert.Nil(t, err)
+		assert.Equal(t, b, master)
+		master, err = backendA.Campaign(ctx, "ns", a, 3*time.Second)
+		assert.Nil(t, err)
+		assert.Equal(t, b, master)
+	})
+
+	t.Run("Resign", func(t *testing.T) {
+		// 非master放弃不影响当前master
+		assert.Nil(t, backendA.Resign(ctx, "ns", a))
+		master, _ = backendA.Master(ctx, "ns")
+		assert.Equal(t, b, master)
+
+		assert.Nil(t, backendB.Resign(ctx, "ns", b))
+		master, err = backendA.Master(ctx, "ns")
+		assert.Nil(t, err)
+		assert.True(t, master.IsEmpty())
+		master, err = backendA.Campaign(ctx, "ns", a, 3*time.Second)
+		assert.Nil(t, err)
+		assert.Equal(t, a, master)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/liuhailove/gmiter/core/base"
@@ -24,9 +25,20 @@ const (
 )
 
 var (
+	// TokenClient 默认的HTTP TokenClient
+	//
+	// Deprecated: 使用GetClusterTokenClient获取集群限流当前使用的TokenClient，使用SetClusterTokenClient替换
 	TokenClient *DefaultClusterTokenClient
+
+	// tokenClient 集群限流使用的TokenClient，默认通过HTTP请求TokenServer，保存的值为tokenClientHolder
+	tokenClient atomic.Value
 )
 
+// tokenClientHolder atomic.Value 要求每次写入的具体类型一致，这里统一包装一层
+type tokenClientHolder struct {
+	client ClusterTokenClient
+}
+
 func init() {
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -52,9 +64,47 @@ func init() {
 	TokenClient = &DefaultClusterTokenClient{
 		httpClient: httpClient,
 	}
+	SetClusterTokenClient(TokenClient)
 }
 
+// ClusterTokenClient 集群限流客户端，向TokenServer请求Token
 type ClusterTokenClient interface {
+	// RequestToken 从TokenServer请求tokens
+	//
+	// @param tokenServerIp TokenServer的IP
+	// @param tokenServerPort TokenServer的端口
+	// @param ruleId 唯一规则ID
+	// @param acquireCount 请求token的数量
+	// @param prioritized 请求是否需要优先处理
+	// @return token请求处理结果
+	RequestToken(tokenServerIp string, tokenServerPort int32, ruleId string, acquireCount uint32, prioritized int32) *base.TokResult
+}
+
+// RuleTokenService 可直接使用已加载的规则请求Token的TokenService，由base.TokenService的实现可选实现，
+// 避免每次请求都解析规则json
+type RuleTokenService interface {
+	RequestTokenOfRule(rule *Rule, acquireCount uint32, prioritized int32) *base.TokResult
+}
+
+// RequestTokenWithRule 使用已加载的规则向service请求Token，service未实现RuleTokenService时使用规则json请求
+func RequestTokenWithRule(service base.TokenService, rule *Rule, ruleData string, acquireCount uint32, prioritized int32) *base.TokResult {
+	if s, ok := service.(RuleTokenService); ok {
+		return s.RequestTokenOfRule(rule, acquireCount, prioritized)
+	}
+	return service.RequestToken(ruleData, acquireCount, prioritized)
+}
+
+// SetClusterTokenClient 替换集群限流使用的TokenClient
+func SetClusterTokenClient(client ClusterTokenClient) {
+	if client == nil {
+		return
+	}
+	tokenClient.Store(tokenClientHolder{client: client})
+}
+
+// GetClusterTokenClient 返回集群限流当前使用的TokenClient
+func GetClusterTokenClient() ClusterTokenClient {
+	return tokenClient.Load().(tokenClientHolder).client
 }
 
 // DefaultClusterTokenClient 默认TokenClient
@@ -73,32 +123,32 @@ func (d *DefaultClusterTokenClient) RequestToken(tokenServerIp string, tokenServ
 		return base.BadResult
 	}
 	// 获取集群规则
-	var tcController = getTrafficControllerFor(ruleId)
-	if tcController == nil {
+	var rule, ruleData, version = ClusterRuleOf(ruleId)
+	if rule == nil {
 		return base.NoRuleExistsResult
 	}
-	var err error
-	var ruleData []byte
-	ruleData, err = jsonTraffic.Marshal(tcController.rule)
-	if err != nil {
-		logging.Error(err, "unmarshal failed")
-		return base.BadResult
-	}
 	// 如果请求的IP和port和本机相等，说明自身就是master，不需要在经过网络
 	if tokenServerIp == util.GetIP() && strconv.Itoa(int(tokenServerPort)) == config.GetPort() {
 		var inst = spi.GetRegisterTokenServiceInst(constants.DefaultTokenServiceType)
 		if inst != nil {
-			return inst.GetTokenService().RequestToken(string(ruleData), acquireCount, prioritized)
+			return RequestTokenWithRule(inst.GetTokenService(), rule, ruleData, acquireCount, prioritized)
 		}
 	}
-	// 组装请求
+	// 组装请求，只携带规则ID及版本号，master不存在该版本的规则时再携带规则json重试
 	var requestData = make(map[string]string)
-	requestData["rule"] = string(ruleData)
+	requestData["ruleId"] = ruleId
+	requestData["version"] = strconv.FormatUint(version, 10)
 	requestData["acquireCount"] = strconv.Itoa(int(acquireCount))
 	requestData["prioritized"] = strconv.Itoa(int(prioritized))
-	var tokenResult, err2 = d.sendTokenRequest(tokenServerIp, tokenServerPort, AcquireClusterTokenPath, requestData)
-	if err2 != nil {
-		logging.Error(err2, "sendTokenRequest error", "tokenServerIp", tokenServerIp, "tokenServerPort", tokenServerPort, "ruleId", ruleId, "acquireCount", acquireCount)
+	var tokenResult, err = d.sendTokenRequest(tokenServerIp, tokenServerPort, AcquireClusterTokenPath, requestData)
+	if err == nil && tokenResult.Status == int(base.TokResultStatusNoRuleExists) {
+		delete(requestData, "ruleId")
+		delete(requestData, "version")
+		requestData["rule"] = ruleData
+		tokenResult, err = d.sendTokenRequest(tokenServerIp, tokenServerPort, AcquireClusterTokenPath, requestData)
+	}
+	if err != nil {
+		logging.Error(err, "sendTokenRequest error", "tokenServerIp", tokenServerIp, "tokenServerPort", tokenServerPort, "ruleId", ruleId, "acquireCount", acquireCount)
 		return base.FailResult
 	}
 	logging.Info("tokenResult", "tokenResult", tokenResult)
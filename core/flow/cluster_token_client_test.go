@@ -0,0 +1,96 @@
+package flow
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+type fakeClusterTokenClient struct{}
+
+func (f *fakeClusterTokenClient) RequestToken(string, int32, string, uint32, int32) *base.TokResult {
+	return base.StatusOkResult
+}
+
+func TestSetClusterTokenClient(t *testing.T) {
+	defaultClient := GetClusterTokenClient()
+	defer SetClusterTokenClient(defaultClient)
+	assert.IsType(t, &DefaultClusterTokenClient{}, defaultClient)
+	assert.Equal(t, TokenClient, defaultClient)
+
+	// 替换与读取并发执行
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetClusterTokenClient(&fakeClusterTokenClient{})
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NotNil(t, GetClusterTokenClient())
+		}()
+	}
+	wg.Wait()
+	assert.IsType(t, &fakeClusterTokenClient{}, GetClusterTokenClient())
+
+	// 忽略nil
+	SetClusterTokenClient(nil)
+	assert.IsType(t, &fakeClusterTokenClient{}, GetClusterTokenClient())
+}
+
+func TestDefaultClusterTokenClient_RequestToken(t *testing.T) {
+	rule := &Rule{
+		ID:               "http-1",
+		Resource:         "http-token-client",
+		Threshold:        10,
+		StatIntervalInMs: 1000,
+		ClusterMode:      true,
+		ClusterConfig:    &ClusterConfig{ClusterStrategy: int32(ThresholdGlobal), GlobalThreshold: 10},
+	}
+	_, err := LoadRules([]*Rule{rule})
+	assert.Nil(t, err)
+	defer ClearRules()
+	loaded, ruleData, version := ClusterRuleOf("http-1")
+	assert.NotNil(t, loaded)
+	assert.Equal(t, RuleVersion(ruleData), version)
+	_, _, sameVersion := ClusterRuleOf("http-1")
+	assert.Equal(t, version, sameVersion)
+
+	// master只认识旧版本的规则，客户端携带规则json重试
+	var requests []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]string{}
+		for k, v := range r.URL.Query() {
+			params[k] = v[0]
+		}
+		requests = append(requests, params)
+		if params["rule"] == "" && params["version"] != strconv.FormatUint(version, 10) {
+			_, _ = w.Write([]byte(base.NoRuleExistsResult.TokenToThinString()))
+			return
+		}
+		_, _ = w.Write([]byte(base.StatusOkResult.TokenToThinString()))
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	client := &DefaultClusterTokenClient{httpClient: http.Client{}}
+	assert.Equal(t, base.NoRuleExistsResult, client.RequestToken(host, int32(portNum), "absent", 1, 0))
+	assert.Equal(t, int(base.TokResultStatusOk), client.RequestToken(host, int32(portNum), "http-1", 1, 0).Status)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "http-1", requests[0]["ruleId"])
+	assert.Empty(t, requests[0]["rule"])
+
+	version++
+	assert.Equal(t, int(base.TokResultStatusOk), client.RequestToken(host, int32(portNum), "http-1", 1, 0).Status)
+	assert.Len(t, requests, 3)
+	assert.Equal(t, ruleData, requests[2]["rule"])
+}
@@ -27,17 +27,21 @@ func (d *DefaultTokenService) RequestToken(rule string, acquireCount uint32, pri
 	return acquireClusterToken(ru, acquireCount, prioritized)
 }
 
+// RequestTokenOfRule 使用已加载的规则请求Token，不需要解析规则json
+func (d *DefaultTokenService) RequestTokenOfRule(rule *Rule, acquireCount uint32, prioritized int32) *base.TokResult {
+	return acquireClusterToken(rule, acquireCount, prioritized)
+}
+
 func (d *DefaultTokenService) RequestParamToken(rule string, acquireCount uint32, params []interface{}) *base.TokResult {
 	panic("implement me")
 }
 
+// RequestConcurrentToken TokenServer暂不支持集群并发Token，返回失败由调用方按FallbackToLocalWhenFail处理
 func (d *DefaultTokenService) RequestConcurrentToken(rule string, acquireCount uint32) *base.TokResult {
-	panic("implement me")
+	return base.FailResult
 }
 
 func (d *DefaultTokenService) ReleaseConcurrentToken(rule string, tokenId string) {
-	//TODO implement me
-	panic("implement me")
 }
 
 func (d *DefaultTokenService) notValidRequestSimple(id string, count uint32) bool {
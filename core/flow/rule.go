@@ -16,6 +16,9 @@ const (
 	CurrentResource RelationStrategy = iota
 	// AssociatedResource 表示由关联资源而不是当前资源进行流量控制。
 	AssociatedResource
+	// ChainResource 表示仅对经由入口资源(RefResource)调用的当前资源进行流量控制，
+	// 统计维度为(入口资源, 当前资源)，入口资源通过api.WithEntrance或api.WithParentEntry传递。
+	ChainResource
 )
 
 // TokenServerStrategy TokenServer策略
@@ -32,6 +35,8 @@ func (s RelationStrategy) String() string {
 		return "CurrentResource"
 	case AssociatedResource:
 		return "AssociatedResource"
+	case ChainResource:
+		return "ChainResource"
 	default:
 		return "Undefined"
 	}
@@ -98,6 +103,35 @@ func (c ClusterStrategy) String() string {
 	}
 }
 
+// RedisAlgorithm Redis全局限流(ThresholdGlobalRedis)使用的限流算法
+type RedisAlgorithm int32
+
+const (
+	// RedisFixedWindow 固定窗口计数，默认算法，窗口边界处可能出现2倍突发
+	RedisFixedWindow RedisAlgorithm = iota
+	// RedisSlidingLog 滑动日志，记录窗口内的每次请求，精确但占用内存较多
+	RedisSlidingLog
+	// RedisSlidingWindow 滑动窗口计数，按上一窗口的剩余占比加权估算
+	RedisSlidingWindow
+	// RedisTokenBucket 基于GCRA的令牌桶，容量为统计窗口内的阈值
+	RedisTokenBucket
+)
+
+func (a RedisAlgorithm) String() string {
+	switch a {
+	case RedisFixedWindow:
+		return "FixedWindow"
+	case RedisSlidingLog:
+		return "SlidingLog"
+	case RedisSlidingWindow:
+		return "SlidingWindow"
+	case RedisTokenBucket:
+		return "TokenBucket"
+	default:
+		return "Undefined"
+	}
+}
+
 // ClusterConfig 集群流控配置
 type ClusterConfig struct {
 	// FlowId 全局流控ID
@@ -134,6 +168,9 @@ type ClusterConfig struct {
 	TokenServerMasterHost string `json:"tokenServerMasterHost"`
 	// TokenServerMasterPort 选主的master port
 	TokenServerMasterPort int32 `json:"tokenServerMasterPort"`
+	// RedisAlgorithm Redis全局限流算法，仅在ClusterStrategy为FLOW_THRESHOLD_GLOBAL_REDIS时生效，
+	// 窗口大小取规则的StatIntervalInMs，ControlBehavior为Throttling时最多排队MaxQueueingTimeMs，FixedWindow不支持Throttling
+	RedisAlgorithm RedisAlgorithm `json:"redisAlgorithm"`
 }
 
 // Rule 描述流控策略，流控策略基于QPS统计指标
@@ -157,12 +194,13 @@ type Rule struct {
 	// If StatIntervalInMs is 1000(1 second), Threshold means QPS
 	Threshold        float64          `json:"threshold"`
 	RelationStrategy RelationStrategy `json:"relationStrategy"`
-	RefResource      string           `json:"refResource"`
+	// RefResource RelationStrategy为AssociatedResource时为关联资源，为ChainResource时为入口资源
+	RefResource string `json:"refResource"`
 	// MaxQueueingTimeMs only takes effect when ControlBehavior is Throttling.
 	// When MaxQueueingTimeMs is 0, it means Throttling only controls interval of requests,
 	// and requests exceeding the threshold will be rejected directly.
 	MaxQueueingTimeMs uint32 `json:"maxQueueingTimeMs"`
-	// 预热时间
+	// 预热时间，ControlBehavior为Throttling时请求间隔在预热时间内由冷启动间隔逐步缩短到稳定间隔
 	WarmUpPeriodSec uint32 `json:"warmUpPeriodSec"`
 	// 预热期内的令牌生产减缓因子，固定值3
 	WarmUpColdFactor uint32 `json:"warmUpColdFactor"`
@@ -229,7 +267,8 @@ func (r *Rule) isEqualsTo(newRule *Rule) bool {
 			rClusterConfig.DowngradeDurationInMs == nClusterConfig.DowngradeDurationInMs &&
 			rClusterConfig.MasterNodeThreshold == nClusterConfig.MasterNodeThreshold &&
 			rClusterConfig.TokenServerMasterHost == nClusterConfig.TokenServerMasterHost &&
-			rClusterConfig.TokenServerMasterPort == nClusterConfig.TokenServerMasterPort) {
+			rClusterConfig.TokenServerMasterPort == nClusterConfig.TokenServerMasterPort &&
+			rClusterConfig.RedisAlgorithm == nClusterConfig.RedisAlgorithm) {
 			return false
 		}
 	}
@@ -248,7 +287,8 @@ func (r *Rule) isStatReusable(newRule *Rule) bool {
 }
 
 func (r *Rule) needStatistic() bool {
-	return r.TokenCalculateStrategy == WarmUp || r.ControlBehavior == Reject
+	// WarmUp+Throttling及其他Throttling组合不依赖统计
+	return r.ControlBehavior == Reject
 }
 
 func (r *Rule) isClusterMode() bool {
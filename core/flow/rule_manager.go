@@ -104,20 +104,14 @@ func init() {
 	tcGenFuncMap[trafficControllerGenKey{
 		tokenCalculateStrategy: WarmUp,
 		controlBehavior:        Throttling,
-	}] = func(rule *Rule, boundStat *standaloneStatistic) (*TrafficShapingController, error) {
-		if boundStat == nil {
-			var err error
-			boundStat, err = generateStatFor(rule)
-			if err != nil {
-				return nil, err
-			}
-		}
-		tsc, err := NewTrafficShapingController(rule, boundStat)
+	}] = func(rule *Rule, _ *standaloneStatistic) (*TrafficShapingController, error) {
+		// 预热匀速排队由checker自行计算请求间隔，不依赖统计，这里直接使用nop stat
+		tsc, err := NewTrafficShapingController(rule, nopStat)
 		if err != nil || tsc == nil {
 			return nil, err
 		}
-		tsc.flowCalculator = NewWarmUpTrafficShapingCalculator(tsc, rule)
-		tsc.flowChecker = NewThrottlingChecker(tsc, rule.MaxQueueingTimeMs, rule.StatIntervalInMs)
+		tsc.flowCalculator = NewDirectTrafficShapingCalculator(tsc, rule.Threshold)
+		tsc.flowChecker = NewWarmUpThrottlingChecker(tsc, rule)
 		return tsc, nil
 	}
 	tcGenFuncMap[trafficControllerGenKey{
@@ -213,9 +207,6 @@ func onRuleUpdate(rawResRulesMap map[string][]*Rule) (err error) {
 	// 按照ResourceId的Map
 	mId := make(TrafficControllerIdMap, len(validResRulesMap))
 
-	// 按照Resource的集群MetricMap
-	rClusterMap := make(ResourceClusterMap, len(validResRulesMap))
-
 	for res, rulesOfRes := range validResRulesMap {
 		newTcsOfRes := buildResourceTrafficShapingController(res, rulesOfRes, tcMapClone[res])
 		if len(newTcsOfRes) > 0 {
@@ -223,38 +214,6 @@ func onRuleUpdate(rawResRulesMap map[string][]*Rule) (err error) {
 
 			for _, v := range newTcsOfRes {
 				mId[v.rule.ID] = v
-				// 集群metric初始化
-				if v.rule.isClusterMode() {
-					var statIntervalNs int64
-					if v.rule.StatIntervalInMs == 0 {
-						statIntervalNs = 1000 * MillisToNanosOffset
-					} else {
-						statIntervalNs = int64(v.rule.StatIntervalInMs) * MillisToNanosOffset
-					}
-					var maxQueueingTimeNs = int64(v.rule.MaxQueueingTimeMs) * MillisToNanosOffset
-
-					if v.rule.RelationStrategy == AssociatedResource {
-						var oldClusterMetric = rClusterMap[v.rule.RefResource]
-						if oldClusterMetric != nil {
-							// maxQueueTime需要取最小的存入
-							if oldClusterMetric.maxQueueingTimeNs > maxQueueingTimeNs || oldClusterMetric.statIntervalNs != statIntervalNs {
-								rClusterMap[v.rule.RefResource] = NewClusterMetric(config.MetricStatisticSampleCount(), config.MetricStatisticIntervalMs(), maxQueueingTimeNs, statIntervalNs)
-							}
-						} else {
-							rClusterMap[v.rule.RefResource] = NewClusterMetric(config.MetricStatisticSampleCount(), config.MetricStatisticIntervalMs(), maxQueueingTimeNs, statIntervalNs)
-						}
-					} else {
-						var oldClusterMetric = rClusterMap[v.rule.Resource]
-						if oldClusterMetric != nil {
-							// maxQueueTime需要取最小的存入
-							if oldClusterMetric.maxQueueingTimeNs > maxQueueingTimeNs || oldClusterMetric.statIntervalNs != statIntervalNs {
-								rClusterMap[v.rule.Resource] = NewClusterMetric(config.MetricStatisticSampleCount(), config.MetricStatisticIntervalMs(), maxQueueingTimeNs, statIntervalNs)
-							}
-						} else {
-							rClusterMap[v.rule.Resource] = NewClusterMetric(config.MetricStatisticSampleCount(), config.MetricStatisticIntervalMs(), maxQueueingTimeNs, statIntervalNs)
-						}
-					}
-				}
 			}
 		}
 	}
@@ -262,8 +221,9 @@ func onRuleUpdate(rawResRulesMap map[string][]*Rule) (err error) {
 	tcMux.Lock()
 	tcMap = m
 	tcIdMap = mId
-	resClusterMap = rClusterMap
+	resClusterMap = buildClusterMetrics(m, resClusterMap)
 	tcMux.Unlock()
+	retainEntranceNodes()
 	currentRules = rawResRulesMap
 	logging.Debug("[Flow onRuleUpdate] Time statistic(ns) for updating flow rule", "timeCost", util.CurrentTimeNano()-start)
 	logRuleUpdate(validResRulesMap)
@@ -335,7 +295,9 @@ func onResourceRuleUpdate(res string, rawResRules []*Rule) (err error) {
 			tcIdMap[v.rule.ID] = v
 		}
 	}
+	resClusterMap = buildClusterMetrics(tcMap, resClusterMap)
 	tcMux.Unlock()
+	retainEntranceNodes()
 	currentRules[res] = rawResRules
 	logging.Debug("[Flow onResourceRuleUpdate] Time statistic(ns) for updating flow rule", "timeCost", util.CurrentTimeNano()-start)
 	logging.Info("[Flow] load resource level rules", "resource", res, "validResRules", validResRules)
@@ -363,7 +325,9 @@ func LoadRulesOfResource(res string, rules []*Rule) (bool, error) {
 				delete(tcIdMap, k)
 			}
 		}
+		resClusterMap = buildClusterMetrics(tcMap, resClusterMap)
 		tcMux.Unlock()
+		retainEntranceNodes()
 		logging.Info("[Flow] clear resource level rules", "resource", res)
 		return true, nil
 	}
@@ -424,6 +388,31 @@ func GetRulesOfResource(res string) []Rule {
 	return ret
 }
 
+// GetRuleOfId returns the rule of the given id based on copy, nil if absent.
+func GetRuleOfId(id string) *Rule {
+	tc := getTrafficControllerFor(id)
+	if tc == nil {
+		return nil
+	}
+	rule := *tc.BoundRule()
+	return &rule
+}
+
+// ClusterRuleOf 返回id对应的规则及其json、版本号，规则不存在时返回nil。
+// 返回的规则为已加载的规则本身，调用方不可修改；集群TokenClient只携带规则ID及版本号，
+// TokenServer缺少规则或版本不一致时才同步规则json
+func ClusterRuleOf(id string) (*Rule, string, uint64) {
+	tc := getTrafficControllerFor(id)
+	if tc == nil {
+		return nil, "", 0
+	}
+	data, version := tc.boundRuleData()
+	if data == "" {
+		return nil, "", 0
+	}
+	return tc.BoundRule(), data, version
+}
+
 // ClearRules clears all the rules in flow module.
 func ClearRules() error {
 	_, err := LoadRules(nil)
@@ -455,6 +444,21 @@ func rulesFrom(m TrafficControllerMap) []*Rule {
 	return rules
 }
 
+// retainEntranceNodes 仅保留已加载的链路规则引用的入口统计节点
+func retainEntranceNodes() {
+	refs := make([]stat.EntranceResource, 0)
+	tcMux.RLock()
+	for _, tcs := range tcMap {
+		for _, tc := range tcs {
+			if tc.rule.RelationStrategy == ChainResource {
+				refs = append(refs, stat.EntranceResource{Entrance: tc.rule.RefResource, Resource: tc.rule.Resource})
+			}
+		}
+	}
+	tcMux.RUnlock()
+	stat.RetainEntranceNodes(refs)
+}
+
 func generateStatFor(rule *Rule) (*standaloneStatistic, error) {
 	if !rule.needStatistic() {
 		return nopStat, nil
@@ -468,6 +472,9 @@ func generateStatFor(rule *Rule) (*standaloneStatistic, error) {
 	if rule.RelationStrategy == AssociatedResource {
 		// use associated statistic
 		resNode = stat.GetOrCreateResourceNode(rule.RefResource, base.ResTypeCommon)
+	} else if rule.RelationStrategy == ChainResource {
+		// use the statistic of resource under the entrance
+		resNode = stat.GetOrCreateEntranceNode(rule.RefResource, rule.Resource, base.ResTypeCommon)
 	} else {
 		resNode = stat.GetOrCreateResourceNode(rule.Resource, base.ResTypeCommon)
 	}
@@ -568,6 +575,39 @@ func getTrafficControllerFor(id string) *TrafficShapingController {
 	return tcIdMap[id]
 }
 
+// buildClusterMetrics 为集群模式的规则建立集群metric，关联资源流控按照RefResource统计，
+// 同一资源的多条规则使用最小的maxQueueingTime；参数不变的metric沿用old中的统计。调用方需持有tcMux写锁
+func buildClusterMetrics(m TrafficControllerMap, old ResourceClusterMap) ResourceClusterMap {
+	rClusterMap := make(ResourceClusterMap, len(old))
+	for _, tcs := range m {
+		for _, tc := range tcs {
+			if !tc.rule.isClusterMode() {
+				continue
+			}
+			var statIntervalNs int64
+			if tc.rule.StatIntervalInMs == 0 {
+				statIntervalNs = 1000 * MillisToNanosOffset
+			} else {
+				statIntervalNs = int64(tc.rule.StatIntervalInMs) * MillisToNanosOffset
+			}
+			var maxQueueingTimeNs = int64(tc.rule.MaxQueueingTimeMs) * MillisToNanosOffset
+			var res = tc.rule.Resource
+			if tc.rule.RelationStrategy == AssociatedResource {
+				res = tc.rule.RefResource
+			}
+			if cm := rClusterMap[res]; cm != nil && cm.maxQueueingTimeNs <= maxQueueingTimeNs && cm.statIntervalNs == statIntervalNs {
+				continue
+			}
+			if cm := old[res]; cm != nil && cm.maxQueueingTimeNs == maxQueueingTimeNs && cm.statIntervalNs == statIntervalNs {
+				rClusterMap[res] = cm
+				continue
+			}
+			rClusterMap[res] = NewClusterMetric(config.MetricStatisticSampleCount(), config.MetricStatisticIntervalMs(), maxQueueingTimeNs, statIntervalNs)
+		}
+	}
+	return rClusterMap
+}
+
 func getClusterMetric(res string) *ClusterMetric {
 	tcMux.RLock()
 	defer tcMux.RUnlock()
@@ -670,12 +710,15 @@ func IsValidRule(rule *Rule) error {
 			return errors.New("invalid MaxQueueingTimeMs for ControlBehavior, MaxQueueingTimeMs must be great than 0 and Threshold must be great than 0 and MaxQueueingTimeMs  must be great than 1/Threshold")
 		}
 	}
-	if !(rule.RelationStrategy >= CurrentResource && rule.RelationStrategy <= AssociatedResource) {
+	if !(rule.RelationStrategy >= CurrentResource && rule.RelationStrategy <= ChainResource) {
 		return errors.New("invalid RelationStrategy")
 	}
 	if rule.RelationStrategy == AssociatedResource && rule.RefResource == "" {
 		return errors.New("RefResource must be non empty when RelationStrategy is AssociatedResource")
 	}
+	if rule.RelationStrategy == ChainResource && rule.RefResource == "" {
+		return errors.New("RefResource must be non empty when RelationStrategy is ChainResource")
+	}
 	if rule.TokenCalculateStrategy == WarmUp {
 		if rule.WarmUpPeriodSec <= 0 {
 			return errors.New("WarmUpPeriodSec must be great than 0")
@@ -733,6 +776,14 @@ func IsValidRule(rule *Rule) error {
 				//}
 			}
 		}
+		if ThresholdGlobalRedis == ClusterStrategy(clusterConfig.ClusterStrategy) {
+			if clusterConfig.RedisAlgorithm < RedisFixedWindow || clusterConfig.RedisAlgorithm > RedisTokenBucket {
+				return errors.New("invalid redisAlgorithm")
+			}
+			if clusterConfig.RedisAlgorithm == RedisFixedWindow && rule.ControlBehavior == Throttling {
+				return errors.New("redisAlgorithm FixedWindow does not support Throttling, use SlidingLog, SlidingWindow or TokenBucket")
+			}
+		}
 	}
 	return nil
 }
@@ -1 +1,95 @@
 package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/stat"
+)
+
+func TestIsValidRule_RedisAlgorithm(t *testing.T) {
+	newRule := func(algorithm RedisAlgorithm, behavior ControlBehavior) *Rule {
+		return &Rule{
+			Resource:          "abc",
+			ControlBehavior:   behavior,
+			Threshold:         10,
+			MaxQueueingTimeMs: 500,
+			StatIntervalInMs:  1000,
+			ClusterMode:       true,
+			ClusterConfig: &ClusterConfig{
+				ClusterStrategy: int32(ThresholdGlobalRedis),
+				GlobalThreshold: 10,
+				RedisAlgorithm:  algorithm,
+			},
+		}
+	}
+	assert.Nil(t, IsValidRule(newRule(RedisFixedWindow, Reject)))
+	assert.NotNil(t, IsValidRule(newRule(RedisFixedWindow, Throttling)))
+	assert.Nil(t, IsValidRule(newRule(RedisSlidingLog, Throttling)))
+	assert.Nil(t, IsValidRule(newRule(RedisTokenBucket, Throttling)))
+	assert.NotNil(t, IsValidRule(newRule(RedisTokenBucket+1, Reject)))
+}
+
+// 仅为已加载的链路规则引用的(入口资源, 资源)创建入口统计节点，规则移除后节点被移除
+func TestLoadRules_EntranceNodes(t *testing.T) {
+	defer ClearRules()
+	_, err := LoadRules([]*Rule{{
+		Resource:               "chain-node-res",
+		TokenCalculateStrategy: Direct,
+		ControlBehavior:        Reject,
+		RelationStrategy:       ChainResource,
+		RefResource:            "entrance-a",
+		Threshold:              10,
+	}})
+	assert.Nil(t, err)
+	assert.NotNil(t, stat.GetEntranceNode("entrance-a", "chain-node-res"))
+
+	prepare := func(entrance string) base.StatNode {
+		ctx := base.NewSlotChain().GetPooledContext()
+		ctx.Resource = base.NewResourceWrapper("chain-node-res", base.ResTypeCommon, base.Inbound)
+		ctx.Entrance = entrance
+		stat.DefaultResourceNodePrepareSlot.Prepare(ctx)
+		return ctx.EntranceStatNode
+	}
+	assert.NotNil(t, prepare("entrance-a"))
+	assert.Nil(t, prepare("entrance-b"))
+	assert.Nil(t, stat.GetEntranceNode("entrance-b", "chain-node-res"))
+
+	_, err = LoadRulesOfResource("chain-node-res", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, stat.GetEntranceNode("entrance-a", "chain-node-res"))
+	assert.Nil(t, prepare("entrance-a"))
+}
+
+func TestLoadRulesOfResource_ClusterMetric(t *testing.T) {
+	defer ClearRules()
+	newRule := func(id, res string) *Rule {
+		return &Rule{
+			ID:                     id,
+			Resource:               res,
+			TokenCalculateStrategy: Direct,
+			ControlBehavior:        Reject,
+			Threshold:              10,
+			StatIntervalInMs:       1000,
+			ClusterMode:            true,
+			ClusterConfig:          &ClusterConfig{ClusterStrategy: int32(ThresholdGlobal), GlobalThreshold: 10},
+		}
+	}
+	_, err := LoadRules([]*Rule{newRule("cm-1", "cm-res-1")})
+	assert.Nil(t, err)
+	metric := getClusterMetric("cm-res-1")
+	assert.NotNil(t, metric)
+
+	// 按资源加载的集群规则同样建立集群metric，其他资源的metric保持不变
+	_, err = LoadRulesOfResource("cm-res-2", []*Rule{newRule("cm-2", "cm-res-2")})
+	assert.Nil(t, err)
+	assert.NotNil(t, getClusterMetric("cm-res-2"))
+	assert.True(t, metric == getClusterMetric("cm-res-1"))
+
+	_, err = LoadRulesOfResource("cm-res-2", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, getClusterMetric("cm-res-2"))
+	assert.True(t, metric == getClusterMetric("cm-res-1"))
+}
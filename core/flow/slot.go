@@ -3,7 +3,10 @@ package flow
 import (
 	"github.com/liuhailove/gmiter/constants"
 	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/election"
 	"github.com/liuhailove/gmiter/spi"
+	"net"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -72,8 +75,16 @@ func (s Slot) Check(ctx *base.EntryContext) *base.TokenResult {
 		if !needContinueCheck {
 			continue
 		}
+		node := ctx.StatNode
+		// 链路检查，仅对经由指定入口资源的调用生效
+		if tc.BoundRule().RelationStrategy == ChainResource {
+			if ctx.Entrance != tc.BoundRule().RefResource {
+				continue
+			}
+			node = ctx.EntranceStatNode
+		}
 		logging.Debug("flow_slot canPassCheck", "res", res)
-		r := canPassCheck(tc, ctx.StatNode, ctx.Input.BatchCount)
+		r := canPassCheck(tc, node, ctx.Input.BatchCount)
 		if r == nil {
 			// nil means pass
 			continue
@@ -152,11 +163,12 @@ func checkInCluster(tc *TrafficShapingController, resStat base.StatNode, batchCo
 		var inst = spi.GetRegisterTokenServiceInst(constants.RedisTokenServiceType)
 		tokenResult = inst.GetTokenService().RequestToken(string(data), batchCount, flag)
 	case int32(ThresholdGlobal):
+		var host, port = tokenServerOf(tc.rule.ClusterConfig)
 		// 降级处理
-		if tc.rule.ClusterConfig.TokenServerMasterHost == "" || tc.rule.ClusterConfig.TokenServerMasterPort <= 0 {
+		if host == "" || port <= 0 {
 			return fallbackToLocalOrPass(tc, resStat, batchCount, flag)
 		}
-		tokenResult = TokenClient.RequestToken(tc.rule.ClusterConfig.TokenServerMasterHost, tc.rule.ClusterConfig.TokenServerMasterPort, tc.rule.ID, batchCount, flag)
+		tokenResult = GetClusterTokenClient().RequestToken(host, port, tc.rule.ID, batchCount, flag)
 	}
 
 	switch base.TokResultStatus(tokenResult.Status) {
@@ -196,6 +208,26 @@ func fallbackToLocalOrPass(tc *TrafficShapingController, resStat base.StatNode,
 	return nil
 }
 
+// tokenServerOf 获取TokenServer地址，独立TokenServer使用TokenServerAddress，
+// 否则优先使用选举出的master，未启动选主时使用规则中配置的master
+func tokenServerOf(clusterConfig *ClusterConfig) (string, int32) {
+	if TokenServerStrategy(clusterConfig.TokenServerStrategy) == TokenServerStrategyIndependentTokenServer {
+		host, port, err := net.SplitHostPort(clusterConfig.TokenServerAddress)
+		if err != nil {
+			return "", 0
+		}
+		p, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			return "", 0
+		}
+		return host, int32(p)
+	}
+	if master, ok := election.CurrentMaster(); ok {
+		return master.Host, master.Port
+	}
+	return clusterConfig.TokenServerMasterHost, clusterConfig.TokenServerMasterPort
+}
+
 // allowRemoteProceed 是否允许继续请求远程Token
 func allowRemoteProceed() bool {
 	return ClientTryPass(config.ClientNamespace())
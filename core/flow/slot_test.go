@@ -0,0 +1,39 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/election"
+)
+
+func TestTokenServerOf(t *testing.T) {
+	clusterConfig := &ClusterConfig{
+		TokenServerStrategy:   int32(TokenServerStrategyNodeSelectMaster),
+		TokenServerMasterHost: "10.0.0.1",
+		TokenServerMasterPort: 8719,
+	}
+	host, port := tokenServerOf(clusterConfig)
+	assert.Equal(t, "10.0.0.1", host)
+	assert.Equal(t, int32(8719), port)
+
+	// 启动选主后使用选出的master
+	assert.Nil(t, election.Start(election.NewStaticBackend(election.Member{Host: "10.0.0.2", Port: 8720})))
+	host, port = tokenServerOf(clusterConfig)
+	assert.Equal(t, "10.0.0.2", host)
+	assert.Equal(t, int32(8720), port)
+	election.Stop()
+
+	host, port = tokenServerOf(&ClusterConfig{
+		TokenServerStrategy: int32(TokenServerStrategyIndependentTokenServer),
+		TokenServerAddress:  "10.0.0.3:18730",
+	})
+	assert.Equal(t, "10.0.0.3", host)
+	assert.Equal(t, int32(18730), port)
+	host, _ = tokenServerOf(&ClusterConfig{
+		TokenServerStrategy: int32(TokenServerStrategyIndependentTokenServer),
+		TokenServerAddress:  "10.0.0.3",
+	})
+	assert.Equal(t, "", host)
+}
@@ -30,6 +30,10 @@ func (s *StandaloneStatSlot) Initial() {}
 func (s *StandaloneStatSlot) OnEntryPassed(ctx *base.EntryContext) {
 	res := ctx.Resource.Name()
 	for _, tc := range getTrafficControllerListFor(res) {
+		// 链路规则仅统计经由入口资源的调用，与Slot.Check一致
+		if tc.rule.RelationStrategy == ChainResource && ctx.Entrance != tc.rule.RefResource {
+			continue
+		}
 		if !tc.boundStat.reuseResourceStat {
 			if tc.boundStat.writeOnlyMetric != nil {
 				// TODO
@@ -0,0 +1,48 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/stat"
+)
+
+// 链路规则使用独立统计时，只有经由入口资源的调用计入统计
+func TestStandaloneStatSlot_ChainResource(t *testing.T) {
+	defer ClearRules()
+	_, err := LoadRules([]*Rule{{
+		Resource:               "chain-res",
+		TokenCalculateStrategy: Direct,
+		ControlBehavior:        Reject,
+		RelationStrategy:       ChainResource,
+		RefResource:            "entrance-a",
+		Threshold:              2,
+		StatIntervalInMs:       3000,
+	}})
+	assert.Nil(t, err)
+	tcs := getTrafficControllerListFor("chain-res")
+	assert.Equal(t, 1, len(tcs))
+	assert.False(t, tcs[0].boundStat.reuseResourceStat)
+
+	metric := tcs[0].boundStat.readOnlyMetric
+	onPassed := func(entrance string) {
+		ctx := base.NewSlotChain().GetPooledContext()
+		ctx.Resource = base.NewResourceWrapper("chain-res", base.ResTypeCommon, base.Inbound)
+		ctx.Entrance = entrance
+		if entrance != "" {
+			ctx.EntranceStatNode = stat.GetOrCreateEntranceNode(entrance, "chain-res", base.ResTypeCommon)
+		}
+		ctx.Input.BatchCount = 2
+		DefaultStandaloneStatSlot.OnEntryPassed(ctx)
+	}
+
+	onPassed("entrance-b")
+	onPassed("")
+	assert.Equal(t, int64(0), metric.GetSum(base.MetricEventPass))
+	onPassed("entrance-a")
+	assert.Equal(t, int64(2), metric.GetSum(base.MetricEventPass))
+	onPassed("entrance-b")
+	assert.Equal(t, int64(2), metric.GetSum(base.MetricEventPass))
+}
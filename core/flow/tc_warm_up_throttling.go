@@ -0,0 +1,175 @@
+package flow
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+// WarmUpThrottlingChecker 预热匀速排队，请求间隔在WarmUpPeriodSec内由冷启动间隔(稳定间隔*WarmUpColdFactor)逐步缩短到稳定间隔，
+// 超出间隔的请求排队等待而不是直接拒绝，算法参考Sentinel的WarmUpRateLimiter(即Guava的SmoothWarmingUp)。
+//
+// 桶中存储的令牌越多系统越"冷"，空闲时按照warmUpPeriod/maxPermits的速度积累令牌，
+// 令牌数在thresholdPermits之上时取令牌的耗时沿斜率从稳定间隔线性增长到冷启动间隔。
+// 每次请求的耗时计入下一次可通过时间，请求的排队时间为下一次可通过时间与当前时间的差值。
+type WarmUpThrottlingChecker struct {
+	owner             *TrafficShapingController
+	maxQueueingTimeNs int64
+	statIntervalNs    int64
+	warmUpPeriodNs    int64
+	coldFactor        uint32
+
+	mux sync.Mutex
+	// threshold 当前参数对应的阈值，阈值变化时重新计算参数
+	threshold float64
+	// stableIntervalNs 预热结束后每个令牌的间隔
+	stableIntervalNs float64
+	// coolDownIntervalNs 空闲时积累一个令牌的间隔
+	coolDownIntervalNs float64
+	thresholdPermits   float64
+	maxPermits         float64
+	slope              float64
+	storedPermits      float64
+	// nextFreeTicketNs 下一个请求可通过的时间
+	nextFreeTicketNs int64
+}
+
+func NewWarmUpThrottlingChecker(owner *TrafficShapingController, rule *Rule) *WarmUpThrottlingChecker {
+	var statIntervalNs int64
+	if rule.StatIntervalInMs == 0 {
+		statIntervalNs = 1000 * MillisToNanosOffset
+	} else {
+		statIntervalNs = int64(rule.StatIntervalInMs) * MillisToNanosOffset
+	}
+	coldFactor := rule.WarmUpColdFactor
+	if coldFactor <= 1 {
+		coldFactor = config.DefaultWarmUpColdFactor
+		logging.Warn("[NewWarmUpThrottlingChecker] No set WarmUpColdFactor,use default warm up cold factor value", "defaultWarmUpColdFactor", config.DefaultWarmUpColdFactor)
+	}
+	return &WarmUpThrottlingChecker{
+		owner:             owner,
+		maxQueueingTimeNs: int64(rule.MaxQueueingTimeMs) * MillisToNanosOffset,
+		statIntervalNs:    statIntervalNs,
+		warmUpPeriodNs:    int64(rule.WarmUpPeriodSec) * int64(time.Second),
+		coldFactor:        coldFactor,
+	}
+}
+
+func (c *WarmUpThrottlingChecker) BoundOwner() *TrafficShapingController {
+	return c.owner
+}
+
+func (c *WarmUpThrottlingChecker) DoCheck(_ base.StatNode, batchCount uint32, threshold float64) *base.TokenResult {
+	// Pass when batch count is less or equal than 0.
+	if batchCount <= 0 {
+		return nil
+	}
+
+	var rule *Rule
+	if c.BoundOwner() != nil {
+		rule = c.BoundOwner().BoundRule()
+	}
+
+	if threshold <= 0.0 {
+		msg := "flow warm up throttling check blocked, threshold is <= 0.0"
+		return base.NewTokenResultBlockedWithCause(base.BlockTypeFlow, msg, rule, nil)
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	curNano := int64(util.CurrentTimeNano())
+	c.updateThreshold(threshold, curNano)
+	c.resync(curNano)
+
+	// 排队时间为之前请求预占的时间
+	waitNs := c.nextFreeTicketNs - curNano
+	if waitNs > c.maxQueueingTimeNs {
+		return base.NewTokenResultBlockedWithCause(base.BlockTypeFlow, BlockMsgQueueing, rule, nil)
+	}
+
+	// 优先消耗桶中存储的令牌(冷启动期间耗时更长)，不足部分按照稳定间隔计算
+	permits := float64(batchCount)
+	storedToSpend := math.Min(permits, c.storedPermits)
+	freshPermits := permits - storedToSpend
+	costNs := c.storedPermitsToWaitTime(c.storedPermits, storedToSpend) + freshPermits*c.stableIntervalNs
+	c.nextFreeTicketNs += int64(math.Ceil(costNs))
+	c.storedPermits -= storedToSpend
+
+	if waitNs > 0 {
+		return base.NewTokenResultShouldWait(time.Duration(waitNs))
+	}
+	return nil
+}
+
+// updateThreshold 初始化或者阈值变化时重新计算预热参数，阈值变化时按照比例保留已存储的令牌
+func (c *WarmUpThrottlingChecker) updateThreshold(threshold float64, curNano int64) {
+	if util.Float64Equals(c.threshold, threshold) {
+		return
+	}
+	oldMaxPermits := c.maxPermits
+	initialized := c.threshold > 0
+
+	c.threshold = threshold
+	c.stableIntervalNs = float64(c.statIntervalNs) / threshold
+	coldIntervalNs := c.stableIntervalNs * float64(c.coldFactor)
+	c.thresholdPermits = 0.5 * float64(c.warmUpPeriodNs) / c.stableIntervalNs
+	c.maxPermits = c.thresholdPermits + 2.0*float64(c.warmUpPeriodNs)/(c.stableIntervalNs+coldIntervalNs)
+	if c.maxPermits > c.thresholdPermits {
+		c.slope = (coldIntervalNs - c.stableIntervalNs) / (c.maxPermits - c.thresholdPermits)
+	} else {
+		c.slope = 0
+	}
+	if c.maxPermits > 0 {
+		c.coolDownIntervalNs = float64(c.warmUpPeriodNs) / c.maxPermits
+	} else {
+		c.coolDownIntervalNs = 0
+	}
+
+	if !initialized {
+		// 初始为冷启动状态
+		c.storedPermits = c.maxPermits
+		c.nextFreeTicketNs = curNano
+		return
+	}
+	if oldMaxPermits > 0 {
+		c.storedPermits = c.storedPermits * c.maxPermits / oldMaxPermits
+	} else {
+		c.storedPermits = 0
+	}
+}
+
+// resync 根据空闲时间积累令牌，时钟回拨时不积累
+func (c *WarmUpThrottlingChecker) resync(curNano int64) {
+	if curNano <= c.nextFreeTicketNs {
+		return
+	}
+	if c.coolDownIntervalNs > 0 {
+		newPermits := float64(curNano-c.nextFreeTicketNs) / c.coolDownIntervalNs
+		c.storedPermits = math.Min(c.maxPermits, c.storedPermits+newPermits)
+	}
+	c.nextFreeTicketNs = curNano
+}
+
+// storedPermitsToWaitTime 从storedPermits个令牌中取出permitsToTake个令牌的耗时，即间隔曲线下的面积
+func (c *WarmUpThrottlingChecker) storedPermitsToWaitTime(storedPermits, permitsToTake float64) float64 {
+	availablePermitsAboveThreshold := storedPermits - c.thresholdPermits
+	var costNs float64
+	if availablePermitsAboveThreshold > 0 {
+		permitsAboveThresholdToTake := math.Min(availablePermitsAboveThreshold, permitsToTake)
+		length := c.permitsToInterval(availablePermitsAboveThreshold) +
+			c.permitsToInterval(availablePermitsAboveThreshold-permitsAboveThresholdToTake)
+		costNs = permitsAboveThresholdToTake * length / 2.0
+		permitsToTake -= permitsAboveThresholdToTake
+	}
+	return costNs + c.stableIntervalNs*permitsToTake
+}
+
+func (c *WarmUpThrottlingChecker) permitsToInterval(permits float64) float64 {
+	return c.stableIntervalNs + permits*c.slope
+}
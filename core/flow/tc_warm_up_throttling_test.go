@@ -0,0 +1,102 @@
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWarmUpThrottlingRule(maxQueueingTimeMs uint32) *Rule {
+	return &Rule{
+		Resource:               "abc",
+		TokenCalculateStrategy: WarmUp,
+		ControlBehavior:        Throttling,
+		Threshold:              10,
+		MaxQueueingTimeMs:      maxQueueingTimeMs,
+		WarmUpPeriodSec:        10,
+		WarmUpColdFactor:       3,
+	}
+}
+
+func TestWarmUpThrottlingChecker_WarmUp(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	rule := newWarmUpThrottlingRule(10000)
+	tc := NewWarmUpThrottlingChecker(nil, rule)
+
+	// 首个请求直接通过
+	assert.Nil(t, tc.DoCheck(nil, 1, rule.Threshold))
+
+	// 按照排队时间依次放行，排队时间即上一个请求的间隔
+	var waits []time.Duration
+	var elapsed time.Duration
+	for i := 0; i < 200; i++ {
+		res := tc.DoCheck(nil, 1, rule.Threshold)
+		assert.True(t, res != nil && res.Status() == base.ResultStatusShouldWait)
+		wait := res.NanosToWait()
+		waits = append(waits, wait)
+		util.Sleep(wait)
+		if wait > 101*time.Millisecond {
+			elapsed += wait
+		}
+	}
+	// 冷启动间隔约为稳定间隔(100ms)的3倍
+	assert.InDelta(t, float64(300*time.Millisecond), float64(waits[0]), float64(5*time.Millisecond))
+	for i := 1; i < len(waits); i++ {
+		assert.True(t, waits[i] <= waits[i-1])
+	}
+	// 预热结束后恢复到稳定间隔，预热耗时约为WarmUpPeriodSec
+	assert.InDelta(t, float64(100*time.Millisecond), float64(waits[len(waits)-1]), float64(time.Millisecond))
+	assert.InDelta(t, float64(10*time.Second), float64(elapsed), float64(500*time.Millisecond))
+
+	// 长时间空闲后重新进入冷启动
+	util.Sleep(time.Minute)
+	assert.Nil(t, tc.DoCheck(nil, 1, rule.Threshold))
+	res := tc.DoCheck(nil, 1, rule.Threshold)
+	assert.InDelta(t, float64(300*time.Millisecond), float64(res.NanosToWait()), float64(5*time.Millisecond))
+}
+
+func TestWarmUpThrottlingChecker_Queueing(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	rule := newWarmUpThrottlingRule(500)
+	tc := NewWarmUpThrottlingChecker(nil, rule)
+
+	assert.Nil(t, tc.DoCheck(nil, 1, rule.Threshold))
+	res := tc.DoCheck(nil, 1, rule.Threshold)
+	assert.True(t, res.Status() == base.ResultStatusShouldWait)
+	queueing := res.NanosToWait()
+	assert.InDelta(t, float64(300*time.Millisecond), float64(queueing), float64(5*time.Millisecond))
+
+	// 排队时间累加，超过MaxQueueingTimeMs时拒绝
+	res = tc.DoCheck(nil, 1, rule.Threshold)
+	assert.True(t, res.IsBlocked())
+	assert.Equal(t, BlockMsgQueueing, res.BlockError().BlockMsg())
+
+	// 被拒绝的请求不占用排队时间
+	util.Sleep(100 * time.Millisecond)
+	res = tc.DoCheck(nil, 1, rule.Threshold)
+	assert.True(t, res.Status() == base.ResultStatusShouldWait)
+	assert.True(t, res.NanosToWait() < 500*time.Millisecond)
+	assert.True(t, res.NanosToWait() > queueing-100*time.Millisecond)
+}
+
+func TestWarmUpThrottlingChecker_InvalidThreshold(t *testing.T) {
+	util.SetClock(util.NewMockClock())
+	tc := NewWarmUpThrottlingChecker(nil, newWarmUpThrottlingRule(500))
+	assert.Nil(t, tc.DoCheck(nil, 0, 10))
+	assert.True(t, tc.DoCheck(nil, 1, 0).IsBlocked())
+}
+
+func TestWarmUpThrottlingGenerator(t *testing.T) {
+	rule := newWarmUpThrottlingRule(500)
+	assert.Nil(t, IsValidRule(rule))
+	assert.False(t, rule.needStatistic())
+	generator := tcGenFuncMap[trafficControllerGenKey{tokenCalculateStrategy: WarmUp, controlBehavior: Throttling}]
+	tsc, err := generator(rule, nil)
+	assert.Nil(t, err)
+	_, ok := tsc.FlowChecker().(*WarmUpThrottlingChecker)
+	assert.True(t, ok)
+	assert.Equal(t, rule.Threshold, tsc.FlowCalculator().CalculateAllowedTokens(1, 0))
+}
@@ -1,6 +1,9 @@
 package flow
 
 import (
+	"hash/fnv"
+	"sync"
+
 	"github.com/liuhailove/gmiter/core/base"
 	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
 )
@@ -53,6 +56,11 @@ type TrafficShapingController struct {
 	boundStat standaloneStatistic
 	// 降级时间到
 	DowngradeTimeInNsTo int64
+
+	// ruleData、ruleVersion 规则的json及版本号，集群限流首次使用时计算
+	ruleDataOnce sync.Once
+	ruleData     string
+	ruleVersion  uint64
 }
 
 func NewTrafficShapingController(rule *Rule, boundStat *standaloneStatistic) (*TrafficShapingController, error) {
@@ -66,6 +74,26 @@ func (t *TrafficShapingController) BoundRule() *Rule {
 	return t.rule
 }
 
+// boundRuleData 返回规则的json及版本号，规则加载后不再变化，因此只计算一次
+func (t *TrafficShapingController) boundRuleData() (string, uint64) {
+	t.ruleDataOnce.Do(func() {
+		data, err := jsonTraffic.Marshal(t.rule)
+		if err != nil {
+			return
+		}
+		t.ruleData = string(data)
+		t.ruleVersion = RuleVersion(t.ruleData)
+	})
+	return t.ruleData, t.ruleVersion
+}
+
+// RuleVersion 规则json的版本号，json相同的规则版本号相同
+func RuleVersion(ruleData string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(ruleData))
+	return h.Sum64()
+}
+
 func (t *TrafficShapingController) FlowChecker() TrafficShapingChecker {
 	return t.flowChecker
 }
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// MatchStrategy 匹配策略
+type MatchStrategy int32
+
+const (
+	// MatchExact 精确匹配
+	MatchExact MatchStrategy = iota
+	// MatchPrefix 前缀匹配，路径匹配时支持 /order/** 格式
+	MatchPrefix
+	// MatchRegex 正则匹配
+	MatchRegex
+	// MatchContains 包含匹配，仅用于参数值匹配
+	MatchContains
+)
+
+func (s MatchStrategy) String() string {
+	switch s {
+	case MatchExact:
+		return "Exact"
+	case MatchPrefix:
+		return "Prefix"
+	case MatchRegex:
+		return "Regex"
+	case MatchContains:
+		return "Contains"
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
+
+// ApiPredicateItem API分组的路径匹配条件
+type ApiPredicateItem struct {
+	// Pattern 匹配的路径，MatchPrefix时如 /order/**，MatchRegex时为正则表达式
+	Pattern string `json:"pattern"`
+	// MatchStrategy 匹配策略
+	MatchStrategy MatchStrategy `json:"matchStrategy"`
+}
+
+// ApiDefinition API分组定义，路径满足任一匹配条件即属于该分组
+type ApiDefinition struct {
+	// ApiName API分组名称，作为资源名称
+	ApiName string `json:"apiName"`
+	// PredicateItems 路径匹配条件
+	PredicateItems []*ApiPredicateItem `json:"predicateItems"`
+}
+
+func (d *ApiDefinition) String() string {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	b, err := json.Marshal(d)
+	if err != nil {
+		// Return the fallback string
+		return fmt.Sprintf("{ApiName=%s, PredicateItems=%+v}", d.ApiName, d.PredicateItems)
+	}
+	return string(b)
+}
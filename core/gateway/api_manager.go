@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/logging"
+)
+
+// apiMatcher 已编译的API分组
+type apiMatcher struct {
+	apiName  string
+	matchers []matcher
+}
+
+func (m *apiMatcher) match(path string) bool {
+	for _, fn := range m.matchers {
+		if fn(path) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	apiMatchers   = make([]*apiMatcher, 0)
+	apiRwMux      = &sync.RWMutex{}
+	currentApis   = make([]*ApiDefinition, 0)
+	updateApisMux = new(sync.Mutex)
+)
+
+// LoadApiDefinitions loads the given API definitions, while all previous definitions will be replaced.
+// the first returned value indicates whether you do real load operation, if the definitions is the same with previous definitions, return false
+func LoadApiDefinitions(defs []*ApiDefinition) (bool, error) {
+	updateApisMux.Lock()
+	defer updateApisMux.Unlock()
+	if reflect.DeepEqual(currentApis, defs) {
+		logging.Info("[Gateway] Load api definitions is the same with current api definitions, so ignore load operation.")
+		return false, nil
+	}
+	matchers := make([]*apiMatcher, 0, len(defs))
+	for _, def := range defs {
+		m, err := compileApiDefinition(def)
+		if err != nil {
+			logging.Warn("[Gateway LoadApiDefinitions] Ignoring invalid api definition", "definition", def, "reason", err.Error())
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+	apiRwMux.Lock()
+	apiMatchers = matchers
+	apiRwMux.Unlock()
+	currentApis = defs
+	logging.Info("[Gateway] Api definitions were loaded", "definitions", defs)
+	return true, nil
+}
+
+// ClearApiDefinitions clears all the API definitions.
+func ClearApiDefinitions() error {
+	_, err := LoadApiDefinitions(nil)
+	return err
+}
+
+// GetApiDefinitions returns all the API definitions based on copy.
+func GetApiDefinitions() []ApiDefinition {
+	updateApisMux.Lock()
+	defer updateApisMux.Unlock()
+	ret := make([]ApiDefinition, 0, len(currentApis))
+	for _, def := range currentApis {
+		if def != nil {
+			ret = append(ret, *def)
+		}
+	}
+	return ret
+}
+
+// MatchApis returns the names of all the API groups matching the path
+func MatchApis(path string) []string {
+	apiRwMux.RLock()
+	defer apiRwMux.RUnlock()
+	var names []string
+	for _, m := range apiMatchers {
+		if m.match(path) {
+			names = append(names, m.apiName)
+		}
+	}
+	return names
+}
+
+func compileApiDefinition(def *ApiDefinition) (*apiMatcher, error) {
+	if err := IsValidApiDefinition(def); err != nil {
+		return nil, err
+	}
+	m := &apiMatcher{apiName: def.ApiName, matchers: make([]matcher, 0, len(def.PredicateItems))}
+	for _, item := range def.PredicateItems {
+		fn, err := newMatcher(item.Pattern, item.MatchStrategy)
+		if err != nil {
+			return nil, err
+		}
+		m.matchers = append(m.matchers, fn)
+	}
+	return m, nil
+}
+
+// IsValidApiDefinition checks whether the given ApiDefinition is valid.
+func IsValidApiDefinition(def *ApiDefinition) error {
+	if def == nil {
+		return errors.New("nil api definition")
+	}
+	if len(def.ApiName) == 0 {
+		return errors.New("empty api name")
+	}
+	if len(def.PredicateItems) == 0 {
+		return errors.New("empty predicate items")
+	}
+	for _, item := range def.PredicateItems {
+		if item == nil || len(item.Pattern) == 0 {
+			return errors.New("empty pattern of predicate item")
+		}
+		if item.MatchStrategy == MatchContains {
+			return errors.New("contains match strategy is not supported by api definition")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Package gateway provides the API gateway mode of sea.
+//
+// 网关模式下通过ApiDefinition按照路径(精确、前缀、正则)定义API分组，网关适配器以路由ID及匹配到的API分组名称
+// 作为资源名称(资源类型为base.ResTypeAPIGateway)进入sea，因此flow、hotspot等规则可以直接以API分组名称为资源，
+// 一条规则即可覆盖 /order/** 下的所有接口。
+//
+// 网关规则Rule支持从客户端IP、Host、Header、URL参数及Cookie中解析参数，加载时转换为对应资源的hotspot规则，
+// 网关适配器通过ParseParams解析参数并以attachment的方式传入Entry。
+package gateway
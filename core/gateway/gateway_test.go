@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+type fakeParser struct {
+	headers map[string]string
+}
+
+func (f *fakeParser) Path() string               { return "/order/1" }
+func (f *fakeParser) RemoteAddress() string      { return "10.0.0.1" }
+func (f *fakeParser) Host() string               { return "api.example.com" }
+func (f *fakeParser) Header(key string) string   { return f.headers[key] }
+func (f *fakeParser) URLParam(key string) string { return "" }
+func (f *fakeParser) Cookie(key string) string   { return "" }
+
+func TestMatchApis(t *testing.T) {
+	_, err := LoadApiDefinitions([]*ApiDefinition{
+		{ApiName: "order", PredicateItems: []*ApiPredicateItem{{Pattern: "/order/**", MatchStrategy: MatchPrefix}}},
+		{ApiName: "detail", PredicateItems: []*ApiPredicateItem{{Pattern: `^/order/\d+$`, MatchStrategy: MatchRegex}}},
+		{ApiName: "health", PredicateItems: []*ApiPredicateItem{{Pattern: "/health", MatchStrategy: MatchExact}}},
+		{ApiName: "invalid", PredicateItems: []*ApiPredicateItem{{Pattern: "(", MatchStrategy: MatchRegex}}},
+	})
+	assert.Nil(t, err)
+	defer ClearApiDefinitions()
+
+	assert.Equal(t, []string{"order", "detail"}, MatchApis("/order/1"))
+	assert.Equal(t, []string{"order"}, MatchApis("/order"))
+	assert.Equal(t, []string{"order"}, MatchApis("/order/list"))
+	assert.Equal(t, []string{"health"}, MatchApis("/health"))
+	assert.Nil(t, MatchApis("/orders"))
+	assert.Equal(t, 4, len(GetApiDefinitions()))
+}
+
+func TestLoadRules(t *testing.T) {
+	_, err := LoadRules([]*Rule{
+		{Resource: "order", ResourceMode: ResourceModeCustomApiName, Threshold: 10, DurationInSec: 1},
+		{Resource: "order", ResourceMode: ResourceModeCustomApiName, Threshold: 1, DurationInSec: 1,
+			ParamItem: &ParamItem{ParseStrategy: ParseHeader, FieldName: "X-User", Pattern: "vip", MatchStrategy: MatchPrefix}},
+		{Resource: "order", ResourceMode: ResourceModeCustomApiName, Threshold: 5, DurationInSec: 1,
+			ParamItem: &ParamItem{ParseStrategy: ParseClientIP}},
+		{Resource: "invalid", ParamItem: &ParamItem{ParseStrategy: ParseHeader}},
+	})
+	assert.Nil(t, err)
+
+	hotspotRules := hotspot.GetRulesOfResource("order")
+	assert.Equal(t, 3, len(hotspotRules))
+	assert.Equal(t, "$gw:1", hotspotRules[1].ParamKey)
+	assert.Equal(t, hotspot.QPS, hotspotRules[1].MetricType)
+	assert.Equal(t, 0, len(hotspot.GetRulesOfResource("invalid")))
+	assert.Equal(t, 3, len(GetRules()))
+
+	params := ParseParams("order", &fakeParser{headers: map[string]string{"X-User": "vip-1"}})
+	assert.Equal(t, map[interface{}]interface{}{"$gw:0": DefaultParamValue, "$gw:1": "vip-1", "$gw:2": "10.0.0.1"}, params)
+	// 参数值不匹配Pattern时规则不生效
+	params = ParseParams("order", &fakeParser{headers: map[string]string{"X-User": "normal"}})
+	assert.Equal(t, map[interface{}]interface{}{"$gw:0": DefaultParamValue, "$gw:2": "10.0.0.1"}, params)
+	assert.Nil(t, ParseParams("none", &fakeParser{}))
+
+	assert.Nil(t, ClearRules())
+	assert.Equal(t, 0, len(hotspot.GetRulesOfResource("order")))
+}
+
+// hotspot.LoadRules只替换hotspot自身的规则，网关规则转换的规则仍然生效
+func TestLoadRulesWithHotspotRules(t *testing.T) {
+	defer ClearRules()
+	defer hotspot.ClearRules()
+	_, err := LoadRules([]*Rule{
+		{Resource: "order", ResourceMode: ResourceModeCustomApiName, Threshold: 10, DurationInSec: 1},
+	})
+	assert.Nil(t, err)
+
+	_, err = hotspot.LoadRules([]*hotspot.Rule{
+		{Resource: "order", MetricType: hotspot.QPS, ParamIdx: 0, Threshold: 1, DurationInSec: 1},
+		{Resource: "user", MetricType: hotspot.QPS, ParamIdx: 0, Threshold: 1, DurationInSec: 1},
+	})
+	assert.Nil(t, err)
+	hotspotRules := hotspot.GetRulesOfResource("order")
+	assert.Equal(t, 2, len(hotspotRules))
+	assert.Equal(t, "", hotspotRules[0].ParamKey)
+	assert.Equal(t, "$gw:0", hotspotRules[1].ParamKey)
+
+	assert.Nil(t, hotspot.ClearRules())
+	hotspotRules = hotspot.GetRulesOfResource("order")
+	assert.Equal(t, 1, len(hotspotRules))
+	assert.Equal(t, "$gw:0", hotspotRules[0].ParamKey)
+	assert.Equal(t, 0, len(hotspot.GetRulesOfResource("user")))
+
+	// 清除资源上hotspot自身的规则时保留网关规则
+	_, err = hotspot.LoadRulesOfResource("order", []*hotspot.Rule{
+		{Resource: "order", MetricType: hotspot.QPS, ParamIdx: 0, Threshold: 1, DurationInSec: 1},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(hotspot.GetRulesOfResource("order")))
+	assert.Nil(t, hotspot.ClearRulesOfResource("order"))
+	assert.Equal(t, 1, len(hotspot.GetRulesOfResource("order")))
+
+	assert.Nil(t, ClearRules())
+	assert.Equal(t, 0, len(hotspot.GetRulesOfResource("order")))
+}
@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// matcher 匹配路径或参数值
+type matcher func(string) bool
+
+func newMatcher(pattern string, strategy MatchStrategy) (matcher, error) {
+	switch strategy {
+	case MatchExact:
+		return func(s string) bool {
+			return s == pattern
+		}, nil
+	case MatchPrefix:
+		prefix := strings.TrimRight(pattern, "*")
+		return func(s string) bool {
+			// /order/** 同时匹配 /order
+			return strings.HasPrefix(s, prefix) || (strings.HasSuffix(prefix, "/") && s == prefix[:len(prefix)-1])
+		}, nil
+	case MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regex pattern %s", pattern)
+		}
+		return re.MatchString, nil
+	case MatchContains:
+		return func(s string) bool {
+			return strings.Contains(s, pattern)
+		}, nil
+	default:
+		return nil, errors.Errorf("unsupported match strategy: %d", strategy)
+	}
+}
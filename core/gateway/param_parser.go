@@ -0,0 +1,59 @@
+package gateway
+
+// RequestItemParser 从网关请求中解析参数
+type RequestItemParser interface {
+	// Path 请求路径
+	Path() string
+	// RemoteAddress 客户端IP
+	RemoteAddress() string
+	// Host 请求的Host
+	Host() string
+	// Header 请求头的值
+	Header(key string) string
+	// URLParam URL参数的值
+	URLParam(key string) string
+	// Cookie Cookie的值
+	Cookie(key string) string
+}
+
+// ParseParams 按照资源上的网关规则解析请求参数，返回值作为Entry的attachments传入。
+// 参数值为空或与规则的Pattern不匹配时不设置参数，对应的规则不生效
+func ParseParams(resource string, parser RequestItemParser) map[interface{}]interface{} {
+	items := getRuleItemsOfResource(resource)
+	if len(items) == 0 {
+		return nil
+	}
+	params := make(map[interface{}]interface{}, len(items))
+	for _, item := range items {
+		if item.rule.ParamItem == nil {
+			params[item.paramKey] = DefaultParamValue
+			continue
+		}
+		value := parseParam(item.rule.ParamItem, parser)
+		if len(value) == 0 {
+			continue
+		}
+		if item.valueMatcher != nil && !item.valueMatcher(value) {
+			continue
+		}
+		params[item.paramKey] = value
+	}
+	return params
+}
+
+func parseParam(item *ParamItem, parser RequestItemParser) string {
+	switch item.ParseStrategy {
+	case ParseClientIP:
+		return parser.RemoteAddress()
+	case ParseHost:
+		return parser.Host()
+	case ParseHeader:
+		return parser.Header(item.FieldName)
+	case ParseURLParam:
+		return parser.URLParam(item.FieldName)
+	case ParseCookie:
+		return parser.Cookie(item.FieldName)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+// ResourceMode 网关规则的资源类型
+type ResourceMode int32
+
+const (
+	// ResourceModeRouteId 资源为网关路由ID
+	ResourceModeRouteId ResourceMode = iota
+	// ResourceModeCustomApiName 资源为ApiDefinition定义的API分组名称
+	ResourceModeCustomApiName
+)
+
+func (m ResourceMode) String() string {
+	switch m {
+	case ResourceModeRouteId:
+		return "RouteId"
+	case ResourceModeCustomApiName:
+		return "CustomApiName"
+	default:
+		return strconv.Itoa(int(m))
+	}
+}
+
+// MetricType 网关规则的统计类型
+type MetricType int32
+
+const (
+	// QPS 按照每秒请求数限流
+	QPS MetricType = iota
+	// Concurrency 按照并发数限流
+	Concurrency
+)
+
+func (t MetricType) String() string {
+	switch t {
+	case QPS:
+		return "QPS"
+	case Concurrency:
+		return "Concurrency"
+	default:
+		return "Undefined"
+	}
+}
+
+// ParseStrategy 参数解析策略
+type ParseStrategy int32
+
+const (
+	// ParseClientIP 客户端IP
+	ParseClientIP ParseStrategy = iota
+	// ParseHost 请求的Host
+	ParseHost
+	// ParseHeader 请求头，FieldName为请求头名称
+	ParseHeader
+	// ParseURLParam URL参数，FieldName为参数名称
+	ParseURLParam
+	// ParseCookie Cookie，FieldName为Cookie名称
+	ParseCookie
+)
+
+func (s ParseStrategy) String() string {
+	switch s {
+	case ParseClientIP:
+		return "ClientIP"
+	case ParseHost:
+		return "Host"
+	case ParseHeader:
+		return "Header"
+	case ParseURLParam:
+		return "URLParam"
+	case ParseCookie:
+		return "Cookie"
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
+
+// ParamItem 网关规则的参数解析配置
+type ParamItem struct {
+	// ParseStrategy 参数解析策略
+	ParseStrategy ParseStrategy `json:"parseStrategy"`
+	// FieldName 请求头、URL参数或Cookie的名称
+	FieldName string `json:"fieldName"`
+	// Pattern 参数值的匹配模式，为空时按照参数值分别限流，否则只有匹配的参数值参与限流
+	Pattern string `json:"pattern"`
+	// MatchStrategy 参数值的匹配策略
+	MatchStrategy MatchStrategy `json:"matchStrategy"`
+}
+
+// Rule 网关流控规则，ParamItem为空时对资源整体限流，否则按照解析出的参数值分别限流
+type Rule struct {
+	// ID 规则唯一ID（可选）
+	ID string `json:"id,omitempty"`
+	// App 规则归属的应用名称
+	App string `json:"app,omitempty"`
+	// RuleName 规则名称
+	RuleName string `json:"ruleName,omitempty"`
+	// Resource 路由ID或API分组名称
+	Resource string `json:"resource"`
+	// ResourceMode 资源类型
+	ResourceMode ResourceMode `json:"resourceMode"`
+	// MetricType 统计类型
+	MetricType MetricType `json:"metricType"`
+	// ControlBehavior 流量整形行为，仅在MetricType为QPS时生效
+	ControlBehavior hotspot.ControlBehavior `json:"controlBehavior"`
+	// Threshold 阈值
+	Threshold float64 `json:"threshold"`
+	// DurationInSec 统计的时间间隔，仅在MetricType为QPS时生效
+	DurationInSec int64 `json:"durationInSec"`
+	// BurstCount 突发请求数，仅在ControlBehavior为Reject时生效
+	BurstCount int64 `json:"burstCount"`
+	// MaxQueueingTimeMs 最长排队时间，仅在ControlBehavior为Throttling时生效
+	MaxQueueingTimeMs int64 `json:"maxQueueingTimeMs"`
+	// ParamItem 参数解析配置
+	ParamItem *ParamItem `json:"paramItem"`
+}
+
+func (r *Rule) String() string {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	b, err := json.Marshal(r)
+	if err != nil {
+		// Return the fallback string
+		return fmt.Sprintf("{Id=%s, Resource=%s, ResourceMode=%s, MetricType=%s, Threshold=%f, ParamItem=%+v}", r.ID, r.Resource, r.ResourceMode, r.MetricType, r.Threshold, r.ParamItem)
+	}
+	return string(b)
+}
+
+func (r *Rule) ResourceName() string {
+	return r.Resource
+}
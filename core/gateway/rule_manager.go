@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/core/hotspot"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+const (
+	// paramKeyPrefix 网关规则转换的hotspot规则的ParamKey前缀，后接规则在资源内的序号
+	paramKeyPrefix = "$gw:"
+	// DefaultParamValue 未配置ParamItem的网关规则使用的参数值，即对资源整体限流
+	DefaultParamValue = "$D"
+	// hotspotRuleSource 网关规则转换的hotspot规则的来源
+	hotspotRuleSource = "gateway"
+)
+
+// ruleItem 已加载的网关规则
+type ruleItem struct {
+	rule *Rule
+	// paramKey 转换后的hotspot规则的ParamKey
+	paramKey string
+	// valueMatcher 参数值匹配器，为nil时不校验参数值
+	valueMatcher matcher
+}
+
+var (
+	ruleMap       = make(map[string][]*ruleItem)
+	rwMux         = &sync.RWMutex{}
+	currentRules  = make([]*Rule, 0)
+	updateRuleMux = new(sync.Mutex)
+)
+
+// LoadRules loads the given gateway rules, while all previous rules will be replaced.
+// 网关规则按照资源转换为hotspot规则，作为独立的来源加载，与hotspot.LoadRules加载的规则合并生效，不会被其覆盖。
+// the first returned value indicates whether you do real load operation, if the rules is the same with previous rules, return false
+func LoadRules(rules []*Rule) (bool, error) {
+	updateRuleMux.Lock()
+	defer updateRuleMux.Unlock()
+	if reflect.DeepEqual(currentRules, rules) {
+		logging.Info("[Gateway] Load rules is the same with current rules, so ignore load operation.")
+		return false, nil
+	}
+	items := make(map[string][]*ruleItem, len(rules))
+	hotspotRules := make([]*hotspot.Rule, 0, len(rules))
+	for _, rule := range rules {
+		if err := IsValidRule(rule); err != nil {
+			logging.Warn("[Gateway LoadRules] Ignoring invalid gateway rule", "rule", rule, "reason", err.Error())
+			continue
+		}
+		item := &ruleItem{rule: rule, paramKey: paramKeyPrefix + strconv.Itoa(len(items[rule.Resource]))}
+		if rule.ParamItem != nil && len(rule.ParamItem.Pattern) > 0 {
+			// 已经过IsValidRule校验
+			item.valueMatcher, _ = newMatcher(rule.ParamItem.Pattern, rule.ParamItem.MatchStrategy)
+		}
+		items[rule.Resource] = append(items[rule.Resource], item)
+		hotspotRules = append(hotspotRules, toHotspotRule(rule, item.paramKey))
+	}
+
+	_, err := hotspot.LoadRulesOfSource(hotspotRuleSource, hotspotRules)
+	rwMux.Lock()
+	ruleMap = items
+	rwMux.Unlock()
+	currentRules = rules
+	logging.Info("[Gateway] Gateway rules were loaded", "rules", rules)
+	return true, err
+}
+
+// ClearRules clears all the gateway rules and the converted hotspot rules.
+func ClearRules() error {
+	_, err := LoadRules(nil)
+	return err
+}
+
+// GetRules returns all the gateway rules based on copy.
+func GetRules() []Rule {
+	rwMux.RLock()
+	defer rwMux.RUnlock()
+	ret := make([]Rule, 0, len(ruleMap))
+	for _, items := range ruleMap {
+		for _, item := range items {
+			ret = append(ret, *item.rule)
+		}
+	}
+	return ret
+}
+
+func getRuleItemsOfResource(res string) []*ruleItem {
+	rwMux.RLock()
+	defer rwMux.RUnlock()
+	return ruleMap[res]
+}
+
+// toHotspotRule 网关规则转换为hotspot规则，参数通过attachment中的paramKey传入
+func toHotspotRule(rule *Rule, paramKey string) *hotspot.Rule {
+	r := &hotspot.Rule{
+		ID:                rule.ID,
+		App:               rule.App,
+		RuleName:          rule.RuleName,
+		Resource:          rule.Resource,
+		MetricType:        hotspot.QPS,
+		ControlBehavior:   rule.ControlBehavior,
+		ParamKey:          paramKey,
+		ParamKind:         hotspot.KindString,
+		Threshold:         rule.Threshold,
+		MaxQueueingTimeMs: rule.MaxQueueingTimeMs,
+		BurstCount:        rule.BurstCount,
+		DurationInSec:     rule.DurationInSec,
+	}
+	if rule.MetricType == Concurrency {
+		r.MetricType = hotspot.Concurrency
+	}
+	return r
+}
+
+// IsValidRule checks whether the given gateway Rule is valid.
+func IsValidRule(rule *Rule) error {
+	if rule == nil {
+		return errors.New("nil gateway rule")
+	}
+	if len(rule.Resource) == 0 {
+		return errors.New("empty resource of gateway rule")
+	}
+	if rule.ResourceMode != ResourceModeRouteId && rule.ResourceMode != ResourceModeCustomApiName {
+		return errors.Errorf("unsupported resource mode: %d", rule.ResourceMode)
+	}
+	if rule.MetricType != QPS && rule.MetricType != Concurrency {
+		return errors.Errorf("unsupported metric type: %d", rule.MetricType)
+	}
+	if rule.Threshold < 0 {
+		return errors.New("negative threshold")
+	}
+	if rule.MetricType == QPS && rule.DurationInSec <= 0 {
+		return errors.New("invalid duration")
+	}
+	if rule.BurstCount < 0 || rule.MaxQueueingTimeMs < 0 {
+		return errors.New("negative burst count or max queueing time")
+	}
+	if item := rule.ParamItem; item != nil {
+		if item.ParseStrategy < ParseClientIP || item.ParseStrategy > ParseCookie {
+			return errors.Errorf("unsupported parse strategy: %d", item.ParseStrategy)
+		}
+		if item.ParseStrategy >= ParseHeader && len(item.FieldName) == 0 {
+			return errors.New("empty field name of param item")
+		}
+		if len(item.Pattern) > 0 {
+			if _, err := newMatcher(item.Pattern, item.MatchStrategy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
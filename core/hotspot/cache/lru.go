@@ -160,6 +160,7 @@ func (c *LRU) Keys() []interface{} {
 	i := 0
 	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
 		keys[i] = ent.Value.(*entry).key
+		i++
 	}
 	return keys
 }
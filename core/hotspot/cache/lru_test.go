@@ -12,3 +12,15 @@ func TestLRU_Add(t *testing.T) {
 		c.Add(strconv.Itoa(i), &val)
 	}
 }
+
+func TestLRU_Keys(t *testing.T) {
+	c := NewLRUCacheMap(3)
+	for i := 1; i <= 4; i++ {
+		val := int64(i)
+		c.Add(strconv.Itoa(i), &val)
+	}
+	keys := c.Keys()
+	if len(keys) != 3 || keys[0] != "2" || keys[1] != "3" || keys[2] != "4" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
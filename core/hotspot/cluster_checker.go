@@ -0,0 +1,109 @@
+package hotspot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/spi"
+)
+
+// concurrencyTokensKey EntryContext.Data 中保存已申请的集群并发Token的key
+type concurrencyTokensKey struct{}
+
+// concurrencyToken 已申请的集群并发Token，在Entry退出时释放
+type concurrencyToken struct {
+	rule    string
+	tokenId string
+}
+
+func isRedisClusterMode(rule *Rule) bool {
+	return rule.ClusterMode && rule.ClusterConfig != nil && rule.ClusterConfig.ClusterStrategy == int32(ThresholdGlobalRedis)
+}
+
+func redisTokenService() base.TokenService {
+	var inst = spi.GetRegisterTokenServiceInst(constants.RedisTokenServiceType)
+	if inst == nil {
+		return nil
+	}
+	return inst.GetTokenService()
+}
+
+// canPassRedisCheck Redis全局热点参数限流，QPS按照参数值申请Token，并发按照参数值申请并发Token
+func canPassRedisCheck(ctx *base.EntryContext, tc TrafficShapingController, arg interface{}, batch int64) *base.TokenResult {
+	var rule = tc.BoundRule()
+	var tokenService = redisTokenService()
+	if tokenService == nil {
+		return fallbackToLocalOrPass(tc, arg, batch)
+	}
+	if rule.MetricType == Concurrency {
+		return canPassRedisConcurrencyCheck(ctx, tokenService, tc, arg, batch)
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	if err != nil {
+		logging.Error(err, "Fail to marshal hotspot rule in canPassRedisCheck()", "rule", rule)
+		return fallbackToLocalOrPass(tc, arg, batch)
+	}
+	var tokResult = tokenService.RequestParamToken(string(data), uint32(batch), []interface{}{arg})
+	switch base.TokResultStatus(tokResult.Status) {
+	case base.TokResultStatusOk:
+		return nil
+	case base.TokResultStatusShouldWait:
+		return base.NewTokenResultShouldWait(time.Duration(tokResult.WaitInMs) * time.Millisecond)
+	case base.TokResultStatusBlocked:
+		return base.NewTokenResultBlockedWithCause(base.BlockTypeHotSpotParamFlow, "hotspot cluster check blocked", rule, arg)
+	default:
+		return fallbackToLocalOrPass(tc, arg, batch)
+	}
+}
+
+func canPassRedisConcurrencyCheck(ctx *base.EntryContext, tokenService base.TokenService, tc TrafficShapingController, arg interface{}, batch int64) *base.TokenResult {
+	var rule = tc.BoundRule()
+	data, err := jsonTraffic.Marshal(concurrencyTokenRule(rule, arg))
+	if err != nil {
+		logging.Error(err, "Fail to marshal hotspot rule in canPassRedisConcurrencyCheck()", "rule", rule)
+		return fallbackToLocalOrPass(tc, arg, batch)
+	}
+	var tokResult = tokenService.RequestConcurrentToken(string(data), uint32(batch))
+	switch base.TokResultStatus(tokResult.Status) {
+	case base.TokResultStatusOk:
+		var tokens, _ = ctx.Data[concurrencyTokensKey{}].([]concurrencyToken)
+		ctx.Data[concurrencyTokensKey{}] = append(tokens, concurrencyToken{rule: string(data), tokenId: fmt.Sprint(tokResult.TokenId)})
+		return nil
+	case base.TokResultStatusBlocked:
+		return base.NewTokenResultBlockedWithCause(base.BlockTypeHotSpotParamFlow, "hotspot cluster concurrency check blocked", rule, arg)
+	default:
+		return fallbackToLocalOrPass(tc, arg, batch)
+	}
+}
+
+// concurrencyTokenRule 并发Token按照参数值区分，参数值追加到资源名中，参数的特定阈值作为全局阈值
+func concurrencyTokenRule(rule *Rule, arg interface{}) *Rule {
+	var tokenRule = *rule
+	var clusterConfig = *rule.ClusterConfig
+	tokenRule.Resource = rule.Resource + "#" + fmt.Sprint(arg)
+	if specificThreshold, ok := rule.SpecificItems[arg]; ok {
+		clusterConfig.GlobalThreshold = float64(specificThreshold)
+	}
+	tokenRule.ClusterConfig = &clusterConfig
+	tokenRule.SpecificItems = nil
+	return &tokenRule
+}
+
+// releaseConcurrencyTokens 释放本次Entry申请的集群并发Token
+func releaseConcurrencyTokens(ctx *base.EntryContext) {
+	var tokens, ok = ctx.Data[concurrencyTokensKey{}].([]concurrencyToken)
+	if !ok {
+		return
+	}
+	delete(ctx.Data, concurrencyTokensKey{})
+	var tokenService = redisTokenService()
+	if tokenService == nil {
+		return
+	}
+	for _, token := range tokens {
+		tokenService.ReleaseConcurrentToken(token.rule, token.tokenId)
+	}
+}
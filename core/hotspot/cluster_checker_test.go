@@ -0,0 +1,94 @@
+package hotspot
+
+import (
+	"testing"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/spi"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTokenService struct {
+	base.TokenService
+	paramResult      *base.TokResult
+	concurrentResult *base.TokResult
+	params           []interface{}
+	concurrentRule   string
+	released         []string
+}
+
+func (f *fakeTokenService) RequestParamToken(rule string, acquireCount uint32, params []interface{}) *base.TokResult {
+	f.params = params
+	return f.paramResult
+}
+
+func (f *fakeTokenService) RequestConcurrentToken(rule string, acquireCount uint32) *base.TokResult {
+	f.concurrentRule = rule
+	return f.concurrentResult
+}
+
+func (f *fakeTokenService) ReleaseConcurrentToken(rule string, tokenId string) {
+	f.released = append(f.released, tokenId)
+}
+
+type fakeTokenServiceInitFunc struct {
+	tokenService *fakeTokenService
+}
+
+func (f *fakeTokenServiceInitFunc) Initial() error             { return nil }
+func (f *fakeTokenServiceInitFunc) Order() int                 { return 1000 }
+func (f *fakeTokenServiceInitFunc) ImmediatelyLoadOnce() error { return nil }
+func (f *fakeTokenServiceInitFunc) GetRegisterType() constants.RegisterType {
+	return constants.RedisTokenServiceType
+}
+func (f *fakeTokenServiceInitFunc) GetTokenService() base.TokenService { return f.tokenService }
+func (f *fakeTokenServiceInitFunc) ReInitial() error                   { return nil }
+
+func TestCanPassRedisCheck(t *testing.T) {
+	tokenService := &fakeTokenService{}
+	spi.Register(&fakeTokenServiceInitFunc{tokenService: tokenService})
+
+	rule := &Rule{
+		ID:            "1",
+		Resource:      "abc",
+		MetricType:    QPS,
+		Threshold:     10,
+		DurationInSec: 1,
+		SpecificItems: map[interface{}]int64{"vip": 1},
+		ClusterMode:   true,
+		ClusterConfig: &ClusterConfig{ClusterStrategy: int32(ThresholdGlobalRedis), GlobalThreshold: 5},
+	}
+	ctx := base.NewEmptyEntryContext()
+	ctx.Resource = base.NewResourceWrapper("abc", base.ResTypeCommon, base.Inbound)
+	ctx.Data = make(map[interface{}]interface{})
+
+	t.Run("QPS", func(t *testing.T) {
+		tc := tcGenFuncMap[Reject](rule, nil)
+		tokenService.paramResult = base.StatusOkResult
+		assert.Nil(t, canPassCheck(ctx, tc, "a", 1))
+		assert.Equal(t, []interface{}{"a"}, tokenService.params)
+
+		tokenService.paramResult = base.BlockedResult
+		result := canPassCheck(ctx, tc, "a", 1)
+		assert.True(t, result.IsBlocked())
+		assert.Equal(t, base.BlockTypeHotSpotParamFlow, result.BlockError().BlockType())
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		concurrencyRule := *rule
+		concurrencyRule.MetricType = Concurrency
+		tc := tcGenFuncMap[Reject](&concurrencyRule, nil)
+		tokenService.concurrentResult = &base.TokResult{Status: int(base.TokResultStatusOk), TokenId: 7}
+		assert.Nil(t, canPassCheck(ctx, tc, "vip", 1))
+		// 参数值追加到资源名中，特定阈值作为全局阈值
+		assert.Contains(t, tokenService.concurrentRule, `"resource":"abc#vip"`)
+		assert.Contains(t, tokenService.concurrentRule, `"globalThreshold":1`)
+
+		DefaultConcurrencyStatSlot.OnCompleted(ctx)
+		assert.Equal(t, []string{"7"}, tokenService.released)
+		// 已释放的Token不会重复释放
+		DefaultConcurrencyStatSlot.OnCompleted(ctx)
+		assert.Equal(t, []string{"7"}, tokenService.released)
+	})
+}
@@ -52,10 +52,12 @@ func (c *ConcurrencyStatSlot) OnEntryPassed(ctx *base.EntryContext) {
 }
 
 func (c *ConcurrencyStatSlot) OnEntryBlocked(ctx *base.EntryContext, blockError *base.BlockError) {
-	// Do nothing
+	// 被其他规则阻塞时，已申请的集群并发Token需要归还
+	releaseConcurrencyTokens(ctx)
 }
 
 func (c *ConcurrencyStatSlot) OnCompleted(ctx *base.EntryContext) {
+	releaseConcurrencyTokens(ctx)
 	res := ctx.Resource.Name()
 	tcs := getTrafficControllersFor(res)
 	for _, tc := range tcs {
@@ -0,0 +1,290 @@
+package hotspot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/hotspot/cache"
+	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	// DefaultTopParamsNum 每条规则每个统计周期默认保留的热点参数值个数，可以通过 InitTopParamsTask 的topN修改，
+	// 对应配置项为stat.hotParams.topN
+	DefaultTopParamsNum = 10
+)
+
+var (
+	// 热点参数值的通过数及阻塞数，每个统计周期重置，序列数不超过规则数*topN*2
+	topParamCountGauge = metric_exporter.NewGauge(
+		"hotspot_top_param_count",
+		"Pass and block count of top hot parameter values in last period",
+		[]string{"resource", "rule_id", "value", "type"})
+
+	topParams    = make([]*TopParams, 0)
+	topParamsMux = new(sync.RWMutex)
+	// 每条规则每个统计周期保留的热点参数值个数
+	topParamsNum      = DefaultTopParamsNum
+	topParamsOnce     sync.Once
+	topParamsStopChan = make(chan struct{})
+)
+
+func init() {
+	metric_exporter.Register(topParamCountGauge)
+}
+
+// ParamCount 参数值在一个统计周期内的通过数及阻塞数
+type ParamCount struct {
+	Value      string `json:"value"`
+	PassCount  int64  `json:"passCount"`
+	BlockCount int64  `json:"blockCount"`
+}
+
+// TopParams 热点规则在最近一个统计周期内的热点参数值，按阻塞数、通过数降序排列
+type TopParams struct {
+	Resource  string       `json:"resource"`
+	RuleId    string       `json:"ruleId"`
+	Timestamp uint64       `json:"timestamp"`
+	Params    []ParamCount `json:"params"`
+}
+
+// MetricItems 转换为指标日志中的指标项，资源名称见 HotParamResourceName
+func (t *TopParams) MetricItems() []*base.MetricItem {
+	items := make([]*base.MetricItem, 0, len(t.Params))
+	for _, p := range t.Params {
+		items = append(items, &base.MetricItem{
+			Resource:                 HotParamResourceName(t.Resource, t.RuleId, p.Value),
+			Timestamp:                t.Timestamp,
+			PassQps:                  uint64(p.PassCount),
+			BlockQps:                 uint64(p.BlockCount),
+			BlockedNumByHotspotParam: uint64(p.BlockCount),
+		})
+	}
+	return items
+}
+
+// HotParamResourceName 热点参数值在指标日志中的资源名称，格式为：__hot_param__:{resource}:{ruleId}:{value}
+func HotParamResourceName(resource, ruleId, value string) string {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	return base.HotParamResourceNamePrefix + resource + ":" + ruleId + ":" + value
+}
+
+type paramCounters struct {
+	pass  cache.ConcurrentCounterCache
+	block cache.ConcurrentCounterCache
+}
+
+// ParamStatCounter 按参数值记录一个统计周期内的通过数及阻塞数，
+// 通过数及阻塞数分别保存在 cache.ConcurrentCounterCache 中，超过容量时淘汰最久未访问的参数值。
+// 请求路径上只原子更新计数，TakeTopN 替换为新的缓存后再汇总上一个周期的计数，
+// 与替换并发的少量计数可能不计入任何周期
+type ParamStatCounter struct {
+	capacity int
+	// counters *paramCounters
+	counters atomic.Value
+	// takeMux 串行化 TakeTopN
+	takeMux sync.Mutex
+}
+
+func NewParamStatCounter(capacity int) *ParamStatCounter {
+	counters := newParamCounters(capacity)
+	if counters == nil {
+		return nil
+	}
+	c := &ParamStatCounter{capacity: capacity}
+	c.counters.Store(counters)
+	return c
+}
+
+func newParamCounters(capacity int) *paramCounters {
+	pass, block := cache.NewLRUCacheMap(capacity), cache.NewLRUCacheMap(capacity)
+	if pass == nil || block == nil {
+		return nil
+	}
+	return &paramCounters{pass: pass, block: block}
+}
+
+// AddPass 增加参数值的通过数
+func (c *ParamStatCounter) AddPass(arg interface{}, count int64) {
+	if c == nil {
+		return
+	}
+	addParamCount(c.counters.Load().(*paramCounters).pass, arg, count)
+}
+
+// AddBlock 增加参数值的阻塞数
+func (c *ParamStatCounter) AddBlock(arg interface{}, count int64) {
+	if c == nil {
+		return
+	}
+	addParamCount(c.counters.Load().(*paramCounters).block, arg, count)
+}
+
+func addParamCount(counter cache.ConcurrentCounterCache, arg interface{}, count int64) {
+	value := new(int64)
+	*value = count
+	if prior := counter.AddIfAbsent(arg, value); prior != nil {
+		atomic.AddInt64(prior, count)
+	}
+}
+
+// TakeTopN 返回阻塞数、通过数最大的n个参数值并清空统计，开始新的统计周期
+func (c *ParamStatCounter) TakeTopN(n int) []ParamCount {
+	if c == nil || n <= 0 {
+		return nil
+	}
+	c.takeMux.Lock()
+	defer c.takeMux.Unlock()
+	last := c.counters.Load().(*paramCounters)
+	c.counters.Store(newParamCounters(c.capacity))
+
+	counts := make(map[interface{}]*ParamCount, last.pass.Len())
+	countOf := func(key interface{}) *ParamCount {
+		pc, ok := counts[key]
+		if !ok {
+			pc = &ParamCount{Value: fmt.Sprint(key)}
+			counts[key] = pc
+		}
+		return pc
+	}
+	for _, key := range last.pass.Keys() {
+		if value, ok := last.pass.Get(key); ok {
+			countOf(key).PassCount = atomic.LoadInt64(value)
+		}
+	}
+	for _, key := range last.block.Keys() {
+		if value, ok := last.block.Get(key); ok {
+			countOf(key).BlockCount = atomic.LoadInt64(value)
+		}
+	}
+	ret := make([]ParamCount, 0, len(counts))
+	for _, pc := range counts {
+		ret = append(ret, *pc)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].BlockCount != ret[j].BlockCount {
+			return ret[i].BlockCount > ret[j].BlockCount
+		}
+		if ret[i].PassCount != ret[j].PassCount {
+			return ret[i].PassCount > ret[j].PassCount
+		}
+		return ret[i].Value < ret[j].Value
+	})
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// recordParamStat 记录参数值的检查结果
+func recordParamStat(tc TrafficShapingController, arg interface{}, batch int64, result *base.TokenResult) {
+	metric := tc.BoundMetric()
+	if metric == nil {
+		return
+	}
+	if result != nil && result.IsBlocked() {
+		metric.ParamStatCounter.AddBlock(arg, batch)
+	} else {
+		metric.ParamStatCounter.AddPass(arg, batch)
+	}
+}
+
+// InitTopParamsTask 启动热点参数值快照任务，每intervalMs调用一次 TakeTopParamsSnapshot，
+// 即统计周期为intervalMs，每条规则保留topN个参数值，topN为0时使用 DefaultTopParamsNum。
+// 快照任务独立于指标日志聚合任务，关闭指标日志时仍然刷新快照及Prometheus指标
+func InitTopParamsTask(intervalMs uint32, topN uint32) {
+	if intervalMs == 0 {
+		return
+	}
+	topParamsOnce.Do(func() {
+		if topN > 0 {
+			topParamsNum = int(topN)
+		}
+		ticker := util.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+		go util.RunWithRecover(func() {
+			for {
+				select {
+				case <-ticker.C():
+					TakeTopParamsSnapshot(util.CurrentTimeMillis())
+				case <-topParamsStopChan:
+					ticker.Stop()
+					return
+				}
+			}
+		})
+	})
+}
+
+// TakeTopParamsSnapshot 生成全部热点规则在当前统计周期内的TopN参数值快照并开始新的统计周期，
+// 快照可以通过 GetTopParams 获取，同时刷新Prometheus指标。由 InitTopParamsTask 启动的任务周期性调用
+func TakeTopParamsSnapshot(timestamp uint64) []*TopParams {
+	tcMux.RLock()
+	tcs := make([]TrafficShapingController, 0, len(tcMap))
+	for _, resTcs := range tcMap {
+		tcs = append(tcs, resTcs...)
+	}
+	tcMux.RUnlock()
+
+	snapshot := make([]*TopParams, 0, len(tcs))
+	for _, tc := range tcs {
+		metric := tc.BoundMetric()
+		if metric == nil {
+			continue
+		}
+		params := metric.ParamStatCounter.TakeTopN(topParamsNum)
+		if len(params) == 0 {
+			continue
+		}
+		snapshot = append(snapshot, &TopParams{
+			Resource:  tc.BoundRule().Resource,
+			RuleId:    tc.BoundRule().ID,
+			Timestamp: timestamp,
+			Params:    params,
+		})
+	}
+	sort.SliceStable(snapshot, func(i, j int) bool {
+		if snapshot[i].Resource != snapshot[j].Resource {
+			return snapshot[i].Resource < snapshot[j].Resource
+		}
+		return snapshot[i].RuleId < snapshot[j].RuleId
+	})
+
+	topParamsMux.Lock()
+	topParams = snapshot
+	topParamsMux.Unlock()
+
+	// 只保留最近一个周期的序列，避免参数值变化导致序列无限增长
+	topParamCountGauge.Reset()
+	for _, t := range snapshot {
+		for _, p := range t.Params {
+			topParamCountGauge.Set(float64(p.PassCount), t.Resource, t.RuleId, p.Value, "pass")
+			topParamCountGauge.Set(float64(p.BlockCount), t.Resource, t.RuleId, p.Value, "block")
+		}
+	}
+	return snapshot
+}
+
+// GetTopParams 返回最近一次快照中resource下每条规则的前n个热点参数值，resource为空时返回全部资源，n小于等于0时不截断
+func GetTopParams(resource string, n int) []TopParams {
+	topParamsMux.RLock()
+	defer topParamsMux.RUnlock()
+	ret := make([]TopParams, 0, len(topParams))
+	for _, t := range topParams {
+		if len(resource) > 0 && t.Resource != resource {
+			continue
+		}
+		cp := *t
+		if n > 0 && len(cp.Params) > n {
+			cp.Params = cp.Params[:n]
+		}
+		ret = append(ret, cp)
+	}
+	return ret
+}
@@ -0,0 +1,133 @@
+package hotspot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+func TestParamStatCounter_TakeTopN(t *testing.T) {
+	c := NewParamStatCounter(3)
+	c.AddPass("a", 5)
+	c.AddPass("b", 1)
+	c.AddBlock("b", 2)
+	c.AddPass(int64(7), 3)
+	assert.Equal(t, []ParamCount{{Value: "b", PassCount: 1, BlockCount: 2}}, c.TakeTopN(1))
+
+	// 超过容量时淘汰最久未访问的参数值
+	c.AddPass("a", 5)
+	c.AddPass("b", 2)
+	c.AddPass(int64(7), 3)
+	c.AddPass("a", 1)
+	c.AddPass("d", 1)
+	c.AddBlock("d", 1)
+	assert.Equal(t, []ParamCount{
+		{Value: "d", PassCount: 1, BlockCount: 1},
+		{Value: "a", PassCount: 6},
+		{Value: "7", PassCount: 3},
+	}, c.TakeTopN(3))
+	// 取出后开始新的统计周期
+	assert.Empty(t, c.TakeTopN(2))
+
+	// 并发更新计数
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.AddPass("a", 1)
+				c.AddBlock("b", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []ParamCount{
+		{Value: "b", BlockCount: 1000},
+		{Value: "a", PassCount: 1000},
+	}, c.TakeTopN(3))
+
+	var nilCounter *ParamStatCounter
+	nilCounter.AddPass("a", 1)
+	assert.Nil(t, nilCounter.TakeTopN(1))
+}
+
+func TestTakeTopParamsSnapshot(t *testing.T) {
+	defer func() { _ = ClearRules() }()
+	_, err := LoadRules([]*Rule{{
+		ID:            "hot",
+		Resource:      "hot-res",
+		MetricType:    QPS,
+		ParamIdx:      0,
+		Threshold:     1,
+		DurationInSec: 10,
+	}})
+	assert.Nil(t, err)
+
+	for _, arg := range []string{"u1", "u1", "u1", "u2"} {
+		ctx := base.NewSlotChain().GetPooledContext()
+		ctx.Resource = base.NewResourceWrapper("hot-res", base.ResTypeCommon, base.Inbound)
+		ctx.Input.BatchCount = 1
+		ctx.Input.Args = []interface{}{arg}
+		DefaultSlot.Check(ctx)
+	}
+
+	snapshot := TakeTopParamsSnapshot(1000)
+	expected := TopParams{
+		Resource:  "hot-res",
+		RuleId:    "hot",
+		Timestamp: 1000,
+		Params: []ParamCount{
+			{Value: "u1", PassCount: 1, BlockCount: 2},
+			{Value: "u2", PassCount: 1},
+		},
+	}
+	assert.Equal(t, []*TopParams{&expected}, snapshot)
+	assert.Equal(t, []TopParams{expected}, GetTopParams("", 0))
+	assert.Equal(t, expected.Params[:1], GetTopParams("hot-res", 1)[0].Params)
+	assert.Empty(t, GetTopParams("other", 0))
+
+	items := snapshot[0].MetricItems()
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, "__hot_param__:hot-res:hot:u1", items[0].Resource)
+	assert.Equal(t, uint64(1), items[0].PassQps)
+	assert.Equal(t, uint64(2), items[0].BlockQps)
+	assert.Equal(t, uint64(2), items[0].BlockedNumByHotspotParam)
+
+	// 没有新请求时快照为空
+	assert.Empty(t, TakeTopParamsSnapshot(2000))
+	assert.Empty(t, GetTopParams("", 0))
+}
+
+func TestInitTopParamsTask(t *testing.T) {
+	defer func() { _ = ClearRules() }()
+	_, err := LoadRules([]*Rule{{
+		ID:            "hot-task",
+		Resource:      "hot-task-res",
+		MetricType:    QPS,
+		ParamIdx:      0,
+		Threshold:     10,
+		DurationInSec: 10,
+	}})
+	assert.Nil(t, err)
+
+	for _, arg := range []string{"u1", "u1", "u2"} {
+		ctx := base.NewSlotChain().GetPooledContext()
+		ctx.Resource = base.NewResourceWrapper("hot-task-res", base.ResTypeCommon, base.Inbound)
+		ctx.Input.BatchCount = 1
+		ctx.Input.Args = []interface{}{arg}
+		DefaultSlot.Check(ctx)
+	}
+
+	InitTopParamsTask(10, 1)
+	defer close(topParamsStopChan)
+	// 快照任务独立运行，每条规则只保留topN个参数值
+	assert.Eventually(t, func() bool {
+		return len(GetTopParams("hot-task-res", 0)) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []ParamCount{{Value: "u1", PassCount: 2}}, GetTopParams("hot-task-res", 0)[0].Params)
+}
@@ -18,4 +18,6 @@ type ParamsMetric struct {
 	RuleTokenCounter cache.ConcurrentCounterCache
 	// ConcurrencyCounter 记录实时并发数
 	ConcurrentCounter cache.ConcurrentCounterCache
+	// ParamStatCounter 记录参数值在统计周期内的通过数及阻塞数，用于上报热点参数值
+	ParamStatCounter *ParamStatCounter
 }
@@ -89,8 +89,9 @@ const (
 type ClusterStrategy int32
 
 const (
-	ThresholdAvgLocal ClusterStrategy = 1
-	ThresholdGlobal   ClusterStrategy = 2
+	ThresholdAvgLocal    ClusterStrategy = 1
+	ThresholdGlobal      ClusterStrategy = 2
+	ThresholdGlobalRedis ClusterStrategy = 3
 )
 
 func (c ClusterStrategy) String() string {
@@ -99,6 +100,8 @@ func (c ClusterStrategy) String() string {
 		return "FlowThresholdAvgLocal"
 	case ThresholdGlobal:
 		return "FlowThresholdGlobal"
+	case ThresholdGlobalRedis:
+		return "FlowThresholdGlobalRedis"
 	default:
 		return "Undefined"
 	}
@@ -116,6 +119,7 @@ type ClusterConfig struct {
 	// ClusterStrategy 集群策略
 	// FLOW_THRESHOLD_AVG_LOCAL-1：全局均摊，此策略每个节点的阈值在server端计算
 	// FLOW_THRESHOLD_GLOBAL-2： 全局限流，此策略下向TokenServer获取Token
+	// FLOW_THRESHOLD_GLOBAL_REDIS-3： Redis全局限流，此策略下向RedisTokenServer获取Token
 	ClusterStrategy int32 `json:"clusterStrategy"`
 	// ResourceTimeout 如果客户端保持Token的时间超过ResourceTimeout，resourceTimeoutStrategy策略将会生效
 	ResourceTimeout int64 `json:"resourceTimeout"`
@@ -6,6 +6,7 @@ import (
 	"github.com/liuhailove/gmiter/util"
 	"github.com/pkg/errors"
 	"reflect"
+	"sort"
 	"sync"
 )
 
@@ -21,6 +22,9 @@ var (
 	tcMux         = new(sync.RWMutex)
 	currentRules  = make(map[string][]*Rule, 0)
 	updateRuleMux = new(sync.Mutex)
+	// sourceRules 其他模块(如网关)通过LoadRulesOfSource加载的规则，按来源及资源分组，
+	// 与LoadRules加载的规则按资源合并后生效，不会被LoadRules及LoadRulesOfResource覆盖
+	sourceRules = make(map[string]map[string][]*Rule)
 )
 
 func init() {
@@ -144,8 +148,9 @@ func onRuleUpdate(rawResRulesMap map[string][]*Rule) (err error) {
 	}()
 
 	// ignore invalid rules
-	validResRulesMap := make(map[string][]*Rule, len(rawResRulesMap))
-	for res, rules := range rawResRulesMap {
+	mergedResRulesMap := withSourceRules(rawResRulesMap)
+	validResRulesMap := make(map[string][]*Rule, len(mergedResRulesMap))
+	for res, rules := range mergedResRulesMap {
 		validResRules := make([]*Rule, 0, len(rules))
 		for _, rule := range rules {
 			if err := IsValidRule(rule); err != nil {
@@ -194,8 +199,9 @@ func onResourceRuleUpdate(res string, rawResRules []*Rule) (err error) {
 			}
 		}
 	}()
-	validResRules := make([]*Rule, 0, len(rawResRules))
-	for _, rule := range rawResRules {
+	mergedResRules := append(append(make([]*Rule, 0, len(rawResRules)), rawResRules...), sourceRulesOf(res)...)
+	validResRules := make([]*Rule, 0, len(mergedResRules))
+	for _, rule := range mergedResRules {
 		if err := IsValidRule(rule); err != nil {
 			logging.Warn("[HotSpot onResourceRuleUpdate] Ignoring invalid hotspot param flow rule", "rule", rule, "reason", err.Error())
 			continue
@@ -218,7 +224,11 @@ func onResourceRuleUpdate(res string, rawResRules []*Rule) (err error) {
 	}
 	tcMux.Unlock()
 
-	currentRules[res] = rawResRules
+	if len(rawResRules) == 0 {
+		delete(currentRules, res)
+	} else {
+		currentRules[res] = rawResRules
+	}
 	logging.Debug("[HotSpot onResourceRuleUpdate] Time statistic(ns) for updating hotspot param flow rules", "timeCost", util.CurrentTimeNano()-start)
 	logging.Info("[HotSpot] load resource level hotspot param flow rules", "resource", res, "validResRules", validResRules)
 	return nil
@@ -237,6 +247,10 @@ func LoadRulesOfResource(res string, rules []*Rule) (bool, error) {
 
 	// clear resource rules
 	if len(rules) == 0 {
+		// 存在其他来源的规则时只清除LoadRules加载的规则
+		if len(sourceRulesOf(res)) > 0 {
+			return true, onResourceRuleUpdate(res, nil)
+		}
 		// clear resource's currentRules
 		delete(currentRules, res)
 		// clear tcMap
@@ -258,6 +272,68 @@ func LoadRulesOfResource(res string, rules []*Rule) (bool, error) {
 	return true, err
 }
 
+// LoadRulesOfSource replaces all the hotspot param flow rules of the given source, such as the rules converted from gateway rules.
+// 不同来源的规则与LoadRules加载的规则按资源合并后生效，LoadRules及LoadRulesOfResource不会覆盖其他来源的规则。
+// The first returned value indicates whether you do real load operation, if the rules is the same with previous source's rules, return false.
+func LoadRulesOfSource(source string, rules []*Rule) (bool, error) {
+	if len(source) == 0 {
+		return false, errors.New("empty source")
+	}
+	resRulesMap := make(map[string][]*Rule, 16)
+	for _, rule := range rules {
+		resRulesMap[rule.Resource] = append(resRulesMap[rule.Resource], rule)
+	}
+
+	updateRuleMux.Lock()
+	defer updateRuleMux.Unlock()
+	if len(resRulesMap) == 0 && len(sourceRules[source]) == 0 || reflect.DeepEqual(sourceRules[source], resRulesMap) {
+		logging.Info("[HotSpot] Load rules of source is the same with current rules, so ignore load operation.", "source", source)
+		return false, nil
+	}
+	if len(resRulesMap) == 0 {
+		delete(sourceRules, source)
+	} else {
+		sourceRules[source] = resRulesMap
+	}
+	err := onRuleUpdate(currentRules)
+	return true, err
+}
+
+// withSourceRules 按资源合并resRulesMap及各来源的规则，来源按名称排序以保证规则顺序稳定
+func withSourceRules(resRulesMap map[string][]*Rule) map[string][]*Rule {
+	if len(sourceRules) == 0 {
+		return resRulesMap
+	}
+	merged := make(map[string][]*Rule, len(resRulesMap))
+	for res, rules := range resRulesMap {
+		merged[res] = append(merged[res], rules...)
+	}
+	for _, source := range sortedSources() {
+		for res, rules := range sourceRules[source] {
+			merged[res] = append(merged[res], rules...)
+		}
+	}
+	return merged
+}
+
+// sourceRulesOf 返回各来源在资源res上的规则
+func sourceRulesOf(res string) []*Rule {
+	var rules []*Rule
+	for _, source := range sortedSources() {
+		rules = append(rules, sourceRules[source][res]...)
+	}
+	return rules
+}
+
+func sortedSources() []string {
+	sources := make([]string, 0, len(sourceRules))
+	for source := range sourceRules {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
 func logRuleUpdate(m map[string][]*Rule) {
 	rules := make([]*Rule, 0, 8)
 	for _, rs := range m {
@@ -47,7 +47,8 @@ func (s *Slot) Check(ctx *base.EntryContext) *base.TokenResult {
 		if !needContinueCheck {
 			continue
 		}
-		r := canPassCheck(tc, arg, batch)
+		r := canPassCheck(ctx, tc, arg, batch)
+		recordParamStat(tc, arg, batch, r)
 		if r == nil {
 			continue
 		}
@@ -65,8 +66,12 @@ func (s *Slot) Check(ctx *base.EntryContext) *base.TokenResult {
 	return result
 }
 
-func canPassCheck(tc TrafficShapingController, arg interface{}, batch int64) *base.TokenResult {
+func canPassCheck(ctx *base.EntryContext, tc TrafficShapingController, arg interface{}, batch int64) *base.TokenResult {
 	var rule = tc.BoundRule()
+	if isRedisClusterMode(rule) {
+		// Redis全局限流
+		return canPassRedisCheck(ctx, tc, arg, batch)
+	}
 	if rule.MetricType == QPS &&
 		rule.ClusterMode &&
 		rule.ClusterConfig != nil &&
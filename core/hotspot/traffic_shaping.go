@@ -109,6 +109,7 @@ func newBaseTrafficShapingController(r *Rule) *baseTrafficShapingController {
 		metric := &ParamsMetric{
 			RuleTimeCounter:  cache.NewLRUCacheMap(size),
 			RuleTokenCounter: cache.NewLRUCacheMap(size),
+			ParamStatCounter: NewParamStatCounter(size),
 		}
 		return newBaseTrafficShapingControllerWithMetric(r, metric)
 	case Concurrency:
@@ -118,7 +119,10 @@ func newBaseTrafficShapingController(r *Rule) *baseTrafficShapingController {
 		} else {
 			size = ConcurrencyMaxCount
 		}
-		metric := &ParamsMetric{ConcurrentCounter: cache.NewLRUCacheMap(size)}
+		metric := &ParamsMetric{
+			ConcurrentCounter: cache.NewLRUCacheMap(size),
+			ParamStatCounter:  NewParamStatCounter(size),
+		}
 		return newBaseTrafficShapingControllerWithMetric(r, metric)
 	default:
 		logging.Error(errors.New("unsupported metric type"), "Ignoring the rule due to unsupported  metric type in Rule.newBaseTrafficShapingController()", "MetricType", r.MetricType.String())
@@ -205,6 +209,29 @@ func (c *baseTrafficShapingController) ExtractArgs(ctx *base.EntryContext) (valu
 	return
 }
 
+// ExtractParam 按照与热点规则相同的来源(Attachments、Header、Metadata、参数)从ctx中抽取参数，未匹配时返回nil
+func ExtractParam(ctx *base.EntryContext, paramSource ParameterSourceType, paramIdx int, paramKey string, paramKind ParamKind) interface{} {
+	if ctx == nil || ctx.Input == nil {
+		return nil
+	}
+	c := &baseTrafficShapingController{
+		paramIndex:  paramIdx,
+		paramKey:    paramKey,
+		paramKind:   paramKind,
+		paramSource: paramSource,
+	}
+	if len(ctx.Input.Args) == 0 {
+		if value := c.extractAttachmentArgs(ctx); value != nil {
+			return value
+		}
+		if value := c.extractHeader(ctx); value != nil {
+			return value
+		}
+		return c.extractMetadata(ctx)
+	}
+	return c.ExtractArgs(ctx)
+}
+
 // extractHeader 从header中抽取参数
 func (c *baseTrafficShapingController) extractHeader(ctx *base.EntryContext) interface{} {
 	if ParameterTypeHeader != c.paramSource {
@@ -0,0 +1,76 @@
+package isolation
+
+import (
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/spi"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// concurrencyTokensKey EntryContext.Data 中保存已申请的集群并发Token的key
+type concurrencyTokensKey struct{}
+
+// concurrencyToken 已申请的集群并发Token，在Entry退出时释放
+type concurrencyToken struct {
+	rule    string
+	tokenId string
+}
+
+// redisTokenService 获取通过SPI注册的RedisTokenService，未注册时返回nil
+func redisTokenService() base.TokenService {
+	var inst = spi.GetRegisterTokenServiceInst(constants.RedisTokenServiceType)
+	if inst == nil {
+		return nil
+	}
+	return inst.GetTokenService()
+}
+
+// canPassClusterCheck 向RedisTokenService申请并发Token，
+// 返回是否通过以及是否需要回退到本地隔离检查
+func canPassClusterCheck(ctx *base.EntryContext, rule *Rule, batchCount uint32) (passed bool, fallbackToLocal bool) {
+	var tokenService = redisTokenService()
+	if tokenService == nil {
+		return true, rule.ClusterConfig.FallbackToLocalWhenFail
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	if err != nil {
+		logging.Error(err, "Fail to marshal isolation rule in canPassClusterCheck()", "rule", rule)
+		return true, rule.ClusterConfig.FallbackToLocalWhenFail
+	}
+	var tokResult = tokenService.RequestConcurrentToken(string(data), batchCount)
+	switch base.TokResultStatus(tokResult.Status) {
+	case base.TokResultStatusOk:
+		var tokens, _ = ctx.Data[concurrencyTokensKey{}].([]concurrencyToken)
+		ctx.Data[concurrencyTokensKey{}] = append(tokens, concurrencyToken{rule: string(data), tokenId: fmt.Sprint(tokResult.TokenId)})
+		return true, false
+	case base.TokResultStatusBlocked:
+		return false, false
+	default:
+		logging.Warn("[Isolation] Fail to request cluster concurrent token", "rule", rule, "status", tokResult.Status)
+		return true, rule.ClusterConfig.FallbackToLocalWhenFail
+	}
+}
+
+// releaseConcurrencyTokens 释放本次Entry申请的集群并发Token
+func releaseConcurrencyTokens(ctx *base.EntryContext) {
+	var tokens, ok = ctx.Data[concurrencyTokensKey{}].([]concurrencyToken)
+	if !ok {
+		return
+	}
+	delete(ctx.Data, concurrencyTokensKey{})
+	var tokenService = redisTokenService()
+	if tokenService == nil {
+		return
+	}
+	for _, token := range tokens {
+		tokenService.ReleaseConcurrentToken(token.rule, token.tokenId)
+	}
+}
@@ -0,0 +1,103 @@
+package isolation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/spi"
+)
+
+type fakeTokenService struct {
+	base.TokenService
+	concurrentResult *base.TokResult
+	concurrentRule   string
+	released         []string
+}
+
+func (f *fakeTokenService) RequestConcurrentToken(rule string, acquireCount uint32) *base.TokResult {
+	f.concurrentRule = rule
+	return f.concurrentResult
+}
+
+func (f *fakeTokenService) ReleaseConcurrentToken(rule string, tokenId string) {
+	f.released = append(f.released, tokenId)
+}
+
+// fakeTokenServiceInitFunc 注册fakeTokenService，order需大于已注册的实例才会覆盖
+type fakeTokenServiceInitFunc struct {
+	order        int
+	registerType constants.RegisterType
+	tokenService *fakeTokenService
+}
+
+func (f *fakeTokenServiceInitFunc) Initial() error             { return nil }
+func (f *fakeTokenServiceInitFunc) Order() int                 { return f.order }
+func (f *fakeTokenServiceInitFunc) ImmediatelyLoadOnce() error { return nil }
+func (f *fakeTokenServiceInitFunc) GetRegisterType() constants.RegisterType {
+	return f.registerType
+}
+func (f *fakeTokenServiceInitFunc) GetTokenService() base.TokenService { return f.tokenService }
+func (f *fakeTokenServiceInitFunc) ReInitial() error                   { return nil }
+
+func TestClusterCheck(t *testing.T) {
+	tokenService := &fakeTokenService{}
+	spi.Register(&fakeTokenServiceInitFunc{order: 1000, registerType: constants.RedisTokenServiceType, tokenService: tokenService})
+
+	rule := &Rule{
+		ID:            "1",
+		Resource:      "job",
+		MetricType:    Concurrency,
+		Threshold:     1,
+		ClusterMode:   true,
+		ClusterConfig: &ClusterConfig{ClientOfflineTime: 60000},
+	}
+	ctx := base.NewEmptyEntryContext()
+	ctx.Resource = base.NewResourceWrapper("job", base.ResTypeTask, base.Inbound)
+	ctx.Data = make(map[interface{}]interface{})
+
+	tokenService.concurrentResult = &base.TokResult{Status: int(base.TokResultStatusOk), TokenId: 7}
+	passed, fallbackToLocal := canPassClusterCheck(ctx, rule, 1)
+	assert.True(t, passed)
+	assert.False(t, fallbackToLocal)
+	assert.Contains(t, tokenService.concurrentRule, `"resource":"job"`)
+	assert.Contains(t, tokenService.concurrentRule, `"clientOfflineTime":60000`)
+	DefaultStatSlot.OnCompleted(ctx)
+	assert.Equal(t, []string{"7"}, tokenService.released)
+	// 已释放的Token不会重复释放
+	DefaultStatSlot.OnCompleted(ctx)
+	assert.Equal(t, []string{"7"}, tokenService.released)
+
+	tokenService.concurrentResult = base.BlockedResult
+	passed, _ = canPassClusterCheck(ctx, rule, 1)
+	assert.False(t, passed)
+
+	// TokenService异常时按FallbackToLocalWhenFail决定是否回退本地隔离
+	tokenService.concurrentResult = base.FailResult
+	passed, fallbackToLocal = canPassClusterCheck(ctx, rule, 1)
+	assert.True(t, passed)
+	assert.False(t, fallbackToLocal)
+	rule.ClusterConfig.FallbackToLocalWhenFail = true
+	passed, fallbackToLocal = canPassClusterCheck(ctx, rule, 1)
+	assert.True(t, passed)
+	assert.True(t, fallbackToLocal)
+}
+
+func TestIsValidClusterRule(t *testing.T) {
+	rule := &Rule{Resource: "job", MetricType: Concurrency, Threshold: 1, ClusterMode: true}
+	assert.NotNil(t, IsValidRule(rule))
+	rule.ClusterConfig = &ClusterConfig{}
+	assert.NotNil(t, IsValidRule(rule))
+	rule.ID = "1"
+	assert.Nil(t, IsValidRule(rule))
+	rule.ClusterConfig.ClusterStrategy = ThresholdGlobalRedis
+	assert.Nil(t, IsValidRule(rule))
+	// TokenServer不支持并发Token
+	rule.ClusterConfig.ClusterStrategy = ClusterStrategy(flow.ThresholdGlobal)
+	assert.NotNil(t, IsValidRule(rule))
+	rule.ClusterConfig.ClusterStrategy = 1
+	assert.NotNil(t, IsValidRule(rule))
+}
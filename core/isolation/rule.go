@@ -23,6 +23,24 @@ func (s MetricType) String() string {
 	}
 }
 
+// ClusterStrategy 集群并发隔离策略，取值与flow.ClusterStrategy保持一致。
+// TokenServer(DefaultTokenService)暂不支持并发Token，只支持ThresholdGlobalRedis
+type ClusterStrategy int32
+
+const (
+	// ThresholdGlobalRedis 向RedisTokenService申请并发Token，未设置集群策略时的默认值
+	ThresholdGlobalRedis ClusterStrategy = 3
+)
+
+func (c ClusterStrategy) String() string {
+	switch c {
+	case ThresholdGlobalRedis:
+		return "ThresholdGlobalRedis"
+	default:
+		return "Undefined"
+	}
+}
+
 // Rule 描述隔离策略（例如信号量隔离）
 type Rule struct {
 	// ID 规则唯一ID（可选）
@@ -39,6 +57,28 @@ type Rule struct {
 	MetricType MetricType `json:"metricType"`
 	// Threshold 阈值
 	Threshold uint32 `json:"threshold"`
+	// ClusterMode 是否为集群模式，集群模式下按ClusterConfig.ClusterStrategy向对应的TokenService申请并发Token，实现跨实例的并发隔离
+	ClusterMode bool `json:"clusterMode"`
+	// ClusterConfig 集群配置，ClusterMode为true时必填
+	ClusterConfig *ClusterConfig `json:"clusterConfig"`
+}
+
+// ClusterConfig 集群并发隔离配置，字段与flow.ClusterConfig保持一致
+type ClusterConfig struct {
+	// ClusterStrategy 集群策略，目前只支持ThresholdGlobalRedis，为0时取ThresholdGlobalRedis
+	ClusterStrategy ClusterStrategy `json:"clusterStrategy"`
+	// FallbackToLocalWhenFail 当向tokenServer请求Token失败时，是否要被回退到本地隔离，
+	// true: 回退到本地隔离
+	// false：不回退，直接通过
+	FallbackToLocalWhenFail bool `json:"fallbackToLocalWhenFail"`
+	// ResourceTimeout 如果客户端保持Token的时间超过ResourceTimeout，resourceTimeoutStrategy策略将会生效
+	ResourceTimeout int64 `json:"resourceTimeout"`
+	// ResourceTimeoutStrategy 资源超时策略， 0-忽略， 1-释放Token
+	ResourceTimeoutStrategy int32 `json:"resourceTimeoutStrategy"`
+	// ClientOfflineTime 如果一个客户端下线了，tokenServer会在ClientOfflineTime后删除这个client保持的全部token
+	ClientOfflineTime int64 `json:"clientOfflineTime"`
+	// GlobalThreshold 全局并发阈值，为0时取规则的Threshold
+	GlobalThreshold float64 `json:"globalThreshold"`
 }
 
 func (r *Rule) String() string {
@@ -206,5 +206,19 @@ func IsValidRule(r *Rule) error {
 	if r.Threshold == 0 {
 		return errors.New("zero threshold")
 	}
+	if r.ClusterMode {
+		if r.ClusterConfig == nil {
+			return errors.New("nil cluster config of cluster mode isolation rule")
+		}
+		if len(r.ID) == 0 {
+			return errors.New("empty id of cluster mode isolation rule")
+		}
+		if r.ClusterConfig.GlobalThreshold < 0 {
+			return errors.New("negative global threshold")
+		}
+		if s := r.ClusterConfig.ClusterStrategy; s != 0 && s != ThresholdGlobalRedis {
+			return errors.Errorf("unsupported cluster strategy: %d", s)
+		}
+	}
 	return nil
 }
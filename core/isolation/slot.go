@@ -50,6 +50,16 @@ func checkPass(ctx *base.EntryContext) (bool, *Rule, uint32) {
 	curCount := uint32(0)
 	for _, rule := range getRulesOfResource(ctx.Resource.Name()) {
 		threshold := rule.Threshold
+		if rule.MetricType == Concurrency && rule.ClusterMode && rule.ClusterConfig != nil {
+			// 集群并发隔离
+			passed, fallbackToLocal := canPassClusterCheck(ctx, rule, batchCount)
+			if !passed {
+				return false, rule, curCount
+			}
+			if !fallbackToLocal {
+				continue
+			}
+		}
 		if rule.MetricType == Concurrency {
 			if cur := statNode.CurrentConcurrency(); cur >= 0 {
 				curCount = uint32(cur)
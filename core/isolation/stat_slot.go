@@ -0,0 +1,39 @@
+package isolation
+
+import (
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	StatSlotOrder = 3500
+)
+
+var (
+	DefaultStatSlot = &StatSlot{}
+)
+
+// StatSlot 在Entry退出或被阻断时释放集群模式下申请的并发Token
+type StatSlot struct {
+}
+
+func (s *StatSlot) Order() uint32 {
+	return StatSlotOrder
+}
+
+// Initial
+//
+// 初始化，如果有初始化工作放入其中
+func (s *StatSlot) Initial() {
+}
+
+func (s *StatSlot) OnEntryPassed(_ *base.EntryContext) {
+}
+
+func (s *StatSlot) OnEntryBlocked(ctx *base.EntryContext, _ *base.BlockError) {
+	// 被其他规则阻塞时，已申请的集群并发Token需要归还
+	releaseConcurrencyTokens(ctx)
+}
+
+func (s *StatSlot) OnCompleted(ctx *base.EntryContext) {
+	releaseConcurrencyTokens(ctx)
+}
@@ -3,6 +3,7 @@ package metric
 import (
 	"github.com/liuhailove/gmiter/core/base"
 	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/hotspot"
 	"github.com/liuhailove/gmiter/core/stat"
 	"github.com/liuhailove/gmiter/core/system_metric"
 	"github.com/liuhailove/gmiter/logging"
@@ -31,6 +32,9 @@ var (
 	// 当前map
 	currentMetricTimeMap = make(map[uint64][]*base.MetricItem)
 	mux                  sync.RWMutex
+
+	// 最近一次汇聚的热点参数值快照时间
+	lastHotParamsTime uint64
 )
 
 func InitTask() (err error) {
@@ -106,6 +110,8 @@ func doAggregate() {
 	// 汇聚CPU和负载
 	aggregateIntoMap(maps, currentCpuMetricItems(curTime), stat.CpuNode())
 	aggregateIntoMap(maps, currentLoadMetricItems(curTime), stat.LoadNode())
+	// 汇聚热点参数值
+	aggregateHotParamsIntoMap(maps, curTime)
 	// Update current last fetch timestamp.
 	lastFetchTime = int64(curTime)
 
@@ -131,6 +137,18 @@ func aggregateIntoMap(mm metricTimeMap, metrics map[uint64]*base.MetricItem, nod
 	}
 }
 
+// aggregateHotParamsIntoMap 汇聚热点参数值快照任务生成的最新TopN快照，同一快照只汇聚一次
+func aggregateHotParamsIntoMap(mm metricTimeMap, currentTime uint64) {
+	snapshot := hotspot.GetTopParams("", 0)
+	if len(snapshot) == 0 || snapshot[0].Timestamp <= lastHotParamsTime {
+		return
+	}
+	lastHotParamsTime = snapshot[0].Timestamp
+	for i := range snapshot {
+		mm[currentTime] = append(mm[currentTime], snapshot[i].MetricItems()...)
+	}
+}
+
 func isActiveMetricItem(item *base.MetricItem) bool {
 	return item.PassQps > 0 || item.BlockQps > 0 || item.CompleteQps > 0 || item.ErrorQps > 0 ||
 		item.AvgRt > 0 || item.Concurrency > 0
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/liuhailove/gmiter/core/hotspot"
 	"github.com/liuhailove/gmiter/core/stat"
 	"github.com/liuhailove/gmiter/util"
 	"github.com/stretchr/testify/assert"
@@ -269,6 +270,35 @@ func (sw *MockMetricLogWriter) Write(ts uint64, items []*base.MetricItem) error
 	return nil
 }
 
+func Test_aggregateHotParamsIntoMap(t *testing.T) {
+	defer func() { _ = hotspot.ClearRules() }()
+	_, err := hotspot.LoadRules([]*hotspot.Rule{{
+		ID:            "hot",
+		Resource:      "hot-res",
+		MetricType:    hotspot.QPS,
+		ParamIdx:      0,
+		Threshold:     10,
+		DurationInSec: 10,
+	}})
+	assert.Nil(t, err)
+	ctx := base.NewSlotChain().GetPooledContext()
+	ctx.Resource = base.NewResourceWrapper("hot-res", base.ResTypeCommon, base.Inbound)
+	ctx.Input.BatchCount = 1
+	ctx.Input.Args = []interface{}{"u1"}
+	hotspot.DefaultSlot.Check(ctx)
+	hotspot.TakeTopParamsSnapshot(1000)
+
+	mm := make(metricTimeMap)
+	aggregateHotParamsIntoMap(mm, 2000)
+	assert.Equal(t, 1, len(mm[2000]))
+	assert.Equal(t, "__hot_param__:hot-res:hot:u1", mm[2000][0].Resource)
+	assert.Equal(t, uint64(1), mm[2000][0].PassQps)
+	// 同一快照只汇聚一次
+	mm = make(metricTimeMap)
+	aggregateHotParamsIntoMap(mm, 3000)
+	assert.Empty(t, mm)
+}
+
 func Test_Aggregate(t *testing.T) {
 	t.Run("Test_aggregate", func(t *testing.T) {
 		util.SetClock(util.NewMockClock())
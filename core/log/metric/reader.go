@@ -6,6 +6,7 @@ import (
 	"github.com/liuhailove/gmiter/logging"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -150,7 +151,7 @@ func (r *defaultMetricLogReader) readMetricsInOneFileByEndTime(filename string,
 		}
 
 		// empty resource name indicates "fetch all"
-		if resource == "" || resource == item.Resource {
+		if resource == "" || resource == item.Resource || isHotParamItemOf(item, resource) {
 			items = append(items, item)
 		}
 		// Max items limit to avoid infinite reading
@@ -200,3 +201,8 @@ func openFileAndSeekTo(filename string, offset uint64) (*os.File, error) {
 func newDefaultMetricLogReader() MetricLogReader {
 	return &defaultMetricLogReader{}
 }
+
+// isHotParamItemOf 是否为resource的热点参数值指标项
+func isHotParamItemOf(item *base.MetricItem, resource string) bool {
+	return strings.HasPrefix(item.Resource, base.HotParamResourceNamePrefix+resource+":")
+}
@@ -15,12 +15,15 @@ type MetricBucket struct {
 	counter        [base.MetricEventTotal]int64
 	minRt          int64
 	maxConcurrency int32
+	// rtHistogram RT分布，用于计算分位RT
+	rtHistogram *RtHistogram
 }
 
 func NewMetricBucket() *MetricBucket {
 	mb := &MetricBucket{
 		minRt:          base.DefaultStatisticMaxRt,
 		maxConcurrency: 0,
+		rtHistogram:    NewRtHistogram(),
 	}
 	return mb
 }
@@ -57,10 +60,12 @@ func (mb *MetricBucket) reset() {
 	}
 	atomic.StoreInt64(&mb.minRt, base.DefaultStatisticMaxRt)
 	atomic.StoreInt32(&mb.maxConcurrency, int32(0))
+	mb.rtHistogram.reset()
 }
 
 func (mb *MetricBucket) AddRt(rt int64) {
 	mb.addCount(base.MetricEventRt, rt)
+	mb.rtHistogram.Add(rt)
 	if rt < atomic.LoadInt64(&mb.minRt) {
 		// Might not be accurate here.
 		atomic.StoreInt64(&mb.minRt, rt)
@@ -71,6 +76,11 @@ func (mb *MetricBucket) MinRt() int64 {
 	return atomic.LoadInt64(&mb.minRt)
 }
 
+// RtHistogram 返回桶内的RT分布
+func (mb *MetricBucket) RtHistogram() *RtHistogram {
+	return mb.rtHistogram
+}
+
 func (mb *MetricBucket) UpdateConcurrency(concurrency int32) {
 	cc := concurrency
 	if cc > atomic.LoadInt32(&mb.maxConcurrency) {
@@ -0,0 +1,126 @@
+package base
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	// rtHistogramSubBucketBits 每个2的幂次区间内的子桶数为2^rtHistogramSubBucketBits，相对误差不超过1/2^rtHistogramSubBucketBits
+	rtHistogramSubBucketBits = 3
+	rtHistogramSubBucketNum  = 1 << rtHistogramSubBucketBits
+	// rtHistogramLinearMax 小于该值的RT每个值一个桶，精确统计
+	rtHistogramLinearMax = rtHistogramSubBucketNum << 1
+)
+
+// rtHistogramBucketNum RT直方图的桶数，最大记录base.DefaultStatisticMaxRt
+var rtHistogramBucketNum = rtHistogramIndexOf(base.DefaultStatisticMaxRt) + 1
+
+// RtHistogram 记录RT分布的直方图，采用HDR风格的对数分桶：
+// RT小于rtHistogramLinearMax时每个值一个桶，之后每个2的幂次区间等分为rtHistogramSubBucketNum个桶。
+// Note that all operations of the RtHistogram are thread-safe.
+type RtHistogram struct {
+	counts []int64
+	// total 记录的RT总数，与counts同步累加，避免Count遍历所有桶
+	total int64
+}
+
+func NewRtHistogram() *RtHistogram {
+	return &RtHistogram{counts: make([]int64, rtHistogramBucketNum)}
+}
+
+// rtHistogramIndexOf 返回rt所在桶的下标
+func rtHistogramIndexOf(rt int64) int {
+	if rt < 0 {
+		rt = 0
+	}
+	if rt > base.DefaultStatisticMaxRt {
+		rt = base.DefaultStatisticMaxRt
+	}
+	v := uint64(rt)
+	if v < rtHistogramLinearMax {
+		return int(v)
+	}
+	msb := bits.Len64(v) - 1
+	shift := msb - rtHistogramSubBucketBits
+	sub := int(v>>uint(shift)) & (rtHistogramSubBucketNum - 1)
+	return rtHistogramLinearMax + (msb-rtHistogramSubBucketBits-1)*rtHistogramSubBucketNum + sub
+}
+
+// rtHistogramUpperBoundOf 返回下标为index的桶可记录的最大RT
+func rtHistogramUpperBoundOf(index int) int64 {
+	if index < rtHistogramLinearMax {
+		return int64(index)
+	}
+	offset := index - rtHistogramLinearMax
+	shift := offset/rtHistogramSubBucketNum + 1
+	sub := offset % rtHistogramSubBucketNum
+	return int64((rtHistogramSubBucketNum+sub+1)<<uint(shift)) - 1
+}
+
+// Add 记录一次RT
+func (h *RtHistogram) Add(rt int64) {
+	atomic.AddInt64(&h.counts[rtHistogramIndexOf(rt)], 1)
+	atomic.AddInt64(&h.total, 1)
+}
+
+// Count 返回记录的RT总数
+func (h *RtHistogram) Count() int64 {
+	return atomic.LoadInt64(&h.total)
+}
+
+func (h *RtHistogram) reset() {
+	atomic.StoreInt64(&h.total, 0)
+	for i := range h.counts {
+		atomic.StoreInt64(&h.counts[i], 0)
+	}
+}
+
+// Reset 清空直方图
+func (h *RtHistogram) Reset() {
+	h.reset()
+}
+
+// PercentileRtOf 合并多个直方图并计算percentile(取值范围(0, 100])分位的RT，返回所在桶的上界，没有数据时返回0。
+// 按桶下标依次累加各直方图的计数，每个桶只遍历一次，不分配合并用的数组
+func PercentileRtOf(histograms []*RtHistogram, percentile float64) float64 {
+	if percentile <= 0 {
+		return 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	var total int64
+	for _, h := range histograms {
+		if h != nil {
+			total += h.Count()
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	// 第rank个RT所在的桶
+	rank := int64(math.Ceil(float64(total) * percentile / 100.0))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i := 0; i < rtHistogramBucketNum; i++ {
+		for _, h := range histograms {
+			if h != nil {
+				seen += atomic.LoadInt64(&h.counts[i])
+			}
+		}
+		if seen >= rank {
+			upper := rtHistogramUpperBoundOf(i)
+			if upper > base.DefaultStatisticMaxRt {
+				upper = base.DefaultStatisticMaxRt
+			}
+			return float64(upper)
+		}
+	}
+	return float64(base.DefaultStatisticMaxRt)
+}
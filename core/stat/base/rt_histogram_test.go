@@ -0,0 +1,62 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRtHistogramIndex(t *testing.T) {
+	// 每个RT都落在所在桶的范围内，且相对误差不超过1/rtHistogramSubBucketNum
+	for rt := int64(0); rt <= base.DefaultStatisticMaxRt; rt++ {
+		idx := rtHistogramIndexOf(rt)
+		upper := rtHistogramUpperBoundOf(idx)
+		assert.True(t, upper >= rt)
+		if idx > 0 {
+			assert.True(t, rtHistogramUpperBoundOf(idx-1) < rt)
+		}
+		if rt >= rtHistogramLinearMax {
+			assert.True(t, float64(upper-rt)/float64(rt) <= 1.0/rtHistogramSubBucketNum)
+		} else {
+			assert.Equal(t, rt, upper)
+		}
+	}
+	assert.Equal(t, rtHistogramBucketNum-1, rtHistogramIndexOf(base.DefaultStatisticMaxRt*2))
+	assert.Equal(t, 0, rtHistogramIndexOf(-1))
+}
+
+func TestPercentileRtOf(t *testing.T) {
+	h1 := NewRtHistogram()
+	h2 := NewRtHistogram()
+	assert.Equal(t, 0.0, PercentileRtOf([]*RtHistogram{h1, h2}, 99))
+	for rt := int64(1); rt <= 10; rt++ {
+		for i := 0; i < 9; i++ {
+			h1.Add(rt)
+		}
+	}
+	// 10%的慢请求
+	for i := 0; i < 10; i++ {
+		h2.Add(1000)
+	}
+	histograms := []*RtHistogram{h1, h2}
+	assert.Equal(t, int64(100), h1.Count()+h2.Count())
+	assert.Equal(t, 6.0, PercentileRtOf(histograms, 50))
+	assert.Equal(t, 10.0, PercentileRtOf(histograms, 90))
+	p99 := PercentileRtOf(histograms, 99)
+	assert.True(t, p99 >= 1000 && p99 <= 1000*(1+1.0/rtHistogramSubBucketNum))
+	assert.Equal(t, p99, PercentileRtOf(histograms, 100))
+
+	h2.Reset()
+	assert.Equal(t, 10.0, PercentileRtOf(histograms, 99))
+}
+
+func TestMetricBucketRtHistogram(t *testing.T) {
+	mb := NewMetricBucket()
+	mb.Add(base.MetricEventRt, 3)
+	mb.Add(base.MetricEventRt, 7)
+	assert.Equal(t, int64(2), mb.RtHistogram().Count())
+	assert.Equal(t, 7.0, PercentileRtOf([]*RtHistogram{mb.RtHistogram()}, 90))
+	mb.reset()
+	assert.Equal(t, int64(0), mb.RtHistogram().Count())
+}
@@ -181,6 +181,32 @@ func (m *SlidingWindowMetric) MaxConcurrency() int32 {
 	return maxConcurrency
 }
 
+// PercentileRT 合并统计窗口内各个桶的RT分布，计算percentile分位的RT
+func (m *SlidingWindowMetric) PercentileRT(percentile float64) float64 {
+	now := util.CurrentTimeMillis()
+	satisfiedBuckets := m.getSatisfiedBuckets(now)
+	return PercentileRtOf(histogramsOf(satisfiedBuckets), percentile)
+}
+
+// histogramsOf 返回桶的RT分布
+func histogramsOf(ws []*BucketWrap) []*RtHistogram {
+	histograms := make([]*RtHistogram, 0, len(ws))
+	for _, w := range ws {
+		mb := w.Value.Load()
+		if mb == nil {
+			logging.Error(errors.New("nil BucketWrap"), "Current bucket value is nil in SlidingWindowMetric.histogramsOf()")
+			continue
+		}
+		counter, ok := mb.(*MetricBucket)
+		if !ok {
+			logging.Error(errors.New("type assert failed"), "Fail to do type assert in SlidingWindowMetric.histogramsOf()", "expectType", "*MetricBucket", "actualType", reflect.TypeOf(mb).Name())
+			continue
+		}
+		histograms = append(histograms, counter.RtHistogram())
+	}
+	return histograms
+}
+
 func (m *SlidingWindowMetric) AvgRT() float64 {
 	return float64(m.GetSum(base.MetricEventRt)) / float64(m.GetSum(base.MetricEventComplete))
 }
@@ -250,9 +276,17 @@ func (m *SlidingWindowMetric) metricItemFromBuckets(ts uint64, ws []*BucketWrap)
 	} else {
 		item.AvgRt = uint64(allRt)
 	}
+	fillPercentileRt(item, histogramsOf(ws))
 	return item
 }
 
+// fillPercentileRt 填充MetricItem的分位RT
+func fillPercentileRt(item *base.MetricItem, histograms []*RtHistogram) {
+	item.P50Rt = uint64(PercentileRtOf(histograms, 50))
+	item.P90Rt = uint64(PercentileRtOf(histograms, 90))
+	item.P99Rt = uint64(PercentileRtOf(histograms, 99))
+}
+
 func (m *SlidingWindowMetric) metricItemFromBucket(w *BucketWrap) *base.MetricItem {
 	mi := w.Value.Load()
 	if mi == nil {
@@ -277,5 +311,6 @@ func (m *SlidingWindowMetric) metricItemFromBucket(w *BucketWrap) *base.MetricIt
 	} else {
 		item.AvgRt = uint64(mb.Get(base.MetricEventRt))
 	}
+	fillPercentileRt(item, []*RtHistogram{mb.RtHistogram()})
 	return item
 }
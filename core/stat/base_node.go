@@ -96,6 +96,10 @@ func (n *BaseStatNode) MinRT() float64 {
 	return n.metric.MinRT()
 }
 
+func (n *BaseStatNode) PercentileRT(percentile float64) float64 {
+	return n.metric.PercentileRT(percentile)
+}
+
 func (n *BaseStatNode) MaxConcurrency() int32 {
 	return n.metric.MaxConcurrency()
 }
@@ -68,3 +68,53 @@ func GetOrCreateResourceNode(resource string, resourceType base.ResourceType) *R
 	resNodeMap.Store(resource, node)
 	return node
 }
+
+// entranceNodeKey 入口资源下资源统计节点的key
+type entranceNodeKey struct {
+	entrance string
+	resource string
+}
+
+// entranceNodeMap 按照(入口资源, 资源)维度统计，用于链路流控，仅包含链路规则引用的(入口资源, 资源)，
+// 避免为每个入口与资源的组合创建统计节点
+var entranceNodeMap = sync.Map{}
+
+// EntranceResource 链路规则引用的(入口资源, 资源)
+type EntranceResource struct {
+	Entrance string
+	Resource string
+}
+
+// GetEntranceNode 返回经由入口资源entrance调用resource的统计节点
+func GetEntranceNode(entrance, resource string) *ResourceNode {
+	val, ok := entranceNodeMap.Load(entranceNodeKey{entrance: entrance, resource: resource})
+	if ok {
+		return val.(*ResourceNode)
+	}
+	return nil
+}
+
+// GetOrCreateEntranceNode 返回经由入口资源entrance调用resource的统计节点，不存在时创建
+func GetOrCreateEntranceNode(entrance, resource string, resourceType base.ResourceType) *ResourceNode {
+	key := entranceNodeKey{entrance: entrance, resource: resource}
+	if val, ok := entranceNodeMap.Load(key); ok {
+		return val.(*ResourceNode)
+	}
+	val, _ := entranceNodeMap.LoadOrStore(key, NewResourceNode(resource, resourceType))
+	return val.(*ResourceNode)
+}
+
+// RetainEntranceNodes 仅保留refs对应的入口统计节点，不存在时创建，其余节点被移除，在链路规则更新后调用
+func RetainEntranceNodes(refs []EntranceResource) {
+	retained := make(map[entranceNodeKey]struct{}, len(refs))
+	for _, ref := range refs {
+		GetOrCreateEntranceNode(ref.Entrance, ref.Resource, base.ResTypeCommon)
+		retained[entranceNodeKey{entrance: ref.Entrance, resource: ref.Resource}] = struct{}{}
+	}
+	entranceNodeMap.Range(func(key, _ interface{}) bool {
+		if _, ok := retained[key.(entranceNodeKey)]; !ok {
+			entranceNodeMap.Delete(key)
+		}
+		return true
+	})
+}
@@ -28,4 +28,10 @@ func (s *ResourceNodePrepareSlot) Prepare(ctx *base.EntryContext) {
 	node := GetOrCreateResourceNode(ctx.Resource.Name(), ctx.Resource.Classification())
 	// Set the resource node to the context.
 	ctx.StatNode = node
+	// 存在调用链路入口且被链路规则引用时按照(入口资源, 资源)维度统计
+	if ctx.Entrance != "" {
+		if entranceNode := GetEntranceNode(ctx.Entrance, ctx.Resource.Name()); entranceNode != nil {
+			ctx.EntranceStatNode = entranceNode
+		}
+	}
 }
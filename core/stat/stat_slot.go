@@ -40,6 +40,9 @@ func (s *Slot) Initial() {}
 
 func (s Slot) OnEntryPassed(ctx *base.EntryContext) {
 	s.recordPassFor(ctx.StatNode, ctx.Input.BatchCount)
+	if ctx.EntranceStatNode != nil {
+		s.recordPassFor(ctx.EntranceStatNode, ctx.Input.BatchCount)
+	}
 	if ctx.Resource.FlowType() == base.Inbound {
 		s.recordPassFor(InboundNode(), ctx.Input.BatchCount)
 	}
@@ -53,6 +56,9 @@ func (s Slot) OnEntryBlocked(ctx *base.EntryContext, blockError *base.BlockError
 	if blockError != nil {
 		s.recordBlockForType(ctx.StatNode, blockError.BlockType(), ctx.Input.BatchCount)
 	}
+	if ctx.EntranceStatNode != nil {
+		s.recordBlockFor(ctx.EntranceStatNode, ctx.Input.BatchCount)
+	}
 	if ctx.Resource.FlowType() == base.Inbound {
 		s.recordBlockFor(InboundNode(), ctx.Input.BatchCount)
 	}
@@ -65,6 +71,9 @@ func (s Slot) OnCompleted(ctx *base.EntryContext) {
 	rt := util.CurrentTimeMillis() - ctx.StartTime()
 	ctx.PutRt(rt)
 	s.recordCompleteFor(ctx.StatNode, ctx.Input.BatchCount, rt, ctx.Err())
+	if ctx.EntranceStatNode != nil {
+		s.recordCompleteFor(ctx.EntranceStatNode, ctx.Input.BatchCount, rt, ctx.Err())
+	}
 	if ctx.Resource.FlowType() == base.Inbound {
 		s.recordCompleteFor(InboundNode(), ctx.Input.BatchCount, rt, ctx.Err())
 	}
@@ -0,0 +1,96 @@
+package apollo
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+var (
+	ErrWriteNotSupported = errors.New("apollo datasource does not support write, please publish rules on apollo portal")
+)
+
+// ApolloDataSource 基于apollo的规则数据源，一个实例对应命名空间中的一个配置项
+type ApolloDataSource struct {
+	datasource.Base
+	watcher       *Watcher
+	propertyKey   string
+	isInitialized util.AtomicBool
+	closed        util.AtomicBool
+}
+
+// NewDatasource 创建apollo数据源，watcher需由调用方启动
+func NewDatasource(watcher *Watcher, key string, handlers ...datasource.PropertyHandler) (*ApolloDataSource, error) {
+	if watcher == nil {
+		return nil, errors.New("nil apollo watcher")
+	}
+	ds := &ApolloDataSource{
+		watcher:     watcher,
+		propertyKey: key,
+	}
+	for _, h := range handlers {
+		ds.AddPropertyHandler(h)
+	}
+	return ds, nil
+}
+
+func (s *ApolloDataSource) ReadSource() ([]byte, error) {
+	value, ok := s.watcher.Get(s.propertyKey)
+	if !ok {
+		return nil, nil
+	}
+	return []byte(value), nil
+}
+
+func (s *ApolloDataSource) Initialize() error {
+	if !s.isInitialized.CompareAndSet(false, true) {
+		return nil
+	}
+	// 先注册监听再读取，避免漏掉两者之间的变更
+	s.watcher.AddListener(s.onChange)
+	if err := s.doReadAndUpdate(); err != nil {
+		logging.Error(err, "Fail to execute ApolloDataSource.doReadAndUpdate", "key", s.propertyKey)
+	}
+	return nil
+}
+
+func (s *ApolloDataSource) Write(bytes []byte) error {
+	return ErrWriteNotSupported
+}
+
+func (s *ApolloDataSource) doReadAndUpdate() error {
+	src, err := s.ReadSource()
+	if err != nil {
+		return err
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	return s.Handle(src)
+}
+
+func (s *ApolloDataSource) onChange(key, value string) {
+	if key != s.propertyKey || s.closed.Get() {
+		return
+	}
+	var err error
+	if value == "" {
+		logging.Warn("[ApolloDataSource] The property key was deleted.", "key", s.propertyKey)
+		err = s.Handle(nil)
+	} else {
+		err = s.Handle([]byte(value))
+	}
+	if err != nil {
+		logging.Error(err, "Fail to handle apollo config change", "key", s.propertyKey)
+	}
+}
+
+func (s *ApolloDataSource) Close() error {
+	if !s.closed.CompareAndSet(false, true) {
+		return nil
+	}
+	logging.Info("[Apollo] The ApolloDataSource had been closed.", "key", s.propertyKey)
+	return nil
+}
@@ -0,0 +1,179 @@
+package apollo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeApollo 模拟apollo config service的最小实现
+type fakeApollo struct {
+	mux            sync.Mutex
+	secret         string
+	configurations map[string]string
+	notificationId int64
+	changed        chan struct{}
+	unauthorized   int
+}
+
+func newFakeApollo() *fakeApollo {
+	return &fakeApollo{configurations: map[string]string{}, notificationId: 1, changed: make(chan struct{}, 1)}
+}
+
+func (f *fakeApollo) set(key, value string) {
+	f.mux.Lock()
+	f.configurations[key] = value
+	f.notificationId++
+	f.mux.Unlock()
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (f *fakeApollo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.secret != "" {
+		pathWithQuery := r.URL.Path + "?" + r.URL.RawQuery
+		expected := "Apollo app:" + signature(r.Header.Get("Timestamp"), pathWithQuery, f.secret)
+		if r.Header.Get("Authorization") != expected {
+			f.mux.Lock()
+			f.unauthorized++
+			f.mux.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	switch {
+	case r.URL.Path == notificationsPath:
+		var notifications []*Notification
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("notifications")), &notifications)
+		for {
+			f.mux.Lock()
+			id := f.notificationId
+			f.mux.Unlock()
+			if len(notifications) > 0 && notifications[0].NotificationId != id {
+				_ = json.NewEncoder(w).Encode([]*Notification{{NamespaceName: notifications[0].NamespaceName, NotificationId: id}})
+				return
+			}
+			select {
+			case <-f.changed:
+			case <-time.After(200 * time.Millisecond):
+				w.WriteHeader(http.StatusNotModified)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	case strings.HasPrefix(r.URL.Path, configsPath+"/app/default/"):
+		f.mux.Lock()
+		releaseKey := strconv.FormatInt(f.notificationId, 10)
+		conf := &Config{AppId: "app", Cluster: "default", NamespaceName: "application", ReleaseKey: releaseKey, Configurations: map[string]string{}}
+		for k, v := range f.configurations {
+			conf.Configurations[k] = v
+		}
+		f.mux.Unlock()
+		if r.URL.Query().Get("releaseKey") == releaseKey {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(conf)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type recordHandler struct {
+	mux  sync.Mutex
+	srcs []string
+}
+
+func (h *recordHandler) propertyHandler() datasource.PropertyHandler {
+	return datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		return string(src), nil
+	}, func(data interface{}) error {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		h.srcs = append(h.srcs, data.(string))
+		return nil
+	})
+}
+
+func (h *recordHandler) count() int {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return len(h.srcs)
+}
+
+func (h *recordHandler) last() string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if len(h.srcs) == 0 {
+		return ""
+	}
+	return h.srcs[len(h.srcs)-1]
+}
+
+func TestApolloDataSource(t *testing.T) {
+	fake := newFakeApollo()
+	fake.secret = "s3cret"
+	fake.set("flowRule", `[{"resource":"a"}]`)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	backupDir, err := ioutil.TempDir("", "apollo")
+	assert.Nil(t, err)
+	defer os.RemoveAll(backupDir)
+
+	client, err := NewClient(server.URL, "app", "default", "s3cret")
+	assert.Nil(t, err)
+	watcher := NewWatcher(client, "application", true, backupDir)
+	watcher.Start()
+	defer watcher.Stop()
+
+	h := &recordHandler{}
+	ds, err := NewDatasource(watcher, "flowRule", h.propertyHandler())
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	assert.Equal(t, `[{"resource":"a"}]`, h.last())
+	assert.Equal(t, ErrWriteNotSupported, ds.Write([]byte("[]")))
+
+	t.Run("LongPollUpdate", func(t *testing.T) {
+		fake.set("flowRule", `[{"resource":"b"}]`)
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"b"}]`
+		}, 3*time.Second, 10*time.Millisecond)
+		// 其他配置项变化不触发
+		count := h.count()
+		fake.set("otherRule", "x")
+		time.Sleep(300 * time.Millisecond)
+		assert.Equal(t, count, h.count())
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		data, err := datasource.ReadBackup(backupDir, watcher.backupName())
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), `[{\"resource\":\"b\"}]`)
+
+		// 服务端不可用时从备份恢复
+		down, err := NewClient("127.0.0.1:1", "app", "default", "")
+		assert.Nil(t, err)
+		w := NewWatcher(down, "application", true, backupDir)
+		w.Start()
+		defer w.Stop()
+		value, ok := w.Get("flowRule")
+		assert.True(t, ok)
+		assert.Equal(t, `[{"resource":"b"}]`, value)
+	})
+
+	assert.Zero(t, fake.unauthorized)
+	assert.Nil(t, ds.Close())
+}
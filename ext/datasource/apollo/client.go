@@ -0,0 +1,168 @@
+package apollo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	notificationsPath = "/notifications/v2"
+	configsPath       = "/configs"
+
+	// longPollTimeout apollo服务端最多hold长轮询60s，客户端超时需大于该值
+	longPollTimeout = 90 * time.Second
+	requestTimeout  = 3 * time.Second
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	ErrEmptyServerAddr = errors.New("apollo server address is empty")
+)
+
+// Notification 长轮询的通知，NotificationId为-1时服务端立即返回当前的通知
+type Notification struct {
+	NamespaceName  string `json:"namespaceName"`
+	NotificationId int64  `json:"notificationId"`
+}
+
+// Config apollo中一个命名空间的配置
+type Config struct {
+	AppId          string            `json:"appId"`
+	Cluster        string            `json:"cluster"`
+	NamespaceName  string            `json:"namespaceName"`
+	Configurations map[string]string `json:"configurations"`
+	ReleaseKey     string            `json:"releaseKey"`
+}
+
+// Client apollo config service的轻量客户端
+type Client struct {
+	serverAddr string
+	appId      string
+	cluster    string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewClient 创建客户端，serverAddr未指定协议时默认为http，secret为空时不对请求签名
+func NewClient(serverAddr, appId, cluster, secret string) (*Client, error) {
+	serverAddr = strings.TrimSpace(serverAddr)
+	if serverAddr == "" {
+		return nil, ErrEmptyServerAddr
+	}
+	if !strings.HasPrefix(serverAddr, "http://") && !strings.HasPrefix(serverAddr, "https://") {
+		serverAddr = "http://" + serverAddr
+	}
+	return &Client{
+		serverAddr: strings.TrimRight(serverAddr, "/"),
+		appId:      appId,
+		cluster:    cluster,
+		secret:     secret,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// GetConfig 获取命名空间的配置，releaseKey与服务端一致时返回nil
+func (c *Client) GetConfig(ctx context.Context, namespace, releaseKey string) (*Config, error) {
+	query := url.Values{}
+	query.Set("releaseKey", releaseKey)
+	query.Set("ip", util.GetIP())
+	path := configsPath + "/" + url.PathEscape(c.appId) + "/" + url.PathEscape(c.cluster) + "/" + url.PathEscape(namespace)
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	status, body, err := c.get(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusNotFound:
+		// 命名空间不存在，视为空配置
+		return &Config{NamespaceName: namespace, Configurations: map[string]string{}}, nil
+	case http.StatusOK:
+		var conf Config
+		if err = jsonTraffic.Unmarshal(body, &conf); err != nil {
+			return nil, errors.Wrapf(err, "fail to unmarshal apollo config of namespace[%s]", namespace)
+		}
+		if conf.Configurations == nil {
+			conf.Configurations = map[string]string{}
+		}
+		return &conf, nil
+	default:
+		return nil, errors.Errorf("apollo get config of namespace[%s] failed, status: %d, body: %s", namespace, status, body)
+	}
+}
+
+// Notifications 长轮询命名空间的变更，没有变更时服务端hold住请求直到超时，此时返回nil
+func (c *Client) Notifications(ctx context.Context, notifications []*Notification) ([]*Notification, error) {
+	data, err := jsonTraffic.Marshal(notifications)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("appId", c.appId)
+	query.Set("cluster", c.cluster)
+	query.Set("notifications", string(data))
+	ctx, cancel := context.WithTimeout(ctx, longPollTimeout)
+	defer cancel()
+	status, body, err := c.get(ctx, notificationsPath, query)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+		var result []*Notification
+		if err = jsonTraffic.Unmarshal(body, &result); err != nil {
+			return nil, errors.Wrap(err, "fail to unmarshal apollo notifications")
+		}
+		return result, nil
+	default:
+		return nil, errors.Errorf("apollo notifications failed, status: %d, body: %s", status, body)
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values) (int, []byte, error) {
+	pathWithQuery := path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.serverAddr+pathWithQuery, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		req.Header.Set("Authorization", "Apollo "+c.appId+":"+signature(timestamp, pathWithQuery, c.secret))
+		req.Header.Set("Timestamp", timestamp)
+	}
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return rsp.StatusCode, body, nil
+}
+
+// signature apollo访问密钥签名：Base64(HmacSHA1(secret, timestamp + "\n" + pathWithQuery))
+func signature(timestamp, pathWithQuery, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + pathWithQuery))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package apollo
+
+import (
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/ext/datasource/util"
+	"github.com/liuhailove/gmiter/logging"
+	util2 "github.com/liuhailove/gmiter/util"
+)
+
+var (
+	isInitialized util2.AtomicBool
+)
+
+// Initialize 规则保存在apollo命名空间config.ApolloDatasourceNamespaceName()中，配置项的key为规则名
+func Initialize() {
+	if !isInitialized.CompareAndSet(false, true) {
+		return
+	}
+	if config.RuleConsistentModeType() != config.ApolloMode {
+		return
+	}
+	client, err := NewClient(config.ApolloDatasourceIP(), config.ApolloDatasourceAppId(),
+		config.ApolloDatasourceCluster(), config.ApolloDatasourceSecret())
+	if err != nil {
+		logging.Error(err, "Apollo Fail to create client", "ip", config.ApolloDatasourceIP())
+		return
+	}
+	watcher := NewWatcher(client, config.ApolloDatasourceNamespaceName(),
+		config.ApolloDatasourceIsBackupConfig(), config.ApolloDatasourceBackupConfigPath())
+	watcher.Start()
+	// 流控规则
+	if !initDataSource(watcher, config.FlowRuleName(), util.RegisterFlowDataSource,
+		datasource.NewFlowRulesHandler(datasource.FlowRuleJsonArrayParser)) {
+		return
+	}
+	// 授权规则
+	if !initDataSource(watcher, config.AuthorityRuleName(), util.RegisterAuthorityDataSource,
+		datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)) {
+		return
+	}
+	// 降级规则
+	if !initDataSource(watcher, config.DegradeRuleName(), util.RegisterDegradeDataSource,
+		datasource.NewCircuitBreakerRulesHandler(datasource.CircuitBreakerRuleJsonArrayParser)) {
+		return
+	}
+	// 系统规则
+	if !initDataSource(watcher, config.SystemRuleName(), util.RegisterSystemDataSource,
+		datasource.NewSystemRulesHandler(datasource.SystemRuleJsonArrayParser)) {
+		return
+	}
+	// 热点规则
+	if !initDataSource(watcher, config.HotspotRuleName(), util.RegisterHotspotSource,
+		datasource.NewHotSpotParamRulesHandler(datasource.HotSpotParamRuleJsonArrayParser)) {
+		return
+	}
+	// mock规则
+	if !initDataSource(watcher, config.MockRuleName(), util.RegisterMockDataSource,
+		datasource.NewMockRulesHandler(datasource.MockRuleJsonArrayParser)) {
+		return
+	}
+	// retry规则
+	if !initDataSource(watcher, config.RetryRuleName(), util.RegisterRetryDataSource,
+		datasource.NewRetryRulesHandler(datasource.RetryRuleJsonArrayParser)) {
+		return
+	}
+	// gray规则
+	if !initDataSource(watcher, config.GrayRuleName(), util.RegisterGrayDataSource,
+		datasource.NewGrayRulesHandler(datasource.GrayRuleJsonArrayParser)) {
+		return
+	}
+	// isolation规则
+	if !initDataSource(watcher, config.IsolationRuleName(), util.RegisterIsolationDataSource,
+		datasource.NewIsolationRulesHandler(datasource.IsolationRuleJsonArrayParser)) {
+		return
+	}
+	// weightRouter规则
+	initDataSource(watcher, config.WeightRouterRuleName(), util.RegisterWeightRouterDataSource,
+		datasource.NewWeightRouterRulesHandler(datasource.WeightRouterRuleJsonArrayParser))
+}
+
+func initDataSource(watcher *Watcher, ruleName string, register func(datasource.DataSource), handlers ...datasource.PropertyHandler) bool {
+	ds, err := NewDatasource(watcher, ruleName, handlers...)
+	if err != nil {
+		logging.Error(err, "Apollo Fail to create datasource", "ruleName", ruleName)
+		return false
+	}
+	if err = ds.Initialize(); err != nil {
+		logging.Error(err, "Apollo Fail to Initialize datasource", "ruleName", ruleName)
+		return false
+	}
+	register(ds)
+	return true
+}
@@ -0,0 +1,200 @@
+package apollo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	maxRetryBackoff = 10 * time.Second
+	minRetryBackoff = 100 * time.Millisecond
+)
+
+// ChangeListener 配置项变化时回调，配置项被删除时value为空
+type ChangeListener func(key, value string)
+
+// Watcher 通过长轮询监听一个命名空间，同一个命名空间下的多个数据源共享一个Watcher
+type Watcher struct {
+	client    *Client
+	namespace string
+	isBackup  bool
+	backupDir string
+
+	mux            sync.RWMutex
+	configurations map[string]string
+	releaseKey     string
+	notificationId int64
+	listeners      []ChangeListener
+
+	isStarted util.AtomicBool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewWatcher 创建Watcher，isBackup为true时每次配置变化都会备份到backupDir，
+// 服务端不可用时从备份中恢复
+func NewWatcher(client *Client, namespace string, isBackup bool, backupDir string) *Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Watcher{
+		client:         client,
+		namespace:      namespace,
+		isBackup:       isBackup,
+		backupDir:      backupDir,
+		configurations: map[string]string{},
+		notificationId: -1,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Start 同步拉取一次配置，之后在后台长轮询
+func (w *Watcher) Start() {
+	if !w.isStarted.CompareAndSet(false, true) {
+		return
+	}
+	if err := w.fetch(); err != nil {
+		logging.Error(err, "[Apollo] Fail to fetch config, try to load backup", "namespace", w.namespace)
+		w.loadBackup()
+	}
+	go util.RunWithRecover(w.longPoll)
+}
+
+// Stop 停止长轮询
+func (w *Watcher) Stop() {
+	w.cancel()
+}
+
+// Get 获取配置项
+func (w *Watcher) Get(key string) (string, bool) {
+	w.mux.RLock()
+	defer w.mux.RUnlock()
+	value, ok := w.configurations[key]
+	return value, ok
+}
+
+// AddListener 添加配置变化监听
+func (w *Watcher) AddListener(listener ChangeListener) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+func (w *Watcher) longPoll() {
+	backoff := minRetryBackoff
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		default:
+		}
+		w.mux.RLock()
+		notification := &Notification{NamespaceName: w.namespace, NotificationId: w.notificationId}
+		w.mux.RUnlock()
+		notifications, err := w.client.Notifications(w.ctx, []*Notification{notification})
+		if err == nil && len(notifications) > 0 {
+			err = w.fetch()
+			if err == nil {
+				w.mux.Lock()
+				for _, n := range notifications {
+					if n.NamespaceName == w.namespace && n.NotificationId > w.notificationId {
+						w.notificationId = n.NotificationId
+					}
+				}
+				w.mux.Unlock()
+			}
+		}
+		if err == nil {
+			backoff = minRetryBackoff
+			continue
+		}
+		if w.ctx.Err() != nil {
+			return
+		}
+		logging.Warn("[Apollo] Long poll failed, retrying", "namespace", w.namespace, "err", err, "backoff", backoff)
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// fetch 拉取配置并通知发生变化的配置项
+func (w *Watcher) fetch() error {
+	w.mux.RLock()
+	releaseKey := w.releaseKey
+	w.mux.RUnlock()
+	conf, err := w.client.GetConfig(w.ctx, w.namespace, releaseKey)
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		return nil
+	}
+	w.update(conf.Configurations, conf.ReleaseKey)
+	if w.isBackup {
+		if data, err := jsonTraffic.Marshal(conf.Configurations); err == nil {
+			if err = datasource.WriteBackup(w.backupDir, w.backupName(), data); err != nil {
+				logging.Warn("[Apollo] Fail to backup config", "namespace", w.namespace, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) loadBackup() {
+	if !w.isBackup {
+		return
+	}
+	data, err := datasource.ReadBackup(w.backupDir, w.backupName())
+	if err != nil || len(data) == 0 {
+		if err != nil {
+			logging.Error(err, "[Apollo] Fail to read backup", "namespace", w.namespace)
+		}
+		return
+	}
+	var configurations map[string]string
+	if err = jsonTraffic.Unmarshal(data, &configurations); err != nil {
+		logging.Error(err, "[Apollo] Fail to unmarshal backup", "namespace", w.namespace)
+		return
+	}
+	// 备份没有releaseKey，服务端恢复后会全量拉取一次
+	w.update(configurations, "")
+}
+
+func (w *Watcher) update(configurations map[string]string, releaseKey string) {
+	w.mux.Lock()
+	old := w.configurations
+	w.configurations = configurations
+	w.releaseKey = releaseKey
+	listeners := w.listeners
+	w.mux.Unlock()
+	for key, value := range configurations {
+		if oldValue, ok := old[key]; ok && oldValue == value {
+			continue
+		}
+		for _, listener := range listeners {
+			listener(key, value)
+		}
+	}
+	for key := range old {
+		if _, ok := configurations[key]; ok {
+			continue
+		}
+		for _, listener := range listeners {
+			listener(key, "")
+		}
+	}
+}
+
+func (w *Watcher) backupName() string {
+	return "apollo_" + w.client.appId + "_" + w.client.cluster + "_" + w.namespace + ".json"
+}
@@ -0,0 +1,58 @@
+package datasource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultBackupDirName 默认的配置备份目录名，位于日志目录下
+	DefaultBackupDirName = "config-cache"
+)
+
+// BackupDir 配置备份目录，dir为空时使用日志目录下的config-cache
+func BackupDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Join(config.LogBaseDir(), DefaultBackupDirName)
+}
+
+// WriteBackup 将配置备份到本地文件，先写临时文件再重命名，避免进程退出时留下不完整的备份
+func WriteBackup(dir, name string, data []byte) error {
+	dir = BackupDir(dir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "fail to create backup dir[%s]", dir)
+	}
+	tmp, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "fail to create backup file in dir[%s]", dir)
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return errors.Wrapf(err, "fail to write backup file[%s]", tmp.Name())
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrapf(err, "fail to rename backup file[%s]", tmp.Name())
+	}
+	return nil
+}
+
+// ReadBackup 读取本地备份的配置，备份不存在时返回nil
+func ReadBackup(dir, name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(BackupDir(dir), name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
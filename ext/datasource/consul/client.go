@@ -0,0 +1,139 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	kvPath = "/v1/kv/"
+
+	indexHeader = "X-Consul-Index"
+	tokenHeader = "X-Consul-Token"
+
+	// DefaultWaitTime 阻塞查询的最长等待时间，consul服务端上限为10分钟
+	DefaultWaitTime = 5 * time.Minute
+	requestTimeout  = 3 * time.Second
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	ErrEmptyAddress = errors.New("consul address is empty")
+)
+
+type kvPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+// Client consul KV HTTP API的轻量客户端
+type Client struct {
+	address    string
+	token      string
+	datacenter string
+	httpClient *http.Client
+}
+
+// NewClient 创建客户端，address未指定协议时默认为http
+func NewClient(address, token, datacenter string) (*Client, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, ErrEmptyAddress
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	return &Client{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		datacenter: datacenter,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Get 读取key，index大于0时为阻塞查询，直到key的index大于传入的index或者超过wait才返回。
+// key不存在时value为nil，返回值index为consul返回的X-Consul-Index
+func (c *Client) Get(ctx context.Context, key string, index uint64, wait time.Duration) ([]byte, uint64, error) {
+	query := url.Values{}
+	timeout := requestTimeout
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.FormatInt(wait.Milliseconds(), 10)+"ms")
+		// consul会在wait的基础上增加最多1/16的随机抖动
+		timeout += wait + wait/16
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	rsp, body, err := c.do(ctx, http.MethodGet, key, query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	newIndex, _ := strconv.ParseUint(rsp.Header.Get(indexHeader), 10, 64)
+	switch rsp.StatusCode {
+	case http.StatusNotFound:
+		return nil, newIndex, nil
+	case http.StatusOK:
+		var pairs []*kvPair
+		if err = jsonTraffic.Unmarshal(body, &pairs); err != nil {
+			return nil, 0, errors.Wrapf(err, "fail to unmarshal consul kv[%s]", key)
+		}
+		if len(pairs) == 0 {
+			return nil, newIndex, nil
+		}
+		return pairs[0].Value, newIndex, nil
+	default:
+		return nil, 0, errors.Errorf("consul get kv[%s] failed, status: %d, body: %s", key, rsp.StatusCode, body)
+	}
+}
+
+// Put 写入key
+func (c *Client) Put(ctx context.Context, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	rsp, body, err := c.do(ctx, http.MethodPut, key, url.Values{}, value)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "true" {
+		return errors.Errorf("consul put kv[%s] failed, status: %d, body: %s", key, rsp.StatusCode, body)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, value []byte) (*http.Response, []byte, error) {
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	u := c.address + kvPath + strings.TrimLeft(key, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(value))
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.token != "" {
+		req.Header.Set(tokenHeader, c.token)
+	}
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rsp, body, nil
+}
@@ -0,0 +1,210 @@
+package consul
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	maxRetryBackoff = 10 * time.Second
+	minRetryBackoff = 100 * time.Millisecond
+)
+
+// Option ConsulDataSource的配置项
+type Option func(*ConsulDataSource)
+
+// WithBackup 每次配置变化时备份到dir，consul不可用时从备份中恢复，dir为空时使用默认备份目录
+func WithBackup(dir string) Option {
+	return func(s *ConsulDataSource) {
+		s.isBackup = true
+		s.backupDir = dir
+	}
+}
+
+// WithWaitTime 设置阻塞查询的最长等待时间
+func WithWaitTime(wait time.Duration) Option {
+	return func(s *ConsulDataSource) {
+		if wait > 0 {
+			s.waitTime = wait
+		}
+	}
+}
+
+// ConsulDataSource 基于consul KV的规则数据源，一个实例通过阻塞查询监听一个key
+type ConsulDataSource struct {
+	datasource.Base
+	client      *Client
+	propertyKey string
+	waitTime    time.Duration
+	isBackup    bool
+	backupDir   string
+	// lastIndex 最近一次读取到的X-Consul-Index，作为下一次阻塞查询的index
+	lastIndex     uint64
+	isInitialized util.AtomicBool
+	closed        util.AtomicBool
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// NewDatasource 创建consul数据源
+func NewDatasource(client *Client, key string, handlers []datasource.PropertyHandler, opts ...Option) (*ConsulDataSource, error) {
+	if client == nil {
+		return nil, errors.New("nil consul client")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ds := &ConsulDataSource{
+		client:      client,
+		propertyKey: key,
+		waitTime:    DefaultWaitTime,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	for _, opt := range opts {
+		opt(ds)
+	}
+	for _, h := range handlers {
+		ds.AddPropertyHandler(h)
+	}
+	return ds, nil
+}
+
+func (s *ConsulDataSource) ReadSource() ([]byte, error) {
+	value, index, err := s.client.Get(s.ctx, s.propertyKey, 0, 0)
+	if err != nil {
+		return nil, errors.Errorf("ConsulDataSource fail to read key[%s], err: %+v", s.propertyKey, err)
+	}
+	atomic.StoreUint64(&s.lastIndex, index)
+	s.backup(value)
+	return value, nil
+}
+
+func (s *ConsulDataSource) Initialize() error {
+	if !s.isInitialized.CompareAndSet(false, true) {
+		return nil
+	}
+	if err := s.doReadAndUpdate(); err != nil {
+		logging.Error(err, "Fail to execute ConsulDataSource.doReadAndUpdate", "key", s.propertyKey)
+		s.loadBackup()
+	}
+	go util.RunWithRecover(s.watch)
+	return nil
+}
+
+func (s *ConsulDataSource) Write(bytes []byte) error {
+	if err := s.client.Put(s.ctx, s.propertyKey, bytes); err != nil {
+		logging.Error(err, "ConsulDataSource fail to write the property", "key", s.propertyKey)
+		return errors.Errorf("ConsulDataSource fail to write key[%s], err: %+v", s.propertyKey, err)
+	}
+	return nil
+}
+
+func (s *ConsulDataSource) doReadAndUpdate() error {
+	src, err := s.ReadSource()
+	if err != nil {
+		return err
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	return s.Handle(src)
+}
+
+// watch 阻塞查询key的变更，直到数据源关闭
+func (s *ConsulDataSource) watch() {
+	backoff := minRetryBackoff
+	for !s.closed.Get() {
+		lastIndex := atomic.LoadUint64(&s.lastIndex)
+		if lastIndex == 0 {
+			// 尚未成功读取过，先全量读取一次以获得index
+			if err := s.doReadAndUpdate(); err != nil {
+				backoff = s.sleep(backoff, err)
+			}
+			continue
+		}
+		value, index, err := s.client.Get(s.ctx, s.propertyKey, lastIndex, s.waitTime)
+		if err != nil {
+			backoff = s.sleep(backoff, err)
+			continue
+		}
+		backoff = minRetryBackoff
+		if index == lastIndex {
+			// 等待超时，没有变化
+			continue
+		}
+		if index < lastIndex {
+			// index回退（如consul重建），按照consul的建议重置index重新全量读取
+			atomic.StoreUint64(&s.lastIndex, 0)
+			continue
+		}
+		atomic.StoreUint64(&s.lastIndex, index)
+		s.backup(value)
+		if value == nil {
+			logging.Warn("[ConsulDataSource] The property key was deleted.", "key", s.propertyKey)
+		}
+		if err = s.Handle(value); err != nil {
+			logging.Error(err, "Fail to handle consul kv change", "key", s.propertyKey)
+		}
+	}
+}
+
+func (s *ConsulDataSource) sleep(backoff time.Duration, err error) time.Duration {
+	if s.closed.Get() {
+		return backoff
+	}
+	logging.Warn("[ConsulDataSource] Blocking query failed, retrying", "key", s.propertyKey, "err", err, "backoff", backoff)
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(backoff):
+	}
+	if backoff *= 2; backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+func (s *ConsulDataSource) backup(value []byte) {
+	if !s.isBackup || value == nil {
+		return
+	}
+	if err := datasource.WriteBackup(s.backupDir, s.backupName(), value); err != nil {
+		logging.Warn("[ConsulDataSource] Fail to backup property", "key", s.propertyKey, "err", err)
+	}
+}
+
+func (s *ConsulDataSource) loadBackup() {
+	if !s.isBackup {
+		return
+	}
+	data, err := datasource.ReadBackup(s.backupDir, s.backupName())
+	if err != nil {
+		logging.Error(err, "[ConsulDataSource] Fail to read backup", "key", s.propertyKey)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	if err = s.Handle(data); err != nil {
+		logging.Error(err, "[ConsulDataSource] Fail to handle backup", "key", s.propertyKey)
+	}
+}
+
+func (s *ConsulDataSource) backupName() string {
+	return "consul_" + strings.ReplaceAll(strings.Trim(s.propertyKey, "/"), "/", "_")
+}
+
+func (s *ConsulDataSource) Close() error {
+	if !s.closed.CompareAndSet(false, true) {
+		return nil
+	}
+	s.cancel()
+	logging.Info("[Consul] The ConsulDataSource had been closed.", "key", s.propertyKey)
+	return nil
+}
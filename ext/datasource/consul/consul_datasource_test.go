@@ -0,0 +1,161 @@
+package consul
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConsul 模拟consul KV HTTP API的最小实现，支持阻塞查询
+type fakeConsul struct {
+	mux     sync.Mutex
+	index   uint64
+	kvs     map[string][]byte
+	changed chan struct{}
+	// tokens 请求携带的token
+	tokens []string
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{index: 1, kvs: make(map[string][]byte), changed: make(chan struct{})}
+}
+
+func (f *fakeConsul) put(key string, value []byte) {
+	f.mux.Lock()
+	f.index++
+	f.kvs[key] = value
+	changed := f.changed
+	f.changed = make(chan struct{})
+	f.mux.Unlock()
+	close(changed)
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, kvPath) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, kvPath)
+	f.mux.Lock()
+	f.tokens = append(f.tokens, r.Header.Get(tokenHeader))
+	f.mux.Unlock()
+	if r.Method == http.MethodPut {
+		body, _ := ioutil.ReadAll(r.Body)
+		f.put(key, body)
+		_, _ = w.Write([]byte("true"))
+		return
+	}
+	f.mux.Lock()
+	changed := f.changed
+	index := f.index
+	f.mux.Unlock()
+	if idx, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); idx > 0 && idx >= index {
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	w.Header().Set(indexHeader, strconv.FormatUint(f.index, 10))
+	value, ok := f.kvs[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode([]*kvPair{{Key: key, Value: value, ModifyIndex: f.index}})
+}
+
+type recordHandler struct {
+	mux  sync.Mutex
+	srcs []string
+}
+
+func (h *recordHandler) propertyHandler() datasource.PropertyHandler {
+	return datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		return string(src), nil
+	}, func(data interface{}) error {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		h.srcs = append(h.srcs, data.(string))
+		return nil
+	})
+}
+
+func (h *recordHandler) last() string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if len(h.srcs) == 0 {
+		return ""
+	}
+	return h.srcs[len(h.srcs)-1]
+}
+
+func TestConsulDataSource(t *testing.T) {
+	fake := newFakeConsul()
+	fake.put("gmiter/app/flowRule", []byte(`[{"resource":"a"}]`))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	backupDir, err := ioutil.TempDir("", "consul")
+	assert.Nil(t, err)
+	defer os.RemoveAll(backupDir)
+
+	client, err := NewClient(server.URL, "token", "")
+	assert.Nil(t, err)
+	h := &recordHandler{}
+	ds, err := NewDatasource(client, "gmiter/app/flowRule", []datasource.PropertyHandler{h.propertyHandler()},
+		WithBackup(backupDir), WithWaitTime(time.Second))
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	defer ds.Close()
+	assert.Equal(t, `[{"resource":"a"}]`, h.last())
+
+	t.Run("BlockingQueryUpdate", func(t *testing.T) {
+		fake.put("gmiter/app/flowRule", []byte(`[{"resource":"b"}]`))
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"b"}]`
+		}, 3*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		assert.Nil(t, ds.Write([]byte(`[{"resource":"c"}]`)))
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"c"}]`
+		}, 3*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Backup", func(t *testing.T) {
+		data, err := datasource.ReadBackup(backupDir, ds.backupName())
+		assert.Nil(t, err)
+		assert.Equal(t, `[{"resource":"c"}]`, string(data))
+
+		// consul不可用时从备份恢复
+		down, err := NewClient("127.0.0.1:1", "", "")
+		assert.Nil(t, err)
+		h2 := &recordHandler{}
+		ds2, err := NewDatasource(down, "gmiter/app/flowRule", []datasource.PropertyHandler{h2.propertyHandler()}, WithBackup(backupDir))
+		assert.Nil(t, err)
+		assert.Nil(t, ds2.Initialize())
+		defer ds2.Close()
+		assert.Equal(t, `[{"resource":"c"}]`, h2.last())
+	})
+
+	fake.mux.Lock()
+	for _, token := range fake.tokens {
+		assert.Equal(t, "token", token)
+	}
+	fake.mux.Unlock()
+}
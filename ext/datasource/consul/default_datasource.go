@@ -0,0 +1,104 @@
+package consul
+
+import (
+	"path"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/ext/datasource/util"
+	"github.com/liuhailove/gmiter/logging"
+	util2 "github.com/liuhailove/gmiter/util"
+)
+
+var (
+	isInitialized util2.AtomicBool
+)
+
+// RuleKey 规则在 consul 中的 key，格式为 {keyPrefix}/{appName}/{ruleName}
+func RuleKey(ruleName string) string {
+	prefix := config.ConsulDatasourceKeyPrefix()
+	if prefix == "" {
+		prefix = config.DefaultConsulKeyPrefix
+	}
+	return path.Join(prefix, config.AppName(), ruleName)
+}
+
+func Initialize() {
+	if !isInitialized.CompareAndSet(false, true) {
+		return
+	}
+	if config.RuleConsistentModeType() != config.ConsulMode {
+		return
+	}
+	client, err := NewClient(config.ConsulDatasourceAddress(), config.ConsulDatasourceToken(), config.ConsulDatasourceDatacenter())
+	if err != nil {
+		logging.Error(err, "Consul Fail to create client", "address", config.ConsulDatasourceAddress())
+		return
+	}
+	var opts []Option
+	if config.ConsulDatasourceIsBackupConfig() {
+		opts = append(opts, WithBackup(config.ConsulDatasourceBackupConfigPath()))
+	}
+	// 流控规则
+	if !initDataSource(client, config.FlowRuleName(), util.RegisterFlowDataSource, opts,
+		datasource.NewFlowRulesHandler(datasource.FlowRuleJsonArrayParser)) {
+		return
+	}
+	// 授权规则
+	if !initDataSource(client, config.AuthorityRuleName(), util.RegisterAuthorityDataSource, opts,
+		datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)) {
+		return
+	}
+	// 降级规则
+	if !initDataSource(client, config.DegradeRuleName(), util.RegisterDegradeDataSource, opts,
+		datasource.NewCircuitBreakerRulesHandler(datasource.CircuitBreakerRuleJsonArrayParser)) {
+		return
+	}
+	// 系统规则
+	if !initDataSource(client, config.SystemRuleName(), util.RegisterSystemDataSource, opts,
+		datasource.NewSystemRulesHandler(datasource.SystemRuleJsonArrayParser)) {
+		return
+	}
+	// 热点规则
+	if !initDataSource(client, config.HotspotRuleName(), util.RegisterHotspotSource, opts,
+		datasource.NewHotSpotParamRulesHandler(datasource.HotSpotParamRuleJsonArrayParser)) {
+		return
+	}
+	// mock规则
+	if !initDataSource(client, config.MockRuleName(), util.RegisterMockDataSource, opts,
+		datasource.NewMockRulesHandler(datasource.MockRuleJsonArrayParser)) {
+		return
+	}
+	// retry规则
+	if !initDataSource(client, config.RetryRuleName(), util.RegisterRetryDataSource, opts,
+		datasource.NewRetryRulesHandler(datasource.RetryRuleJsonArrayParser)) {
+		return
+	}
+	// gray规则
+	if !initDataSource(client, config.GrayRuleName(), util.RegisterGrayDataSource, opts,
+		datasource.NewGrayRulesHandler(datasource.GrayRuleJsonArrayParser)) {
+		return
+	}
+	// isolation规则
+	if !initDataSource(client, config.IsolationRuleName(), util.RegisterIsolationDataSource, opts,
+		datasource.NewIsolationRulesHandler(datasource.IsolationRuleJsonArrayParser)) {
+		return
+	}
+	// weightRouter规则
+	initDataSource(client, config.WeightRouterRuleName(), util.RegisterWeightRouterDataSource, opts,
+		datasource.NewWeightRouterRulesHandler(datasource.WeightRouterRuleJsonArrayParser))
+}
+
+func initDataSource(client *Client, ruleName string, register func(datasource.DataSource), opts []Option, handlers ...datasource.PropertyHandler) bool {
+	ds, err := NewDatasource(client, RuleKey(ruleName), handlers, opts...)
+	if err != nil {
+		logging.Error(err, "Consul Fail to create datasource", "ruleName", ruleName)
+		return false
+	}
+	if err = ds.Initialize(); err != nil {
+		logging.Error(err, "Consul Fail to Initialize datasource", "ruleName", ruleName)
+		return false
+	}
+	register(ds)
+	return true
+}
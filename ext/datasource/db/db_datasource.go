@@ -0,0 +1,245 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+var (
+	// ErrVersionConflict 写入时数据库中的版本与本地已加载的版本不一致，需重新读取后再写入
+	ErrVersionConflict = errors.New("rule version conflict")
+)
+
+// VersionListener 规则加载成功后回调，version为规则在数据库中的版本
+type VersionListener func(ruleType int32, version int64)
+
+// Option DbDataSource的配置项
+type Option func(*DbDataSource)
+
+// WithTableName 设置规则表名
+func WithTableName(tableName string) Option {
+	return func(s *DbDataSource) {
+		if tableName != "" {
+			s.tableName = tableName
+		}
+	}
+}
+
+// WithPollInterval 设置轮询规则版本的间隔
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *DbDataSource) {
+		if interval > 0 {
+			s.pollInterval = interval
+		}
+	}
+}
+
+// WithVersionListener 设置规则加载成功后的回调
+func WithVersionListener(listener VersionListener) Option {
+	return func(s *DbDataSource) {
+		s.versionListener = listener
+	}
+}
+
+// DbDataSource 基于database/sql的规则数据源，一个实例对应规则表中的一行(app, rule_type)。
+// 规则表结构：
+//
+//	CREATE TABLE gmiter_rule (
+//	  app       VARCHAR(128) NOT NULL,
+//	  rule_type INT          NOT NULL,
+//	  version   BIGINT       NOT NULL,
+//	  content   TEXT         NOT NULL,
+//	  PRIMARY KEY (app, rule_type)
+//	);
+//
+// 数据源周期性轮询version，变化时重新读取content；写入时以已加载的version做乐观并发控制，
+// SQL占位符使用?，适用于MySQL、SQLite等
+type DbDataSource struct {
+	datasource.Base
+	db              *sql.DB
+	tableName       string
+	app             string
+	ruleType        int32
+	pollInterval    time.Duration
+	versionListener VersionListener
+	// version 已加载的规则版本，0表示没有规则
+	version int64
+	// failedVersion 最近一次加载失败的规则版本，轮询时跳过，直到数据库中的版本再次变化，-1表示没有
+	failedVersion int64
+	// updateMux 保证轮询与写入后的加载串行执行
+	updateMux     sync.Mutex
+	isInitialized util.AtomicBool
+	closed        util.AtomicBool
+	stopCh        chan struct{}
+}
+
+// NewDatasource 创建数据库数据源
+func NewDatasource(db *sql.DB, app string, ruleType int32, handlers []datasource.PropertyHandler, opts ...Option) (*DbDataSource, error) {
+	if db == nil {
+		return nil, errors.New("nil sql db")
+	}
+	ds := &DbDataSource{
+		db:            db,
+		tableName:     config.DefaultDbTableName,
+		app:           app,
+		ruleType:      ruleType,
+		pollInterval:  time.Duration(config.DefaultDbPollIntervalMs) * time.Millisecond,
+		failedVersion: -1,
+		stopCh:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ds)
+	}
+	for _, h := range handlers {
+		ds.AddPropertyHandler(h)
+	}
+	return ds, nil
+}
+
+// Version 已加载的规则版本
+func (s *DbDataSource) Version() int64 {
+	return atomic.LoadInt64(&s.version)
+}
+
+func (s *DbDataSource) ReadSource() ([]byte, error) {
+	_, content, err := s.read()
+	return content, err
+}
+
+// read 读取规则的版本及内容，规则不存在时version为0
+func (s *DbDataSource) read() (int64, []byte, error) {
+	var version int64
+	var content string
+	err := s.db.QueryRow("SELECT version, content FROM "+s.tableName+" WHERE app = ? AND rule_type = ?", s.app, s.ruleType).
+		Scan(&version, &content)
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, errors.Errorf("DbDataSource fail to read rule[app=%s, ruleType=%d], err: %+v", s.app, s.ruleType, err)
+	}
+	return version, []byte(content), nil
+}
+
+func (s *DbDataSource) readVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow("SELECT version FROM "+s.tableName+" WHERE app = ? AND rule_type = ?", s.app, s.ruleType).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (s *DbDataSource) Initialize() error {
+	if !s.isInitialized.CompareAndSet(false, true) {
+		return nil
+	}
+	if err := s.doReadAndUpdate(); err != nil {
+		logging.Error(err, "Fail to execute DbDataSource.doReadAndUpdate", "app", s.app, "ruleType", s.ruleType)
+	}
+	go util.RunWithRecover(s.poll)
+	return nil
+}
+
+// Write 以已加载的版本为条件更新规则，数据库中的版本已变化时返回ErrVersionConflict，
+// 写入成功后立即加载新规则
+func (s *DbDataSource) Write(bytes []byte) error {
+	expected := s.Version()
+	result, err := s.db.Exec("UPDATE "+s.tableName+" SET content = ?, version = version + 1 WHERE app = ? AND rule_type = ? AND version = ?",
+		string(bytes), s.app, s.ruleType, expected)
+	if err != nil {
+		return errors.Errorf("DbDataSource fail to write rule[app=%s, ruleType=%d], err: %+v", s.app, s.ruleType, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		current, err := s.readVersion()
+		if err != nil {
+			return errors.Errorf("DbDataSource fail to read rule version[app=%s, ruleType=%d], err: %+v", s.app, s.ruleType, err)
+		}
+		if current != 0 || expected != 0 {
+			return ErrVersionConflict
+		}
+		if _, err = s.db.Exec("INSERT INTO "+s.tableName+" (app, rule_type, version, content) VALUES (?, ?, ?, ?)",
+			s.app, s.ruleType, 1, string(bytes)); err != nil {
+			// 并发插入时主键冲突
+			if current, _ = s.readVersion(); current != 0 {
+				return ErrVersionConflict
+			}
+			return errors.Errorf("DbDataSource fail to insert rule[app=%s, ruleType=%d], err: %+v", s.app, s.ruleType, err)
+		}
+	}
+	return s.doReadAndUpdate()
+}
+
+func (s *DbDataSource) doReadAndUpdate() error {
+	s.updateMux.Lock()
+	defer s.updateMux.Unlock()
+	version, src, err := s.read()
+	if err != nil {
+		return err
+	}
+	loaded := s.Version()
+	if version == loaded {
+		return nil
+	}
+	if version == 0 {
+		logging.Warn("[DbDataSource] The rule was deleted.", "app", s.app, "ruleType", s.ruleType)
+		// 规则被删除时加载空规则
+		src = []byte("[]")
+	}
+	if err = s.Handle(src); err != nil {
+		atomic.StoreInt64(&s.failedVersion, version)
+		return err
+	}
+	atomic.StoreInt64(&s.failedVersion, -1)
+	atomic.StoreInt64(&s.version, version)
+	if s.versionListener != nil {
+		s.versionListener(s.ruleType, version)
+	}
+	return nil
+}
+
+// poll 周期性检查规则版本，直到数据源关闭
+func (s *DbDataSource) poll() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+		version, err := s.readVersion()
+		if err != nil {
+			logging.Warn("[DbDataSource] Fail to poll rule version", "app", s.app, "ruleType", s.ruleType, "err", err)
+			continue
+		}
+		if version == s.Version() || version == atomic.LoadInt64(&s.failedVersion) {
+			continue
+		}
+		if err = s.doReadAndUpdate(); err != nil {
+			logging.Error(err, "Fail to execute DbDataSource.doReadAndUpdate", "app", s.app, "ruleType", s.ruleType)
+		}
+	}
+}
+
+func (s *DbDataSource) Close() error {
+	if !s.closed.CompareAndSet(false, true) {
+		return nil
+	}
+	close(s.stopCh)
+	logging.Info("[Db] The DbDataSource had been closed.", "app", s.app, "ruleType", s.ruleType)
+	return nil
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+// memDriver 仅支持DbDataSource所用SQL的内存database/sql驱动，dsn相同的连接共享一张表
+type memDriver struct {
+	mux    sync.Mutex
+	tables map[string]*memTable
+}
+
+type memRow struct {
+	version int64
+	content string
+}
+
+type memTable struct {
+	mux  sync.Mutex
+	rows map[string]*memRow
+}
+
+func (t *memTable) set(app string, ruleType int64, version int64, content string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	key := memKey(app, ruleType)
+	if version == 0 {
+		delete(t.rows, key)
+		return
+	}
+	t.rows[key] = &memRow{version: version, content: content}
+}
+
+func memKey(app interface{}, ruleType interface{}) string {
+	return app.(string) + "|" + strconv.FormatInt(ruleType.(int64), 10)
+}
+
+var testDriver = &memDriver{tables: make(map[string]*memTable)}
+
+func init() {
+	sql.Register("gmiter-mem", testDriver)
+}
+
+func (d *memDriver) table(dsn string) *memTable {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	t, ok := d.tables[dsn]
+	if !ok {
+		t = &memTable{rows: make(map[string]*memRow)}
+		d.tables[dsn] = t
+	}
+	return t
+}
+
+func (d *memDriver) reset(dsn string) *memTable {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	t := &memTable{rows: make(map[string]*memRow)}
+	d.tables[dsn] = t
+	return t
+}
+
+func (d *memDriver) Open(dsn string) (driver.Conn, error) {
+	return &memConn{table: d.table(dsn)}, nil
+}
+
+type memConn struct {
+	table *memTable
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{table: c.table, query: query}, nil
+}
+
+func (c *memConn) Close() error {
+	return nil
+}
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transaction not supported")
+}
+
+type memStmt struct {
+	table *memTable
+	query string
+}
+
+func (s *memStmt) Close() error {
+	return nil
+}
+
+func (s *memStmt) NumInput() int {
+	return -1
+}
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	t := s.table
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "UPDATE"):
+		row, ok := t.rows[memKey(args[1], args[2])]
+		if !ok || row.version != args[3].(int64) {
+			return driver.RowsAffected(0), nil
+		}
+		row.content = args[0].(string)
+		row.version++
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "INSERT"):
+		key := memKey(args[0], args[1])
+		if _, ok := t.rows[key]; ok {
+			return nil, errors.New("duplicate entry")
+		}
+		t.rows[key] = &memRow{version: args[2].(int64), content: args[3].(string)}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unsupported exec: " + s.query)
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	t := s.table
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	row, ok := t.rows[memKey(args[0], args[1])]
+	rows := &memRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT version, content"):
+		rows.columns = []string{"version", "content"}
+		if ok {
+			rows.values = [][]driver.Value{{row.version, row.content}}
+		}
+	case strings.HasPrefix(s.query, "SELECT version"):
+		rows.columns = []string{"version"}
+		if ok {
+			rows.values = [][]driver.Value{{row.version}}
+		}
+	default:
+		return nil, errors.New("unsupported query: " + s.query)
+	}
+	return rows, nil
+}
+
+type memRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *memRows) Columns() []string {
+	return r.columns
+}
+
+func (r *memRows) Close() error {
+	return nil
+}
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+type recordHandler struct {
+	mux  sync.Mutex
+	srcs []string
+}
+
+func (h *recordHandler) propertyHandler() datasource.PropertyHandler {
+	return datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		return string(src), nil
+	}, func(data interface{}) error {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		h.srcs = append(h.srcs, data.(string))
+		return nil
+	})
+}
+
+func (h *recordHandler) last() string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if len(h.srcs) == 0 {
+		return ""
+	}
+	return h.srcs[len(h.srcs)-1]
+}
+
+func TestDbDataSource(t *testing.T) {
+	table := testDriver.reset(t.Name())
+	sqlDB, err := sql.Open("gmiter-mem", t.Name())
+	assert.Nil(t, err)
+	defer sqlDB.Close()
+	table.set("app", 1, 3, `[{"resource":"a"}]`)
+
+	var versionMux sync.Mutex
+	versions := make(map[int32]int64)
+	h := &recordHandler{}
+	ds, err := NewDatasource(sqlDB, "app", 1, []datasource.PropertyHandler{h.propertyHandler()},
+		WithPollInterval(10*time.Millisecond),
+		WithVersionListener(func(ruleType int32, version int64) {
+			versionMux.Lock()
+			defer versionMux.Unlock()
+			versions[ruleType] = version
+		}))
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	defer ds.Close()
+	assert.Equal(t, `[{"resource":"a"}]`, h.last())
+	assert.Equal(t, int64(3), ds.Version())
+
+	t.Run("Poll", func(t *testing.T) {
+		table.set("app", 1, 4, `[{"resource":"b"}]`)
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"b"}]`
+		}, time.Second, 5*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			versionMux.Lock()
+			defer versionMux.Unlock()
+			return versions[1] == 4
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("WriteOptimistic", func(t *testing.T) {
+		assert.Nil(t, ds.Write([]byte(`[{"resource":"c"}]`)))
+		assert.Equal(t, int64(5), ds.Version())
+		assert.Equal(t, `[{"resource":"c"}]`, h.last())
+
+		// 其他实例写入后，基于旧版本的写入失败
+		other, err := NewDatasource(sqlDB, "app", 1, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, other.doReadAndUpdate())
+		assert.Nil(t, other.Write([]byte(`[{"resource":"d"}]`)))
+		assert.Equal(t, ErrVersionConflict, ds.Write([]byte(`[{"resource":"e"}]`)))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		table.set("app", 1, 0, "")
+		assert.Eventually(t, func() bool {
+			return h.last() == "[]"
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int64(0), ds.Version())
+	})
+}
+
+func TestDbDataSource_WriteInsert(t *testing.T) {
+	testDriver.reset(t.Name())
+	sqlDB, err := sql.Open("gmiter-mem", t.Name())
+	assert.Nil(t, err)
+	defer sqlDB.Close()
+	h := &recordHandler{}
+	ds, err := NewDatasource(sqlDB, "app", 2, []datasource.PropertyHandler{h.propertyHandler()})
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	defer ds.Close()
+	assert.Equal(t, "", h.last())
+
+	assert.Nil(t, ds.Write([]byte(`[{"resource":"a"}]`)))
+	assert.Equal(t, int64(1), ds.Version())
+	assert.Equal(t, `[{"resource":"a"}]`, h.last())
+}
+
+func TestDbDataSource_PollSkipFailedVersion(t *testing.T) {
+	table := testDriver.reset(t.Name())
+	sqlDB, err := sql.Open("gmiter-mem", t.Name())
+	assert.Nil(t, err)
+	defer sqlDB.Close()
+	table.set("app", 3, 1, `[{"resource":"a"}]`)
+
+	var parseMux sync.Mutex
+	var parsed []string
+	h := datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		parseMux.Lock()
+		defer parseMux.Unlock()
+		parsed = append(parsed, string(src))
+		if string(src) == "bad" {
+			return nil, errors.New("invalid rule")
+		}
+		return string(src), nil
+	}, func(data interface{}) error {
+		return nil
+	})
+	parsedCount := func(src string) int {
+		parseMux.Lock()
+		defer parseMux.Unlock()
+		count := 0
+		for _, p := range parsed {
+			if p == src {
+				count++
+			}
+		}
+		return count
+	}
+	ds, err := NewDatasource(sqlDB, "app", 3, []datasource.PropertyHandler{h}, WithPollInterval(5*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	defer ds.Close()
+
+	// 加载失败的版本只处理一次，直到版本再次变化
+	table.set("app", 3, 2, "bad")
+	assert.Eventually(t, func() bool { return parsedCount("bad") == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, parsedCount("bad"))
+	assert.Equal(t, int64(1), ds.Version())
+
+	table.set("app", 3, 3, `[{"resource":"b"}]`)
+	assert.Eventually(t, func() bool { return ds.Version() == 3 }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/ext/datasource/util"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/transport/http/rule"
+	util2 "github.com/liuhailove/gmiter/util"
+)
+
+var (
+	isInitialized util2.AtomicBool
+)
+
+// Initialize 使用config中的驱动及连接串打开数据库，驱动需由应用自行import
+func Initialize() {
+	if config.RuleConsistentModeType() != config.DbMode {
+		return
+	}
+	db, err := sql.Open(config.DbDatasourceDriverName(), config.DbDatasourceDsn())
+	if err != nil {
+		logging.Error(err, "Db Fail to open database", "driverName", config.DbDatasourceDriverName())
+		return
+	}
+	InitializeWithDB(db)
+}
+
+// InitializeWithDB 使用应用已有的连接池初始化全部规则的数据源
+func InitializeWithDB(db *sql.DB) {
+	if !isInitialized.CompareAndSet(false, true) {
+		return
+	}
+	opts := []Option{
+		WithTableName(config.DbDatasourceTableName()),
+		WithPollInterval(time.Duration(config.DbDatasourcePollIntervalMs()) * time.Millisecond),
+		// 与拉取dashboard规则共用版本记录，便于通过fetchMaxVersion查看当前加载的版本
+		WithVersionListener(func(ruleType int32, version int64) {
+			rule.SetRuleTypeCurrentVersion(ruleType, "v_"+strconv.FormatInt(version, 10))
+		}),
+	}
+	// 流控规则
+	if !initDataSource(db, rule.FlowRuleType, util.RegisterFlowDataSource, opts,
+		datasource.NewFlowRulesHandler(datasource.FlowRuleJsonArrayParser)) {
+		return
+	}
+	// 授权规则
+	if !initDataSource(db, rule.AuthorityRuleType, util.RegisterAuthorityDataSource, opts,
+		datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)) {
+		return
+	}
+	// 降级规则
+	if !initDataSource(db, rule.DegradeRuleType, util.RegisterDegradeDataSource, opts,
+		datasource.NewCircuitBreakerRulesHandler(datasource.CircuitBreakerRuleJsonArrayParser)) {
+		return
+	}
+	// 系统规则
+	if !initDataSource(db, rule.SystemRuleType, util.RegisterSystemDataSource, opts,
+		datasource.NewSystemRulesHandler(datasource.SystemRuleJsonArrayParser)) {
+		return
+	}
+	// 热点规则
+	if !initDataSource(db, rule.HotParamRuleType, util.RegisterHotspotSource, opts,
+		datasource.NewHotSpotParamRulesHandler(datasource.HotSpotParamRuleJsonArrayParser)) {
+		return
+	}
+	// mock规则
+	if !initDataSource(db, rule.MockRuleType, util.RegisterMockDataSource, opts,
+		datasource.NewMockRulesHandler(datasource.MockRuleJsonArrayParser)) {
+		return
+	}
+	// retry规则
+	if !initDataSource(db, rule.RetryRuleType, util.RegisterRetryDataSource, opts,
+		datasource.NewRetryRulesHandler(datasource.RetryRuleJsonArrayParser)) {
+		return
+	}
+	// gray规则
+	if !initDataSource(db, rule.GrayRuleType, util.RegisterGrayDataSource, opts,
+		datasource.NewGrayRulesHandler(datasource.GrayRuleJsonArrayParser)) {
+		return
+	}
+	// isolation规则
+	if !initDataSource(db, rule.IsolationRuleType, util.RegisterIsolationDataSource, opts,
+		datasource.NewIsolationRulesHandler(datasource.IsolationRuleJsonArrayParser)) {
+		return
+	}
+	// weightRouter规则
+	initDataSource(db, rule.WeightRouterRuleType, util.RegisterWeightRouterDataSource, opts,
+		datasource.NewWeightRouterRulesHandler(datasource.WeightRouterRuleJsonArrayParser))
+}
+
+func initDataSource(db *sql.DB, ruleType int32, register func(datasource.DataSource), opts []Option, handlers ...datasource.PropertyHandler) bool {
+	ds, err := NewDatasource(db, config.AppName(), ruleType, handlers, opts...)
+	if err != nil {
+		logging.Error(err, "Db Fail to create datasource", "ruleType", ruleType)
+		return false
+	}
+	if err = ds.Initialize(); err != nil {
+		logging.Error(err, "Db Fail to Initialize datasource", "ruleType", ruleType)
+		return false
+	}
+	register(ds)
+	return true
+}
@@ -0,0 +1,100 @@
+package etcdv3
+
+import (
+	"path"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/ext/datasource/util"
+	"github.com/liuhailove/gmiter/logging"
+	util2 "github.com/liuhailove/gmiter/util"
+)
+
+var (
+	isInitialized util2.AtomicBool
+)
+
+// RuleKey 规则在 etcd 中的 key，格式为 {keyPrefix}/{appName}/{ruleName}
+func RuleKey(ruleName string) string {
+	prefix := config.EtcdDatasourceKeyPrefix()
+	if prefix == "" {
+		prefix = config.DefaultEtcdV3Prefix
+	}
+	return path.Join("/", prefix, config.AppName(), ruleName)
+}
+
+func Initialize() {
+	if !isInitialized.CompareAndSet(false, true) {
+		return
+	}
+	if config.RuleConsistentModeType() != config.EtcdMode {
+		return
+	}
+	client, err := NewClient(config.EtcdDatasourceEndpoints())
+	if err != nil {
+		logging.Error(err, "Etcdv3 Fail to create client", "endpoints", config.EtcdDatasourceEndpoints())
+		return
+	}
+	// 流控规则
+	if !initDataSource(client, config.FlowRuleName(), util.RegisterFlowDataSource,
+		datasource.NewFlowRulesHandler(datasource.FlowRuleJsonArrayParser)) {
+		return
+	}
+	// 授权规则
+	if !initDataSource(client, config.AuthorityRuleName(), util.RegisterAuthorityDataSource,
+		datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)) {
+		return
+	}
+	// 降级规则
+	if !initDataSource(client, config.DegradeRuleName(), util.RegisterDegradeDataSource,
+		datasource.NewCircuitBreakerRulesHandler(datasource.CircuitBreakerRuleJsonArrayParser)) {
+		return
+	}
+	// 系统规则
+	if !initDataSource(client, config.SystemRuleName(), util.RegisterSystemDataSource,
+		datasource.NewSystemRulesHandler(datasource.SystemRuleJsonArrayParser)) {
+		return
+	}
+	// 热点规则
+	if !initDataSource(client, config.HotspotRuleName(), util.RegisterHotspotSource,
+		datasource.NewHotSpotParamRulesHandler(datasource.HotSpotParamRuleJsonArrayParser)) {
+		return
+	}
+	// mock规则
+	if !initDataSource(client, config.MockRuleName(), util.RegisterMockDataSource,
+		datasource.NewMockRulesHandler(datasource.MockRuleJsonArrayParser)) {
+		return
+	}
+	// retry规则
+	if !initDataSource(client, config.RetryRuleName(), util.RegisterRetryDataSource,
+		datasource.NewRetryRulesHandler(datasource.RetryRuleJsonArrayParser)) {
+		return
+	}
+	// gray规则
+	if !initDataSource(client, config.GrayRuleName(), util.RegisterGrayDataSource,
+		datasource.NewGrayRulesHandler(datasource.GrayRuleJsonArrayParser)) {
+		return
+	}
+	// isolation规则
+	if !initDataSource(client, config.IsolationRuleName(), util.RegisterIsolationDataSource,
+		datasource.NewIsolationRulesHandler(datasource.IsolationRuleJsonArrayParser)) {
+		return
+	}
+	// weightRouter规则
+	initDataSource(client, config.WeightRouterRuleName(), util.RegisterWeightRouterDataSource,
+		datasource.NewWeightRouterRulesHandler(datasource.WeightRouterRuleJsonArrayParser))
+}
+
+func initDataSource(client *Client, ruleName string, register func(datasource.DataSource), handlers ...datasource.PropertyHandler) bool {
+	ds, err := NewDatasource(client, RuleKey(ruleName), handlers...)
+	if err != nil {
+		logging.Error(err, "Etcdv3 Fail to create datasource", "ruleName", ruleName)
+		return false
+	}
+	if err = ds.Initialize(); err != nil {
+		logging.Error(err, "Etcdv3 Fail to Initialize datasource", "ruleName", ruleName)
+		return false
+	}
+	register(ds)
+	return true
+}
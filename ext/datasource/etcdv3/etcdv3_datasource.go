@@ -0,0 +1,194 @@
+package etcdv3
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+	"github.com/liuhailove/gmiter/util/etcd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSessionTTLSec 会话租约的TTL，单位秒，同一客户端的数据源共享一个会话
+	DefaultSessionTTLSec int64 = 10
+	// maxReconnectBackoff watch 重连的最大退避时间
+	maxReconnectBackoff = 10 * time.Second
+	minReconnectBackoff = 100 * time.Millisecond
+)
+
+// Client etcd v3 客户端，与选主后端共用 util/etcd 的实现
+type Client = etcd.Client
+
+// NewClient 创建客户端，endpoints 为逗号分隔的地址列表
+func NewClient(endpoints string) (*Client, error) {
+	return etcd.NewClient(endpoints)
+}
+
+// Etcdv3DataSource 基于 etcd v3 的规则数据源，一个实例监听一个 key。
+// 同一客户端的数据源共享一个会话租约(见 etcd.Client.AcquireSession)，续约失败（如网络分区超过TTL）时视为会话丢失，
+// 会重新获取会话、全量读取并从最新 revision 重建 watch，避免漏掉变更。
+type Etcdv3DataSource struct {
+	datasource.Base
+	client        *Client
+	propertyKey   string
+	sessionTTLSec int64
+	// lastUpdatedRevision 最近一次处理的 revision，重建 watch 时从其下一个版本开始
+	lastUpdatedRevision int64
+	isInitialized       util.AtomicBool
+	closed              util.AtomicBool
+	ctx                 context.Context
+	cancel              context.CancelFunc
+}
+
+// NewDatasource 创建 etcd v3 数据源
+func NewDatasource(client *Client, key string, handlers ...datasource.PropertyHandler) (*Etcdv3DataSource, error) {
+	if client == nil {
+		return nil, errors.New("nil etcdv3 client")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ds := &Etcdv3DataSource{
+		client:        client,
+		propertyKey:   key,
+		sessionTTLSec: DefaultSessionTTLSec,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	for _, h := range handlers {
+		ds.AddPropertyHandler(h)
+	}
+	return ds, nil
+}
+
+func (s *Etcdv3DataSource) ReadSource() ([]byte, error) {
+	kv, revision, err := s.client.Get(s.ctx, s.propertyKey)
+	if err != nil {
+		return nil, errors.Errorf("Etcdv3DataSource fail to read key[%s], err: %+v", s.propertyKey, err)
+	}
+	atomic.StoreInt64(&s.lastUpdatedRevision, revision)
+	if kv == nil {
+		return nil, nil
+	}
+	return kv.Value, nil
+}
+
+func (s *Etcdv3DataSource) Initialize() error {
+	if !s.isInitialized.CompareAndSet(false, true) {
+		return nil
+	}
+	if err := s.doReadAndUpdate(); err != nil {
+		logging.Error(err, "Fail to execute Etcdv3DataSource.doReadAndUpdate", "key", s.propertyKey)
+	}
+	go util.RunWithRecover(s.watch)
+	return nil
+}
+
+func (s *Etcdv3DataSource) Write(bytes []byte) error {
+	if _, err := s.client.Put(s.ctx, s.propertyKey, bytes, 0); err != nil {
+		logging.Error(err, "Etcdv3DataSource fail to write the property, err", err, "key", s.propertyKey)
+		return errors.Errorf("Etcdv3DataSource fail to write key[%s], err: %+v", s.propertyKey, err)
+	}
+	return nil
+}
+
+func (s *Etcdv3DataSource) doReadAndUpdate() error {
+	src, err := s.ReadSource()
+	if err != nil {
+		return err
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	return s.Handle(src)
+}
+
+// watch 维护会话并监听 key 的变更，直到数据源关闭
+func (s *Etcdv3DataSource) watch() {
+	backoff := minReconnectBackoff
+	for !s.closed.Get() {
+		err := s.watchInSession()
+		if s.closed.Get() {
+			return
+		}
+		logging.Warn("[Etcdv3DataSource] Watch session lost, reconnecting", "key", s.propertyKey, "err", err, "backoff", backoff)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+		// 重连后全量读取一次，防止断连期间的变更或 compact 导致漏更新
+		if err = s.doReadAndUpdate(); err != nil {
+			logging.Error(err, "Fail to execute Etcdv3DataSource.doReadAndUpdate after reconnect", "key", s.propertyKey)
+			continue
+		}
+		backoff = minReconnectBackoff
+	}
+}
+
+// watchInSession 在客户端共享的会话内 watch，会话丢失或 watch 断开时返回
+func (s *Etcdv3DataSource) watchInSession() error {
+	session, err := s.client.AcquireSession(s.ctx, s.sessionTTLSec)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.client.ReleaseSession(session); err != nil {
+			logging.Warn("[Etcdv3DataSource] Fail to revoke session lease", "key", s.propertyKey, "leaseId", session.LeaseId(), "err", err)
+		}
+	}()
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-session.Done():
+			// 会话丢失，终止当前 watch
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = s.client.Watch(ctx, s.propertyKey, atomic.LoadInt64(&s.lastUpdatedRevision)+1, s.handleEvents)
+	if sessionErr := session.Err(); sessionErr != nil {
+		return sessionErr
+	}
+	return err
+}
+
+func (s *Etcdv3DataSource) handleEvents(events []*etcd.Event, revision int64) {
+	for _, ev := range events {
+		if ev.Kv != nil && int64(ev.Kv.ModRevision) <= atomic.LoadInt64(&s.lastUpdatedRevision) {
+			continue
+		}
+		var err error
+		if ev.Type == etcd.EventTypeDelete {
+			logging.Warn("[Etcdv3DataSource] The property key was deleted.", "key", s.propertyKey)
+			err = s.Handle(nil)
+		} else if ev.Kv != nil {
+			err = s.Handle(ev.Kv.Value)
+		}
+		if err != nil {
+			logging.Error(err, "Fail to handle etcdv3 watch event", "key", s.propertyKey)
+		}
+		if ev.Kv != nil && int64(ev.Kv.ModRevision) > atomic.LoadInt64(&s.lastUpdatedRevision) {
+			atomic.StoreInt64(&s.lastUpdatedRevision, int64(ev.Kv.ModRevision))
+		}
+	}
+	if revision > atomic.LoadInt64(&s.lastUpdatedRevision) {
+		atomic.StoreInt64(&s.lastUpdatedRevision, revision)
+	}
+}
+
+func (s *Etcdv3DataSource) Close() error {
+	if !s.closed.CompareAndSet(false, true) {
+		return nil
+	}
+	s.cancel()
+	logging.Info("[Etcdv3] The Etcdv3DataSource had been closed.", "key", s.propertyKey)
+	return nil
+}
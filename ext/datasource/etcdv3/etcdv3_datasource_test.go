@@ -0,0 +1,273 @@
+package etcdv3
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyValue fakeEtcd 中保存的键值对
+type fakeKeyValue struct {
+	key         []byte
+	value       []byte
+	modRevision int64
+}
+
+// fakeEtcd 模拟 etcd v3 gRPC-gateway 的最小实现
+type fakeEtcd struct {
+	mux      sync.Mutex
+	revision int64
+	kvs      map[string]*fakeKeyValue
+	watchers []chan *fakeKeyValue
+	leases   map[int64]bool
+	leaseId  int64
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string]*fakeKeyValue), leases: make(map[int64]bool)}
+}
+
+func (kv *fakeKeyValue) toJson() map[string]interface{} {
+	return map[string]interface{}{"key": kv.key, "value": kv.value, "mod_revision": strconv.FormatInt(kv.modRevision, 10)}
+}
+
+func (f *fakeEtcd) header() map[string]string {
+	return map[string]string{"revision": strconv.FormatInt(f.revision, 10)}
+}
+
+func (f *fakeEtcd) put(key string, value []byte) {
+	f.mux.Lock()
+	f.revision++
+	kv := &fakeKeyValue{key: []byte(key), value: value, modRevision: f.revision}
+	f.kvs[key] = kv
+	watchers := f.watchers
+	f.mux.Unlock()
+	for _, w := range watchers {
+		w <- kv
+	}
+}
+
+func (f *fakeEtcd) expireLeases() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.leases = make(map[int64]bool)
+}
+
+func (f *fakeEtcd) leaseCount() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return len(f.leases)
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req struct {
+			Key []byte `json:"key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mux.Lock()
+		rsp := map[string]interface{}{"header": f.header()}
+		if kv, ok := f.kvs[string(req.Key)]; ok {
+			rsp["kvs"] = []map[string]interface{}{kv.toJson()}
+		}
+		f.mux.Unlock()
+		_ = json.NewEncoder(w).Encode(rsp)
+	case "/v3/kv/put":
+		var req struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.put(string(req.Key), req.Value)
+		f.mux.Lock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"header": f.header()})
+		f.mux.Unlock()
+	case "/v3/lease/grant":
+		f.mux.Lock()
+		f.leaseId++
+		id := f.leaseId
+		f.leases[id] = true
+		f.mux.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": strconv.FormatInt(id, 10), "TTL": "1"})
+	case "/v3/lease/keepalive":
+		var req struct {
+			ID int64 `json:"ID"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mux.Lock()
+		ttl := "0"
+		if f.leases[req.ID] {
+			ttl = "1"
+		}
+		f.mux.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]string{"ID": strconv.FormatInt(req.ID, 10), "TTL": ttl}})
+	case "/v3/lease/revoke":
+		var req struct {
+			ID int64 `json:"ID"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mux.Lock()
+		delete(f.leases, req.ID)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"header": f.header()})
+		f.mux.Unlock()
+	case "/v3/watch":
+		var req struct {
+			CreateRequest struct {
+				Key           []byte `json:"key"`
+				StartRevision int64  `json:"start_revision"`
+			} `json:"create_request"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		ch := make(chan *fakeKeyValue, 16)
+		f.mux.Lock()
+		f.watchers = append(f.watchers, ch)
+		// 和 etcd 一致，从 start_revision 开始回放历史变更
+		if kv, ok := f.kvs[string(req.CreateRequest.Key)]; ok && kv.modRevision >= req.CreateRequest.StartRevision {
+			ch <- kv
+		}
+		f.mux.Unlock()
+		flusher := w.(http.Flusher)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+		flusher.Flush()
+		for {
+			select {
+			case kv := <-ch:
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+					"events": []map[string]interface{}{{"kv": kv.toJson()}},
+				}})
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type recordHandler struct {
+	mux  sync.Mutex
+	srcs []string
+}
+
+func (h *recordHandler) propertyHandler() datasource.PropertyHandler {
+	return datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		return string(src), nil
+	}, func(data interface{}) error {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		h.srcs = append(h.srcs, data.(string))
+		return nil
+	})
+}
+
+func (h *recordHandler) last() string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if len(h.srcs) == 0 {
+		return ""
+	}
+	return h.srcs[len(h.srcs)-1]
+}
+
+func TestEtcdv3DataSource(t *testing.T) {
+	fake := newFakeEtcd()
+	fake.put("/gmiter/app/flowRule.json", []byte("[1]"))
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	assert.Nil(t, err)
+	h := &recordHandler{}
+	ds, err := NewDatasource(client, "/gmiter/app/flowRule.json", h.propertyHandler())
+	assert.Nil(t, err)
+	defer ds.Close()
+
+	t.Run("Initialize_LoadCurrentValue", func(t *testing.T) {
+		assert.Nil(t, ds.Initialize())
+		assert.Equal(t, "[1]", h.last())
+	})
+
+	t.Run("Write_TriggerWatch", func(t *testing.T) {
+		assert.Nil(t, ds.Write([]byte("[2]")))
+		assert.Eventually(t, func() bool { return h.last() == "[2]" }, 3*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("ReadSource", func(t *testing.T) {
+		src, err := ds.ReadSource()
+		assert.Nil(t, err)
+		assert.Equal(t, "[2]", string(src))
+	})
+}
+
+func TestEtcdv3DataSource_ReconnectOnLeaseLoss(t *testing.T) {
+	fake := newFakeEtcd()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	assert.Nil(t, err)
+	h := &recordHandler{}
+	ds, err := NewDatasource(client, "k", h.propertyHandler())
+	assert.Nil(t, err)
+	ds.sessionTTLSec = 1
+	defer ds.Close()
+	assert.Nil(t, ds.Initialize())
+
+	assert.Eventually(t, func() bool {
+		fake.mux.Lock()
+		defer fake.mux.Unlock()
+		return len(fake.watchers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// 绕过 watch 直接修改数据，租约失效后数据源会重建会话并全量读取
+	fake.expireLeases()
+	fake.mux.Lock()
+	fake.revision++
+	fake.kvs["k"] = &fakeKeyValue{key: []byte("k"), value: []byte("[3]"), modRevision: fake.revision}
+	fake.mux.Unlock()
+	assert.Eventually(t, func() bool { return h.last() == "[3]" }, 5*time.Second, 20*time.Millisecond)
+	// 重连后只保留当前会话的租约，关闭后租约被撤销
+	assert.Eventually(t, func() bool { return fake.leaseCount() == 1 }, 3*time.Second, 20*time.Millisecond)
+	assert.Nil(t, ds.Close())
+	assert.Eventually(t, func() bool { return fake.leaseCount() == 0 }, 3*time.Second, 20*time.Millisecond)
+}
+
+// 同一客户端的数据源共享一个会话租约
+func TestEtcdv3DataSource_ShareSession(t *testing.T) {
+	fake := newFakeEtcd()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	assert.Nil(t, err)
+	var dss []*Etcdv3DataSource
+	for _, key := range []string{"a", "b", "c"} {
+		ds, err := NewDatasource(client, key, (&recordHandler{}).propertyHandler())
+		assert.Nil(t, err)
+		assert.Nil(t, ds.Initialize())
+		dss = append(dss, ds)
+	}
+	assert.Eventually(t, func() bool {
+		fake.mux.Lock()
+		defer fake.mux.Unlock()
+		return len(fake.watchers) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, fake.leaseCount())
+
+	// 最后一个数据源关闭后撤销租约
+	assert.Nil(t, dss[0].Close())
+	assert.Nil(t, dss[1].Close())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, fake.leaseCount())
+	assert.Nil(t, dss[2].Close())
+	assert.Eventually(t, func() bool { return fake.leaseCount() == 0 }, 3*time.Second, 20*time.Millisecond)
+}
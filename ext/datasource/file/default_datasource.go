@@ -28,7 +28,8 @@ func Initialize() {
 		util.RegisterFlowDataSource(dsFlowRule)
 
 		// 授权规则
-		dsAuthorityRule := NewFileDataSource(config.SourceFilePath(), config.AuthorityRuleName())
+		authorityHandler := datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)
+		dsAuthorityRule := NewFileDataSource(config.SourceFilePath(), config.AuthorityRuleName(), authorityHandler)
 		err = dsAuthorityRule.Initialize()
 		if err != nil {
 			logging.Error(err, "DsAuthorityRule Fail to Initialize datasource error", err)
@@ -3,6 +3,7 @@ package datasource
 import (
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/liuhailove/gmiter/core/authority"
 	"github.com/liuhailove/gmiter/core/base"
 	cb "github.com/liuhailove/gmiter/core/circuitbreaker"
 	"github.com/liuhailove/gmiter/core/flow"
@@ -423,6 +424,54 @@ func NewIsolationRulesHandler(converter PropertyConverter) *DefaultPropertyHandl
 	return NewDefaultPropertyHandler(converter, IsolationRulesUpdater)
 }
 
+// AuthorityRuleJsonArrayParser provide JSON  as the default serialization for list of authority.Rule
+func AuthorityRuleJsonArrayParser(src []byte) (interface{}, error) {
+	if valid, err := checkSrcComplianceJson(src); !valid {
+		return nil, err
+	}
+
+	rules := make([]*authority.Rule, 0, 8)
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	if err := json.Unmarshal(src, &rules); err != nil {
+		desc := fmt.Sprintf("TokenResultStatusFail to convert source bytes to []*authority.Rule, err: %s", err.Error())
+		return nil, NewError(ConvertSourceError, desc)
+	}
+	return rules, nil
+}
+
+// AuthorityRulesUpdater load the newest []authority.Rule to downstream authority component.
+func AuthorityRulesUpdater(data interface{}) error {
+	if data == nil {
+		return authority.ClearRules()
+	}
+
+	rules := make([]*authority.Rule, 0, 8)
+	if val, ok := data.([]authority.Rule); ok {
+		for i := range val {
+			rules = append(rules, &val[i])
+		}
+	} else if val, ok := data.([]*authority.Rule); ok {
+		rules = val
+	} else {
+		return NewError(
+			UpdatePropertyError,
+			fmt.Sprintf("TokenResultStatusFail to type assert data to []authority.Rule or []*authority.Rule, in fact, data: %+v", data),
+		)
+	}
+	_, err := authority.LoadRules(rules)
+	if err == nil {
+		return nil
+	}
+	return NewError(
+		UpdatePropertyError,
+		fmt.Sprintf("%+v", err),
+	)
+}
+
+func NewAuthorityRulesHandler(converter PropertyConverter) *DefaultPropertyHandler {
+	return NewDefaultPropertyHandler(converter, AuthorityRulesUpdater)
+}
+
 // NodeStatTrans 节点统计转换
 func NodeStatTrans(metricItems []*base.MetricItem) ([]byte, error) {
 	var metricItemData = transToNode(metricItems)
@@ -0,0 +1,336 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/logging"
+)
+
+const (
+	// Group 规则CRD的API组
+	Group = "gmiter.io"
+	// Version 规则CRD的版本
+	Version = "v1alpha1"
+
+	// ConditionTypeValid 规则是否通过校验的Condition类型
+	ConditionTypeValid = "Valid"
+	// ReasonInvalidRule 规则未通过IsValidRule校验
+	ReasonInvalidRule = "InvalidRule"
+	// ReasonValidRule 规则通过校验并已加载
+	ReasonValidRule = "Loaded"
+
+	ConditionTrue  = "True"
+	ConditionFalse = "False"
+
+	inClusterTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	requestTimeout = 5 * time.Second
+	// tokenRefreshPeriod 从文件读取的token的缓存时间，与client-go一致，
+	// projected ServiceAccount token会定期轮换(默认约1小时)，到期后重新读取
+	tokenRefreshPeriod = time.Minute
+	// watchTimeoutSeconds 服务端关闭watch的时间，到期后客户端从最新resourceVersion重新watch
+	watchTimeoutSeconds = "300"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	ErrNotInCluster = errors.New("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+	// ErrResourceExpired watch的resourceVersion已过期(410 Gone)，需要重新list
+	ErrResourceExpired = errors.New("k8s resource version expired")
+)
+
+// ObjectMeta CR的元数据
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// Condition CR的状态条件
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// Status CR的状态
+type Status struct {
+	Conditions []*Condition `json:"conditions,omitempty"`
+}
+
+// Condition 获取指定类型的Condition，不存在时返回nil
+func (s *Status) Condition(conditionType string) *Condition {
+	if s == nil {
+		return nil
+	}
+	for _, c := range s.Conditions {
+		if c.Type == conditionType {
+			return c
+		}
+	}
+	return nil
+}
+
+// Object 规则CR，Spec为单条规则，格式与对应规则的JSON数组元素一致
+type Object struct {
+	ApiVersion string          `json:"apiVersion,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Metadata   ObjectMeta      `json:"metadata"`
+	Spec       json.RawMessage `json:"spec"`
+	Status     *Status         `json:"status,omitempty"`
+}
+
+type objectList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []*Object `json:"items"`
+}
+
+// WatchEvent watch返回的事件，Type为ADDED、MODIFIED、DELETED、BOOKMARK或ERROR
+type WatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type apiStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Client k8s api server的轻量客户端，只包含规则CR需要的list、watch以及status更新
+type Client struct {
+	apiServer  string
+	httpClient *http.Client
+
+	// tokenFile 不为空时token从文件读取并缓存tokenRefreshPeriod
+	tokenFile   string
+	token       string
+	tokenExpiry time.Time
+	tokenMux    sync.Mutex
+}
+
+// NewClient 创建客户端，apiServer未指定协议时默认为https，token为空时不携带认证信息
+func NewClient(apiServer, token string, httpClient *http.Client) *Client {
+	if !strings.HasPrefix(apiServer, "http://") && !strings.HasPrefix(apiServer, "https://") {
+		apiServer = "https://" + apiServer
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		apiServer:  strings.TrimRight(apiServer, "/"),
+		token:      strings.TrimSpace(token),
+		httpClient: httpClient,
+	}
+}
+
+// NewInClusterClient 使用Pod的ServiceAccount创建客户端，apiServer、tokenFile为空时使用集群内默认配置
+func NewInClusterClient(apiServer, tokenFile string) (*Client, error) {
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, ErrNotInCluster
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if tokenFile == "" {
+		tokenFile = inClusterTokenFile
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to read service account token[%s]", tokenFile)
+	}
+	tlsConfig := &tls.Config{}
+	if ca, err := ioutil.ReadFile(inClusterCAFile); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		tlsConfig.RootCAs = pool
+	}
+	c := NewClient(apiServer, string(token), &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}})
+	c.tokenFile = tokenFile
+	c.tokenExpiry = time.Now().Add(tokenRefreshPeriod)
+	return c, nil
+}
+
+// bearerToken 请求携带的token，从文件读取的token缓存到期后重新读取，读取失败时沿用缓存的token
+func (c *Client) bearerToken() string {
+	c.tokenMux.Lock()
+	defer c.tokenMux.Unlock()
+	if c.tokenFile == "" || time.Now().Before(c.tokenExpiry) {
+		return c.token
+	}
+	c.tokenExpiry = time.Now().Add(tokenRefreshPeriod)
+	token, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		logging.Warn("[K8sClient] Fail to reload service account token, use the cached token", "tokenFile", c.tokenFile, "err", err)
+		return c.token
+	}
+	c.token = strings.TrimSpace(string(token))
+	return c.token
+}
+
+// expireToken 认证失败时使缓存的token过期，下次请求重新读取文件
+func (c *Client) expireToken() {
+	c.tokenMux.Lock()
+	c.tokenExpiry = time.Time{}
+	c.tokenMux.Unlock()
+}
+
+// InClusterNamespace 当前Pod所在的命名空间
+func InClusterNamespace() string {
+	data, err := ioutil.ReadFile(inClusterNamespaceFile)
+	if err != nil {
+		return "default"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c *Client) resourcePath(namespace, resource string) string {
+	return "/apis/" + Group + "/" + Version + "/namespaces/" + url.PathEscape(namespace) + "/" + resource
+}
+
+// List 列出命名空间下的CR，返回CR列表以及list时的resourceVersion
+func (c *Client) List(ctx context.Context, namespace, resource, labelSelector string) ([]*Object, string, error) {
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	rsp, err := c.do(ctx, http.MethodGet, c.resourcePath(namespace, resource), query, "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close()
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("k8s list %s failed, status: %d, body: %s", resource, rsp.StatusCode, body)
+	}
+	var list objectList
+	if err = jsonTraffic.Unmarshal(body, &list); err != nil {
+		return nil, "", errors.Wrapf(err, "fail to unmarshal %s list", resource)
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// Watch 从resourceVersion开始监听CR的变更，每个事件回调一次handler，直到连接断开或ctx结束。
+// resourceVersion过期时返回ErrResourceExpired
+func (c *Client) Watch(ctx context.Context, namespace, resource, labelSelector, resourceVersion string, handler func(event *WatchEvent)) error {
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", watchTimeoutSeconds)
+	query.Set("resourceVersion", resourceVersion)
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+	rsp, err := c.do(ctx, http.MethodGet, c.resourcePath(namespace, resource), query, "", nil)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusGone {
+		return ErrResourceExpired
+	}
+	if rsp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("k8s watch %s failed, status: %d, body: %s", resource, rsp.StatusCode, body)
+	}
+	scanner := bufio.NewScanner(rsp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var event WatchEvent
+		if err = jsonTraffic.Unmarshal(line, &event); err != nil {
+			return errors.Wrapf(err, "fail to unmarshal %s watch event", resource)
+		}
+		if event.Type == "ERROR" {
+			var status apiStatus
+			_ = jsonTraffic.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return ErrResourceExpired
+			}
+			return errors.Errorf("k8s watch %s error, code: %d, message: %s", resource, status.Code, status.Message)
+		}
+		handler(&event)
+	}
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateStatus 通过status子资源更新CR的状态
+func (c *Client) UpdateStatus(ctx context.Context, namespace, resource, name string, status *Status) error {
+	data, err := jsonTraffic.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	rsp, err := c.do(ctx, http.MethodPatch, c.resourcePath(namespace, resource)+"/"+url.PathEscape(name)+"/status", nil,
+		"application/merge-patch+json", data)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("k8s update status of %s/%s failed, status: %d, body: %s", resource, name, rsp.StatusCode, body)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body []byte) (*http.Response, error) {
+	u := c.apiServer + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rsp, err := c.httpClient.Do(req)
+	if err == nil && rsp.StatusCode == http.StatusUnauthorized {
+		c.expireToken()
+	}
+	return rsp, err
+}
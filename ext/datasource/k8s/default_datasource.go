@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/logging"
+	util2 "github.com/liuhailove/gmiter/util"
+)
+
+var (
+	isInitialized util2.AtomicBool
+)
+
+// LabelSelector 规则CR的标签选择器，未配置时为app={appName}
+func LabelSelector() string {
+	if selector := config.K8sDatasourceLabelSelector(); selector != "" {
+		return selector
+	}
+	return "app=" + config.AppName()
+}
+
+func Initialize() {
+	if !isInitialized.CompareAndSet(false, true) {
+		return
+	}
+	if config.RuleConsistentModeType() != config.K8sMode {
+		return
+	}
+	client, err := NewInClusterClient(config.K8sDatasourceApiServer(), config.K8sDatasourceTokenFile())
+	if err != nil {
+		logging.Error(err, "K8s Fail to create client", "apiServer", config.K8sDatasourceApiServer())
+		return
+	}
+	namespace := config.K8sDatasourceNamespace()
+	if namespace == "" {
+		namespace = InClusterNamespace()
+	}
+	for _, kind := range RuleKinds {
+		ds, err := NewDatasource(client, kind, namespace, LabelSelector(), kind.NewHandler())
+		if err != nil {
+			logging.Error(err, "K8s Fail to create datasource", "kind", kind.Kind)
+			return
+		}
+		if err = ds.Initialize(); err != nil {
+			logging.Error(err, "K8s Fail to Initialize datasource", "kind", kind.Kind)
+			return
+		}
+		kind.Register(ds)
+	}
+}
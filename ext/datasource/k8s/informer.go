@@ -0,0 +1,186 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	maxRetryBackoff = 10 * time.Second
+	minRetryBackoff = 100 * time.Millisecond
+)
+
+// Informer 通过list-watch在本地维护一类CR的缓存，缓存变化时回调handler
+type Informer struct {
+	client        *Client
+	namespace     string
+	resource      string
+	labelSelector string
+
+	mux             sync.RWMutex
+	objects         map[string]*Object
+	resourceVersion string
+	handler         func()
+
+	// synced 首次list成功后关闭
+	synced    chan struct{}
+	syncOnce  sync.Once
+	isStarted util.AtomicBool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewInformer 创建Informer，resource为CRD的复数名称，如flowrules
+func NewInformer(client *Client, namespace, resource, labelSelector string) *Informer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Informer{
+		client:        client,
+		namespace:     namespace,
+		resource:      resource,
+		labelSelector: labelSelector,
+		objects:       make(map[string]*Object),
+		synced:        make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// SetHandler 设置缓存变化时的回调，需在Start之前调用
+func (i *Informer) SetHandler(handler func()) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.handler = handler
+}
+
+// Start 在后台执行list-watch
+func (i *Informer) Start() {
+	if !i.isStarted.CompareAndSet(false, true) {
+		return
+	}
+	go util.RunWithRecover(i.run)
+}
+
+// WaitForSync 等待首次list完成，超时返回false
+func (i *Informer) WaitForSync(timeout time.Duration) bool {
+	select {
+	case <-i.synced:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stop 停止list-watch
+func (i *Informer) Stop() {
+	i.cancel()
+}
+
+// List 缓存中的CR，按名称排序
+func (i *Informer) List() []*Object {
+	i.mux.RLock()
+	defer i.mux.RUnlock()
+	objects := make([]*Object, 0, len(i.objects))
+	for _, obj := range i.objects {
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(a, b int) bool {
+		return objects[a].Metadata.Name < objects[b].Metadata.Name
+	})
+	return objects
+}
+
+func (i *Informer) run() {
+	backoff := minRetryBackoff
+	needList := true
+	for i.ctx.Err() == nil {
+		var err error
+		if needList {
+			if err = i.list(); err == nil {
+				needList = false
+			}
+		}
+		if err == nil {
+			i.mux.RLock()
+			rv := i.resourceVersion
+			i.mux.RUnlock()
+			err = i.client.Watch(i.ctx, i.namespace, i.resource, i.labelSelector, rv, i.onEvent)
+			if err == nil {
+				// 服务端正常关闭watch，从最新resourceVersion继续
+				backoff = minRetryBackoff
+				continue
+			}
+			if err == ErrResourceExpired {
+				logging.Info("[K8sInformer] Resource version expired, relisting", "resource", i.resource)
+				needList = true
+				continue
+			}
+		}
+		if i.ctx.Err() != nil {
+			return
+		}
+		logging.Warn("[K8sInformer] List-watch failed, retrying", "resource", i.resource, "err", err, "backoff", backoff)
+		select {
+		case <-i.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (i *Informer) list() error {
+	items, rv, err := i.client.List(i.ctx, i.namespace, i.resource, i.labelSelector)
+	if err != nil {
+		return err
+	}
+	objects := make(map[string]*Object, len(items))
+	for _, obj := range items {
+		objects[obj.Metadata.Name] = obj
+	}
+	i.mux.Lock()
+	i.objects = objects
+	i.resourceVersion = rv
+	handler := i.handler
+	i.mux.Unlock()
+	i.syncOnce.Do(func() {
+		close(i.synced)
+	})
+	if handler != nil {
+		handler()
+	}
+	return nil
+}
+
+func (i *Informer) onEvent(event *WatchEvent) {
+	var obj Object
+	if err := jsonTraffic.Unmarshal(event.Object, &obj); err != nil {
+		logging.Warn("[K8sInformer] Fail to unmarshal watch event object", "resource", i.resource, "err", err)
+		return
+	}
+	i.mux.Lock()
+	if obj.Metadata.ResourceVersion != "" {
+		i.resourceVersion = obj.Metadata.ResourceVersion
+	}
+	changed := true
+	switch event.Type {
+	case "ADDED", "MODIFIED":
+		i.objects[obj.Metadata.Name] = &obj
+	case "DELETED":
+		delete(i.objects, obj.Metadata.Name)
+	default:
+		// BOOKMARK只更新resourceVersion
+		changed = false
+	}
+	handler := i.handler
+	i.mux.Unlock()
+	if changed && handler != nil {
+		handler()
+	}
+}
@@ -0,0 +1,161 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	// DefaultSyncTimeout 初始化时等待首次list的时间，超时后规则在同步完成时再加载
+	DefaultSyncTimeout = 3 * time.Second
+)
+
+var (
+	ErrWriteNotSupported = errors.New("k8s datasource does not support write, please apply the custom resources instead")
+)
+
+// K8sDataSource 基于k8s CRD的规则数据源，一个实例对应一类规则CRD，
+// 每个CR的spec为一条规则，未通过IsValidRule校验的规则不会加载，并将校验结果写回CR的status
+type K8sDataSource struct {
+	datasource.Base
+	client        *Client
+	kind          *RuleKind
+	informer      *Informer
+	isInitialized util.AtomicBool
+	closed        util.AtomicBool
+}
+
+// NewDatasource 创建k8s数据源，监听namespace下满足labelSelector的CR
+func NewDatasource(client *Client, kind *RuleKind, namespace, labelSelector string, handlers ...datasource.PropertyHandler) (*K8sDataSource, error) {
+	if client == nil {
+		return nil, errors.New("nil k8s client")
+	}
+	if kind == nil {
+		return nil, errors.New("nil k8s rule kind")
+	}
+	ds := &K8sDataSource{
+		client:   client,
+		kind:     kind,
+		informer: NewInformer(client, namespace, kind.Resource, labelSelector),
+	}
+	for _, h := range handlers {
+		ds.AddPropertyHandler(h)
+	}
+	return ds, nil
+}
+
+// ReadSource 将缓存中通过校验的CR的spec组装为规则JSON数组
+func (s *K8sDataSource) ReadSource() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	count := 0
+	for _, obj := range s.informer.List() {
+		if err := s.validate(obj); err != nil {
+			logging.Warn("[K8sDataSource] Ignoring invalid rule", "kind", s.kind.Kind, "name", obj.Metadata.Name, "err", err)
+			s.updateCondition(obj, ConditionFalse, ReasonInvalidRule, err.Error())
+			continue
+		}
+		s.updateCondition(obj, ConditionTrue, ReasonValidRule, "")
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(obj.Spec)
+		count++
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+func (s *K8sDataSource) Initialize() error {
+	if !s.isInitialized.CompareAndSet(false, true) {
+		return nil
+	}
+	s.informer.SetHandler(s.onChange)
+	s.informer.Start()
+	if !s.informer.WaitForSync(DefaultSyncTimeout) {
+		logging.Warn("[K8sDataSource] Timeout waiting for informer to sync", "kind", s.kind.Kind)
+	}
+	return nil
+}
+
+func (s *K8sDataSource) Write(bytes []byte) error {
+	return ErrWriteNotSupported
+}
+
+func (s *K8sDataSource) onChange() {
+	if s.closed.Get() {
+		return
+	}
+	src, err := s.ReadSource()
+	if err == nil {
+		err = s.Handle(src)
+	}
+	if err != nil {
+		logging.Error(err, "Fail to handle k8s rule change", "kind", s.kind.Kind)
+	}
+}
+
+// validate 单独解析并校验一个CR的spec
+func (s *K8sDataSource) validate(obj *Object) error {
+	if len(obj.Spec) == 0 {
+		return errors.New("empty spec")
+	}
+	src := make([]byte, 0, len(obj.Spec)+2)
+	src = append(append(append(src, '['), obj.Spec...), ']')
+	rules, err := s.kind.Parser(src)
+	if err != nil {
+		return err
+	}
+	return s.kind.Validate(rules)
+}
+
+// updateCondition Valid条件发生变化时写回CR的status
+func (s *K8sDataSource) updateCondition(obj *Object, status, reason, message string) {
+	current := obj.Status.Condition(ConditionTypeValid)
+	if current == nil && status == ConditionTrue {
+		// 合法的规则只在之前被标记为不合法时才需要更新
+		return
+	}
+	if current != nil && current.Status == status && current.Message == message && current.ObservedGeneration == obj.Metadata.Generation {
+		return
+	}
+	newStatus := &Status{}
+	if obj.Status != nil {
+		for _, c := range obj.Status.Conditions {
+			if c.Type != ConditionTypeValid {
+				newStatus.Conditions = append(newStatus.Conditions, c)
+			}
+		}
+	}
+	newStatus.Conditions = append(newStatus.Conditions, &Condition{
+		Type:               ConditionTypeValid,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: obj.Metadata.Generation,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339),
+	})
+	namespace := obj.Metadata.Namespace
+	if namespace == "" {
+		namespace = s.informer.namespace
+	}
+	if err := s.client.UpdateStatus(context.Background(), namespace, s.kind.Resource, obj.Metadata.Name, newStatus); err != nil {
+		logging.Warn("[K8sDataSource] Fail to update rule status", "kind", s.kind.Kind, "name", obj.Metadata.Name, "err", err)
+	}
+}
+
+func (s *K8sDataSource) Close() error {
+	if !s.closed.CompareAndSet(false, true) {
+		return nil
+	}
+	s.informer.Stop()
+	logging.Info("[K8s] The K8sDataSource had been closed.", "kind", s.kind.Kind)
+	return nil
+}
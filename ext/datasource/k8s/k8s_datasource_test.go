@@ -0,0 +1,261 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+const testResourcePath = "/apis/" + Group + "/" + Version + "/namespaces/test/flowrules"
+
+// fakeApiServer 模拟k8s api server中一类CR的list、watch以及status子资源
+type fakeApiServer struct {
+	mux      sync.Mutex
+	rv       int
+	objects  map[string]*Object
+	watchers []chan *WatchEvent
+	// patches CR名称 -> 最近一次写入的status
+	patches   map[string]*Status
+	selectors []string
+	// expired 为true时watch返回410，模拟resourceVersion过期
+	expired bool
+	// token 接受的Bearer token
+	token string
+}
+
+func newFakeApiServer() *fakeApiServer {
+	return &fakeApiServer{objects: make(map[string]*Object), patches: make(map[string]*Status), token: "token"}
+}
+
+func (f *fakeApiServer) apply(eventType, name, spec string) {
+	f.mux.Lock()
+	f.rv++
+	obj := &Object{Kind: "FlowRule", Metadata: ObjectMeta{Name: name, Namespace: "test", ResourceVersion: strconv.Itoa(f.rv), Generation: 1},
+		Spec: []byte(spec)}
+	if eventType == "DELETED" {
+		delete(f.objects, name)
+	} else {
+		f.objects[name] = obj
+	}
+	watchers := f.watchers
+	f.mux.Unlock()
+	data, _ := json.Marshal(obj)
+	for _, w := range watchers {
+		w <- &WatchEvent{Type: eventType, Object: data}
+	}
+}
+
+func (f *fakeApiServer) status(name string) *Status {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.patches[name]
+}
+
+func (f *fakeApiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	token := f.token
+	f.mux.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, testResourcePath+"/") && strings.HasSuffix(r.URL.Path, "/status"):
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, testResourcePath+"/"), "/status")
+		body, _ := ioutil.ReadAll(r.Body)
+		var patch struct {
+			Status *Status `json:"status"`
+		}
+		_ = json.Unmarshal(body, &patch)
+		f.mux.Lock()
+		f.patches[name] = patch.Status
+		f.mux.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && r.URL.Path == testResourcePath && r.URL.Query().Get("watch") == "true":
+		f.mux.Lock()
+		if f.expired {
+			f.expired = false
+			f.mux.Unlock()
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		ch := make(chan *WatchEvent, 16)
+		f.watchers = append(f.watchers, ch)
+		f.mux.Unlock()
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		flusher.Flush()
+		for {
+			select {
+			case ev, ok := <-ch:
+				if !ok {
+					return
+				}
+				_ = json.NewEncoder(w).Encode(ev)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	case r.Method == http.MethodGet && r.URL.Path == testResourcePath:
+		f.mux.Lock()
+		f.selectors = append(f.selectors, r.URL.Query().Get("labelSelector"))
+		list := map[string]interface{}{
+			"metadata": map[string]string{"resourceVersion": strconv.Itoa(f.rv)},
+		}
+		items := make([]*Object, 0, len(f.objects))
+		for _, obj := range f.objects {
+			items = append(items, obj)
+		}
+		list["items"] = items
+		f.mux.Unlock()
+		_ = json.NewEncoder(w).Encode(list)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type recordHandler struct {
+	mux  sync.Mutex
+	srcs []string
+}
+
+func (h *recordHandler) propertyHandler() datasource.PropertyHandler {
+	return datasource.NewDefaultPropertyHandler(func(src []byte) (interface{}, error) {
+		return string(src), nil
+	}, func(data interface{}) error {
+		h.mux.Lock()
+		defer h.mux.Unlock()
+		h.srcs = append(h.srcs, data.(string))
+		return nil
+	})
+}
+
+func (h *recordHandler) last() string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if len(h.srcs) == 0 {
+		return ""
+	}
+	return h.srcs[len(h.srcs)-1]
+}
+
+func TestK8sDataSource(t *testing.T) {
+	fake := newFakeApiServer()
+	fake.apply("ADDED", "a", `{"resource":"a","threshold":10}`)
+	fake.apply("ADDED", "b", `{"resource":"","threshold":10}`)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", nil)
+	h := &recordHandler{}
+	ds, err := NewDatasource(client, FlowRuleKind, "test", "app=demo", h.propertyHandler())
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Initialize())
+	defer ds.Close()
+
+	t.Run("InvalidRuleSkippedWithStatus", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"a","threshold":10}]`
+		}, 3*time.Second, 10*time.Millisecond)
+		status := fake.status("b")
+		if assert.NotNil(t, status) {
+			c := status.Condition(ConditionTypeValid)
+			assert.Equal(t, ConditionFalse, c.Status)
+			assert.Equal(t, ReasonInvalidRule, c.Reason)
+			assert.Equal(t, "empty resource", c.Message)
+		}
+		// 合法且未被标记过的规则不写status
+		assert.Nil(t, fake.status("a"))
+		assert.Equal(t, "app=demo", fake.selectors[0])
+	})
+
+	t.Run("WatchUpdate", func(t *testing.T) {
+		fake.apply("ADDED", "c", `{"resource":"c","threshold":1}`)
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"a","threshold":10},{"resource":"c","threshold":1}]`
+		}, 3*time.Second, 10*time.Millisecond)
+
+		fake.apply("DELETED", "a", `{"resource":"a","threshold":10}`)
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"c","threshold":1}]`
+		}, 3*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Relist", func(t *testing.T) {
+		fake.mux.Lock()
+		fake.expired = true
+		// 断开已有的watch，重新watch时返回410
+		watchers := fake.watchers
+		fake.watchers = nil
+		fake.mux.Unlock()
+		for _, w := range watchers {
+			close(w)
+		}
+		fake.mux.Lock()
+		fake.rv++
+		fake.objects["d"] = &Object{Metadata: ObjectMeta{Name: "d", Namespace: "test"}, Spec: []byte(`{"resource":"d","threshold":2}`)}
+		fake.mux.Unlock()
+		assert.Eventually(t, func() bool {
+			return h.last() == `[{"resource":"c","threshold":1},{"resource":"d","threshold":2}]`
+		}, 3*time.Second, 10*time.Millisecond)
+	})
+
+	assert.Equal(t, ErrWriteNotSupported, ds.Write([]byte("[]")))
+}
+
+func TestInClusterClient_TokenRotation(t *testing.T) {
+	fake := newFakeApiServer()
+	fake.apply("ADDED", "a", `{"resource":"a","threshold":10}`)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, ioutil.WriteFile(tokenFile, []byte("token\n"), 0600))
+	client, err := NewInClusterClient(server.URL, tokenFile)
+	assert.Nil(t, err)
+	list := func() error {
+		_, _, err := client.List(context.Background(), "test", "flowrules", "")
+		return err
+	}
+	rotate := func(token string) {
+		assert.Nil(t, ioutil.WriteFile(tokenFile, []byte(token), 0600))
+		fake.mux.Lock()
+		fake.token = token
+		fake.mux.Unlock()
+	}
+	assert.Nil(t, list())
+
+	t.Run("ReloadAfterUnauthorized", func(t *testing.T) {
+		rotate("token-1")
+		// 缓存未过期时仍使用旧token，401后重新读取文件
+		assert.NotNil(t, list())
+		assert.Nil(t, list())
+	})
+
+	t.Run("ReloadAfterExpiry", func(t *testing.T) {
+		rotate("token-2")
+		client.tokenMux.Lock()
+		client.tokenExpiry = time.Now().Add(-time.Second)
+		client.tokenMux.Unlock()
+		assert.Nil(t, list())
+	})
+
+	t.Run("KeepCachedTokenOnReadError", func(t *testing.T) {
+		assert.Nil(t, os.Remove(tokenFile))
+		client.expireToken()
+		assert.Nil(t, list())
+	})
+}
@@ -0,0 +1,211 @@
+package k8s
+
+import (
+	"github.com/liuhailove/gmiter/core/authority"
+	cb "github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/gray"
+	"github.com/liuhailove/gmiter/core/hotspot"
+	"github.com/liuhailove/gmiter/core/isolation"
+	"github.com/liuhailove/gmiter/core/mock"
+	retry "github.com/liuhailove/gmiter/core/retry/rule"
+	"github.com/liuhailove/gmiter/core/system"
+	"github.com/liuhailove/gmiter/core/weight_router"
+	"github.com/liuhailove/gmiter/ext/datasource"
+	"github.com/liuhailove/gmiter/ext/datasource/util"
+)
+
+// RuleKind 一类规则CRD，CR的spec经Parser转换后由Validate校验
+type RuleKind struct {
+	// Kind CRD的类型，如FlowRule
+	Kind string
+	// Resource CRD的复数名称，如flowrules
+	Resource string
+	// Parser 规则的JSON数组解析函数
+	Parser datasource.PropertyConverter
+	// Validate 校验Parser解析出的规则
+	Validate func(rules interface{}) error
+	// NewHandler 创建加载规则的PropertyHandler
+	NewHandler func() datasource.PropertyHandler
+	// Register 注册数据源
+	Register func(datasource.DataSource)
+}
+
+var (
+	FlowRuleKind = &RuleKind{
+		Kind:     "FlowRule",
+		Resource: "flowrules",
+		Parser:   datasource.FlowRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*flow.Rule) {
+				if err := flow.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewFlowRulesHandler(datasource.FlowRuleJsonArrayParser)
+		},
+		Register: util.RegisterFlowDataSource,
+	}
+	AuthorityRuleKind = &RuleKind{
+		Kind:     "AuthorityRule",
+		Resource: "authorityrules",
+		Parser:   datasource.AuthorityRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*authority.Rule) {
+				if err := authority.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewAuthorityRulesHandler(datasource.AuthorityRuleJsonArrayParser)
+		},
+		Register: util.RegisterAuthorityDataSource,
+	}
+	CircuitBreakerRuleKind = &RuleKind{
+		Kind:     "CircuitBreakerRule",
+		Resource: "circuitbreakerrules",
+		Parser:   datasource.CircuitBreakerRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*cb.Rule) {
+				if err := cb.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewCircuitBreakerRulesHandler(datasource.CircuitBreakerRuleJsonArrayParser)
+		},
+		Register: util.RegisterDegradeDataSource,
+	}
+	SystemRuleKind = &RuleKind{
+		Kind:     "SystemRule",
+		Resource: "systemrules",
+		Parser:   datasource.SystemRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*system.Rule) {
+				if err := system.IsValidSystemRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewSystemRulesHandler(datasource.SystemRuleJsonArrayParser)
+		},
+		Register: util.RegisterSystemDataSource,
+	}
+	HotspotRuleKind = &RuleKind{
+		Kind:     "HotspotRule",
+		Resource: "hotspotrules",
+		Parser:   datasource.HotSpotParamRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*hotspot.Rule) {
+				if err := hotspot.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewHotSpotParamRulesHandler(datasource.HotSpotParamRuleJsonArrayParser)
+		},
+		Register: util.RegisterHotspotSource,
+	}
+	MockRuleKind = &RuleKind{
+		Kind:     "MockRule",
+		Resource: "mockrules",
+		Parser:   datasource.MockRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*mock.Rule) {
+				if err := mock.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewMockRulesHandler(datasource.MockRuleJsonArrayParser)
+		},
+		Register: util.RegisterMockDataSource,
+	}
+	RetryRuleKind = &RuleKind{
+		Kind:     "RetryRule",
+		Resource: "retryrules",
+		Parser:   datasource.RetryRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*retry.Rule) {
+				if err := retry.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewRetryRulesHandler(datasource.RetryRuleJsonArrayParser)
+		},
+		Register: util.RegisterRetryDataSource,
+	}
+	GrayRuleKind = &RuleKind{
+		Kind:     "GrayRule",
+		Resource: "grayrules",
+		Parser:   datasource.GrayRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*gray.Rule) {
+				if err := gray.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewGrayRulesHandler(datasource.GrayRuleJsonArrayParser)
+		},
+		Register: util.RegisterGrayDataSource,
+	}
+	IsolationRuleKind = &RuleKind{
+		Kind:     "IsolationRule",
+		Resource: "isolationrules",
+		Parser:   datasource.IsolationRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*isolation.Rule) {
+				if err := isolation.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewIsolationRulesHandler(datasource.IsolationRuleJsonArrayParser)
+		},
+		Register: util.RegisterIsolationDataSource,
+	}
+	WeightRouterRuleKind = &RuleKind{
+		Kind:     "WeightRouterRule",
+		Resource: "weightrouterrules",
+		Parser:   datasource.WeightRouterRuleJsonArrayParser,
+		Validate: func(rules interface{}) error {
+			for _, r := range rules.([]*weight_router.Rule) {
+				if err := weight_router.IsValidRule(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		NewHandler: func() datasource.PropertyHandler {
+			return datasource.NewWeightRouterRulesHandler(datasource.WeightRouterRuleJsonArrayParser)
+		},
+		Register: util.RegisterWeightRouterDataSource,
+	}
+
+	// RuleKinds 支持的全部规则CRD
+	RuleKinds = []*RuleKind{
+		FlowRuleKind, AuthorityRuleKind, CircuitBreakerRuleKind, SystemRuleKind, HotspotRuleKind,
+		MockRuleKind, RetryRuleKind, GrayRuleKind, IsolationRuleKind, WeightRouterRuleKind,
+	}
+)
@@ -0,0 +1,11 @@
+// Package goredis provides the github.com/go-redis/redis/v8 hook of gmiter.
+//
+// 通过 client.AddHook(goredis.NewHook()) 接入，每个命令均经过sea entry，资源类型为base.ResTypeCache，
+// 资源名称默认为 redis:命令名，如 redis:get，可通过WithKeyPrefixResource按key前缀生成资源名称，命令的key作为第一个参数传入，
+// 热点参数规则(ParamIdx为0)可限制热点key。pipeline中资源名称及key相同的命令合并为一个批量entry。
+//
+// 阻断处理：mock时mock数据按命令的返回类型设置，如GET的mock数据为字符串，INCR的mock数据为 10；
+// mock异常信息为redis: nil时返回redis.Nil用于模拟缓存未命中，其他异常信息作为命令错误返回；ctx超时替换后继续执行；
+// 其他阻断返回*base.BlockError或WithBlockFallback的返回值。redis.Nil不计为错误。
+// mock的命令不会实际执行，直接调用client.Process时返回ErrMocked，命令本身的结果及错误为mock结果。
+package goredis
@@ -0,0 +1,229 @@
+package goredis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+var (
+	// ErrMocked 命令被mock时跳过实际执行，命令的结果在AfterProcess中替换为mock结果。
+	// go-redis v8的hook只能通过BeforeProcess返回错误跳过执行，该错误会作为client.Process的返回值，
+	// 直接调用Process时可通过errors.Is(err, ErrMocked)判断，Get等命令方法及Do忽略Process的返回值，不受影响
+	ErrMocked = errors.New("redis command mocked")
+)
+
+type (
+	hook struct {
+		opts *options
+	}
+
+	stateKey struct{}
+
+	// cmdState 单个命令的entry及mock状态
+	cmdState struct {
+		entry  *base.SeaEntry
+		mock   *base.BlockError
+		cancel context.CancelFunc
+	}
+
+	// cmdGroup pipeline中资源名称及key相同的命令，作为一个批量entry
+	cmdGroup struct {
+		resource string
+		key      string
+		cmds     []redis.Cmder
+		entry    *base.SeaEntry
+		mock     *base.BlockError
+	}
+
+	// pipelineState pipeline的entry及mock状态
+	pipelineState struct {
+		groups []*cmdGroup
+		cancel context.CancelFunc
+	}
+)
+
+// NewHook returns a redis.Hook wrapping every command with sea entry, 资源类型为base.ResTypeCache，
+// 命令的key作为第一个参数传入，可通过热点参数规则(ParamIdx为0)限制热点key
+func NewHook(seaOpts ...Option) redis.Hook {
+	return &hook{opts: evaluateOptions(seaOpts)}
+}
+
+func (h *hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if config.CloseAll() {
+		return ctx, nil
+	}
+	entry, blockErr := h.entry(ctx, h.opts.resourceName(ctx, cmd), commandKey(cmd), 1)
+	if blockErr == nil {
+		return context.WithValue(ctx, stateKey{}, &cmdState{entry: entry}), nil
+	}
+	switch blockErr.BlockType() {
+	case base.BlockTypeMock:
+		return context.WithValue(ctx, stateKey{}, &cmdState{mock: blockErr}), ErrMocked
+	case base.BlockTypeMockError:
+		return ctx, mockError(blockErr)
+	case base.BlockTypeMockCtxTimeout:
+		if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+			newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+			return context.WithValue(newCtx, stateKey{}, &cmdState{cancel: cancel}), nil
+		}
+	}
+	return ctx, h.opts.blockError(ctx, cmd, blockErr)
+}
+
+func (h *hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	state, ok := ctx.Value(stateKey{}).(*cmdState)
+	if !ok {
+		return nil
+	}
+	if state.mock != nil {
+		return applyMock(cmd, state.mock)
+	}
+	if state.cancel != nil {
+		state.cancel()
+	}
+	if state.entry != nil {
+		traceError(state.entry, cmd.Err())
+		state.entry.Exit()
+	}
+	return nil
+}
+
+// BeforeProcessPipeline pipeline中资源名称及key相同的命令合并为一个entry，批量数为命令数；
+// 任一entry被阻断时整个pipeline返回阻断错误，mock的命令仍会执行，结果在AfterProcessPipeline中替换，
+// pipeline返回的错误仍为实际执行的结果
+func (h *hook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if config.CloseAll() {
+		return ctx, nil
+	}
+	var (
+		state   = &pipelineState{}
+		timeout time.Duration
+	)
+	for _, g := range h.groupCommands(ctx, cmds) {
+		entry, blockErr := h.entry(ctx, g.resource, g.key, len(g.cmds))
+		if blockErr == nil {
+			g.entry = entry
+			state.groups = append(state.groups, g)
+			continue
+		}
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock, base.BlockTypeMockError:
+			g.mock = blockErr
+			state.groups = append(state.groups, g)
+			continue
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				if d := time.Duration(ctxTimeout) * time.Millisecond; timeout == 0 || d < timeout {
+					timeout = d
+				}
+				continue
+			}
+		}
+		state.exit()
+		return ctx, h.opts.blockError(ctx, g.cmds[0], blockErr)
+	}
+	if timeout > 0 {
+		ctx, state.cancel = context.WithTimeout(ctx, timeout)
+	}
+	return context.WithValue(ctx, stateKey{}, state), nil
+}
+
+func (h *hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	state, ok := ctx.Value(stateKey{}).(*pipelineState)
+	if !ok {
+		return nil
+	}
+	for _, g := range state.groups {
+		if g.mock == nil {
+			continue
+		}
+		for _, cmd := range g.cmds {
+			_ = applyMock(cmd, g.mock)
+		}
+	}
+	if state.cancel != nil {
+		state.cancel()
+	}
+	state.exit()
+	return nil
+}
+
+// entry 创建Cache出口资源entry，key作为参数传入，ctx中存在上游entry时沿用其调用链路入口
+func (h *hook) entry(ctx context.Context, resource, key string, batchCount int) (*base.SeaEntry, *base.BlockError) {
+	entryOpts := []sea.EntryOption{
+		sea.WithResourceType(base.ResTypeCache),
+		sea.WithTrafficType(base.Outbound),
+		sea.WithParentContext(ctx),
+		sea.WithBatchCount(uint32(batchCount)),
+	}
+	if len(key) > 0 {
+		entryOpts = append(entryOpts, sea.WithArgs(key))
+	}
+	return sea.Entry(resource, entryOpts...)
+}
+
+// groupCommands 按资源名称及key分组，保持命令的先后顺序，事务的multi/exec不计入
+func (h *hook) groupCommands(ctx context.Context, cmds []redis.Cmder) []*cmdGroup {
+	var (
+		groups []*cmdGroup
+		index  = make(map[string]*cmdGroup)
+	)
+	for _, cmd := range cmds {
+		if name := cmd.Name(); name == "multi" || name == "exec" {
+			continue
+		}
+		resource, key := h.opts.resourceName(ctx, cmd), commandKey(cmd)
+		groupKey := resource + "\x00" + key
+		g, ok := index[groupKey]
+		if !ok {
+			g = &cmdGroup{resource: resource, key: key}
+			index[groupKey] = g
+			groups = append(groups, g)
+		}
+		g.cmds = append(g.cmds, cmd)
+	}
+	return groups
+}
+
+// exit 记录各组命令的错误并退出entry
+func (s *pipelineState) exit() {
+	for _, g := range s.groups {
+		if g.entry == nil {
+			continue
+		}
+		for _, cmd := range g.cmds {
+			if err := cmd.Err(); err != nil && err != redis.Nil {
+				traceError(g.entry, err)
+				break
+			}
+		}
+		g.entry.Exit()
+	}
+}
+
+// commandKey 命令的key，即第一个参数，无参数时为空
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	if key, ok := args[1].(string); ok {
+		return key
+	}
+	return fmt.Sprint(args[1])
+}
+
+// traceError 记录错误用于熔断统计，redis.Nil表示key不存在，不属于错误
+func traceError(entry *base.SeaEntry, err error) {
+	if err != nil && err != redis.Nil {
+		sea.TraceError(entry, err)
+	}
+}
@@ -0,0 +1,197 @@
+package goredis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/hotspot"
+	"github.com/liuhailove/gmiter/core/mock"
+)
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+func newTestClient(t *testing.T, opts ...Option) (*miniredis.Miniredis, *redis.Client) {
+	mr, err := miniredis.Run()
+	assert.Nil(t, err)
+	t.Cleanup(mr.Close)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	client.AddHook(NewHook(opts...))
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, client
+}
+
+func TestHook(t *testing.T) {
+	initSea()
+	ctx := context.Background()
+	mr, client := newTestClient(t)
+	assert.Nil(t, mr.Set("k1", "v1"))
+	assert.Nil(t, mr.Set("k2", "v2"))
+
+	t.Run("Pass", func(t *testing.T) {
+		assert.Nil(t, client.Set(ctx, "k3", "v3", 0).Err())
+		val, err := client.Get(ctx, "k3").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, "v3", val)
+		_, err = client.Get(ctx, "none").Result()
+		assert.Equal(t, redis.Nil, err)
+	})
+
+	t.Run("HotKey", func(t *testing.T) {
+		_, err := hotspot.LoadRules([]*hotspot.Rule{{
+			Resource:        "redis:get",
+			MetricType:      hotspot.QPS,
+			ControlBehavior: hotspot.Reject,
+			ParamIdx:        0,
+			Threshold:       100,
+			DurationInSec:   1,
+			SpecificItems:   map[interface{}]int64{"k1": 1},
+		}})
+		assert.Nil(t, err)
+		defer hotspot.ClearRules()
+		assert.Nil(t, client.Get(ctx, "k1").Err())
+		blockErr, ok := client.Get(ctx, "k1").Err().(*base.BlockError)
+		assert.True(t, ok)
+		assert.Equal(t, base.BlockTypeHotSpotParamFlow, blockErr.BlockType())
+		assert.Nil(t, client.Get(ctx, "k2").Err())
+	})
+
+	t.Run("MockValue", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:           "redis:get",
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: "mock",
+		}, {
+			Resource:           "redis:incr",
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: "10",
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		val, err := client.Get(ctx, "k1").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", val)
+		n, err := client.Incr(ctx, "counter").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), n)
+		assert.False(t, mr.Exists("counter"))
+
+		// 直接调用Process时返回ErrMocked，命令结果为mock数据
+		cmd := redis.NewStringCmd(ctx, "get", "k1")
+		assert.Equal(t, ErrMocked, client.Process(ctx, cmd))
+		val, err = cmd.Result()
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", val)
+		doVal, err := client.Do(ctx, "get", "k1").Text()
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", doVal)
+	})
+
+	t.Run("MockMiss", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:        "redis:get",
+			ControlBehavior: mock.Panic,
+			ThenThrowMsg:    redis.Nil.Error(),
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		_, err = client.Get(ctx, "k1").Result()
+		assert.Equal(t, redis.Nil, err)
+	})
+}
+
+func TestPipeline(t *testing.T) {
+	initSea()
+	ctx := context.Background()
+	mr, client := newTestClient(t, WithKeyPrefixResource(":"))
+
+	t.Run("BatchBlock", func(t *testing.T) {
+		_, err := flow.LoadRules([]*flow.Rule{{
+			Resource:               "redis:user",
+			TokenCalculateStrategy: flow.Direct,
+			ControlBehavior:        flow.Reject,
+			Threshold:              2,
+			StatIntervalInMs:       1000,
+		}})
+		assert.Nil(t, err)
+		defer flow.ClearRules()
+		cmds, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, "user:1", "a", 0)
+			pipe.Set(ctx, "user:1", "b", 0)
+			pipe.Set(ctx, "user:1", "c", 0)
+			return nil
+		})
+		blockErr, ok := err.(*base.BlockError)
+		assert.True(t, ok)
+		assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+		assert.Equal(t, 3, len(cmds))
+		assert.False(t, mr.Exists("user:1"))
+
+		_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, "user:2", "a", 0)
+			pipe.Set(ctx, "order:2", "b", 0)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.True(t, mr.Exists("user:2"))
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		assert.Nil(t, mr.Set("user:1", "real"))
+		assert.Nil(t, mr.Set("order:1", "real"))
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:           "redis:user",
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: "mock",
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		var user, order *redis.StringCmd
+		_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			user = pipe.Get(ctx, "user:1")
+			order = pipe.Get(ctx, "order:1")
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", user.Val())
+		assert.Equal(t, "real", order.Val())
+	})
+}
+
+func TestTraceError(t *testing.T) {
+	initSea()
+	ctx := context.Background()
+	mr, client := newTestClient(t)
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         "redis:get",
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   1000,
+		Threshold:        1,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	// key不存在不计为错误
+	assert.Equal(t, redis.Nil, client.Get(ctx, "none").Err())
+	mr.SetError("server error")
+	assert.NotNil(t, client.Get(ctx, "k1").Err())
+	mr.SetError("")
+	blockErr, ok := client.Get(ctx, "k1").Err().(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeCircuitBreaking, blockErr.BlockType())
+}
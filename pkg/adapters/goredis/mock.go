@@ -0,0 +1,64 @@
+package goredis
+
+import (
+	"reflect"
+
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// mockError mock异常转换为命令错误，异常信息为redis: nil时返回redis.Nil用于模拟缓存未命中
+func mockError(blockErr *base.BlockError) error {
+	strVal, ok := blockErr.TriggeredValue().(string)
+	if !ok {
+		return blockErr
+	}
+	if strVal == redis.Nil.Error() {
+		return redis.Nil
+	}
+	return errors.New(strVal)
+}
+
+// applyMock 将mock结果设置到命令上
+func applyMock(cmd redis.Cmder, blockErr *base.BlockError) error {
+	var err error
+	if blockErr.BlockType() == base.BlockTypeMockError {
+		err = mockError(blockErr)
+	} else if strVal, ok := blockErr.TriggeredValue().(string); ok {
+		err = setVal(cmd, strVal)
+	} else {
+		err = blockErr
+	}
+	cmd.SetErr(err)
+	return err
+}
+
+// setVal 通过命令的SetVal方法设置mock数据，参数为string或interface{}时直接设置，其他类型按json解析，
+// 如IntCmd的mock数据为 1，StringSliceCmd的mock数据为 ["a","b"]
+func setVal(cmd redis.Cmder, data string) error {
+	method := reflect.ValueOf(cmd).MethodByName("SetVal")
+	if !method.IsValid() || method.Type().NumIn() != 1 {
+		return errors.Errorf("unsupported mock command %T", cmd)
+	}
+	valType := method.Type().In(0)
+	var val reflect.Value
+	switch valType.Kind() {
+	case reflect.String, reflect.Interface:
+		val = reflect.ValueOf(data).Convert(valType)
+	default:
+		ptr := reflect.New(valType)
+		if err := jsonTraffic.Unmarshal([]byte(data), ptr.Interface()); err != nil {
+			return errors.Wrapf(err, "parse mock data of %T", cmd)
+		}
+		val = ptr.Elem()
+	}
+	method.Call([]reflect.Value{val})
+	return nil
+}
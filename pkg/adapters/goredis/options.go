@@ -0,0 +1,79 @@
+package goredis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	// resourcePrefix 资源名称前缀
+	resourcePrefix = "redis:"
+)
+
+type (
+	Option func(*options)
+
+	options struct {
+		resourceExtract func(context.Context, redis.Cmder) string
+		blockFallback   func(context.Context, redis.Cmder, *base.BlockError) error
+	}
+)
+
+// WithResourceExtractor sets the resource extractor of redis command.
+// 默认资源名称为 redis:命令名，如 redis:get
+func WithResourceExtractor(fn func(context.Context, redis.Cmder) string) Option {
+	return func(opts *options) {
+		opts.resourceExtract = fn
+	}
+}
+
+// WithKeyPrefixResource 按key前缀生成资源名称，如separator为:时 user:1 的资源名称为 redis:user，
+// key不包含separator的命令仍按命令名生成资源名称
+func WithKeyPrefixResource(separator string) Option {
+	return WithResourceExtractor(func(ctx context.Context, cmd redis.Cmder) string {
+		if key := commandKey(cmd); len(separator) > 0 {
+			if idx := strings.Index(key, separator); idx > 0 {
+				return resourcePrefix + key[:idx]
+			}
+		}
+		return commandResourceName(cmd)
+	})
+}
+
+// WithBlockFallback sets the block fallback handler, the returned error is set to the command.
+// 默认直接返回*base.BlockError，pipeline中使用被阻断的第一个命令调用
+func WithBlockFallback(fn func(context.Context, redis.Cmder, *base.BlockError) error) Option {
+	return func(opts *options) {
+		opts.blockFallback = fn
+	}
+}
+
+func evaluateOptions(opts []Option) *options {
+	optCopy := &options{}
+	for _, o := range opts {
+		o(optCopy)
+	}
+	return optCopy
+}
+
+func (o *options) resourceName(ctx context.Context, cmd redis.Cmder) string {
+	if o.resourceExtract != nil {
+		return o.resourceExtract(ctx, cmd)
+	}
+	return commandResourceName(cmd)
+}
+
+func (o *options) blockError(ctx context.Context, cmd redis.Cmder, blockErr *base.BlockError) error {
+	if o.blockFallback != nil {
+		return o.blockFallback(ctx, cmd, blockErr)
+	}
+	return blockErr
+}
+
+func commandResourceName(cmd redis.Cmder) string {
+	return resourcePrefix + cmd.Name()
+}
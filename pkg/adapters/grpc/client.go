@@ -0,0 +1,239 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+// NewUnaryClientInterceptor creates the unary client interceptor wrapped with sea entry.
+// 资源配置了重试规则时按重试模板调用
+func NewUnaryClientInterceptor(seaOpts ...Option) grpc.UnaryClientInterceptor {
+	opts := evaluateOptions(seaOpts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if config.CloseAll() {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		resourceName := method
+		if opts.unaryClientResourceExtract != nil {
+			resourceName = opts.unaryClientResourceExtract(ctx, method, req)
+		}
+		entry, blockErr := sea.Entry(
+			resourceName,
+			sea.WithResourceType(opts.resourceType),
+			sea.WithTrafficType(base.Outbound),
+			sea.WithParentContext(ctx),
+			sea.WithTargetAddress(cc.Target()),
+			sea.WithArgs(req),
+			sea.WithRsps(reply),
+			sea.WithMetaData(outgoingMetaData(ctx)))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMock:
+				if strVal, ok := blockErr.TriggeredValue().(string); ok {
+					return unmarshalMock(strVal, reply)
+				}
+			case base.BlockTypeMockError:
+				return mockError(blockErr)
+			case base.BlockTypeMockRequest:
+				return invokeWithRetry(ctx, resourceName, method, blockErr.TriggeredValue(), reply, cc, invoker, callOpts)
+			case base.BlockTypeMockCtxTimeout:
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+					defer cancel()
+					return invokeWithRetry(newCtx, resourceName, method, req, reply, cc, invoker, callOpts)
+				}
+			}
+			if opts.unaryClientBlockFallback != nil {
+				return opts.unaryClientBlockFallback(ctx, method, req, cc, blockErr)
+			}
+			return blockStatus(blockErr).Err()
+		}
+		defer entry.Exit()
+
+		err := invokeWithRetry(ctx, resourceName, method, req, reply, cc, invoker, callOpts)
+		if err != nil {
+			sea.TraceError(entry, err)
+		}
+		return err
+	}
+}
+
+// NewStreamClientInterceptor creates the stream client interceptor wrapped with sea entry.
+// entry在流结束时退出：RecvMsg返回io.EOF或错误、ctx结束，非io.EOF的错误记为异常
+func NewStreamClientInterceptor(seaOpts ...Option) grpc.StreamClientInterceptor {
+	opts := evaluateOptions(seaOpts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if config.CloseAll() {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		resourceName := method
+		if opts.streamClientResourceExtract != nil {
+			resourceName = opts.streamClientResourceExtract(ctx, desc, method)
+		}
+		entry, blockErr := sea.Entry(
+			resourceName,
+			sea.WithResourceType(opts.resourceType),
+			sea.WithTrafficType(base.Outbound),
+			sea.WithParentContext(ctx),
+			sea.WithTargetAddress(cc.Target()),
+			sea.WithMetaData(outgoingMetaData(ctx)))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMock:
+				if strVal, ok := blockErr.TriggeredValue().(string); ok {
+					return &mockClientStream{ctx: ctx, data: strVal}, nil
+				}
+			case base.BlockTypeMockError:
+				return nil, mockError(blockErr)
+			case base.BlockTypeMockCtxTimeout:
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+					cs, err := streamer(newCtx, desc, cc, method, callOpts...)
+					if err != nil {
+						cancel()
+						return nil, err
+					}
+					return &cancelClientStream{ClientStream: cs, cancel: cancel}, nil
+				}
+			}
+			if opts.streamClientBlockFallback != nil {
+				return opts.streamClientBlockFallback(ctx, desc, cc, method, blockErr)
+			}
+			return nil, blockStatus(blockErr).Err()
+		}
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			sea.TraceError(entry, err)
+			entry.Exit()
+			return nil, err
+		}
+		return newGuardedClientStream(ctx, cs, entry), nil
+	}
+}
+
+// guardedClientStream 客户端流，RecvMsg返回io.EOF或错误、ctx结束时退出entry，保证只退出一次
+type guardedClientStream struct {
+	grpc.ClientStream
+	entry *base.SeaEntry
+
+	once sync.Once
+	done chan struct{}
+	mux  sync.Mutex
+	err  error
+}
+
+func newGuardedClientStream(ctx context.Context, cs grpc.ClientStream, entry *base.SeaEntry) *guardedClientStream {
+	s := &guardedClientStream{ClientStream: cs, entry: entry, done: make(chan struct{})}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.finish(ctx.Err())
+			case <-s.done:
+			}
+		}()
+	}
+	return s
+}
+
+func (s *guardedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	// io.EOF表示流已结束，真实的状态由RecvMsg返回
+	if err != io.EOF {
+		s.recordError(err)
+	}
+	return err
+}
+
+func (s *guardedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+// recordError 记录流的首个异常，io.EOF及调用方取消不视为异常
+func (s *guardedClientStream) recordError(err error) {
+	if err == nil || err == io.EOF || err == context.Canceled || status.Code(err) == codes.Canceled {
+		return
+	}
+	s.mux.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mux.Unlock()
+}
+
+func (s *guardedClientStream) finish(err error) {
+	s.recordError(err)
+	s.once.Do(func() {
+		s.mux.Lock()
+		if s.err != nil {
+			sea.TraceError(s.entry, s.err)
+		}
+		s.mux.Unlock()
+		s.entry.Exit()
+		close(s.done)
+	})
+}
+
+// cancelClientStream 流结束时取消mock设置的超时ctx
+type cancelClientStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *cancelClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// mockClientStream 返回一次mock数据后结束的客户端流
+type mockClientStream struct {
+	ctx  context.Context
+	data string
+	done bool
+}
+
+func (s *mockClientStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+func (s *mockClientStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+func (s *mockClientStream) CloseSend() error {
+	return nil
+}
+
+func (s *mockClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mockClientStream) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *mockClientStream) RecvMsg(m interface{}) error {
+	if s.done {
+		return io.EOF
+	}
+	s.done = true
+	return unmarshalMock(s.data, m)
+}
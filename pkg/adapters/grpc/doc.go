@@ -0,0 +1,8 @@
+// Package grpc provides the google.golang.org/grpc unary and stream interceptors of gmiter.
+//
+// 资源名称默认为grpc的完整方法名，如 /grpc.health.v1.Health/Check，incoming/outgoing metadata作为WithMetaData传入。
+//
+// 阻断处理：mock返回mock数据，mock异常返回codes.Unknown，请求替换及ctx超时替换后继续调用；
+// 流控、热点、系统及隔离阻断返回codes.ResourceExhausted，授权阻断返回codes.PermissionDenied，其他阻断返回codes.Unavailable。
+// 客户端一元调用在资源配置了重试规则时按core/retry/rule的重试模板执行。
+package grpc
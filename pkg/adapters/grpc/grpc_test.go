@@ -0,0 +1,279 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/mock"
+	"github.com/liuhailove/gmiter/core/retry/rule"
+	"github.com/liuhailove/gmiter/core/stat"
+)
+
+const (
+	checkMethod = "/grpc.health.v1.Health/Check"
+	watchMethod = "/grpc.health.v1.Health/Watch"
+)
+
+// healthServer 前两次Check返回NOT_SERVING，之后返回SERVING；Watch发送一条消息后返回watchErr
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	checks   int32
+	watchErr error
+}
+
+func (s *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if atomic.AddInt32(&s.checks, 1) <= 2 {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	return s.watchErr
+}
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+// newTestClient 启动bufconn服务端并返回客户端
+func newTestClient(t *testing.T, srv *healthServer, serverOpts []grpc.ServerOption, dialOpts ...grpc.DialOption) grpc_health_v1.HealthClient {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(serverOpts...)
+	grpc_health_v1.RegisterHealthServer(server, srv)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	dialOpts = append(dialOpts,
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial("bufnet", dialOpts...)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return grpc_health_v1.NewHealthClient(conn)
+}
+
+func TestServerInterceptor(t *testing.T) {
+	initSea()
+	client := newTestClient(t, &healthServer{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(NewUnaryServerInterceptor()),
+		grpc.StreamInterceptor(NewStreamServerInterceptor()),
+	})
+
+	t.Run("FlowBlock", func(t *testing.T) {
+		_, err := flow.LoadRules([]*flow.Rule{{
+			Resource:               watchMethod,
+			TokenCalculateStrategy: flow.Direct,
+			ControlBehavior:        flow.Reject,
+			Threshold:              0,
+		}})
+		assert.Nil(t, err)
+		defer flow.ClearRules()
+		stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:           checkMethod,
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: `{"status":"SERVICE_UNKNOWN"}`,
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		rsp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, rsp.Status)
+	})
+}
+
+func TestClientInterceptor(t *testing.T) {
+	initSea()
+	srv := &healthServer{}
+	client := newTestClient(t, srv, nil,
+		grpc.WithUnaryInterceptor(NewUnaryClientInterceptor(WithResourceType(base.ResTypeMicro))),
+		grpc.WithStreamInterceptor(NewStreamClientInterceptor()))
+
+	t.Run("MockByMetaData", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:        checkMethod,
+			ControlBehavior: mock.Panic,
+			AdditionalItems: []mock.AdditionalItem{{Key: "x-mock", Value: "1"}},
+			ThenThrowMsg:    "mock error",
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-mock", "1")
+		_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.Equal(t, codes.Unknown, status.Code(err))
+		assert.Equal(t, "mock error", status.Convert(err).Message())
+		assert.Equal(t, int32(0), atomic.LoadInt32(&srv.checks))
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		_, err := rule.LoadRules([]*rule.Rule{{
+			Resource:         checkMethod,
+			RetryPolicy:      rule.MaxAttemptsRetryPolicy,
+			RetryMaxAttempts: 3,
+			BackoffPolicy:    rule.NoBackOffPolicy,
+			ErrorMatcher:     rule.AnyMatch,
+			SpecificItems:    []rule.Item{{AdditionalItemKey: "status", AdditionalItemValues: []string{"NOT_SERVING"}}},
+		}})
+		assert.Nil(t, err)
+		defer rule.ClearRules()
+		rsp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, rsp.Status)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&srv.checks))
+	})
+
+	t.Run("StreamMock", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:           watchMethod,
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: `{"status":"NOT_SERVING"}`,
+		}})
+		assert.Nil(t, err)
+		defer mock.ClearRules()
+		stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+		rsp, err := stream.Recv()
+		assert.Nil(t, err)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, rsp.Status)
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+// 客户端流在流结束时退出entry，非io.EOF的错误记为异常
+func TestStreamClientInterceptor_Exit(t *testing.T) {
+	initSea()
+	open := func(t *testing.T, ctx context.Context, resource string, watchErr error) grpc_health_v1.Health_WatchClient {
+		client := newTestClient(t, &healthServer{watchErr: watchErr}, nil,
+			grpc.WithStreamInterceptor(NewStreamClientInterceptor(WithStreamClientResourceExtractor(
+				func(context.Context, *grpc.StreamDesc, string) string {
+					return resource
+				}))))
+		stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.Nil(t, err)
+		// 流建立后entry不退出
+		assert.Equal(t, int32(1), stat.GetResourceNode(resource).CurrentConcurrency())
+		return stream
+	}
+	assertExited := func(t *testing.T, resource string, errCount int64) {
+		node := stat.GetResourceNode(resource)
+		assert.Eventually(t, func() bool {
+			return node.CurrentConcurrency() == 0
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int64(1), node.GetSum(base.MetricEventComplete))
+		assert.Equal(t, errCount, node.GetSum(base.MetricEventError))
+	}
+
+	t.Run("EOF", func(t *testing.T) {
+		stream := open(t, context.Background(), "watch-eof", nil)
+		_, err := stream.Recv()
+		assert.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, io.EOF, err)
+		_, _ = stream.Recv()
+		assertExited(t, "watch-eof", 0)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		stream := open(t, context.Background(), "watch-error", status.Error(codes.Internal, "broken"))
+		_, err := stream.Recv()
+		assert.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assertExited(t, "watch-error", 1)
+	})
+
+	t.Run("CtxCancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		_ = open(t, ctx, "watch-cancel", nil)
+		cancel()
+		assertExited(t, "watch-cancel", 0)
+	})
+}
+
+// 按照连接的target熔断，一个下游熔断不影响其他下游
+func TestClientInterceptor_TargetAddress(t *testing.T) {
+	initSea()
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         checkMethod,
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        1,
+		KeyMode:          circuitbreaker.KeyByTargetAddress,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	dial := func(target string) *grpc.ClientConn {
+		conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.Nil(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		return conn
+	}
+	failed, ok := dial("failed:8080"), dial("ok:8080")
+	var invoked int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		atomic.AddInt32(&invoked, 1)
+		if cc == failed {
+			return status.Error(codes.Internal, "internal")
+		}
+		return nil
+	}
+	interceptor := NewUnaryClientInterceptor()
+	call := func(cc *grpc.ClientConn) error {
+		return interceptor(context.Background(), checkMethod, &grpc_health_v1.HealthCheckRequest{},
+			&grpc_health_v1.HealthCheckResponse{}, cc, invoker)
+	}
+	assert.Equal(t, codes.Internal, status.Code(call(failed)))
+	assert.Equal(t, codes.Unavailable, status.Code(call(failed)))
+	assert.Nil(t, call(ok))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&invoked))
+	keys := circuitbreaker.GetOpenKeys(checkMethod)
+	if assert.Equal(t, 1, len(keys)) {
+		assert.Equal(t, "failed:8080", keys[0].Key)
+	}
+}
+
+func TestBlockStatus(t *testing.T) {
+	assert.Equal(t, codes.ResourceExhausted, blockStatus(base.NewBlockError(base.WithBlockType(base.BlockTypeFlow))).Code())
+	assert.Equal(t, codes.Unavailable, blockStatus(base.NewBlockError(base.WithBlockType(base.BlockTypeCircuitBreaking))).Code())
+	assert.Equal(t, codes.PermissionDenied, blockStatus(base.NewBlockError(base.WithBlockType(base.BlockTypeAuthority))).Code())
+}
@@ -0,0 +1,136 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	"github.com/liuhailove/gmiter/core/retry"
+	"github.com/liuhailove/gmiter/core/retry/rule"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+const (
+	// retryItemMatchPrefix 响应属性命中重试规则时的错误前缀
+	retryItemMatchPrefix = "additionalItem match,can retry ,value="
+)
+
+// invokeWithRetry 资源存在重试模板时按模板调用，否则直接调用
+func invokeWithRetry(ctx context.Context, resourceName, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, callOpts []grpc.CallOption) error {
+	var rules = rule.GetRulesOfResource(resourceName)
+	var resRetryTemplate = rule.GetRetryTemplateOfResource(resourceName)
+	if resRetryTemplate == nil || len(rules) == 0 {
+		return invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+	_, err := resRetryTemplate.Execute(&unaryRetryCallback{
+		ctx:      ctx,
+		method:   method,
+		req:      req,
+		reply:    reply,
+		cc:       cc,
+		invoker:  invoker,
+		callOpts: callOpts,
+		rules:    rules,
+	})
+	if err != nil && strings.HasPrefix(err.Error(), retryItemMatchPrefix) {
+		// 重试耗尽时响应仍命中重试规则，返回最后一次的响应
+		err = nil
+	}
+	return err
+}
+
+// unaryRetryCallback grpc一元调用的重试回调
+type unaryRetryCallback struct {
+	ctx      context.Context
+	method   string
+	req      interface{}
+	reply    interface{}
+	cc       *grpc.ClientConn
+	invoker  grpc.UnaryInvoker
+	callOpts []grpc.CallOption
+	rules    []rule.Rule
+}
+
+func (c *unaryRetryCallback) DoWithRetry(content retry.RtyContext) interface{} {
+	if logging.InfoEnabled() && content.GetRetryCount() > 0 {
+		logging.Info("DoWithRetryMore", "method", c.method, "retry count", content.GetRetryCount(), "err", content.GetLastError())
+	}
+	if err := c.invoker(c.ctx, c.method, c.req, c.reply, c.cc, c.callOpts...); err != nil {
+		panic(err)
+	}
+	if value, ok := matchRetryItem(c.rules[0].SpecificItems, c.reply); ok {
+		panic(errors.New(retryItemMatchPrefix + value))
+	}
+	return nil
+}
+
+// matchRetryItem 检查响应属性是否命中规则中需要重试的值
+func matchRetryItem(items []rule.Item, reply interface{}) (string, bool) {
+	if len(items) == 0 {
+		return "", false
+	}
+	data, err := marshalReply(reply)
+	if err != nil {
+		return "", false
+	}
+	var obj map[string]interface{}
+	if err = jsonTraffic.Unmarshal(data, &obj); err != nil {
+		return "", false
+	}
+	for _, item := range items {
+		if len(item.AdditionalItemKey) == 0 || len(item.AdditionalItemValues) == 0 {
+			continue
+		}
+		val, ok := lookup(obj, strings.Split(item.AdditionalItemKey, "."))
+		if !ok {
+			continue
+		}
+		valStrings := formatValue(val)
+		for _, value := range item.AdditionalItemValues {
+			for _, valString := range valStrings {
+				if value == valString {
+					return value, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func lookup(obj map[string]interface{}, properties []string) (interface{}, bool) {
+	var cur interface{} = obj
+	for _, p := range properties {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// formatValue 属性值的字符串形式，数值同时支持整数及6位小数格式
+func formatValue(val interface{}) []string {
+	switch v := val.(type) {
+	case string:
+		return []string{v}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case float64:
+		if v == math.Trunc(v) {
+			return []string{strconv.FormatInt(int64(v), 10), fmt.Sprintf("%.6f", v)}
+		}
+		return []string{fmt.Sprintf("%.6f", v)}
+	default:
+		data, _ := jsonTraffic.Marshal(v)
+		return []string{string(data)}
+	}
+}
@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+// NewUnaryServerInterceptor creates the unary server interceptor wrapped with sea entry.
+func NewUnaryServerInterceptor(seaOpts ...Option) grpc.UnaryServerInterceptor {
+	opts := evaluateOptions(seaOpts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if config.CloseAll() {
+			return handler(ctx, req)
+		}
+		resourceName := info.FullMethod
+		if opts.unaryServerResourceExtract != nil {
+			resourceName = opts.unaryServerResourceExtract(ctx, req, info)
+		}
+		entry, blockErr := sea.Entry(
+			resourceName,
+			sea.WithResourceType(opts.resourceType),
+			sea.WithTrafficType(base.Inbound),
+			sea.WithParentContext(ctx),
+			sea.WithArgs(req),
+			sea.WithMetaData(incomingMetaData(ctx)))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMock:
+				if strVal, ok := blockErr.TriggeredValue().(string); ok {
+					rsp, err := newUnaryReply(info)
+					if err != nil {
+						return nil, err
+					}
+					if err = unmarshalMock(strVal, rsp); err != nil {
+						return nil, err
+					}
+					return rsp, nil
+				}
+			case base.BlockTypeMockError:
+				return nil, mockError(blockErr)
+			case base.BlockTypeMockRequest:
+				return handler(ctx, blockErr.TriggeredValue())
+			case base.BlockTypeMockCtxTimeout:
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+					defer cancel()
+					return handler(newCtx, req)
+				}
+			}
+			if opts.unaryServerBlockFallback != nil {
+				return opts.unaryServerBlockFallback(ctx, req, info, blockErr)
+			}
+			return nil, blockStatus(blockErr).Err()
+		}
+		defer entry.Exit()
+
+		res, err := handler(sea.ContextWithEntry(ctx, entry), req)
+		if err != nil {
+			sea.TraceError(entry, err)
+		}
+		return res, err
+	}
+}
+
+// NewStreamServerInterceptor creates the stream server interceptor wrapped with sea entry.
+func NewStreamServerInterceptor(seaOpts ...Option) grpc.StreamServerInterceptor {
+	opts := evaluateOptions(seaOpts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if config.CloseAll() {
+			return handler(srv, ss)
+		}
+		resourceName := info.FullMethod
+		if opts.streamServerResourceExtract != nil {
+			resourceName = opts.streamServerResourceExtract(srv, ss, info)
+		}
+		entry, blockErr := sea.Entry(
+			resourceName,
+			sea.WithResourceType(opts.resourceType),
+			sea.WithTrafficType(base.Inbound),
+			sea.WithParentContext(ss.Context()),
+			sea.WithMetaData(incomingMetaData(ss.Context())))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMockError:
+				return mockError(blockErr)
+			case base.BlockTypeMockCtxTimeout:
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					newCtx, cancel := context.WithTimeout(ss.Context(), time.Duration(ctxTimeout)*time.Millisecond)
+					defer cancel()
+					return handler(srv, &contextStream{ServerStream: ss, ctx: newCtx})
+				}
+			}
+			if opts.streamServerBlockFallback != nil {
+				return opts.streamServerBlockFallback(srv, ss, info, blockErr)
+			}
+			return blockStatus(blockErr).Err()
+		}
+		defer entry.Exit()
+
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: sea.ContextWithEntry(ss.Context(), entry)})
+		if err != nil {
+			sea.TraceError(entry, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// metaDataMap grpc的metadata key均为小写，多个值时取第一个
+func metaDataMap(md metadata.MD) map[string]string {
+	result := make(map[string]string, len(md))
+	for k, v := range md {
+		if len(v) > 0 {
+			result[k] = v[0]
+		}
+	}
+	return result
+}
+
+func incomingMetaData(ctx context.Context) map[string]string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return metaDataMap(md)
+}
+
+func outgoingMetaData(ctx context.Context) map[string]string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metaDataMap(md)
+}
+
+// blockStatus 将阻断转换为grpc状态，限流类阻断为ResourceExhausted，熔断等不可用为Unavailable
+func blockStatus(blockErr *base.BlockError) *status.Status {
+	switch blockErr.BlockType() {
+	case base.BlockTypeFlow, base.BlockTypeHotSpotParamFlow, base.BlockTypeSystemFlow, base.BlockTypeIsolation:
+		return status.New(codes.ResourceExhausted, blockErr.Error())
+	case base.BlockTypeAuthority:
+		return status.New(codes.PermissionDenied, blockErr.Error())
+	default:
+		return status.New(codes.Unavailable, blockErr.Error())
+	}
+}
+
+// mockError mock异常转换为grpc错误
+func mockError(blockErr *base.BlockError) error {
+	if strVal, ok := blockErr.TriggeredValue().(string); ok {
+		return status.Error(codes.Unknown, strVal)
+	}
+	return blockStatus(blockErr).Err()
+}
+
+// unmarshalMock 将mock数据反序列化到响应，proto消息使用protojson
+func unmarshalMock(data string, rsp interface{}) error {
+	if msg, ok := rsp.(proto.Message); ok {
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal([]byte(data), msg)
+	}
+	return jsonTraffic.Unmarshal([]byte(data), rsp)
+}
+
+func marshalReply(rsp interface{}) ([]byte, error) {
+	if msg, ok := rsp.(proto.Message); ok {
+		return protojson.Marshal(msg)
+	}
+	return jsonTraffic.Marshal(rsp)
+}
+
+// newUnaryReply 根据服务实现的方法签名创建响应，用于服务端返回mock数据
+func newUnaryReply(info *grpc.UnaryServerInfo) (interface{}, error) {
+	methodName := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	method := reflect.ValueOf(info.Server).MethodByName(methodName)
+	if !method.IsValid() || method.Type().NumOut() != 2 {
+		return nil, errors.Errorf("cannot find method %s of grpc server", info.FullMethod)
+	}
+	out := method.Type().Out(0)
+	if out.Kind() != reflect.Ptr {
+		return nil, errors.Errorf("unsupported reply type %s of %s", out, info.FullMethod)
+	}
+	return reflect.New(out.Elem()).Interface(), nil
+}
+
+// contextStream 替换ServerStream的context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
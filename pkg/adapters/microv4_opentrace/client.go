@@ -42,26 +42,16 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 		if opts.clientResourceExtract != nil {
 			resourceName = opts.clientResourceExtract(ctx, req)
 		}
-		metaDataMap := make(map[string]string, 0)
-		metaData, ok := metadata.FromContext(ctx)
-		// 来源服务名称
-		var fromService string
-		if ok {
-			re := regexp.MustCompile(`\b\w`)
-			for k, v := range metaData {
-				metaDataMap[k] = v
-				// 首字母切换为小写，为了兼容micro的配置，microv4把首字符修改为了大写，为了使其应用于v4，所以增加此做法
-				metaDataMap[re.ReplaceAllStringFunc(k, strings.ToLower)] = v
-				if k == "Micro-From-Service" || k == strings.ToLower("Micro-From-Service") {
-					fromService = v
-				}
-			}
-		}
-		var routerRules []weight_router.Rule
+		metaDataMap, fromService := extractMetaData(ctx)
+		var (
+			routerRules []weight_router.Rule
+			routing     bool
+		)
 		entry, blockErr := sea.Entry(
 			resourceName,
 			sea.WithResourceType(base.ResTypeMicro),
 			sea.WithTrafficType(base.Outbound),
+			sea.WithTargetAddress(targetAddress(optArr)),
 			sea.WithArgs(req.Body()),
 			sea.WithRsps(rsp),
 			sea.WithMetaData(metaDataMap),
@@ -113,37 +103,10 @@ func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 			return blockErr
 		}
 		defer entry.Exit()
-		if entry.GrayResource() != nil {
-			if strings.Contains(entry.GrayResource().Name(), "*") {
-				goto RetryLabel
-			}
-			var service, endpoint, err = splitServiceAndEndpoint(entry.GrayResource().Name())
-			if err == nil {
-				req = c.Client.NewRequest(service, endpoint, req.Body(), client.WithContentType(req.ContentType()))
-			} else {
-				logging.Warn("exist error in gray flow", "err", err)
-			}
-			if entry.LinkPass() {
-				md, success := metadata.FromContext(ctx)
-				if success {
-					newMd := metadata.Copy(md)
-					newMd["grayTag"] = entry.GrayTag()
-					ctx = metadata.NewContext(ctx, newMd)
-				}
-			}
-
-			if entry.LinkPass() {
-				var patchMd = metadata.Metadata{}
-				patchMd["grayTag"] = entry.GrayTag()
-				ctx = metadata.MergeContext(ctx, patchMd, false)
-			}
-			if len(entry.GrayAddress()) > 0 {
-				// 在灰度验证时，如果灰度地址部分失效了，需要进行重试，此处设置重试3次，重试间隔为10ms,100ms,1000ms
-				// 重排灰度地址，因为当设置地址后，目前默认一直选择第一个地址，这会导致流量不均匀，因此要重排
-				optArr = append(optArr, client.WithAddress(randomSort(entry.GrayAddress())...), client.WithRetries(3))
-			}
+		ctx, req, optArr, routing = grayRoute(c.Client, ctx, req, entry, optArr)
+		if !routing {
+			goto RetryLabel
 		}
-
 		//动态路由
 		//1. 根据条件筛选有效的路由规则，如接口条件,规则优先级等
 		routerRules = weight_router.GetActualRules()
@@ -311,40 +274,72 @@ func (g *GrpcRetryCallback) DoWithRetry(content retry.RtyContext) interface{} {
 	return nil
 }
 
-func (c *clientWrapper) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
-	if !config.CloseAll() {
-		resourceName := req.Service() + "." + req.Endpoint()
-		options := evaluateOptions(c.Opts)
-		if options.serverResourceExtract != nil {
-			resourceName = options.streamClientResourceExtract(ctx, req)
-		}
-		entry, blockErr := sea.Entry(
-			resourceName,
-			sea.WithResourceType(base.ResTypeRPC),
-			sea.WithTrafficType(base.Outbound),
-			sea.WithArgs(req.Body()))
-		if blockErr != nil {
-			if options.streamClientBlockFallback != nil {
-				return options.streamClientBlockFallback(ctx, req, blockErr)
+// NewClientWrapper returns a sea client Wrapper.
+func NewClientWrapper(opts ...Option) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &clientWrapper{c, opts}
+	}
+}
+
+// extractMetaData 提取metadata及来源服务名称
+func extractMetaData(ctx context.Context) (map[string]string, string) {
+	metaDataMap := make(map[string]string, 0)
+	metaData, ok := metadata.FromContext(ctx)
+	// 来源服务名称
+	var fromService string
+	if ok {
+		re := regexp.MustCompile(`\b\w`)
+		for k, v := range metaData {
+			metaDataMap[k] = v
+			// 首字母切换为小写，为了兼容micro的配置，microv4把首字符修改为了大写，为了使其应用于v4，所以增加此做法
+			metaDataMap[re.ReplaceAllStringFunc(k, strings.ToLower)] = v
+			if k == "Micro-From-Service" || k == strings.ToLower("Micro-From-Service") {
+				fromService = v
 			}
-			return nil, blockErr
 		}
-		defer entry.Exit()
+	}
+	return metaDataMap, fromService
+}
 
-		stream, err := c.Client.Stream(ctx, req, opts...)
-		if err != nil {
-			sea.TraceError(entry, err)
+// grayRoute 按照灰度资源改写请求，透传灰度标签并设置灰度地址，
+// 返回false表示灰度资源为通配资源，不再进行动态路由
+func grayRoute(c client.Client, ctx context.Context, req client.Request, entry *base.SeaEntry, optArr []client.CallOption) (context.Context, client.Request, []client.CallOption, bool) {
+	if entry.GrayResource() == nil {
+		return ctx, req, optArr, true
+	}
+	if strings.Contains(entry.GrayResource().Name(), "*") {
+		return ctx, req, optArr, false
+	}
+	var service, endpoint, err = splitServiceAndEndpoint(entry.GrayResource().Name())
+	if err == nil {
+		reqOpts := []client.RequestOption{client.WithContentType(req.ContentType())}
+		if req.Stream() {
+			reqOpts = append(reqOpts, client.StreamingRequest())
+		}
+		req = c.NewRequest(service, endpoint, req.Body(), reqOpts...)
+	} else {
+		logging.Warn("exist error in gray flow", "err", err)
+	}
+	if entry.LinkPass() {
+		md, success := metadata.FromContext(ctx)
+		if success {
+			newMd := metadata.Copy(md)
+			newMd["grayTag"] = entry.GrayTag()
+			ctx = metadata.NewContext(ctx, newMd)
 		}
-		return stream, err
 	}
-	return c.Client.Stream(ctx, req, opts...)
-}
 
-// NewClientWrapper returns a sea client Wrapper.
-func NewClientWrapper(opts ...Option) client.Wrapper {
-	return func(c client.Client) client.Client {
-		return &clientWrapper{c, opts}
+	if entry.LinkPass() {
+		var patchMd = metadata.Metadata{}
+		patchMd["grayTag"] = entry.GrayTag()
+		ctx = metadata.MergeContext(ctx, patchMd, false)
+	}
+	if len(entry.GrayAddress()) > 0 {
+		// 在灰度验证时，如果灰度地址部分失效了，需要进行重试，此处设置重试3次，重试间隔为10ms,100ms,1000ms
+		// 重排灰度地址，因为当设置地址后，目前默认一直选择第一个地址，这会导致流量不均匀，因此要重排
+		optArr = append(optArr, client.WithAddress(randomSort(entry.GrayAddress())...), client.WithRetries(3))
 	}
+	return ctx, req, optArr, true
 }
 
 // splitServiceAndEndpoint 将资源名称且氛围服务和endpoint
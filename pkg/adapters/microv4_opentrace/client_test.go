@@ -0,0 +1,71 @@
+package microv4_opentrace
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go-micro.dev/v4/client"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+)
+
+// fakeCallClient 对failedAddress的调用返回错误，其余调用成功
+type fakeCallClient struct {
+	client.Client
+	failedAddress string
+	calls         int32
+}
+
+func (c *fakeCallClient) NewRequest(service, endpoint string, req interface{}, reqOpts ...client.RequestOption) client.Request {
+	return client.NewRequest(service, endpoint, req, reqOpts...)
+}
+
+func (c *fakeCallClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	atomic.AddInt32(&c.calls, 1)
+	if targetAddress(opts) == c.failedAddress {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestTargetAddress(t *testing.T) {
+	assert.Equal(t, "", targetAddress(nil))
+	assert.Equal(t, "10.0.0.1:8080", targetAddress([]client.CallOption{client.WithAddress("10.0.0.1:8080", "10.0.0.2:8080")}))
+}
+
+// 按照调用地址熔断，一个下游熔断不影响其他下游
+func TestCall_TargetAddress(t *testing.T) {
+	initSea()
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         "svc.Greeter.KeyedCall",
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        1,
+		KeyMode:          circuitbreaker.KeyByTargetAddress,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	fc := &fakeCallClient{failedAddress: "10.0.0.1:8080"}
+	c := NewClientWrapper()(fc)
+	call := func(address string) error {
+		return c.Call(context.Background(), c.NewRequest("svc", "Greeter.KeyedCall", nil), nil, client.WithAddress(address))
+	}
+	assert.EqualError(t, call("10.0.0.1:8080"), "unavailable")
+	blockErr, ok := call("10.0.0.1:8080").(*base.BlockError)
+	if assert.True(t, ok) {
+		assert.Equal(t, base.BlockTypeCircuitBreaking, blockErr.BlockType())
+	}
+	assert.Nil(t, call("10.0.0.2:8080"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fc.calls))
+	keys := circuitbreaker.GetOpenKeys("svc.Greeter.KeyedCall")
+	if assert.Equal(t, 1, len(keys)) {
+		assert.Equal(t, "10.0.0.1:8080", keys[0].Key)
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/liuhailove/gmiter v1.0.5
 	github.com/opentracing/opentracing-go v1.1.0
 	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
 	go-micro.dev/v4 v4.9.0
 	google.golang.org/grpc v1.27.1
 	google.golang.org/protobuf v1.26.0
@@ -54,7 +55,6 @@ require (
 	github.com/shirou/gopsutil/v3 v3.21.6 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
 	github.com/stretchr/objx v0.1.1 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/tklauser/go-sysconf v0.3.6 // indirect
 	github.com/tklauser/numcpus v0.2.2 // indirect
 	github.com/urfave/cli/v2 v2.3.0 // indirect
@@ -72,3 +72,5 @@ require (
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
+
+replace github.com/liuhailove/gmiter => ../../..
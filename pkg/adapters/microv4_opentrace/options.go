@@ -24,6 +24,9 @@ type (
 		streamClientBlockFallback func(context.Context, client.Request, *base.BlockError) (client.Stream, error)
 		streamServerBlockFallback func(server.Stream, *base.BlockError) server.Stream
 
+		// streamMessageFlowControl 是否开启流式调用的逐条消息流控
+		streamMessageFlowControl bool
+
 		// tracer 链路追踪Tracer
 		tracer opentracing.Tracer
 	}
@@ -87,6 +90,14 @@ func WithStreamServerBlockFallback(fn func(server.Stream, *base.BlockError) serv
 	}
 }
 
+// WithStreamMessageFlowControl 开启流式调用的逐条消息流控，客户端Send及服务端Recv的每条消息
+// 以 流资源名称+StreamMessageResourceSuffix 为资源进入sea，被阻断时返回*base.BlockError且消息不会被发送或读取
+func WithStreamMessageFlowControl() Option {
+	return func(opts *options) {
+		opts.streamMessageFlowControl = true
+	}
+}
+
 func WithOpenTracer(tracer opentracing.Tracer) Option {
 	return func(opts *options) {
 		opts.tracer = tracer
@@ -13,11 +13,15 @@ import (
 	"strings"
 )
 
-// NewHandlerWrapper returns a Handler Wrapper with  sea breaker
+// NewHandlerWrapper returns a Handler Wrapper with  sea breaker.
+// 流式请求的rsp为server.Stream，在handler返回时退出entry，见 serverStreamHandler
 func NewHandlerWrapper(seaOpts ...Option) server.HandlerWrapper {
 	return func(h server.HandlerFunc) server.HandlerFunc {
 		return func(ctx context.Context, req server.Request, rsp interface{}) error {
 			if !config.CloseAll() {
+				if stream, ok := rsp.(server.Stream); ok && req.Stream() {
+					return serverStreamHandler(evaluateOptions(seaOpts), h, ctx, req, stream)
+				}
 				resourceName := req.Service() + "." + req.Endpoint()
 				opts := evaluateOptions(seaOpts)
 				if opts.serverResourceExtract != nil {
@@ -77,25 +81,3 @@ func NewHandlerWrapper(seaOpts ...Option) server.HandlerWrapper {
 		}
 	}
 }
-
-func NewStreamWrapper(seaOpts ...Option) server.StreamWrapper {
-	return func(stream server.Stream) server.Stream {
-		if !config.CloseAll() {
-			resourceName := stream.Request().Service() + "." + stream.Request().Endpoint()
-			opts := evaluateOptions(seaOpts)
-			if opts.serverResourceExtract != nil {
-				resourceName = opts.streamServerResourceExtract(stream)
-			}
-			entry, blockErr := sea.Entry(resourceName, sea.WithResourceType(base.ResTypeRPC), sea.WithTrafficType(base.Inbound))
-			if blockErr != nil {
-				if opts.serverBlockFallback != nil {
-					return opts.streamServerBlockFallback(stream, blockErr)
-				}
-				stream.Send(blockErr)
-				return stream
-			}
-			defer entry.Exit()
-		}
-		return stream
-	}
-}
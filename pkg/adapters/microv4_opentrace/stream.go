@@ -0,0 +1,442 @@
+package microv4_opentrace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/codec"
+	microerror "go-micro.dev/v4/errors"
+	"go-micro.dev/v4/selector"
+	"go-micro.dev/v4/server"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/retry"
+	"github.com/liuhailove/gmiter/core/retry/rule"
+	"github.com/liuhailove/gmiter/core/weight_router"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+const (
+	// StreamMessageResourceSuffix 逐条消息流控的资源名称后缀
+	StreamMessageResourceSuffix = ":message"
+
+	// lastStreamResponse go-micro服务端流式handler正常返回且客户端未结束发送时返回的错误信息
+	lastStreamResponse = "EOS"
+)
+
+// Stream 流式调用在流关闭、接收出错或ctx结束时退出entry，与Call一致地支持灰度、mock、动态路由及重试
+func (c *clientWrapper) Stream(ctx context.Context, req client.Request, optArr ...client.CallOption) (client.Stream, error) {
+	if config.CloseAll() {
+		return c.Client.Stream(ctx, req, optArr...)
+	}
+	opts := evaluateOptions(c.Opts)
+	resourceName := req.Service() + "." + req.Endpoint()
+	if opts.streamClientResourceExtract != nil {
+		resourceName = opts.streamClientResourceExtract(ctx, req)
+	}
+	metaDataMap, fromService := extractMetaData(ctx)
+	entry, blockErr := sea.Entry(
+		resourceName,
+		sea.WithResourceType(base.ResTypeMicro),
+		sea.WithTrafficType(base.Outbound),
+		sea.WithTargetAddress(targetAddress(optArr)),
+		sea.WithArgs(req.Body()),
+		sea.WithMetaData(metaDataMap),
+		sea.WithFromService(fromService))
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				addTrace(opts, ctx, req.Endpoint(), req.Body(), strVal, false)
+				return &mockClientStream{ctx: ctx, req: req, msgs: parseMockMessages(strVal)}, nil
+			}
+			addTrace(opts, ctx, req.Endpoint(), req.Body(), blockErr, false)
+			return nil, blockErr
+		case base.BlockTypeMockError:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				addTrace(opts, ctx, req.Endpoint(), req.Body(), strVal, true)
+				return nil, errors.New(strVal)
+			}
+			addTrace(opts, ctx, req.Endpoint(), req.Body(), blockErr, true)
+			return nil, blockErr
+		case base.BlockTypeMockRequest:
+			newRequest := c.Client.NewRequest(req.Service(), req.Endpoint(), blockErr.TriggeredValue(), client.StreamingRequest())
+			return c.openStream(ctx, newRequest, optArr, &streamGuard{resourceName: resourceName, opts: opts})
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+				return c.openStream(newCtx, req, optArr, &streamGuard{resourceName: resourceName, opts: opts, cancel: cancel})
+			}
+		}
+		if opts.streamClientBlockFallback != nil {
+			return opts.streamClientBlockFallback(ctx, req, blockErr)
+		}
+		return nil, blockErr
+	}
+	ctx, req, optArr, routing := grayRoute(c.Client, ctx, req, entry, optArr)
+	if routing {
+		// 动态路由
+		optArr = append(optArr, client.WithSelectOption(selector.WithStrategy(GenStrategyWithRouterRules(weight_router.GetActualRules()))))
+	}
+	return c.openStream(ctx, req, optArr, &streamGuard{resourceName: resourceName, opts: opts, entry: entry})
+}
+
+// openStream 建立流，失败时立即退出entry，成功时在流结束后退出
+func (c *clientWrapper) openStream(ctx context.Context, req client.Request, optArr []client.CallOption, guard *streamGuard) (client.Stream, error) {
+	stream, err := c.streamWithRetry(ctx, req, optArr, guard.resourceName)
+	if err != nil {
+		guard.finish(err)
+		return nil, err
+	}
+	cs := &clientStream{Stream: stream, guard: guard}
+	guard.watch(ctx)
+	return cs, nil
+}
+
+// streamWithRetry 存在重试规则时按照重试模板建立流，否则仅在灰度阻断时重试
+func (c *clientWrapper) streamWithRetry(ctx context.Context, req client.Request, optArr []client.CallOption, resourceName string) (client.Stream, error) {
+	var rules = rule.GetRulesOfResource(resourceName)
+	var resRetryTemplate = rule.GetRetryTemplateOfResource(resourceName)
+	if resRetryTemplate != nil && rules != nil {
+		result, err := resRetryTemplate.Execute(&streamRetryCallback{client: c.Client, ctx: ctx, optArr: optArr, req: req})
+		if err != nil {
+			return nil, err
+		}
+		stream, _ := result.(client.Stream)
+		if stream == nil {
+			return nil, errors.New("nil stream returned by retry template")
+		}
+		return stream, nil
+	}
+	stream, err := c.Client.Stream(ctx, req, optArr...)
+	for i := 0; i < DefaultRetryNum && isGrayBlocked(err); i++ {
+		stream, err = c.Client.Stream(ctx, req, optArr...)
+	}
+	return stream, err
+}
+
+func isGrayBlocked(err error) bool {
+	microErr, ok := err.(*microerror.Error)
+	return ok && microErr.Code == 500 && microErr.Detail == "error blocked by gray"
+}
+
+// streamRetryCallback 流式调用的重试回调，建立流失败时重试
+type streamRetryCallback struct {
+	client client.Client
+	ctx    context.Context
+	optArr []client.CallOption
+	req    client.Request
+}
+
+func (s *streamRetryCallback) DoWithRetry(content retry.RtyContext) interface{} {
+	if logging.InfoEnabled() && content.GetRetryCount() > 0 {
+		logging.Info("StreamDoWithRetry", "resource", s.req.Service()+"."+s.req.Endpoint(), "retry count", content.GetRetryCount(), "err", content.GetLastError())
+	}
+	stream, err := s.client.Stream(s.ctx, s.req, s.optArr...)
+	if err != nil {
+		panic(err)
+	}
+	return stream
+}
+
+// serverStreamHandler 服务端流式调用在handler返回时退出entry，handler返回的错误记为异常。
+// 被阻断时不调用handler直接返回阻断错误，mock时handler使用的流忽略handler发送的消息并依次发送mock消息
+func serverStreamHandler(opts *options, h server.HandlerFunc, ctx context.Context, req server.Request, stream server.Stream) error {
+	resourceName := req.Service() + "." + req.Endpoint()
+	if opts.streamServerResourceExtract != nil {
+		resourceName = opts.streamServerResourceExtract(stream)
+	}
+	metaDataMap, fromService := extractMetaData(ctx)
+	entry, blockErr := sea.Entry(
+		resourceName,
+		sea.WithResourceType(base.ResTypeMicro),
+		sea.WithTrafficType(base.Inbound),
+		sea.WithArgs(req.Body()),
+		sea.WithMetaData(metaDataMap),
+		sea.WithFromService(fromService))
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				addTrace(opts, ctx, req.Endpoint(), req.Body(), strVal, false)
+				return h(ctx, req, &mockServerStream{Stream: stream, msgs: parseMockMessages(strVal)})
+			}
+		case base.BlockTypeMockError:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				addTrace(opts, ctx, req.Endpoint(), req.Body(), strVal, true)
+				return errors.New(strVal)
+			}
+		}
+		if opts.streamServerBlockFallback != nil {
+			return h(ctx, req, opts.streamServerBlockFallback(stream, blockErr))
+		}
+		return blockErr
+	}
+	guard := &streamGuard{resourceName: resourceName, opts: opts, entry: entry}
+	err := h(ctx, req, &serverStream{Stream: stream, guard: guard})
+	guard.finish(err)
+	return err
+}
+
+// NewStreamWrapper returns a server stream wrapper.
+//
+// Deprecated: go-micro v4的服务端不会应用StreamWrapper，且流本身无法感知handler何时返回，
+// 服务端流式调用的保护由 NewHandlerWrapper 完成，该方法原样返回流
+func NewStreamWrapper(seaOpts ...Option) server.StreamWrapper {
+	return func(stream server.Stream) server.Stream {
+		return stream
+	}
+}
+
+// streamGuard 流的entry，保证只退出一次
+type streamGuard struct {
+	resourceName string
+	opts         *options
+	entry        *base.SeaEntry
+	cancel       context.CancelFunc
+
+	once sync.Once
+	done chan struct{}
+	mux  sync.Mutex
+	err  error
+}
+
+// watch ctx结束时退出entry，避免流未关闭导致entry泄漏
+func (g *streamGuard) watch(ctx context.Context) {
+	g.done = make(chan struct{})
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.finish(ctx.Err())
+		case <-g.done:
+		}
+	}()
+}
+
+// recordError 记录流的首个异常，io.EOF、ctx取消及服务端流正常结束不视为异常
+func (g *streamGuard) recordError(err error) {
+	if err == nil || err == io.EOF || err == context.Canceled || err.Error() == lastStreamResponse {
+		return
+	}
+	g.mux.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mux.Unlock()
+}
+
+func (g *streamGuard) finish(err error) {
+	g.recordError(err)
+	g.once.Do(func() {
+		if g.entry != nil {
+			g.mux.Lock()
+			if g.err != nil {
+				sea.TraceError(g.entry, g.err)
+			}
+			g.mux.Unlock()
+			g.entry.Exit()
+		}
+		if g.cancel != nil {
+			g.cancel()
+		}
+		if g.done != nil {
+			close(g.done)
+		}
+	})
+}
+
+// enterMessage 逐条消息流控
+func (g *streamGuard) enterMessage(msg interface{}, trafficType base.TrafficType) (*base.SeaEntry, *base.BlockError) {
+	if !g.opts.streamMessageFlowControl {
+		return nil, nil
+	}
+	return sea.Entry(
+		g.resourceName+StreamMessageResourceSuffix,
+		sea.WithResourceType(base.ResTypeMicro),
+		sea.WithTrafficType(trafficType),
+		sea.WithArgs(msg))
+}
+
+// clientStream 客户端流，Close或Recv出错(含io.EOF)时退出entry
+type clientStream struct {
+	client.Stream
+	guard *streamGuard
+}
+
+func (s *clientStream) Send(msg interface{}) error {
+	msgEntry, blockErr := s.guard.enterMessage(msg, base.Outbound)
+	if blockErr != nil {
+		return blockErr
+	}
+	if msgEntry != nil {
+		defer msgEntry.Exit()
+	}
+	err := s.Stream.Send(msg)
+	s.guard.recordError(err)
+	return err
+}
+
+func (s *clientStream) Recv(msg interface{}) error {
+	err := s.Stream.Recv(msg)
+	if err != nil {
+		s.guard.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) Close() error {
+	err := s.Stream.Close()
+	s.guard.finish(nil)
+	return err
+}
+
+// serverStream 服务端流，Close或handler返回时退出entry
+type serverStream struct {
+	server.Stream
+	guard *streamGuard
+}
+
+func (s *serverStream) Recv(msg interface{}) error {
+	msgEntry, blockErr := s.guard.enterMessage(msg, base.Inbound)
+	if blockErr != nil {
+		return blockErr
+	}
+	if msgEntry != nil {
+		defer msgEntry.Exit()
+	}
+	err := s.Stream.Recv(msg)
+	s.guard.recordError(err)
+	return err
+}
+
+func (s *serverStream) Send(msg interface{}) error {
+	err := s.Stream.Send(msg)
+	s.guard.recordError(err)
+	return err
+}
+
+func (s *serverStream) Close() error {
+	err := s.Stream.Close()
+	s.guard.finish(nil)
+	return err
+}
+
+// mockServerStream mock的服务端流，Recv返回io.EOF，首次Send时按照handler发送的消息类型依次发送mock消息
+type mockServerStream struct {
+	server.Stream
+	msgs []json.RawMessage
+	once sync.Once
+}
+
+func (s *mockServerStream) Recv(interface{}) error {
+	return io.EOF
+}
+
+func (s *mockServerStream) Send(msg interface{}) error {
+	var err error
+	s.once.Do(func() {
+		typ := reflect.TypeOf(msg)
+		if typ == nil {
+			err = errors.New("nil message")
+			return
+		}
+		for _, data := range s.msgs {
+			var mockMsg interface{}
+			if typ.Kind() == reflect.Ptr {
+				mockMsg = reflect.New(typ.Elem()).Interface()
+			} else {
+				mockMsg = reflect.New(typ).Interface()
+			}
+			if err = json.Unmarshal(data, mockMsg); err != nil {
+				return
+			}
+			if err = s.Stream.Send(mockMsg); err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+// mockClientStream mock的客户端流，依次返回mock消息后返回io.EOF
+type mockClientStream struct {
+	ctx  context.Context
+	req  client.Request
+	mux  sync.Mutex
+	msgs []json.RawMessage
+}
+
+func (s *mockClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mockClientStream) Request() client.Request {
+	return s.req
+}
+
+func (s *mockClientStream) Response() client.Response {
+	return mockResponse{}
+}
+
+func (s *mockClientStream) Send(interface{}) error {
+	return nil
+}
+
+func (s *mockClientStream) Recv(msg interface{}) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	data := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return json.Unmarshal(data, msg)
+}
+
+func (s *mockClientStream) Error() error {
+	return nil
+}
+
+func (s *mockClientStream) Close() error {
+	return nil
+}
+
+func (s *mockClientStream) CloseSend() error {
+	return nil
+}
+
+// mockResponse mock流的响应
+type mockResponse struct{}
+
+func (mockResponse) Codec() codec.Reader {
+	return nil
+}
+
+func (mockResponse) Header() map[string]string {
+	return map[string]string{}
+}
+
+func (mockResponse) Read() ([]byte, error) {
+	return nil, io.EOF
+}
+
+// parseMockMessages mock数据为JSON数组时每个元素为一条消息，否则整体作为一条消息
+func parseMockMessages(data string) []json.RawMessage {
+	var msgs []json.RawMessage
+	if strings.HasPrefix(strings.TrimSpace(data), "[") && json.Unmarshal([]byte(data), &msgs) == nil {
+		return msgs
+	}
+	return []json.RawMessage{json.RawMessage(data)}
+}
@@ -0,0 +1,430 @@
+package microv4_opentrace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/codec"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/server"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/gray"
+	"github.com/liuhailove/gmiter/core/mock"
+	"github.com/liuhailove/gmiter/core/stat"
+)
+
+type testMsg struct {
+	Name string `json:"name"`
+}
+
+// fakeClient 记录建立流的请求，返回的流依次返回msgs中的消息，之后返回recvErr，recvErr为nil时返回io.EOF
+type fakeClient struct {
+	client.Client
+	msgs    []string
+	recvErr error
+
+	mux     sync.Mutex
+	ctx     context.Context
+	req     client.Request
+	streams []*fakeClientStream
+}
+
+func (c *fakeClient) NewRequest(service, endpoint string, req interface{}, reqOpts ...client.RequestOption) client.Request {
+	return client.NewRequest(service, endpoint, req, reqOpts...)
+}
+
+func (c *fakeClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	s := &fakeClientStream{ctx: ctx, req: req, msgs: c.msgs, recvErr: c.recvErr}
+	c.ctx, c.req = ctx, req
+	c.streams = append(c.streams, s)
+	return s, nil
+}
+
+type fakeClientStream struct {
+	ctx     context.Context
+	req     client.Request
+	msgs    []string
+	recvErr error
+
+	mux  sync.Mutex
+	sent []interface{}
+}
+
+func (s *fakeClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeClientStream) Request() client.Request {
+	return s.req
+}
+
+func (s *fakeClientStream) Response() client.Response {
+	return mockResponse{}
+}
+
+func (s *fakeClientStream) Send(msg interface{}) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *fakeClientStream) Recv(msg interface{}) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(s.msgs) == 0 {
+		if s.recvErr != nil {
+			return s.recvErr
+		}
+		return io.EOF
+	}
+	data := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return json.Unmarshal([]byte(data), msg)
+}
+
+func (s *fakeClientStream) Error() error {
+	return nil
+}
+
+func (s *fakeClientStream) Close() error {
+	return nil
+}
+
+func (s *fakeClientStream) CloseSend() error {
+	return nil
+}
+
+type fakeServerRequest struct {
+	service  string
+	endpoint string
+}
+
+func (r *fakeServerRequest) Service() string           { return r.service }
+func (r *fakeServerRequest) Method() string            { return r.endpoint }
+func (r *fakeServerRequest) Endpoint() string          { return r.endpoint }
+func (r *fakeServerRequest) ContentType() string       { return "application/json" }
+func (r *fakeServerRequest) Header() map[string]string { return map[string]string{} }
+func (r *fakeServerRequest) Body() interface{}         { return nil }
+func (r *fakeServerRequest) Read() ([]byte, error)     { return nil, io.EOF }
+func (r *fakeServerRequest) Codec() codec.Reader       { return nil }
+func (r *fakeServerRequest) Stream() bool              { return true }
+
+// fakeServerStream 依次接收msgs中的消息，记录发送的消息
+type fakeServerStream struct {
+	ctx  context.Context
+	req  *fakeServerRequest
+	msgs []string
+	sent []interface{}
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) Request() server.Request {
+	return s.req
+}
+
+func (s *fakeServerStream) Send(msg interface{}) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *fakeServerStream) Recv(msg interface{}) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+	data := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return json.Unmarshal([]byte(data), msg)
+}
+
+func (s *fakeServerStream) Error() error {
+	return nil
+}
+
+func (s *fakeServerStream) Close() error {
+	return nil
+}
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+// serveStream 经NewHandlerWrapper调用服务端流式handler
+func serveStream(fs *fakeServerStream, handler func(stream server.Stream) error, opts ...Option) error {
+	return NewHandlerWrapper(opts...)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return handler(rsp.(server.Stream))
+	})(fs.ctx, fs.req, fs)
+}
+
+func newTestStreamClient(fc *fakeClient, opts ...Option) client.Client {
+	return NewClientWrapper(opts...)(fc)
+}
+
+func TestParseMockMessages(t *testing.T) {
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"name":"a"}`), json.RawMessage(`{"name":"b"}`)},
+		parseMockMessages(` [{"name":"a"}, {"name":"b"}]`))
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"name":"a"}`)}, parseMockMessages(`{"name":"a"}`))
+	// 不是合法的JSON数组时整体作为一条消息
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`[invalid`)}, parseMockMessages(`[invalid`))
+}
+
+func TestStream_Mock(t *testing.T) {
+	initSea()
+	_, err := mock.LoadRules([]*mock.Rule{{
+		Resource:           "svc.Greeter.MockStream",
+		ControlBehavior:    mock.Mock,
+		ThenReturnMockData: `[{"name":"a"},{"name":"b"}]`,
+		AdditionalItems:    []mock.AdditionalItem{{Key: "mock", Value: "true"}},
+	}})
+	assert.Nil(t, err)
+	defer mock.ClearRules()
+
+	// micro资源的mock规则按metadata匹配
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{"Mock": "true"})
+	fc := &fakeClient{}
+	c := newTestStreamClient(fc)
+	stream, err := c.Stream(ctx, c.NewRequest("svc", "Greeter.MockStream", nil, client.StreamingRequest()))
+	assert.Nil(t, err)
+	assert.Nil(t, stream.Send(&testMsg{Name: "ignored"}))
+	var names []string
+	for {
+		msg := &testMsg{}
+		if err = stream.Recv(msg); err != nil {
+			break
+		}
+		names = append(names, msg.Name)
+	}
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Nil(t, stream.Close())
+	// mock时不建立实际的流
+	assert.Empty(t, fc.streams)
+
+	t.Run("Server", func(t *testing.T) {
+		_, err := mock.LoadRules([]*mock.Rule{{
+			Resource:           "svc.Greeter.MockServerStream",
+			ControlBehavior:    mock.Mock,
+			ThenReturnMockData: `[{"name":"a"},{"name":"b"}]`,
+			AdditionalItems:    []mock.AdditionalItem{{Key: "mock", Value: "true"}},
+		}})
+		assert.Nil(t, err)
+		fs := &fakeServerStream{ctx: ctx, req: &fakeServerRequest{service: "svc", endpoint: "Greeter.MockServerStream"}}
+		err = serveStream(fs, func(stream server.Stream) error {
+			assert.Equal(t, io.EOF, stream.Recv(&testMsg{}))
+			assert.Nil(t, stream.Send(&testMsg{Name: "ignored"}))
+			assert.Nil(t, stream.Send(&testMsg{Name: "ignored"}))
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{&testMsg{Name: "a"}, &testMsg{Name: "b"}}, fs.sent)
+	})
+}
+
+func TestStream_Gray(t *testing.T) {
+	initSea()
+	_, err := gray.LoadRules([]*gray.Rule{{
+		Resource:       "svc.Greeter.GrayStream",
+		GrayTag:        "gray",
+		LinkPass:       true,
+		RouterStrategy: gray.WeightRouter,
+		GrayWeightList: []gray.GWeight{{TargetResource: "svc.Greeter.GrayStreamV2", Weight: 100}},
+	}})
+	assert.Nil(t, err)
+	defer gray.LoadRules(nil)
+
+	fc := &fakeClient{}
+	c := newTestStreamClient(fc)
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{"User": "u1"})
+	stream, err := c.Stream(ctx, c.NewRequest("svc", "Greeter.GrayStream", nil, client.StreamingRequest()))
+	assert.Nil(t, err)
+	defer stream.Close()
+
+	assert.Equal(t, "svc", fc.req.Service())
+	assert.Equal(t, "Greeter.GrayStreamV2", fc.req.Endpoint())
+	assert.True(t, fc.req.Stream())
+	md, ok := metadata.FromContext(fc.ctx)
+	assert.True(t, ok)
+	grayTag, _ := md.Get("grayTag")
+	assert.Equal(t, "gray", grayTag)
+}
+
+func TestStream_MessageFlowControl(t *testing.T) {
+	initSea()
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "svc.Greeter.MsgStream" + StreamMessageResourceSuffix,
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              2,
+		StatIntervalInMs:       60000,
+	}, {
+		Resource:               "svc.Greeter.MsgServerStream" + StreamMessageResourceSuffix,
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              1,
+		StatIntervalInMs:       60000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	t.Run("Client", func(t *testing.T) {
+		fc := &fakeClient{}
+		c := newTestStreamClient(fc, WithStreamMessageFlowControl())
+		stream, err := c.Stream(context.Background(), c.NewRequest("svc", "Greeter.MsgStream", nil, client.StreamingRequest()))
+		assert.Nil(t, err)
+		defer stream.Close()
+		assert.Nil(t, stream.Send(&testMsg{Name: "1"}))
+		assert.Nil(t, stream.Send(&testMsg{Name: "2"}))
+		blockErr, ok := stream.Send(&testMsg{Name: "3"}).(*base.BlockError)
+		assert.True(t, ok)
+		assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+		assert.Equal(t, 2, len(fc.streams[0].sent))
+		// 流本身不受逐条消息流控影响
+		assert.Equal(t, int32(1), stat.GetResourceNode("svc.Greeter.MsgStream").CurrentConcurrency())
+	})
+
+	t.Run("Server", func(t *testing.T) {
+		fs := &fakeServerStream{
+			ctx:  context.Background(),
+			req:  &fakeServerRequest{service: "svc", endpoint: "Greeter.MsgServerStream"},
+			msgs: []string{`{"name":"1"}`, `{"name":"2"}`},
+		}
+		err := serveStream(fs, func(stream server.Stream) error {
+			msg := &testMsg{}
+			assert.Nil(t, stream.Recv(msg))
+			assert.Equal(t, "1", msg.Name)
+			blockErr, ok := stream.Recv(msg).(*base.BlockError)
+			assert.True(t, ok)
+			assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+			return nil
+		}, WithStreamMessageFlowControl())
+		assert.Nil(t, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		fc := &fakeClient{}
+		c := newTestStreamClient(fc)
+		stream, err := c.Stream(context.Background(), c.NewRequest("svc", "Greeter.MsgStream", nil, client.StreamingRequest()))
+		assert.Nil(t, err)
+		defer stream.Close()
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, stream.Send(&testMsg{}))
+		}
+	})
+}
+
+// 流在Close、Recv出错及ctx结束时退出entry，多次触发只退出一次
+func TestStream_GuardExitOnce(t *testing.T) {
+	initSea()
+	open := func(t *testing.T, ctx context.Context, resource string, fc *fakeClient) client.Stream {
+		c := newTestStreamClient(fc)
+		stream, err := c.Stream(ctx, c.NewRequest("svc", resource, nil, client.StreamingRequest()))
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), stat.GetResourceNode("svc."+resource).CurrentConcurrency())
+		return stream
+	}
+	assertExited := func(t *testing.T, resource string, errCount int64) {
+		node := stat.GetResourceNode("svc." + resource)
+		assert.Equal(t, int32(0), node.CurrentConcurrency())
+		assert.Equal(t, int64(1), node.GetSum(base.MetricEventComplete))
+		assert.Equal(t, errCount, node.GetSum(base.MetricEventError))
+	}
+
+	t.Run("Close", func(t *testing.T) {
+		stream := open(t, context.Background(), "Greeter.CloseStream", &fakeClient{msgs: []string{`{"name":"a"}`}})
+		assert.Nil(t, stream.Recv(&testMsg{}))
+		assert.Nil(t, stream.Close())
+		assert.Nil(t, stream.Close())
+		assert.Equal(t, io.EOF, stream.Recv(&testMsg{}))
+		assertExited(t, "Greeter.CloseStream", 0)
+	})
+
+	t.Run("RecvEOF", func(t *testing.T) {
+		stream := open(t, context.Background(), "Greeter.EOFStream", &fakeClient{})
+		assert.Equal(t, io.EOF, stream.Recv(&testMsg{}))
+		assert.Nil(t, stream.Close())
+		assertExited(t, "Greeter.EOFStream", 0)
+	})
+
+	t.Run("RecvError", func(t *testing.T) {
+		stream := open(t, context.Background(), "Greeter.ErrorStream", &fakeClient{recvErr: errors.New("broken")})
+		assert.EqualError(t, stream.Recv(&testMsg{}), "broken")
+		assert.EqualError(t, stream.Recv(&testMsg{}), "broken")
+		assert.Nil(t, stream.Close())
+		assertExited(t, "Greeter.ErrorStream", 1)
+	})
+
+	t.Run("CtxCancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := open(t, ctx, "Greeter.CancelStream", &fakeClient{recvErr: errors.New("broken")})
+		cancel()
+		assert.Eventually(t, func() bool {
+			return stat.GetResourceNode("svc.Greeter.CancelStream").CurrentConcurrency() == 0
+		}, time.Second, 10*time.Millisecond)
+		// ctx结束后的Recv错误及Close不再重复退出
+		assert.EqualError(t, stream.Recv(&testMsg{}), "broken")
+		assert.Nil(t, stream.Close())
+		assertExited(t, "Greeter.CancelStream", 0)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := open(t, ctx, "Greeter.ConcurrentStream", &fakeClient{recvErr: errors.New("broken")})
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			cancel()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = stream.Recv(&testMsg{})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = stream.Close()
+		}()
+		wg.Wait()
+		node := stat.GetResourceNode("svc.Greeter.ConcurrentStream")
+		assert.Eventually(t, func() bool {
+			return node.CurrentConcurrency() == 0
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, int64(1), node.GetSum(base.MetricEventComplete))
+	})
+
+	// go-micro服务端流的ctx通常不会结束，handler也不会关闭流，entry在handler返回时退出
+	t.Run("Server", func(t *testing.T) {
+		serve := func(endpoint string, handlerErr error) error {
+			fs := &fakeServerStream{ctx: context.Background(), req: &fakeServerRequest{service: "svc", endpoint: endpoint}}
+			return serveStream(fs, func(stream server.Stream) error {
+				assert.Equal(t, io.EOF, stream.Recv(&testMsg{}))
+				assert.Nil(t, stream.Send(&testMsg{Name: "a"}))
+				assert.Equal(t, int32(1), stat.GetResourceNode("svc."+endpoint).CurrentConcurrency())
+				return handlerErr
+			})
+		}
+		assert.Nil(t, serve("Greeter.ServerStream", nil))
+		assertExited(t, "Greeter.ServerStream", 0)
+		// handler正常返回但客户端未结束发送
+		assert.EqualError(t, serve("Greeter.EOSServerStream", errors.New(lastStreamResponse)), lastStreamResponse)
+		assertExited(t, "Greeter.EOSServerStream", 0)
+		assert.EqualError(t, serve("Greeter.ErrorServerStream", errors.New("broken")), "broken")
+		assertExited(t, "Greeter.ErrorServerStream", 1)
+	})
+}
@@ -5,9 +5,23 @@ import (
 	"encoding/json"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/opentracing/opentracing-go"
+	"go-micro.dev/v4/client"
 	"go-micro.dev/v4/metadata"
 )
 
+// targetAddress 调用方通过client.WithAddress指定的目标地址，用于按照下游地址熔断，
+// 未指定时由selector在调用内部选择节点，返回空
+func targetAddress(optArr []client.CallOption) string {
+	var callOpts client.CallOptions
+	for _, o := range optArr {
+		o(&callOpts)
+	}
+	if len(callOpts.Address) == 0 {
+		return ""
+	}
+	return callOpts.Address[0]
+}
+
 // 增加链路追踪，主要是为了适配在Mock时依然上报到链路追踪
 func addTrace(opts *options, ctx context.Context, endPoint string, reqBody interface{}, rsp interface{}, withErr bool) {
 	if opts.tracer == nil {
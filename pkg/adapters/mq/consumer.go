@@ -0,0 +1,170 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+// Run 循环拉取消息并经Consume处理直到ctx结束，ctx结束或拉取、提交失败时返回错误。
+// 处理失败(含mock异常)的消息不提交，设置了 WithDeadLetter 时交给死信处理函数后提交并继续消费，否则返回处理失败的错误
+func Run(ctx context.Context, c Consumer, handler Handler, seaOpts ...Option) error {
+	opts := evaluateOptions(seaOpts)
+	for {
+		msg, err := c.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		err = opts.process(ctx, c, msg, handler)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Consume 在sea entry保护下处理一条消息，处理成功后提交。
+// 资源类型为base.ResTypeMQ，消息key作为第一个参数传入，消息头作为WithHeaders传入；
+// 被阻断时不丢弃消息，暂停后重试直到通过或ctx结束，flow匀速排队规则阻断时按规则的排队间隔暂停；
+// 处理失败时交给 WithDeadLetter 设置的死信处理函数，未设置时不提交并返回处理失败的错误
+func Consume(ctx context.Context, c Consumer, msg *Message, handler Handler, seaOpts ...Option) error {
+	return evaluateOptions(seaOpts).process(ctx, c, msg, handler)
+}
+
+// process 处理一条消息，处理失败时交给死信处理函数
+func (o *options) process(ctx context.Context, c Consumer, msg *Message, handler Handler) error {
+	err := o.consume(ctx, c, msg, handler)
+	hErr, ok := err.(*handleError)
+	if !ok {
+		return err
+	}
+	if o.deadLetter == nil {
+		return hErr.err
+	}
+	if err = o.deadLetter(ctx, msg, hErr.err); err != nil {
+		logging.Warn("[mq] Failed to hand message to the dead letter handler, the message is not committed", "topic", msg.Topic,
+			"group", c.Group(), "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		return err
+	}
+	deadLetterCounter.Add(1, msg.Topic, c.Group())
+	return c.Commit(ctx, msg)
+}
+
+// handleError 消息处理失败的错误，区别于拉取、提交等broker的错误
+type handleError struct {
+	err error
+}
+
+func (e *handleError) Error() string {
+	return e.err.Error()
+}
+
+func (o *options) consume(ctx context.Context, c Consumer, msg *Message, handler Handler) error {
+	group := c.Group()
+	if tag := msg.Headers[GrayTagHeader]; len(tag) > 0 {
+		ctx = WithGrayTag(ctx, tag)
+	}
+	if config.CloseAll() {
+		return o.handle(ctx, ctx, c, msg, group, nil, handler)
+	}
+	resource := o.consumerResourceName(msg, group)
+	for {
+		entry, blockErr := sea.Entry(
+			resource,
+			sea.WithResourceType(base.ResTypeMQ),
+			sea.WithTrafficType(base.Inbound),
+			sea.WithArgs(string(msg.Key)),
+			sea.WithHeaders(msg.headers()))
+		if blockErr == nil {
+			if entry.LinkPass() && len(entry.GrayTag()) > 0 {
+				ctx = WithGrayTag(ctx, entry.GrayTag())
+			}
+			return o.handle(ctx, ctx, c, msg, group, entry, handler)
+		}
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			// mock视为消费成功
+			consumedCounter.Add(1, msg.Topic, group, resultMock)
+			return c.Commit(ctx, msg)
+		case base.BlockTypeMockError:
+			consumedCounter.Add(1, msg.Topic, group, resultMock)
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				return &handleError{err: errors.New(strVal)}
+			}
+			return &handleError{err: blockErr}
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+				defer cancel()
+				return o.handle(newCtx, ctx, c, msg, group, nil, handler)
+			}
+		}
+		if o.consumerBlockFallback != nil && o.consumerBlockFallback(msg, blockErr) == nil {
+			consumedCounter.Add(1, msg.Topic, group, resultBlock)
+			return c.Commit(ctx, msg)
+		}
+		consumePausedCounter.Add(1, msg.Topic, group, blockErr.BlockType().String())
+		if err := pause(ctx, o.pauseDuration(blockErr)); err != nil {
+			return err
+		}
+	}
+}
+
+// handle 处理消息，成功后使用commitCtx提交，处理失败时返回handleError
+func (o *options) handle(ctx, commitCtx context.Context, c Consumer, msg *Message, group string, entry *base.SeaEntry, handler Handler) error {
+	if entry != nil {
+		defer entry.Exit()
+	}
+	if err := handler(ctx, msg); err != nil {
+		if entry != nil {
+			sea.TraceError(entry, err)
+		}
+		consumedCounter.Add(1, msg.Topic, group, resultError)
+		return &handleError{err: err}
+	}
+	consumedCounter.Add(1, msg.Topic, group, resultPass)
+	return c.Commit(commitCtx, msg)
+}
+
+// pauseDuration 阻断后暂停的时长，flow匀速排队规则取排队间隔与MaxQueueingTimeMs的较大值
+func (o *options) pauseDuration(blockErr *base.BlockError) time.Duration {
+	rule, ok := blockErr.TriggeredRule().(*flow.Rule)
+	if !ok || rule.ControlBehavior != flow.Throttling {
+		return o.blockPause
+	}
+	var pauseMs float64
+	if rule.Threshold > 0 {
+		statIntervalMs := rule.StatIntervalInMs
+		if statIntervalMs == 0 {
+			statIntervalMs = 1000
+		}
+		pauseMs = float64(statIntervalMs) / rule.Threshold
+	}
+	if float64(rule.MaxQueueingTimeMs) > pauseMs {
+		pauseMs = float64(rule.MaxQueueingTimeMs)
+	}
+	if pauseMs <= 0 {
+		return o.blockPause
+	}
+	return time.Duration(pauseMs * float64(time.Millisecond))
+}
+
+func pause(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,7 @@
+// Package kafka provides the github.com/segmentio/kafka-go implementation of gmiter mq adapter.
+//
+// 消费：mq.Run(ctx, kafka.NewConsumer(reader), handler)，reader需设置GroupID，消息处理成功后提交位点。
+// kafka按分区累计提交位点，处理失败的消息不能跳过后继续提交，默认Run返回处理失败的错误，
+// 通过mq.WithDeadLetter将失败的消息转发到死信队列后提交并继续消费；
+// 生产：kafka.NewGuardedProducer(writer).Send(ctx, msgs...)，消息头与kafka.Header一一对应，灰度标签通过mq.GrayTagHeader透传。
+package kafka
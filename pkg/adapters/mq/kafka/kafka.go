@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/liuhailove/gmiter/pkg/adapters/mq"
+)
+
+type (
+	consumer struct {
+		reader *kafka.Reader
+	}
+
+	producer struct {
+		writer *kafka.Writer
+	}
+)
+
+// NewConsumer returns a mq.Consumer fetching messages from r, 消费组为r的GroupID，
+// 配合mq.Run使用时消息处理成功后才提交位点，r需设置GroupID
+func NewConsumer(r *kafka.Reader) mq.Consumer {
+	return &consumer{reader: r}
+}
+
+func (c *consumer) Fetch(ctx context.Context) (*mq.Message, error) {
+	msg, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fromKafkaMessage(msg), nil
+}
+
+func (c *consumer) Commit(ctx context.Context, msg *mq.Message) error {
+	raw, ok := msg.Raw.(kafka.Message)
+	if !ok {
+		return errors.Errorf("message of topic %s is not fetched from kafka", msg.Topic)
+	}
+	return c.reader.CommitMessages(ctx, raw)
+}
+
+func (c *consumer) Group() string {
+	return c.reader.Config().GroupID
+}
+
+func (c *consumer) Close() error {
+	return c.reader.Close()
+}
+
+// NewProducer returns a mq.Producer writing messages by w, w设置了Topic时消息的Topic需为空或与w相同
+func NewProducer(w *kafka.Writer) mq.Producer {
+	return &producer{writer: w}
+}
+
+// NewGuardedProducer returns a mq.Producer writing messages by w and wrapped with sea entry,
+// w设置了Topic时资源名称默认为 mq:produce:w.Topic
+func NewGuardedProducer(w *kafka.Writer, seaOpts ...mq.Option) mq.Producer {
+	if len(w.Topic) > 0 {
+		seaOpts = append([]mq.Option{mq.WithProducerResourceExtractor(func(*mq.Message) string {
+			return "mq:produce:" + w.Topic
+		})}, seaOpts...)
+	}
+	return mq.WrapProducer(NewProducer(w), seaOpts...)
+}
+
+func (p *producer) Send(ctx context.Context, msgs ...*mq.Message) error {
+	kafkaMsgs := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		kafkaMsgs[i] = toKafkaMessage(msg)
+		if len(p.writer.Topic) > 0 {
+			// Writer设置了Topic时消息不允许再设置Topic
+			kafkaMsgs[i].Topic = ""
+		}
+	}
+	return p.writer.WriteMessages(ctx, kafkaMsgs...)
+}
+
+func (p *producer) Close() error {
+	return p.writer.Close()
+}
+
+func fromKafkaMessage(msg kafka.Message) *mq.Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return &mq.Message{
+		Topic:     msg.Topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Raw:       msg,
+	}
+}
+
+func toKafkaMessage(msg *mq.Message) kafka.Message {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return kafka.Message{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
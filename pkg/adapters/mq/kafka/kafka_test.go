@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/pkg/adapters/mq"
+)
+
+func TestMessageConvert(t *testing.T) {
+	raw := kafka.Message{
+		Topic:     "orders",
+		Partition: 1,
+		Offset:    10,
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []kafka.Header{{Key: mq.GrayTagHeader, Value: []byte("gray")}},
+	}
+	msg := fromKafkaMessage(raw)
+	assert.Equal(t, "orders", msg.Topic)
+	assert.Equal(t, 1, msg.Partition)
+	assert.Equal(t, int64(10), msg.Offset)
+	assert.Equal(t, "gray", msg.Headers[mq.GrayTagHeader])
+	assert.Equal(t, raw, msg.Raw)
+
+	converted := toKafkaMessage(msg)
+	assert.Equal(t, raw.Topic, converted.Topic)
+	assert.Equal(t, raw.Key, converted.Key)
+	assert.Equal(t, raw.Value, converted.Value)
+	assert.Equal(t, raw.Headers, converted.Headers)
+}
+
+func TestCommitForeignMessage(t *testing.T) {
+	c := NewConsumer(kafka.NewReader(kafka.ReaderConfig{Brokers: []string{"127.0.0.1:9092"}, GroupID: "g1", Topic: "orders"}))
+	defer c.Close()
+	assert.Equal(t, "g1", c.Group())
+	assert.NotNil(t, c.Commit(context.Background(), &mq.Message{Topic: "orders"}))
+}
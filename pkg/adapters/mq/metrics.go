@@ -0,0 +1,38 @@
+package mq
+
+import (
+	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
+)
+
+const (
+	resultPass  = "pass"
+	resultError = "error"
+	resultBlock = "block"
+	resultMock  = "mock"
+)
+
+var (
+	consumedCounter = metric_exporter.NewCounter(
+		"mq_consumed_total",
+		"Total consumed message count",
+		[]string{"topic", "group", "result"})
+	consumePausedCounter = metric_exporter.NewCounter(
+		"mq_consume_paused_total",
+		"Total consume pause count caused by block",
+		[]string{"topic", "group", "block_type"})
+	deadLetterCounter = metric_exporter.NewCounter(
+		"mq_dead_letter_total",
+		"Total failed message count handed to the dead letter handler",
+		[]string{"topic", "group"})
+	producedCounter = metric_exporter.NewCounter(
+		"mq_produced_total",
+		"Total produced message count",
+		[]string{"topic", "result"})
+)
+
+func init() {
+	metric_exporter.Register(consumedCounter)
+	metric_exporter.Register(consumePausedCounter)
+	metric_exporter.Register(deadLetterCounter)
+	metric_exporter.Register(producedCounter)
+}
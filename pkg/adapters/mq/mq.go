@@ -0,0 +1,73 @@
+package mq
+
+import (
+	"context"
+)
+
+const (
+	// GrayTagHeader 灰度链路透传时携带灰度标签的消息头
+	GrayTagHeader = "X-Gray-Tag"
+)
+
+type (
+	// Message broker无关的消息
+	Message struct {
+		Topic     string
+		Key       []byte
+		Value     []byte
+		Headers   map[string]string
+		Partition int
+		Offset    int64
+		// Raw broker的原始消息，用于Commit
+		Raw interface{}
+	}
+
+	// Consumer broker无关的消费者，由各broker的实现适配
+	Consumer interface {
+		// Fetch 拉取下一条消息，阻塞直到有消息或ctx结束
+		Fetch(ctx context.Context) (*Message, error)
+		// Commit 提交消息的消费位点
+		Commit(ctx context.Context, msg *Message) error
+		// Group 消费组
+		Group() string
+		Close() error
+	}
+
+	// Producer broker无关的生产者，由各broker的实现适配
+	Producer interface {
+		Send(ctx context.Context, msgs ...*Message) error
+		Close() error
+	}
+
+	// Handler 消息处理函数
+	Handler func(ctx context.Context, msg *Message) error
+
+	grayTagKey struct{}
+)
+
+// GrayTagFromContext 消费时消息头携带的灰度标签，不存在时为空
+func GrayTagFromContext(ctx context.Context) string {
+	tag, _ := ctx.Value(grayTagKey{}).(string)
+	return tag
+}
+
+// WithGrayTag 设置ctx的灰度标签，生产消息时写入GrayTagHeader消息头
+func WithGrayTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, grayTagKey{}, tag)
+}
+
+// headers 消息头转换为entry的headers，用于灰度及mock规则匹配
+func (m *Message) headers() map[string][]string {
+	result := make(map[string][]string, len(m.Headers))
+	for k, v := range m.Headers {
+		result[k] = []string{v}
+	}
+	return result
+}
+
+func (m *Message) setHeader(key, value string) {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[key] = value
+}
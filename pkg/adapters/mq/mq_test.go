@@ -0,0 +1,302 @@
+package mq
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/mock"
+)
+
+// memBroker 进程内的消息broker，消费者拉取完消息后返回io.EOF
+type memBroker struct {
+	mux       sync.Mutex
+	topics    map[string][]*Message
+	committed map[string]int64
+}
+
+func newMemBroker() *memBroker {
+	return &memBroker{topics: make(map[string][]*Message), committed: make(map[string]int64)}
+}
+
+func (b *memBroker) Send(ctx context.Context, msgs ...*Message) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for _, msg := range msgs {
+		msg.Offset = int64(len(b.topics[msg.Topic]))
+		b.topics[msg.Topic] = append(b.topics[msg.Topic], msg)
+	}
+	return nil
+}
+
+func (b *memBroker) Close() error {
+	return nil
+}
+
+func (b *memBroker) consumer(topic, group string) *memConsumer {
+	return &memConsumer{broker: b, topic: topic, group: group}
+}
+
+func (b *memBroker) committedOffset(topic, group string) int64 {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if offset, ok := b.committed[topic+"|"+group]; ok {
+		return offset
+	}
+	return -1
+}
+
+type memConsumer struct {
+	broker *memBroker
+	topic  string
+	group  string
+	pos    int
+}
+
+func (c *memConsumer) Fetch(ctx context.Context) (*Message, error) {
+	c.broker.mux.Lock()
+	defer c.broker.mux.Unlock()
+	msgs := c.broker.topics[c.topic]
+	if c.pos >= len(msgs) {
+		return nil, io.EOF
+	}
+	c.pos++
+	return msgs[c.pos-1], nil
+}
+
+func (c *memConsumer) Commit(ctx context.Context, msg *Message) error {
+	c.broker.mux.Lock()
+	defer c.broker.mux.Unlock()
+	c.broker.committed[msg.Topic+"|"+c.group] = msg.Offset
+	return nil
+}
+
+func (c *memConsumer) Group() string {
+	return c.group
+}
+
+func (c *memConsumer) Close() error {
+	return nil
+}
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+func produce(t *testing.T, p Producer, ctx context.Context, topic string, values ...string) {
+	msgs := make([]*Message, len(values))
+	for i, v := range values {
+		msgs[i] = &Message{Topic: topic, Key: []byte(v), Value: []byte(v)}
+	}
+	assert.Nil(t, p.Send(ctx, msgs...))
+}
+
+func TestRun(t *testing.T) {
+	initSea()
+	broker := newMemBroker()
+	producer := WrapProducer(broker)
+	produce(t, producer, WithGrayTag(context.Background(), "gray"), "orders", "a", "b", "c")
+
+	var values, tags []string
+	err := Run(context.Background(), broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+		values = append(values, string(msg.Value))
+		tags = append(tags, GrayTagFromContext(ctx))
+		return nil
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+	assert.Equal(t, []string{"gray", "gray", "gray"}, tags)
+	assert.Equal(t, int64(2), broker.committedOffset("orders", "g1"))
+
+	var handled []string
+	failOnB := func(ctx context.Context, msg *Message) error {
+		handled = append(handled, string(msg.Value))
+		if string(msg.Value) == "b" {
+			return errors.New("handle failed")
+		}
+		return nil
+	}
+
+	t.Run("HandlerError", func(t *testing.T) {
+		handled = nil
+		// 处理失败的消息不提交，Run返回错误，避免提交后续消息时一并提交失败的消息
+		err := Run(context.Background(), broker.consumer("orders", "g2"), failOnB)
+		assert.EqualError(t, err, "handle failed")
+		assert.Equal(t, []string{"a", "b"}, handled)
+		assert.Equal(t, int64(0), broker.committedOffset("orders", "g2"))
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		handled = nil
+		var deadLetters []string
+		err := Run(context.Background(), broker.consumer("orders", "g4"), failOnB,
+			WithDeadLetter(func(ctx context.Context, msg *Message, err error) error {
+				assert.EqualError(t, err, "handle failed")
+				deadLetters = append(deadLetters, string(msg.Value))
+				return nil
+			}))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, []string{"a", "b", "c"}, handled)
+		assert.Equal(t, []string{"b"}, deadLetters)
+		assert.Equal(t, int64(2), broker.committedOffset("orders", "g4"))
+	})
+
+	t.Run("DeadLetterError", func(t *testing.T) {
+		handled = nil
+		err := Run(context.Background(), broker.consumer("orders", "g5"), failOnB,
+			WithDeadLetter(func(ctx context.Context, msg *Message, err error) error {
+				return errors.New("dead letter failed")
+			}))
+		assert.EqualError(t, err, "dead letter failed")
+		assert.Equal(t, []string{"a", "b"}, handled)
+		assert.Equal(t, int64(0), broker.committedOffset("orders", "g5"))
+	})
+
+	t.Run("ConsumeHandlerError", func(t *testing.T) {
+		c := broker.consumer("orders", "g3")
+		msg, err := c.Fetch(context.Background())
+		assert.Nil(t, err)
+		err = Consume(context.Background(), c, msg, func(ctx context.Context, msg *Message) error {
+			return errors.New("handle failed")
+		})
+		assert.EqualError(t, err, "handle failed")
+		assert.Equal(t, int64(-1), broker.committedOffset("orders", "g3"))
+	})
+}
+
+func TestBlockPause(t *testing.T) {
+	initSea()
+	broker := newMemBroker()
+	produce(t, broker, context.Background(), "orders", "a", "b", "c")
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "mq:consume:orders:g1",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              2,
+		StatIntervalInMs:       1000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	var values []string
+	start := time.Now()
+	err = Run(context.Background(), broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+		values = append(values, string(msg.Value))
+		return nil
+	}, WithBlockPause(100*time.Millisecond))
+	assert.Equal(t, io.EOF, err)
+	// 阻断时暂停后重试，消息不丢弃
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.Equal(t, int64(2), broker.committedOffset("orders", "g1"))
+
+	t.Run("ContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := Run(ctx, broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+			return nil
+		}, WithBlockPause(time.Second))
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+func TestConsumeMock(t *testing.T) {
+	initSea()
+	broker := newMemBroker()
+	produce(t, broker, context.Background(), "orders", "a")
+	_, err := mock.LoadRules([]*mock.Rule{{
+		Resource:           "mq:consume:orders:g1",
+		ControlBehavior:    mock.Mock,
+		ThenReturnMockData: "ok",
+	}})
+	assert.Nil(t, err)
+	defer mock.ClearRules()
+
+	called := false
+	err = Run(context.Background(), broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.False(t, called)
+	assert.Equal(t, int64(0), broker.committedOffset("orders", "g1"))
+}
+
+func TestConsumeMockError(t *testing.T) {
+	initSea()
+	broker := newMemBroker()
+	produce(t, broker, context.Background(), "orders", "a", "b")
+	_, err := mock.LoadRules([]*mock.Rule{{
+		Resource:        "mq:consume:orders:g1",
+		ControlBehavior: mock.Panic,
+		ThenThrowMsg:    "mock error",
+	}})
+	assert.Nil(t, err)
+	defer mock.ClearRules()
+
+	calls := 0
+	err = Run(context.Background(), broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+		calls++
+		return nil
+	})
+	assert.EqualError(t, err, "mock error")
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, int64(-1), broker.committedOffset("orders", "g1"))
+
+	// mock异常同样交给死信处理函数
+	var deadLetters []string
+	err = Run(context.Background(), broker.consumer("orders", "g1"), func(ctx context.Context, msg *Message) error {
+		calls++
+		return nil
+	}, WithDeadLetter(func(ctx context.Context, msg *Message, err error) error {
+		deadLetters = append(deadLetters, string(msg.Value))
+		return nil
+	}))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, []string{"a", "b"}, deadLetters)
+	assert.Equal(t, int64(1), broker.committedOffset("orders", "g1"))
+}
+
+func TestProducerBlock(t *testing.T) {
+	initSea()
+	broker := newMemBroker()
+	producer := WrapProducer(broker)
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "mq:produce:orders",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              2,
+		StatIntervalInMs:       1000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	err = producer.Send(context.Background(), &Message{Topic: "orders"}, &Message{Topic: "orders"}, &Message{Topic: "orders"})
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+	assert.Equal(t, 0, len(broker.topics["orders"]))
+	produce(t, producer, context.Background(), "orders", "a", "b")
+	assert.Equal(t, 2, len(broker.topics["orders"]))
+}
+
+func TestPauseDuration(t *testing.T) {
+	opts := evaluateOptions(nil)
+	throttling := &flow.Rule{ControlBehavior: flow.Throttling, Threshold: 20, StatIntervalInMs: 1000}
+	assert.Equal(t, 50*time.Millisecond, opts.pauseDuration(base.NewBlockError(base.WithBlockType(base.BlockTypeFlow), base.WithRule(throttling))))
+	throttling.MaxQueueingTimeMs = 200
+	assert.Equal(t, 200*time.Millisecond, opts.pauseDuration(base.NewBlockError(base.WithBlockType(base.BlockTypeFlow), base.WithRule(throttling))))
+	reject := &flow.Rule{ControlBehavior: flow.Reject, Threshold: 20}
+	assert.Equal(t, DefaultBlockPause, opts.pauseDuration(base.NewBlockError(base.WithBlockType(base.BlockTypeFlow), base.WithRule(reject))))
+}
@@ -0,0 +1,90 @@
+package mq
+
+import (
+	"context"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	// DefaultBlockPause 消费被阻断时暂停的默认时长
+	DefaultBlockPause = time.Second
+)
+
+type (
+	Option func(*options)
+
+	options struct {
+		consumerResourceExtract func(*Message, string) string
+		producerResourceExtract func(*Message) string
+		consumerBlockFallback   func(*Message, *base.BlockError) error
+		deadLetter              func(context.Context, *Message, error) error
+		blockPause              time.Duration
+	}
+)
+
+// WithConsumerResourceExtractor sets the resource extractor of consumed message.
+// The string parameter is the consumer group, 默认资源名称为 mq:consume:topic:group
+func WithConsumerResourceExtractor(fn func(*Message, string) string) Option {
+	return func(opts *options) {
+		opts.consumerResourceExtract = fn
+	}
+}
+
+// WithProducerResourceExtractor sets the resource extractor of produced message.
+// 默认资源名称为 mq:produce:topic
+func WithProducerResourceExtractor(fn func(*Message) string) Option {
+	return func(opts *options) {
+		opts.producerResourceExtract = fn
+	}
+}
+
+// WithConsumerBlockFallback sets the block fallback handler of consumed message.
+// 返回nil时消息视为已处理并提交，如转发到延迟队列；返回错误时暂停后重试
+func WithConsumerBlockFallback(fn func(*Message, *base.BlockError) error) Option {
+	return func(opts *options) {
+		opts.consumerBlockFallback = fn
+	}
+}
+
+// WithDeadLetter 设置处理失败(含mock异常)的消息的死信处理函数，如转发到死信队列，参数为处理失败的错误。
+// 返回nil时提交消息并继续消费后续消息，返回错误时消息不提交，Run返回该错误。
+// 未设置时处理失败的消息不提交，Run直接返回处理失败的错误，由调用方决定重试或退出：
+// kafka等按分区累计提交位点的broker，跳过失败的消息继续消费会在提交后续消息时一并提交失败的消息
+func WithDeadLetter(fn func(context.Context, *Message, error) error) Option {
+	return func(opts *options) {
+		opts.deadLetter = fn
+	}
+}
+
+// WithBlockPause 设置消费被阻断时暂停的时长，flow匀速排队规则阻断时优先使用规则的MaxQueueingTimeMs
+func WithBlockPause(pause time.Duration) Option {
+	return func(opts *options) {
+		opts.blockPause = pause
+	}
+}
+
+func evaluateOptions(opts []Option) *options {
+	optCopy := &options{
+		blockPause: DefaultBlockPause,
+	}
+	for _, o := range opts {
+		o(optCopy)
+	}
+	return optCopy
+}
+
+func (o *options) consumerResourceName(msg *Message, group string) string {
+	if o.consumerResourceExtract != nil {
+		return o.consumerResourceExtract(msg, group)
+	}
+	return "mq:consume:" + msg.Topic + ":" + group
+}
+
+func (o *options) producerResourceName(msg *Message) string {
+	if o.producerResourceExtract != nil {
+		return o.producerResourceExtract(msg)
+	}
+	return "mq:produce:" + msg.Topic
+}
@@ -0,0 +1,130 @@
+package mq
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+type (
+	producer struct {
+		next Producer
+		opts *options
+	}
+
+	// msgGroup 资源名称相同的消息，作为一个批量entry
+	msgGroup struct {
+		resource string
+		msgs     []*Message
+		entry    *base.SeaEntry
+	}
+)
+
+// WrapProducer returns a Producer wrapping p with sea entry, 资源类型为base.ResTypeMQ，
+// 同一次Send中资源名称相同的消息合并为一个批量entry，ctx携带的灰度标签写入GrayTagHeader消息头
+func WrapProducer(p Producer, seaOpts ...Option) Producer {
+	return &producer{next: p, opts: evaluateOptions(seaOpts)}
+}
+
+func (p *producer) Send(ctx context.Context, msgs ...*Message) error {
+	if tag := GrayTagFromContext(ctx); len(tag) > 0 {
+		for _, msg := range msgs {
+			if _, ok := msg.Headers[GrayTagHeader]; !ok {
+				msg.setHeader(GrayTagHeader, tag)
+			}
+		}
+	}
+	if config.CloseAll() {
+		return p.next.Send(ctx, msgs...)
+	}
+	var (
+		groups []*msgGroup
+		toSend []*Message
+	)
+	exit := func(err error) {
+		for _, g := range groups {
+			if err != nil {
+				sea.TraceError(g.entry, err)
+			}
+			g.entry.Exit()
+		}
+	}
+	for _, g := range p.groupMessages(msgs) {
+		entry, blockErr := sea.Entry(
+			g.resource,
+			sea.WithResourceType(base.ResTypeMQ),
+			sea.WithTrafficType(base.Outbound),
+			sea.WithBatchCount(uint32(len(g.msgs))),
+			sea.WithHeaders(g.msgs[0].headers()))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMock:
+				// mock视为发送成功
+				addProduced(g.msgs, resultMock)
+				continue
+			case base.BlockTypeMockError:
+				exit(nil)
+				addProduced(g.msgs, resultMock)
+				if strVal, ok := blockErr.TriggeredValue().(string); ok {
+					return errors.New(strVal)
+				}
+				return blockErr
+			}
+			exit(nil)
+			addProduced(g.msgs, resultBlock)
+			return blockErr
+		}
+		if entry.LinkPass() && len(entry.GrayTag()) > 0 {
+			for _, msg := range g.msgs {
+				msg.setHeader(GrayTagHeader, entry.GrayTag())
+			}
+		}
+		g.entry = entry
+		groups = append(groups, g)
+		toSend = append(toSend, g.msgs...)
+	}
+	if len(toSend) == 0 {
+		return nil
+	}
+	err := p.next.Send(ctx, toSend...)
+	exit(err)
+	if err != nil {
+		addProduced(toSend, resultError)
+	} else {
+		addProduced(toSend, resultPass)
+	}
+	return err
+}
+
+func (p *producer) Close() error {
+	return p.next.Close()
+}
+
+// groupMessages 按资源名称分组，保持消息的先后顺序
+func (p *producer) groupMessages(msgs []*Message) []*msgGroup {
+	var (
+		groups []*msgGroup
+		index  = make(map[string]*msgGroup)
+	)
+	for _, msg := range msgs {
+		resource := p.opts.producerResourceName(msg)
+		g, ok := index[resource]
+		if !ok {
+			g = &msgGroup{resource: resource}
+			index[resource] = g
+			groups = append(groups, g)
+		}
+		g.msgs = append(g.msgs, msg)
+	}
+	return groups
+}
+
+func addProduced(msgs []*Message, result string) {
+	for _, msg := range msgs {
+		producedCounter.Add(1, msg.Topic, result)
+	}
+}
@@ -0,0 +1,202 @@
+package nethttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+type roundTripper struct {
+	next http.RoundTripper
+	opts *options
+}
+
+// NewRoundTripper returns a http.RoundTripper wrapping next with sea entry, next为nil时使用http.DefaultTransport
+func NewRoundTripper(next http.RoundTripper, seaOpts ...Option) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{next: next, opts: evaluateOptions(seaOpts)}
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if config.CloseAll() {
+		return t.next.RoundTrip(req)
+	}
+	opts := t.opts
+	name := clientResourceName(opts, req)
+	// 不修改调用方的请求
+	req = req.Clone(req.Context())
+	body := extractBody(req.Header, &req.Body, opts.maxBodyBytes)
+	entry, blockErr := sea.Entry(
+		name,
+		sea.WithResourceType(base.ResTypeWeb),
+		sea.WithTrafficType(base.Outbound),
+		sea.WithParentContext(req.Context()),
+		sea.WithTargetAddress(req.URL.Host),
+		sea.WithArgs(extractArgs(opts, req)...),
+		sea.WithHeaders(extractHeaders(req.Header)),
+		sea.WithCookies(extractCookies(req.Cookies())),
+		sea.WithBody(body))
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMockError:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				return nil, errors.New(strVal)
+			}
+			return nil, blockErr
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				ctx, cancel := context.WithTimeout(req.Context(), time.Duration(ctxTimeout)*time.Millisecond)
+				rsp, err := t.next.RoundTrip(req.WithContext(ctx))
+				if err != nil {
+					cancel()
+					return nil, err
+				}
+				rsp.Body = &cancelReadCloser{ReadCloser: rsp.Body, cancel: cancel}
+				return rsp, nil
+			}
+		case base.BlockTypeMockRequest:
+			if data, ok := mockRequestBody(blockErr.TriggeredValue()); ok {
+				req.Body = ioutil.NopCloser(bytes.NewReader(data))
+				req.ContentLength = int64(len(data))
+				req.GetBody = nil
+				return t.next.RoundTrip(req)
+			}
+		}
+		if opts.clientBlockFallback != nil {
+			return opts.clientBlockFallback(req, blockErr)
+		}
+		status, header, data := blockResponse(blockErr)
+		return &http.Response{
+			Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Request:       req,
+		}, nil
+	}
+	defer entry.Exit()
+	if entry.LinkPass() {
+		req.Header.Set(GrayTagHeader, entry.GrayTag())
+	}
+	var addresses []string
+	if entry.GrayResource() != nil && !strings.Contains(entry.GrayResource().Name(), "*") {
+		rewriteGrayResource(req, entry.GrayResource().Name())
+	}
+	if len(entry.GrayAddress()) > 0 {
+		// 重排灰度地址，避免流量集中在第一个地址上
+		addresses = randomSort(entry.GrayAddress())
+	}
+	rsp, err := t.roundTripGray(req, addresses)
+	if err != nil {
+		sea.TraceError(entry, err)
+		return nil, err
+	}
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		sea.TraceError(entry, errors.Errorf("http status %d", rsp.StatusCode))
+	}
+	return rsp, nil
+}
+
+// roundTripGray 依次请求灰度地址，地址连接失败且请求体可重放时尝试下一个地址
+func (t *roundTripper) roundTripGray(req *http.Request, addresses []string) (*http.Response, error) {
+	if len(addresses) == 0 {
+		return t.next.RoundTrip(req)
+	}
+	var lastErr error
+	for i, address := range addresses {
+		r := req
+		if i > 0 {
+			if req.Body != nil && req.Body != http.NoBody {
+				if req.GetBody == nil {
+					break
+				}
+				newBody, err := req.GetBody()
+				if err != nil {
+					break
+				}
+				r = req.Clone(req.Context())
+				r.Body = newBody
+			} else {
+				r = req.Clone(req.Context())
+			}
+		}
+		// 仅替换连接地址，保留原Host头
+		if r.Host == "" {
+			r.Host = r.URL.Host
+		}
+		r.URL.Host = address
+		rsp, err := t.next.RoundTrip(r)
+		if err == nil {
+			return rsp, nil
+		}
+		lastErr = err
+		if req.Context().Err() != nil {
+			break
+		}
+		logging.Warn("[nethttp] Fail to request gray address", "address", address, "err", err)
+	}
+	return nil, lastErr
+}
+
+func clientResourceName(opts *options, req *http.Request) string {
+	if opts.clientResourceExtract != nil {
+		return opts.clientResourceExtract(req)
+	}
+	// 出站请求没有路由模式，默认不使用请求路径，避免路径参数导致资源数量膨胀
+	return resourceName(req.Method, req.URL.Host)
+}
+
+// rewriteGrayResource 按灰度资源 method:host/path 改写请求的host及path
+func rewriteGrayResource(req *http.Request, grayRes string) {
+	_, route := splitResourceName(grayRes)
+	idx := strings.Index(route, "/")
+	if idx < 0 {
+		req.URL.Host = route
+	} else {
+		if idx > 0 {
+			req.URL.Host = route[:idx]
+		}
+		req.URL.Path = route[idx:]
+		req.URL.RawPath = ""
+	}
+	req.Host = ""
+}
+
+func randomSort(addresses []string) []string {
+	result := make([]string, len(addresses))
+	copy(result, addresses)
+	rand.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+// cancelReadCloser 响应体关闭时取消mock设置的超时ctx
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,159 @@
+package nethttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRoundTripper(t *testing.T) {
+	initSea()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	// 测试中的路径固定，按照host+path区分资源
+	client := &http.Client{Transport: NewRoundTripper(nil, WithClientResourceExtractor(func(r *http.Request) string {
+		return resourceName(r.Method, r.URL.Host+r.URL.Path)
+	}))}
+
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "GET:" + host + "/limited",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              0,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+	_, err = mock.LoadRules([]*mock.Rule{
+		{
+			Resource:           "GET:" + host + "/mock",
+			ControlBehavior:    mock.Mock,
+			AdditionalItems:    []mock.AdditionalItem{{Key: "x-mock", Value: "1"}},
+			ThenReturnMockData: `{"code":1}`,
+		},
+		{
+			Resource:        "GET:" + host + "/error",
+			ControlBehavior: mock.Panic,
+			AdditionalItems: []mock.AdditionalItem{{Key: "x-mock", Value: "1"}},
+			ThenThrowMsg:    "mock error",
+		},
+	})
+	assert.Nil(t, err)
+	defer mock.ClearRules()
+
+	t.Run("FlowBlock", func(t *testing.T) {
+		rsp, err := client.Get(server.URL + "/limited")
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, rsp.StatusCode)
+		assert.Equal(t, "1", rsp.Header.Get("Retry-After"))
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/mock", nil)
+		req.Header.Set("X-Mock", "1")
+		rsp, err := client.Do(req)
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		data, _ := ioutil.ReadAll(rsp.Body)
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, `{"code":1}`, string(data))
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("MockError", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/error", nil)
+		req.Header.Set("X-Mock", "1")
+		_, err := client.Do(req)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "mock error")
+		}
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("Pass", func(t *testing.T) {
+		rsp, err := client.Get(server.URL + "/pass")
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, 1, requests)
+	})
+}
+
+// 按照请求的host熔断，一个下游地址熔断不影响其他地址
+func TestRoundTripper_TargetAddress(t *testing.T) {
+	initSea()
+	var failedRequests, okRequests int
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failedRequests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failed.Close()
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		okRequests++
+	}))
+	defer ok.Close()
+	client := &http.Client{Transport: NewRoundTripper(nil, WithClientResourceExtractor(func(r *http.Request) string {
+		return "keyed-http"
+	}))}
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         "keyed-http",
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        1,
+		KeyMode:          circuitbreaker.KeyByTargetAddress,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	get := func(url string) int {
+		rsp, err := client.Get(url)
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	assert.Equal(t, http.StatusInternalServerError, get(failed.URL))
+	assert.NotEqual(t, http.StatusInternalServerError, get(failed.URL))
+	assert.Equal(t, 1, failedRequests)
+	assert.Equal(t, http.StatusOK, get(ok.URL))
+	assert.Equal(t, 1, okRequests)
+	assert.Equal(t, []string{strings.TrimPrefix(failed.URL, "http://")}, openKeys("keyed-http"))
+}
+
+func openKeys(resource string) []string {
+	var keys []string
+	for _, state := range circuitbreaker.GetOpenKeys(resource) {
+		keys = append(keys, state.Key)
+	}
+	return keys
+}
+
+func TestClientResourceName(t *testing.T) {
+	// 默认不使用请求路径
+	req := httptest.NewRequest(http.MethodPost, "http://a.com/users/123", nil)
+	assert.Equal(t, "POST:a.com", clientResourceName(evaluateOptions(nil), req))
+}
+
+func TestRewriteGrayResource(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://a.com/v1/users?id=1", nil)
+	rewriteGrayResource(req, "GET:b.com/v2/users")
+	assert.Equal(t, "http://b.com/v2/users?id=1", req.URL.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "http://a.com/v1/users", nil)
+	rewriteGrayResource(req, "GET:c.com:8080")
+	assert.Equal(t, "http://c.com:8080/v1/users", req.URL.String())
+}
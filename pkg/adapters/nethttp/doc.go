@@ -0,0 +1,15 @@
+// Package nethttp provides the net/http server middleware and client RoundTripper adapter of gmiter.
+//
+// 服务端使用NewHandler包装http.Handler，客户端使用NewRoundTripper包装http.RoundTripper，
+// 资源名称为 method:route，服务端route为WithServeMux(或被包装的ServeMux)匹配到的路由模式，
+// 或Go 1.22 ServeMux写入的Request.Pattern，无法获取时为UnmatchedRoute；
+// 客户端route默认为host，按照路由区分资源时使用WithClientResourceExtractor。请求路径不直接作为route，
+// 避免路径参数导致资源、统计节点及监控指标数量膨胀。
+//
+// 网关模式使用NewReverseProxy包装httputil.ReverseProxy，以路由ID(WithRouteExtractor)及gateway.ApiDefinition
+// 匹配到的API分组名称为资源名称，网关规则的参数从客户端IP、Host、Header、URL参数及Cookie中解析。
+//
+// 阻断处理：mock返回200及mock数据，mock异常服务端返回500、客户端返回error，
+// 流控及热点阻断返回429并携带Retry-After，授权阻断返回403，其他阻断返回503。
+// 客户端命中灰度时按灰度资源改写host及path，或将请求发往灰度地址。
+package nethttp
@@ -0,0 +1,148 @@
+package nethttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/gateway"
+)
+
+// proxyErrKey 保存反向代理转发失败的错误
+type proxyErrKey struct{}
+
+// NewReverseProxy returns a http.Handler serving requests by proxy with sea entry in API gateway mode.
+// 依次以路由ID及请求路径匹配到的API分组名称为资源名称进入sea，资源类型为base.ResTypeAPIGateway，
+// 任一资源被阻断时返回阻断响应，转发失败或响应5xx时记录为资源异常。proxy本身不会被修改
+func NewReverseProxy(proxy *httputil.ReverseProxy, seaOpts ...Option) http.Handler {
+	opts := evaluateOptions(seaOpts)
+	p := *proxy
+	errorHandler := proxy.ErrorHandler
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if holder, ok := r.Context().Value(proxyErrKey{}).(*error); ok {
+			*holder = err
+		}
+		if errorHandler != nil {
+			errorHandler(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.CloseAll() {
+			p.ServeHTTP(w, r)
+			return
+		}
+		var (
+			parser  = &requestItemParser{r: r}
+			headers = extractHeaders(r.Header)
+			cookies = extractCookies(r.Cookies())
+			entries = make([]*base.SeaEntry, 0, 2)
+			ctx     = r.Context()
+		)
+		defer func() {
+			for i := len(entries) - 1; i >= 0; i-- {
+				entries[i].Exit()
+			}
+		}()
+		for _, resource := range gatewayResources(opts, r) {
+			entry, blockErr := sea.Entry(
+				resource,
+				sea.WithResourceType(base.ResTypeAPIGateway),
+				sea.WithTrafficType(base.Inbound),
+				sea.WithArgs(extractArgs(opts, r)...),
+				sea.WithHeaders(headers),
+				sea.WithCookies(cookies),
+				sea.WithAttachments(gateway.ParseParams(resource, parser)))
+			if blockErr == nil {
+				entries = append(entries, entry)
+				continue
+			}
+			if blockErr.BlockType() == base.BlockTypeMockCtxTimeout {
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+					defer cancel()
+					continue
+				}
+			}
+			if opts.serverBlockFallback != nil {
+				opts.serverBlockFallback(w, r, blockErr)
+				return
+			}
+			status, header, data := blockResponse(blockErr)
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(data)
+			return
+		}
+		var proxyErr error
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		p.ServeHTTP(sw, r.WithContext(context.WithValue(ctx, proxyErrKey{}, &proxyErr)))
+		if proxyErr == nil && sw.status >= http.StatusInternalServerError {
+			proxyErr = errors.Errorf("http status %d", sw.status)
+		}
+		if proxyErr != nil {
+			for _, entry := range entries {
+				sea.TraceError(entry, proxyErr)
+			}
+		}
+	})
+}
+
+// gatewayResources 路由ID及匹配到的API分组名称
+func gatewayResources(opts *options, r *http.Request) []string {
+	var resources []string
+	if opts.routeExtract != nil {
+		if route := opts.routeExtract(r); len(route) > 0 {
+			resources = append(resources, route)
+		}
+	}
+	return append(resources, gateway.MatchApis(r.URL.Path)...)
+}
+
+// requestItemParser 实现gateway.RequestItemParser
+type requestItemParser struct {
+	r *http.Request
+}
+
+func (p *requestItemParser) Path() string {
+	return p.r.URL.Path
+}
+
+func (p *requestItemParser) RemoteAddress() string {
+	host, _, err := net.SplitHostPort(p.r.RemoteAddr)
+	if err != nil {
+		return p.r.RemoteAddr
+	}
+	return host
+}
+
+func (p *requestItemParser) Host() string {
+	return p.r.Host
+}
+
+func (p *requestItemParser) Header(key string) string {
+	return p.r.Header.Get(key)
+}
+
+func (p *requestItemParser) URLParam(key string) string {
+	return p.r.URL.Query().Get(key)
+}
+
+func (p *requestItemParser) Cookie(key string) string {
+	c, err := p.r.Cookie(key)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
@@ -0,0 +1,86 @@
+package nethttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/gateway"
+)
+
+func TestNewReverseProxy(t *testing.T) {
+	initSea()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	server := httptest.NewServer(NewReverseProxy(httputil.NewSingleHostReverseProxy(target),
+		WithRouteExtractor(func(r *http.Request) string {
+			return "route-backend"
+		})))
+	defer server.Close()
+
+	_, err := gateway.LoadApiDefinitions([]*gateway.ApiDefinition{
+		{ApiName: "order_api", PredicateItems: []*gateway.ApiPredicateItem{{Pattern: "/order/**", MatchStrategy: gateway.MatchPrefix}}},
+		{ApiName: "user_api", PredicateItems: []*gateway.ApiPredicateItem{{Pattern: "/user/**", MatchStrategy: gateway.MatchPrefix}}},
+	})
+	assert.Nil(t, err)
+	defer gateway.ClearApiDefinitions()
+	// 一条flow规则覆盖API分组下的所有接口
+	_, err = flow.LoadRules([]*flow.Rule{{
+		Resource:               "order_api",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              0,
+		StatIntervalInMs:       1000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+	_, err = gateway.LoadRules([]*gateway.Rule{{
+		Resource:      "user_api",
+		ResourceMode:  gateway.ResourceModeCustomApiName,
+		Threshold:     1,
+		DurationInSec: 10,
+		ParamItem:     &gateway.ParamItem{ParseStrategy: gateway.ParseHeader, FieldName: "X-User"},
+	}})
+	assert.Nil(t, err)
+	defer gateway.ClearRules()
+
+	get := func(path, user string) int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if len(user) > 0 {
+			req.Header.Set("X-User", user)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get("/order/1", ""))
+	assert.Equal(t, http.StatusTooManyRequests, get("/order/list", ""))
+	assert.Equal(t, http.StatusOK, get("/other", ""))
+
+	// 按照请求头的值分别限流
+	assert.Equal(t, http.StatusOK, get("/user/1", "a"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/user/1", "a"))
+	assert.Equal(t, http.StatusOK, get("/user/2", "b"))
+	// 未携带请求头时规则不生效
+	assert.Equal(t, http.StatusOK, get("/user/1", ""))
+	assert.Equal(t, http.StatusOK, get("/user/1", ""))
+
+	t.Run("ProxyError", func(t *testing.T) {
+		target, _ := url.Parse("http://127.0.0.1:1")
+		server := httptest.NewServer(NewReverseProxy(httputil.NewSingleHostReverseProxy(target)))
+		defer server.Close()
+		rsp, err := http.Get(server.URL + "/other")
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+	})
+}
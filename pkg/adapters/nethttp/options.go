@@ -0,0 +1,104 @@
+package nethttp
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	// UnmatchedRoute 服务端无法获取路由模式时使用的route
+	UnmatchedRoute = "unmatched"
+	// DefaultMaxBodyBytes 默认读取的请求体上限，超过上限的请求体不参与灰度条件匹配
+	DefaultMaxBodyBytes = 1 << 20
+)
+
+type (
+	Option func(*options)
+
+	options struct {
+		serverResourceExtract func(*http.Request) string
+		clientResourceExtract func(*http.Request) string
+		argsExtract           func(*http.Request) []interface{}
+		routeExtract          func(*http.Request) string
+
+		serverBlockFallback func(http.ResponseWriter, *http.Request, *base.BlockError)
+		clientBlockFallback func(*http.Request, *base.BlockError) (*http.Response, error)
+
+		// mux 用于获取请求匹配的路由，避免路径参数导致资源数量膨胀
+		mux *http.ServeMux
+		// maxBodyBytes 读取请求体的上限，<=0时不读取请求体
+		maxBodyBytes int64
+		// warnUnmatchedOnce 无法获取路由模式时只告警一次
+		warnUnmatchedOnce sync.Once
+	}
+)
+
+// WithServerResourceExtractor sets the resource extractor of inbound request.
+func WithServerResourceExtractor(fn func(*http.Request) string) Option {
+	return func(o *options) {
+		o.serverResourceExtract = fn
+	}
+}
+
+// WithClientResourceExtractor sets the resource extractor of outbound request.
+// 默认资源名称为 method:host，需要按照路由区分资源时通过该选项返回 method:host+路由模式
+func WithClientResourceExtractor(fn func(*http.Request) string) Option {
+	return func(o *options) {
+		o.clientResourceExtract = fn
+	}
+}
+
+// WithArgsExtractor sets the args extractor of request, the args are used by hotspot and mock rules.
+// Web resource args must be string, 默认为 key=value 格式的query参数
+func WithArgsExtractor(fn func(*http.Request) []interface{}) Option {
+	return func(o *options) {
+		o.argsExtract = fn
+	}
+}
+
+// WithRouteExtractor sets the route id extractor of gateway request, 返回空时不以路由ID为资源进入sea
+func WithRouteExtractor(fn func(*http.Request) string) Option {
+	return func(o *options) {
+		o.routeExtract = fn
+	}
+}
+
+// WithServerBlockFallback sets the block fallback handler of inbound request.
+func WithServerBlockFallback(fn func(http.ResponseWriter, *http.Request, *base.BlockError)) Option {
+	return func(o *options) {
+		o.serverBlockFallback = fn
+	}
+}
+
+// WithClientBlockFallback sets the block fallback handler of outbound request.
+func WithClientBlockFallback(fn func(*http.Request, *base.BlockError) (*http.Response, error)) Option {
+	return func(o *options) {
+		o.clientBlockFallback = fn
+	}
+}
+
+// WithServeMux 使用mux匹配到的路由模式作为资源名的路由部分，NewHandler包装的Handler为*http.ServeMux时默认使用该mux
+func WithServeMux(mux *http.ServeMux) Option {
+	return func(o *options) {
+		o.mux = mux
+	}
+}
+
+// WithMaxBodyBytes 设置读取请求体的上限，<=0时不读取请求体
+func WithMaxBodyBytes(maxBodyBytes int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = maxBodyBytes
+	}
+}
+
+func evaluateOptions(opts []Option) *options {
+	optCopy := &options{
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+	for _, o := range opts {
+		o(optCopy)
+	}
+	return optCopy
+}
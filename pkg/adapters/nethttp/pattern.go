@@ -0,0 +1,10 @@
+//go:build !go1.22
+
+package nethttp
+
+import "net/http"
+
+// requestPattern Go 1.22以前的Request不携带路由模式
+func requestPattern(*http.Request) string {
+	return ""
+}
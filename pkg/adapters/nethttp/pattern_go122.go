@@ -0,0 +1,10 @@
+//go:build go1.22
+
+package nethttp
+
+import "net/http"
+
+// requestPattern Go 1.22起ServeMux会将匹配到的路由模式写入Request.Pattern
+func requestPattern(r *http.Request) string {
+	return r.Pattern
+}
@@ -0,0 +1,17 @@
+//go:build go1.22
+
+package nethttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerResourceName_RequestPattern(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/items/123", nil)
+	req.Pattern = "GET /items/{id}"
+	assert.Equal(t, "GET:/items/{id}", serverResourceName(evaluateOptions(nil), req))
+}
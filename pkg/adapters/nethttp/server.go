@@ -0,0 +1,155 @@
+package nethttp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+// EntryFromContext 获取服务端中间件创建的entry，可用于读取灰度标签等信息
+func EntryFromContext(ctx context.Context) *base.SeaEntry {
+	return sea.EntryFromContext(ctx)
+}
+
+// NewHandler returns a http.Handler wrapping h with sea entry
+func NewHandler(h http.Handler, seaOpts ...Option) http.Handler {
+	opts := evaluateOptions(seaOpts)
+	if mux, ok := h.(*http.ServeMux); ok && opts.mux == nil {
+		opts.mux = mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.CloseAll() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		name := serverResourceName(opts, r)
+		body := extractBody(r.Header, &r.Body, opts.maxBodyBytes)
+		entry, blockErr := sea.Entry(
+			name,
+			sea.WithResourceType(base.ResTypeWeb),
+			sea.WithTrafficType(base.Inbound),
+			sea.WithParentContext(r.Context()),
+			sea.WithArgs(extractArgs(opts, r)...),
+			sea.WithHeaders(extractHeaders(r.Header)),
+			sea.WithCookies(extractCookies(r.Cookies())),
+			sea.WithBody(body))
+		if blockErr != nil {
+			switch blockErr.BlockType() {
+			case base.BlockTypeMockCtxTimeout:
+				if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+					ctx, cancel := context.WithTimeout(r.Context(), time.Duration(ctxTimeout)*time.Millisecond)
+					defer cancel()
+					h.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			case base.BlockTypeMockRequest:
+				if data, ok := mockRequestBody(blockErr.TriggeredValue()); ok {
+					r.Body = ioutil.NopCloser(bytes.NewReader(data))
+					r.ContentLength = int64(len(data))
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+			if opts.serverBlockFallback != nil {
+				opts.serverBlockFallback(w, r, blockErr)
+				return
+			}
+			status, header, data := blockResponse(blockErr)
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write(data)
+			return
+		}
+		defer entry.Exit()
+		if entry.LinkPass() {
+			r.Header.Set(GrayTagHeader, entry.GrayTag())
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(sea.ContextWithEntry(r.Context(), entry)))
+		if sw.status >= http.StatusInternalServerError {
+			sea.TraceError(entry, errors.Errorf("http status %d", sw.status))
+		}
+	})
+}
+
+// NewHandlerFunc returns a http.HandlerFunc wrapping h with sea entry
+func NewHandlerFunc(h http.HandlerFunc, seaOpts ...Option) http.HandlerFunc {
+	return NewHandler(h, seaOpts...).ServeHTTP
+}
+
+// serverResourceName 资源名称的route依次取自WithServerResourceExtractor、WithServeMux(或被包装的ServeMux)
+// 匹配到的路由模式、Go 1.22 ServeMux写入的Request.Pattern，均无法获取时归入UnmatchedRoute，
+// 不使用请求路径，避免路径参数导致资源数量膨胀
+func serverResourceName(opts *options, r *http.Request) string {
+	if opts.serverResourceExtract != nil {
+		return opts.serverResourceExtract(r)
+	}
+	var pattern string
+	if opts.mux != nil {
+		_, pattern = opts.mux.Handler(r)
+	}
+	if pattern == "" {
+		pattern = requestPattern(r)
+	}
+	if pattern == "" {
+		opts.warnUnmatchedOnce.Do(func() {
+			logging.Warn("[nethttp] Fail to get the route of request, use WithServeMux or WithServerResourceExtractor to name resources by route",
+				"method", r.Method, "path", r.URL.Path, "route", UnmatchedRoute)
+		})
+		return resourceName(r.Method, UnmatchedRoute)
+	}
+	// Go 1.22的路由模式可能带有method前缀，如 "GET /users/{id}"
+	if idx := strings.IndexByte(pattern, ' '); idx >= 0 {
+		pattern = strings.TrimLeft(pattern[idx+1:], " ")
+	}
+	return resourceName(r.Method, pattern)
+}
+
+// mockRequestBody mock请求替换的新请求体
+func mockRequestBody(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+// statusWriter 记录响应码，5xx记录为资源异常
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,139 @@
+package nethttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+func TestNewHandler(t *testing.T) {
+	initSea()
+	var gotBody string
+	var gotEntry *base.SeaEntry
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(data)
+		gotEntry = EntryFromContext(r.Context())
+		_, _ = w.Write([]byte("ok"))
+	})
+	server := httptest.NewServer(NewHandler(mux, WithServeMux(mux)))
+	defer server.Close()
+
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "GET:/users/",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              0,
+		StatIntervalInMs:       1000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+	_, err = mock.LoadRules([]*mock.Rule{{
+		Resource:           "POST:/users/",
+		ControlBehavior:    mock.Mock,
+		Op:                 mock.Or,
+		AdditionalItems:    []mock.AdditionalItem{{Key: "x-mock", Value: "1"}},
+		ThenReturnMockData: `{"code":1}`,
+	}})
+	assert.Nil(t, err)
+	defer mock.ClearRules()
+
+	t.Run("FlowBlock", func(t *testing.T) {
+		rsp, err := http.Get(server.URL + "/users/1")
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, rsp.StatusCode)
+		assert.Equal(t, "1", rsp.Header.Get("Retry-After"))
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/users/2", strings.NewReader("name=a"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Mock", "1")
+		rsp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		data, _ := ioutil.ReadAll(rsp.Body)
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, `{"code":1}`, string(data))
+		assert.Equal(t, "application/json; charset=utf-8", rsp.Header.Get("Content-Type"))
+	})
+
+	t.Run("Pass", func(t *testing.T) {
+		rsp, err := http.Post(server.URL+"/users/3", "application/json", strings.NewReader(`{"name":"b"}`))
+		assert.Nil(t, err)
+		defer rsp.Body.Close()
+		data, _ := ioutil.ReadAll(rsp.Body)
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, "ok", string(data))
+		// 读取过的请求体需要还原给业务handler
+		assert.Equal(t, `{"name":"b"}`, gotBody)
+		if assert.NotNil(t, gotEntry) {
+			assert.Equal(t, "POST:/users/", gotEntry.Resource().Name())
+			assert.Equal(t, base.ResTypeWeb, gotEntry.Resource().Classification())
+		}
+	})
+}
+
+func TestServerResourceName(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(http.ResponseWriter, *http.Request) {})
+	opts := evaluateOptions([]Option{WithServeMux(mux)})
+	assert.Equal(t, "GET:/users/", serverResourceName(opts, httptest.NewRequest(http.MethodGet, "/users/123", nil)))
+	// 未匹配的请求不以请求路径作为资源
+	assert.Equal(t, "GET:"+UnmatchedRoute, serverResourceName(opts, httptest.NewRequest(http.MethodGet, "/orders/123", nil)))
+	assert.Equal(t, "GET:"+UnmatchedRoute, serverResourceName(evaluateOptions(nil), httptest.NewRequest(http.MethodGet, "/orders/123", nil)))
+
+	// 包装ServeMux时默认使用该mux的路由模式
+	var name string
+	handler := NewHandler(mux)
+	mux.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {
+		name = EntryFromContext(r.Context()).Resource().Name()
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/456", nil))
+	assert.Equal(t, "GET:/orders/", name)
+}
+
+func TestExtractArgs(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users?b=2&a=1&a=3", nil)
+	assert.Equal(t, []interface{}{"a=1", "a=3", "b=2"}, extractArgs(evaluateOptions(nil), req))
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, int64(1), retryAfterSeconds(nil))
+	assert.Equal(t, int64(1), retryAfterSeconds(&flow.Rule{StatIntervalInMs: 500}))
+	assert.Equal(t, int64(3), retryAfterSeconds(&flow.Rule{StatIntervalInMs: 2500}))
+}
+
+func TestExtractBody(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	body := ioutil.NopCloser(strings.NewReader(`{"uid":"100","age":18,"tags":["a"]}`))
+	values := extractBody(header, &body, DefaultMaxBodyBytes)
+	assert.Equal(t, []string{"100"}, values["uid"])
+	assert.Equal(t, []string{"18"}, values["age"])
+	assert.Equal(t, []string{`["a"]`}, values["tags"])
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, `{"uid":"100","age":18,"tags":["a"]}`, string(data))
+
+	// 超过上限时不解析且保留完整请求体
+	body = ioutil.NopCloser(strings.NewReader(`{"uid":"100"}`))
+	assert.Nil(t, extractBody(header, &body, 4))
+	data, _ = ioutil.ReadAll(body)
+	assert.Equal(t, `{"uid":"100"}`, string(data))
+}
@@ -0,0 +1,184 @@
+package nethttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+const (
+	// GrayTagHeader 灰度链路透传时携带灰度标签的请求头
+	GrayTagHeader = "X-Gray-Tag"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// resourceName 资源名称为 method:route
+func resourceName(method, route string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + ":" + route
+}
+
+// splitResourceName 将灰度资源拆分为method及route
+func splitResourceName(name string) (string, string) {
+	idx := strings.Index(name, ":")
+	if idx < 0 {
+		return "", name
+	}
+	return name[:idx], name[idx+1:]
+}
+
+func extractArgs(opts *options, r *http.Request) []interface{} {
+	if opts.argsExtract != nil {
+		return opts.argsExtract(r)
+	}
+	// web资源的参数格式为key=value
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			args = append(args, k+"="+v)
+		}
+	}
+	return args
+}
+
+// extractHeaders 同时保留规范化及小写的header key，与规则中配置的key匹配
+func extractHeaders(header http.Header) map[string][]string {
+	headers := make(map[string][]string, len(header))
+	for k, v := range header {
+		headers[k] = v
+		if lower := strings.ToLower(k); lower != k {
+			headers[lower] = v
+		}
+	}
+	return headers
+}
+
+func extractCookies(cookies []*http.Cookie) map[string][]string {
+	if len(cookies) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(cookies))
+	for _, c := range cookies {
+		result[c.Name] = append(result[c.Name], c.Value)
+	}
+	return result
+}
+
+// extractBody 读取表单或JSON对象请求体的第一层参数，读取后还原body，超过上限时不解析
+func extractBody(header http.Header, body *io.ReadCloser, maxBodyBytes int64) map[string][]string {
+	if maxBodyBytes <= 0 || *body == nil || *body == http.NoBody {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "application/json" {
+		return nil
+	}
+	origin := *body
+	data, err := ioutil.ReadAll(io.LimitReader(origin, maxBodyBytes+1))
+	if err != nil || int64(len(data)) > maxBodyBytes {
+		*body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(data), origin), closer: origin}
+		return nil
+	}
+	_ = origin.Close()
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil
+		}
+		return values
+	}
+	var obj map[string]json.RawMessage
+	if err = jsonTraffic.Unmarshal(data, &obj); err != nil {
+		return nil
+	}
+	result := make(map[string][]string, len(obj))
+	for k, v := range obj {
+		var str string
+		if jsonTraffic.Unmarshal(v, &str) == nil {
+			result[k] = []string{str}
+		} else {
+			result[k] = []string{string(v)}
+		}
+	}
+	return result
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	return m.closer.Close()
+}
+
+// blockResponse 阻断对应的响应，mock返回mock数据，流控返回429并携带Retry-After
+func blockResponse(blockErr *base.BlockError) (int, http.Header, []byte) {
+	header := make(http.Header)
+	switch blockErr.BlockType() {
+	case base.BlockTypeMock:
+		strVal, _ := blockErr.TriggeredValue().(string)
+		if json.Valid([]byte(strVal)) {
+			header.Set("Content-Type", "application/json; charset=utf-8")
+		} else {
+			header.Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		return http.StatusOK, header, []byte(strVal)
+	case base.BlockTypeMockError:
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		if strVal, ok := blockErr.TriggeredValue().(string); ok {
+			return http.StatusInternalServerError, header, []byte(strVal)
+		}
+		return http.StatusInternalServerError, header, []byte(blockErr.Error())
+	case base.BlockTypeFlow, base.BlockTypeHotSpotParamFlow:
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Retry-After", strconv.FormatInt(retryAfterSeconds(blockErr.TriggeredRule()), 10))
+		return http.StatusTooManyRequests, header, []byte(blockErr.Error())
+	case base.BlockTypeAuthority:
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		return http.StatusForbidden, header, []byte(blockErr.Error())
+	default:
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		return http.StatusServiceUnavailable, header, []byte(blockErr.Error())
+	}
+}
+
+// retryAfterSeconds 以规则的统计窗口作为重试间隔，最少1秒
+func retryAfterSeconds(rule base.SeaRule) int64 {
+	var seconds int64
+	switch r := rule.(type) {
+	case *flow.Rule:
+		seconds = (int64(r.StatIntervalInMs) + 999) / 1000
+	case *hotspot.Rule:
+		seconds = r.DurationInSec
+	}
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
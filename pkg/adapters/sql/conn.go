@@ -0,0 +1,132 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+// wrappedConn 包装driver.Conn，被包装的连接不支持的可选接口按database/sql的默认行为处理
+type wrappedConn struct {
+	parent driver.Conn
+	opts   *options
+
+	// pending 被包装的连接返回driver.ErrSkip时未结束的entry，由随后Prepare的同一语句复用，
+	// database/sql不会并发使用同一连接，无需加锁
+	pending      *base.SeaEntry
+	pendingQuery string
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.parent.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else if err = ctx.Err(); err == nil {
+		stmt, err = c.parent.Prepare(query)
+	}
+	if err != nil {
+		if c.pending != nil {
+			traceError(c.pending, err)
+		}
+		exitPending(&c.pending)
+		return nil, err
+	}
+	ws := &wrappedStmt{parent: stmt, query: query, opts: c.opts}
+	if c.pendingQuery == query {
+		ws.pending, c.pending = c.pending, nil
+	}
+	exitPending(&c.pending)
+	return ws, nil
+}
+
+func (c *wrappedConn) Close() error {
+	exitPending(&c.pending)
+	return c.parent.Close()
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, txOpts driver.TxOptions) (driver.Tx, error) {
+	return c.opts.begin(ctx, func(ctx context.Context) (driver.Tx, error) {
+		if beginner, ok := c.parent.(driver.ConnBeginTx); ok {
+			return beginner.BeginTx(ctx, txOpts)
+		}
+		if txOpts.Isolation != driver.IsolationLevel(0) {
+			return nil, errors.New("sql: driver does not support non-default isolation level")
+		}
+		if txOpts.ReadOnly {
+			return nil, errors.New("sql: driver does not support read-only transactions")
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return c.parent.Begin()
+	})
+}
+
+// ExecContext 被包装的连接不支持直接执行时返回driver.ErrSkip，database/sql改为Prepare后由wrappedStmt执行
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.parent.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	exitPending(&c.pending)
+	c.pendingQuery = query
+	return c.opts.exec(ctx, query, args, &c.pending, func(ctx context.Context, query string) (driver.Result, error) {
+		return execer.ExecContext(ctx, query, args)
+	})
+}
+
+// QueryContext 被包装的连接不支持直接查询时返回driver.ErrSkip，database/sql改为Prepare后由wrappedStmt查询
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.parent.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	exitPending(&c.pending)
+	c.pendingQuery = query
+	return c.opts.query(ctx, query, args, &c.pending, func(ctx context.Context, query string) (driver.Rows, error) {
+		return queryer.QueryContext(ctx, query, args)
+	})
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.parent.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.parent.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if validator, ok := c.parent.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue 返回driver.ErrSkip时database/sql使用默认的参数转换
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
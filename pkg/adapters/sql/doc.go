@@ -0,0 +1,9 @@
+// Package sql provides the database/sql driver wrapper of gmiter.
+//
+// 通过Wrap/Register/WrapConnector/OpenDB包装任意driver.Driver或driver.Connector，连接上的Query/Exec/Begin均经过sea entry，
+// 资源类型为base.ResTypeDBSQL，资源名称默认为Fingerprint(query)，如 SELECT * FROM user WHERE id IN (?)，开启事务的资源名称为BEGIN，
+// 绑定参数作为WithArgs传入。执行错误会被记录用于熔断统计，可在不修改DAO的情况下为共享数据库配置隔离及慢SQL熔断。
+//
+// 阻断处理：mock时Query的mock数据为对象数组，如 [{"id":1,"name":"a"}]，Exec的mock数据为 {"lastInsertId":1,"rowsAffected":1}；
+// mock异常返回mock的错误信息，ctx超时替换后继续执行；其他阻断返回*base.BlockError或WithBlockFallback的返回值。
+package sql
@@ -0,0 +1,87 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+)
+
+// Wrap returns a driver.Driver wrapping d, every Query/Exec/Begin of the connections goes through sea entry.
+// d实现了driver.DriverContext时返回值同样实现driver.DriverContext
+func Wrap(d driver.Driver, seaOpts ...Option) driver.Driver {
+	wd := &wrappedDriver{parent: d, opts: evaluateOptions(seaOpts)}
+	if _, ok := d.(driver.DriverContext); ok {
+		return &wrappedDriverContext{wrappedDriver: wd}
+	}
+	return wd
+}
+
+// WrapConnector returns a driver.Connector wrapping c, 可通过sql.OpenDB使用
+func WrapConnector(c driver.Connector, seaOpts ...Option) driver.Connector {
+	return &wrappedConnector{parent: c, opts: evaluateOptions(seaOpts)}
+}
+
+// Register registers the wrapped d as a database/sql driver named name
+func Register(name string, d driver.Driver, seaOpts ...Option) {
+	sql.Register(name, Wrap(d, seaOpts...))
+}
+
+// OpenDB opens a *sql.DB using the wrapped c
+func OpenDB(c driver.Connector, seaOpts ...Option) *sql.DB {
+	return sql.OpenDB(WrapConnector(c, seaOpts...))
+}
+
+type wrappedDriver struct {
+	parent driver.Driver
+	opts   *options
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{parent: conn, opts: d.opts}, nil
+}
+
+type wrappedDriverContext struct {
+	*wrappedDriver
+}
+
+func (d *wrappedDriverContext) OpenConnector(name string) (driver.Connector, error) {
+	connector, err := d.parent.(driver.DriverContext).OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConnector{parent: connector, opts: d.opts, driver: d}, nil
+}
+
+type wrappedConnector struct {
+	parent driver.Connector
+	opts   *options
+	driver driver.Driver
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.parent.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{parent: conn, opts: c.opts}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	if c.driver != nil {
+		return c.driver
+	}
+	return &wrappedDriver{parent: c.parent.Driver(), opts: c.opts}
+}
+
+// Close 关闭被包装的connector，sql.DB.Close时调用
+func (c *wrappedConnector) Close() error {
+	if closer, ok := c.parent.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// inListRegex IN (?, ?, ?) 归一为 IN (?)
+	inListRegex = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	// valuesListRegex VALUES (?), (?) 归一为 VALUES (?)
+	valuesListRegex = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+)
+
+// Fingerprint 返回归一化的SQL指纹：去除注释、字符串及数值字面量替换为?、合并空白、IN列表及多行VALUES合并，
+// 相同结构的SQL得到相同的指纹，用于作为资源名
+func Fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			// 单行注释
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			// 多行注释
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			space = true
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		}
+		if space {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+		}
+		switch {
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i, c)
+			b.WriteByte('?')
+		case c == '`':
+			end := strings.IndexByte(query[i+1:], '`')
+			if end < 0 {
+				b.WriteString(query[i:])
+				i = len(query)
+			} else {
+				b.WriteString(query[i : i+end+2])
+				i += end + 1
+			}
+		case isDigit(c) && (i == 0 || !isIdentChar(query[i-1])):
+			for i+1 < len(query) && (isIdentChar(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	fp := inListRegex.ReplaceAllString(b.String(), "(?)")
+	fp = valuesListRegex.ReplaceAllString(fp, "(?)")
+	return strings.TrimRight(fp, "; ")
+}
+
+// skipQuoted 跳过引号字面量，支持反斜杠转义及连续两个引号的转义，返回结束引号的位置
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query) - 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
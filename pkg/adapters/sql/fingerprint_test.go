@@ -0,0 +1,26 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM user WHERE id = 1", "SELECT * FROM user WHERE id = ?"},
+		{"select *\n  from user\twhere name = 'a''b' and age > 18.5;", "select * from user where name = ? and age > ?"},
+		{`SELECT * FROM user WHERE name = "a\"b"`, "SELECT * FROM user WHERE name = ?"},
+		{"SELECT * FROM user WHERE id IN (1, 2, 3)", "SELECT * FROM user WHERE id IN (?)"},
+		{"SELECT * FROM user WHERE id IN (?,?)", "SELECT * FROM user WHERE id IN (?)"},
+		{"INSERT INTO t1 (a, b) VALUES (1, 'x'), (2, 'y')", "INSERT INTO t1 (a, b) VALUES (?)"},
+		{"SELECT /* hint */ a FROM t -- comment\nWHERE b = 0x1F", "SELECT a FROM t WHERE b = ?"},
+		{"SELECT `col1` FROM `t2` WHERE c = $1", "SELECT `col1` FROM `t2` WHERE c = $1"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, Fingerprint(c.query), c.query)
+	}
+}
@@ -0,0 +1,173 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+const (
+	// beginQuery 开启事务时传给资源提取器的语句
+	beginQuery = "BEGIN"
+)
+
+type (
+	execFunc  func(ctx context.Context, query string) (driver.Result, error)
+	queryFunc func(ctx context.Context, query string) (driver.Rows, error)
+	beginFunc func(ctx context.Context) (driver.Tx, error)
+)
+
+// entry 创建DBSQL出口资源entry，绑定参数作为args传入以支持热点参数及mock参数匹配，
+// ctx中存在上游entry时沿用其调用链路入口
+func (o *options) entry(ctx context.Context, query string, args []driver.NamedValue) (*base.SeaEntry, *base.BlockError) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return sea.Entry(
+		o.resourceName(ctx, query),
+		sea.WithResourceType(base.ResTypeDBSQL),
+		sea.WithTrafficType(base.Outbound),
+		sea.WithParentContext(ctx),
+		sea.WithArgs(values...))
+}
+
+// exec 在sea entry保护下执行Exec，mock数据解析为driver.Result，pending的用法见 keepOrExit。
+// 绑定参数为标量，请求替换(BlockTypeMockRequest)无法作用于SQL，按阻断处理
+func (o *options) exec(ctx context.Context, query string, args []driver.NamedValue, pending **base.SeaEntry, fn execFunc) (driver.Result, error) {
+	if config.CloseAll() {
+		return fn(ctx, query)
+	}
+	entry, blockErr := o.pendingOrEntry(ctx, query, args, pending)
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				return parseMockResult(strVal)
+			}
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+				defer cancel()
+				return fn(newCtx, query)
+			}
+		}
+		return nil, o.blockError(ctx, query, blockErr)
+	}
+	result, err := fn(ctx, query)
+	keepOrExit(entry, err, pending)
+	return result, err
+}
+
+// query 在sea entry保护下执行Query，mock数据解析为driver.Rows，pending的用法见 keepOrExit
+func (o *options) query(ctx context.Context, query string, args []driver.NamedValue, pending **base.SeaEntry, fn queryFunc) (driver.Rows, error) {
+	if config.CloseAll() {
+		return fn(ctx, query)
+	}
+	entry, blockErr := o.pendingOrEntry(ctx, query, args, pending)
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				return parseMockRows(strVal)
+			}
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				newCtx, cancel := context.WithTimeout(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+				rows, err := fn(newCtx, query)
+				if err != nil {
+					cancel()
+					return nil, err
+				}
+				return &cancelRows{Rows: rows, cancel: cancel}, nil
+			}
+		}
+		return nil, o.blockError(ctx, query, blockErr)
+	}
+	rows, err := fn(ctx, query)
+	keepOrExit(entry, err, pending)
+	return rows, err
+}
+
+// begin 在sea entry保护下开启事务
+func (o *options) begin(ctx context.Context, fn beginFunc) (driver.Tx, error) {
+	if config.CloseAll() {
+		return fn(ctx)
+	}
+	entry, blockErr := o.entry(ctx, beginQuery, nil)
+	if blockErr != nil {
+		return nil, o.blockError(ctx, beginQuery, blockErr)
+	}
+	defer entry.Exit()
+
+	tx, err := fn(ctx)
+	traceError(entry, err)
+	return tx, err
+}
+
+// blockError 阻断时返回的错误，mock异常返回mock的错误信息
+func (o *options) blockError(ctx context.Context, query string, blockErr *base.BlockError) error {
+	if blockErr.BlockType() == base.BlockTypeMockError {
+		if strVal, ok := blockErr.TriggeredValue().(string); ok {
+			return errors.New(strVal)
+		}
+	}
+	if o.blockFallback != nil {
+		return o.blockFallback(ctx, query, blockErr)
+	}
+	return blockErr
+}
+
+// pendingOrEntry 存在未结束的entry时直接复用，不再重复检查规则，否则创建新的entry
+func (o *options) pendingOrEntry(ctx context.Context, query string, args []driver.NamedValue, pending **base.SeaEntry) (*base.SeaEntry, *base.BlockError) {
+	if pending != nil && *pending != nil {
+		entry := *pending
+		*pending = nil
+		return entry, nil
+	}
+	return o.entry(ctx, query, args)
+}
+
+// keepOrExit 调用返回driver.ErrSkip时将entry保存到pending，database/sql随后在同一连接上Prepare该语句并由wrappedStmt复用entry执行，
+// 避免同一次调用被重复统计；其他情况记录错误并结束entry
+func keepOrExit(entry *base.SeaEntry, err error, pending **base.SeaEntry) {
+	if err == driver.ErrSkip && pending != nil {
+		*pending = entry
+		return
+	}
+	traceError(entry, err)
+	entry.Exit()
+}
+
+// exitPending 结束未被复用的entry
+func exitPending(pending **base.SeaEntry) {
+	if *pending != nil {
+		(*pending).Exit()
+		*pending = nil
+	}
+}
+
+// traceError 记录错误用于熔断统计，driver.ErrSkip表示驱动不支持该调用方式，不属于业务错误
+func traceError(entry *base.SeaEntry, err error) {
+	if err != nil && err != driver.ErrSkip {
+		sea.TraceError(entry, err)
+	}
+}
+
+// cancelRows 结果集关闭时取消mock设置的超时ctx
+type cancelRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+func (r *cancelRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
@@ -0,0 +1,111 @@
+package sql
+
+import (
+	"database/sql/driver"
+	"io"
+	"math"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+)
+
+// mockResult Exec的mock数据，格式为 {"lastInsertId":1,"rowsAffected":1}
+type mockResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r *mockResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r *mockResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func parseMockResult(data string) (driver.Result, error) {
+	var result struct {
+		LastInsertID int64 `json:"lastInsertId"`
+		RowsAffected int64 `json:"rowsAffected"`
+	}
+	if err := jsonTraffic.Unmarshal([]byte(data), &result); err != nil {
+		return nil, errors.Wrap(err, "parse mock result")
+	}
+	return &mockResult{lastInsertID: result.LastInsertID, rowsAffected: result.RowsAffected}, nil
+}
+
+// mockRows Query的mock数据，格式为对象数组，如 [{"id":1,"name":"a"}]，列顺序以第一个对象的属性顺序为准
+type mockRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *mockRows) Columns() []string {
+	return r.columns
+}
+
+func (r *mockRows) Close() error {
+	return nil
+}
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+func parseMockRows(data string) (driver.Rows, error) {
+	var (
+		rows    = &mockRows{}
+		records []map[string]driver.Value
+		iter    = jsonTraffic.BorrowIterator([]byte(data))
+	)
+	defer jsonTraffic.ReturnIterator(iter)
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		record := make(map[string]driver.Value)
+		iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+			if len(records) == 0 {
+				rows.columns = append(rows.columns, key)
+			}
+			record[key] = mockValue(iter.Read())
+			return true
+		})
+		records = append(records, record)
+		return true
+	})
+	if iter.Error != nil && iter.Error != io.EOF {
+		return nil, errors.Wrap(iter.Error, "parse mock rows")
+	}
+	for _, record := range records {
+		row := make([]driver.Value, len(rows.columns))
+		for i, column := range rows.columns {
+			row[i] = record[column]
+		}
+		rows.rows = append(rows.rows, row)
+	}
+	return rows, nil
+}
+
+// mockValue json值转换为driver.Value，整数转为int64，对象及数组转为json字符串
+func mockValue(val interface{}) driver.Value {
+	switch v := val.(type) {
+	case nil, string, bool:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	default:
+		data, _ := jsonTraffic.Marshal(v)
+		return string(data)
+	}
+}
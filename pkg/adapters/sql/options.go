@@ -0,0 +1,49 @@
+package sql
+
+import (
+	"context"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+type (
+	Option func(*options)
+
+	options struct {
+		resourceExtract func(context.Context, string) string
+		blockFallback   func(context.Context, string, *base.BlockError) error
+	}
+)
+
+// WithResourceExtractor sets the resource extractor of sql statement.
+// The string parameter is the raw query, Begin uses the fixed query "BEGIN".
+// 默认资源名称为Fingerprint(query)
+func WithResourceExtractor(fn func(context.Context, string) string) Option {
+	return func(opts *options) {
+		opts.resourceExtract = fn
+	}
+}
+
+// WithBlockFallback sets the block fallback handler, the returned error is returned to database/sql.
+// 默认直接返回*base.BlockError
+func WithBlockFallback(fn func(context.Context, string, *base.BlockError) error) Option {
+	return func(opts *options) {
+		opts.blockFallback = fn
+	}
+}
+
+func evaluateOptions(opts []Option) *options {
+	optCopy := &options{}
+	for _, o := range opts {
+		o(optCopy)
+	}
+	return optCopy
+}
+
+// resourceName 资源名称，未设置提取器时为SQL指纹
+func (o *options) resourceName(ctx context.Context, query string) string {
+	if o.resourceExtract != nil {
+		return o.resourceExtract(ctx, query)
+	}
+	return Fingerprint(query)
+}
@@ -0,0 +1,443 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/mock"
+	"github.com/liuhailove/gmiter/core/stat"
+	"github.com/liuhailove/gmiter/pkg/adapters/nethttp"
+)
+
+const (
+	insertQuery = "INSERT INTO kv (k, v) VALUES (?, ?)"
+	selectQuery = "SELECT k, v FROM kv WHERE k = ?"
+	failQuery   = "SELECT fail FROM kv"
+)
+
+// memDriver 仅支持kv表插入及按k查询的内存database/sql驱动，dsn为ctx时连接实现ExecerContext/QueryerContext，
+// dsn为skip时连接的ExecerContext/QueryerContext返回driver.ErrSkip
+type memDriver struct {
+	mux   sync.Mutex
+	rows  map[string]string
+	calls int32
+}
+
+func (d *memDriver) Open(dsn string) (driver.Conn, error) {
+	conn := &memConn{driver: d}
+	switch dsn {
+	case "ctx":
+		return &memCtxConn{memConn: conn}, nil
+	case "skip":
+		return &memSkipConn{memConn: conn}, nil
+	}
+	return conn, nil
+}
+
+func (d *memDriver) exec(query string, args []driver.Value) (driver.Result, error) {
+	atomic.AddInt32(&d.calls, 1)
+	if query != insertQuery {
+		return nil, errors.New("unsupported query: " + query)
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.rows[args[0].(string)] = args[1].(string)
+	return driver.RowsAffected(1), nil
+}
+
+func (d *memDriver) query(query string, args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt32(&d.calls, 1)
+	if query != selectQuery {
+		return nil, errors.New("unsupported query: " + query)
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	rows := &memRows{}
+	if v, ok := d.rows[args[0].(string)]; ok {
+		rows.values = append(rows.values, []driver.Value{args[0], v})
+	}
+	return rows, nil
+}
+
+type memConn struct {
+	driver *memDriver
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *memConn) Close() error {
+	return nil
+}
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	return &memTx{}, nil
+}
+
+type memCtxConn struct {
+	*memConn
+}
+
+func (c *memCtxConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values, _ := plainValues(args)
+	return c.driver.exec(query, values)
+}
+
+func (c *memCtxConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, _ := plainValues(args)
+	return c.driver.query(query, values)
+}
+
+type memSkipConn struct {
+	*memConn
+}
+
+func (c *memSkipConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *memSkipConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, driver.ErrSkip
+}
+
+type memStmt struct {
+	driver *memDriver
+	query  string
+}
+
+func (s *memStmt) Close() error {
+	return nil
+}
+
+func (s *memStmt) NumInput() int {
+	return strings.Count(s.query, "?")
+}
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.driver.exec(s.query, args)
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.driver.query(s.query, args)
+}
+
+type memRows struct {
+	values [][]driver.Value
+	pos    int
+}
+
+func (r *memRows) Columns() []string {
+	return []string{"k", "v"}
+}
+
+func (r *memRows) Close() error {
+	return nil
+}
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+type memTx struct{}
+
+func (t *memTx) Commit() error {
+	return nil
+}
+
+func (t *memTx) Rollback() error {
+	return nil
+}
+
+var testDriver = &memDriver{rows: make(map[string]string)}
+
+func init() {
+	Register("gmiter-sql-mem", testDriver)
+}
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+func TestWrapDriver(t *testing.T) {
+	initSea()
+	for _, dsn := range []string{"stmt", "ctx", "skip"} {
+		t.Run(dsn, func(t *testing.T) {
+			db, err := sql.Open("gmiter-sql-mem", dsn)
+			assert.Nil(t, err)
+			defer db.Close()
+
+			t.Run("Pass", func(t *testing.T) {
+				_, err := db.Exec(insertQuery, "a", "1")
+				assert.Nil(t, err)
+				var v string
+				assert.Nil(t, db.QueryRow(selectQuery, "a").Scan(new(string), &v))
+				assert.Equal(t, "1", v)
+				tx, err := db.Begin()
+				assert.Nil(t, err)
+				assert.Nil(t, tx.Commit())
+			})
+
+			t.Run("FlowBlock", func(t *testing.T) {
+				_, err := flow.LoadRules([]*flow.Rule{{
+					Resource:               Fingerprint(insertQuery),
+					TokenCalculateStrategy: flow.Direct,
+					ControlBehavior:        flow.Reject,
+					Threshold:              0,
+				}})
+				assert.Nil(t, err)
+				defer flow.ClearRules()
+				calls := atomic.LoadInt32(&testDriver.calls)
+				_, err = db.Exec(insertQuery, "b", "2")
+				blockErr, ok := err.(*base.BlockError)
+				assert.True(t, ok)
+				assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+				assert.Equal(t, calls, atomic.LoadInt32(&testDriver.calls))
+			})
+
+			t.Run("MockRows", func(t *testing.T) {
+				_, err := mock.LoadRules([]*mock.Rule{{
+					Resource:           Fingerprint(selectQuery),
+					ControlBehavior:    mock.Mock,
+					ThenReturnMockData: `[{"k":"x","v":"mock"},{"k":"y","v":"2"}]`,
+				}})
+				assert.Nil(t, err)
+				defer mock.ClearRules()
+				rows, err := db.Query(selectQuery, "a")
+				assert.Nil(t, err)
+				defer rows.Close()
+				var values []string
+				for rows.Next() {
+					var k, v string
+					assert.Nil(t, rows.Scan(&k, &v))
+					values = append(values, k+"="+v)
+				}
+				assert.Equal(t, []string{"x=mock", "y=2"}, values)
+			})
+
+			t.Run("MockResult", func(t *testing.T) {
+				_, err := mock.LoadRules([]*mock.Rule{{
+					Resource:           Fingerprint(insertQuery),
+					ControlBehavior:    mock.Mock,
+					ThenReturnMockData: `{"lastInsertId":7,"rowsAffected":3}`,
+				}})
+				assert.Nil(t, err)
+				defer mock.ClearRules()
+				result, err := db.Exec(insertQuery, "c", "3")
+				assert.Nil(t, err)
+				affected, _ := result.RowsAffected()
+				assert.Equal(t, int64(3), affected)
+				id, _ := result.LastInsertId()
+				assert.Equal(t, int64(7), id)
+			})
+
+			t.Run("MockError", func(t *testing.T) {
+				_, err := mock.LoadRules([]*mock.Rule{{
+					Resource:        Fingerprint(selectQuery),
+					ControlBehavior: mock.Panic,
+					ThenThrowMsg:    "mock error",
+				}})
+				assert.Nil(t, err)
+				defer mock.ClearRules()
+				_, err = db.Query(selectQuery, "a")
+				assert.EqualError(t, err, "mock error")
+			})
+		})
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	initSea()
+	db := OpenDB(&memConnector{dsn: "ctx"}, WithResourceExtractor(func(ctx context.Context, query string) string {
+		return "kv"
+	}))
+	defer db.Close()
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         "kv",
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   1000,
+		Threshold:        1,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	_, err = db.Query(failQuery)
+	assert.EqualError(t, err, "unsupported query: "+failQuery)
+	_, err = db.Query(failQuery)
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeCircuitBreaking, blockErr.BlockType())
+}
+
+// mockRequestSlot 对指定资源返回请求替换阻断，替换值为首个绑定参数
+type mockRequestSlot struct {
+	resource string
+}
+
+func (s *mockRequestSlot) Order() uint32 {
+	return 0
+}
+
+func (s *mockRequestSlot) Initial() {}
+
+func (s *mockRequestSlot) Check(ctx *base.EntryContext) *base.TokenResult {
+	if ctx.Resource.Name() != s.resource || len(ctx.Input.Args) == 0 {
+		return nil
+	}
+	return base.NewTokenResultBlockedWithCause(base.BlockTypeMockRequest, "", nil, ctx.Input.Args[0])
+}
+
+// 请求替换无法作用于SQL绑定参数，按阻断处理，不能把参数当作SQL执行
+func TestMockRequest(t *testing.T) {
+	initSea()
+	sea.GlobalSlotChain().AddRuleCheckSlot(&mockRequestSlot{resource: "kv-mock-request"})
+	db := OpenDB(&memConnector{dsn: "ctx"}, WithResourceExtractor(func(ctx context.Context, query string) string {
+		return "kv-mock-request"
+	}))
+	defer db.Close()
+
+	calls := atomic.LoadInt32(&testDriver.calls)
+	_, err := db.Exec(insertQuery, selectQuery, "1")
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeMockRequest, blockErr.BlockType())
+	_, err = db.Query(selectQuery, insertQuery)
+	blockErr, ok = err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeMockRequest, blockErr.BlockType())
+	assert.Equal(t, calls, atomic.LoadInt32(&testDriver.calls))
+}
+
+type memConnector struct {
+	dsn string
+}
+
+func (c *memConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return testDriver.Open(c.dsn)
+}
+
+func (c *memConnector) Driver() driver.Driver {
+	return testDriver
+}
+
+// 连接返回driver.ErrSkip后database/sql改为Prepare执行，同一次调用只检查规则及统计一次
+func TestErrSkip(t *testing.T) {
+	initSea()
+	db := OpenDB(&memConnector{dsn: "skip"}, WithResourceExtractor(func(ctx context.Context, query string) string {
+		return "kv-skip"
+	}))
+	defer db.Close()
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "kv-skip",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              2,
+		StatIntervalInMs:       60000,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	_, err = db.Exec(insertQuery, "skip", "1")
+	assert.Nil(t, err)
+	var v string
+	assert.Nil(t, db.QueryRow(selectQuery, "skip").Scan(new(string), &v))
+	assert.Equal(t, "1", v)
+	_, err = db.Exec(insertQuery, "skip", "2")
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+
+	node := stat.GetResourceNode("kv-skip")
+	assert.NotNil(t, node)
+	assert.Equal(t, int64(2), node.GetSum(base.MetricEventPass))
+	assert.Equal(t, int64(2), node.GetSum(base.MetricEventComplete))
+	assert.Equal(t, int64(1), node.GetSum(base.MetricEventBlock))
+}
+
+func TestParseMockRows(t *testing.T) {
+	rows, err := parseMockRows(`[{"id":1,"name":"a","score":1.5,"tags":["x"]},{"name":"b","id":2}]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name", "score", "tags"}, rows.Columns())
+	dest := make([]driver.Value, 4)
+	assert.Nil(t, rows.Next(dest))
+	assert.Equal(t, []driver.Value{int64(1), "a", 1.5, `["x"]`}, dest)
+	assert.Nil(t, rows.Next(dest))
+	assert.Equal(t, []driver.Value{int64(2), "b", nil, nil}, dest)
+	assert.Equal(t, io.EOF, rows.Next(dest))
+
+	_, err = parseMockRows(`{"id":1}`)
+	assert.NotNil(t, err)
+}
+
+// 经由nethttp服务端中间件进入的请求，SQL出口资源沿用请求的调用链路入口，链路规则只限制经由指定接口的查询
+func TestChainEntranceThroughHTTP(t *testing.T) {
+	initSea()
+	db := OpenDB(&memConnector{dsn: "ctx"}, WithResourceExtractor(func(ctx context.Context, query string) string {
+		return "kv-chain"
+	}))
+	defer db.Close()
+	_, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               "kv-chain",
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		RelationStrategy:       flow.ChainResource,
+		RefResource:            "GET:/export",
+		Threshold:              0,
+	}})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	query := func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.QueryContext(r.Context(), selectQuery, "a")
+		if err != nil {
+			if _, ok := err.(*base.BlockError); ok {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = rows.Close()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/export", query)
+	mux.HandleFunc("/checkout", query)
+	handler := nethttp.NewHandler(mux)
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, serve("/export"))
+	assert.Equal(t, http.StatusOK, serve("/checkout"))
+	// 无入口的直接查询不受限
+	rows, err := db.Query(selectQuery, "a")
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+}
@@ -0,0 +1,104 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+// wrappedStmt 包装driver.Stmt，资源名称使用Prepare时的语句
+type wrappedStmt struct {
+	parent driver.Stmt
+	query  string
+	opts   *options
+
+	// pending 连接直接执行返回driver.ErrSkip时转交的entry，首次执行时复用
+	pending *base.SeaEntry
+}
+
+func (s *wrappedStmt) Close() error {
+	exitPending(&s.pending)
+	return s.parent.Close()
+}
+
+func (s *wrappedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.opts.exec(ctx, s.query, args, &s.pending, func(ctx context.Context, query string) (driver.Result, error) {
+		if execer, ok := s.parent.(driver.StmtExecContext); ok {
+			return execer.ExecContext(ctx, args)
+		}
+		values, err := plainValues(args)
+		if err != nil {
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		return s.parent.Exec(values)
+	})
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.opts.query(ctx, s.query, args, &s.pending, func(ctx context.Context, query string) (driver.Rows, error) {
+		if queryer, ok := s.parent.(driver.StmtQueryContext); ok {
+			return queryer.QueryContext(ctx, args)
+		}
+		values, err := plainValues(args)
+		if err != nil {
+			return nil, err
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		return s.parent.Query(values)
+	})
+}
+
+// CheckNamedValue 返回driver.ErrSkip时database/sql使用ColumnConverter转换参数
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (s *wrappedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.parent.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	result := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		result[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return result
+}
+
+// plainValues 被包装的语句不支持命名参数时转换为driver.Value
+func plainValues(args []driver.NamedValue) ([]driver.Value, error) {
+	result := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		result[i] = arg.Value
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package task
+
+import (
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/transport/common/command"
+)
+
+var (
+	taskHistoryCommandHandlerInst = new(taskHistoryCommandHandler)
+)
+
+func init() {
+	command.RegisterHandler(taskHistoryCommandHandlerInst.Name(), taskHistoryCommandHandlerInst)
+}
+
+// taskHistoryCommandHandler 获取调度任务的执行记录
+type taskHistoryCommandHandler struct {
+}
+
+func (t taskHistoryCommandHandler) Name() string {
+	return "getTaskHistory"
+}
+
+func (t taskHistoryCommandHandler) Desc() string {
+	return "get run history of scheduled tasks, request param: name={taskName}, all tasks if empty"
+}
+
+func (t taskHistoryCommandHandler) Handle(request command.Request) *command.Response {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	var result = make(map[string][]Record)
+	if name := request.GetParam("name"); len(name) > 0 {
+		result[name] = History(name)
+	} else {
+		for _, name := range Tasks() {
+			result[name] = History(name)
+		}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return command.OfFailure(err)
+	}
+	return command.OfSuccess(string(data))
+}
@@ -0,0 +1,17 @@
+// Package task provides the sea adapter of scheduled task(base.ResTypeTask).
+//
+// Wrap 将任务包装为Runner，Runner实现了Run()方法，可直接注册到robfig/cron等调度器，
+// 也可在自定义调度器中调用RunContext。每次执行以 task:任务名称 为资源名称进入sea：
+//
+//   - 配置Threshold为1的isolation规则防止任务重叠执行，规则开启ClusterMode时通过RedisTokenService实现跨实例互斥，
+//     可使用NoOverlapRule生成规则，集群模式下Token的最长持有时间取任务的最长执行时间，需配合WithTimeout使用；
+//   - 任务返回的错误会被记录，配合熔断规则(如ErrorCount)在连续失败后自动停用任务，熔断恢复后重新执行；
+//   - 最近的执行记录保存在内存中，可通过History查询，或通过command center的 getTaskHistory 命令获取。
+//
+// Sample code:
+//
+//	c := cron.New()
+//	c.AddJob("@every 1m", task.Wrap("syncOrders", func(ctx context.Context) error {
+//		return syncOrders(ctx)
+//	}, task.WithTimeout(50*time.Second)))
+package task
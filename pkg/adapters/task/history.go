@@ -0,0 +1,96 @@
+package task
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	historyMap = make(map[string]*history)
+	historyMux = &sync.RWMutex{}
+)
+
+// Record 任务的一次执行记录
+type Record struct {
+	// Name 任务名称
+	Name string `json:"name"`
+	// Resource 资源名称
+	Resource string `json:"resource"`
+	// StartTime 开始时间，单位ms
+	StartTime uint64 `json:"startTime"`
+	// CostMs 执行耗时，单位ms
+	CostMs uint64 `json:"costMs"`
+	// Result 执行结果，如ResultSuccess、ResultSkipped
+	Result string `json:"result"`
+	// BlockType 被阻断时的阻断类型
+	BlockType string `json:"blockType,omitempty"`
+	// Error 执行失败时的错误信息
+	Error string `json:"error,omitempty"`
+}
+
+// history 固定大小的执行记录环形缓冲
+type history struct {
+	mux     sync.Mutex
+	records []Record
+	next    int
+	full    bool
+}
+
+func historyOf(name string, size int) *history {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	if h, ok := historyMap[name]; ok {
+		return h
+	}
+	h := &history{records: make([]Record, size)}
+	historyMap[name] = h
+	return h
+}
+
+func (h *history) add(rec Record) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list 按照执行时间倒序返回记录
+func (h *history) list() []Record {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+	ret := make([]Record, 0, n)
+	for i := 1; i <= n; i++ {
+		ret = append(ret, h.records[(h.next-i+len(h.records))%len(h.records)])
+	}
+	return ret
+}
+
+// History returns the recent run records of the task, 按照执行时间倒序
+func History(name string) []Record {
+	historyMux.RLock()
+	h, ok := historyMap[name]
+	historyMux.RUnlock()
+	if !ok {
+		return nil
+	}
+	return h.list()
+}
+
+// Tasks returns the names of all the wrapped tasks
+func Tasks() []string {
+	historyMux.RLock()
+	defer historyMux.RUnlock()
+	names := make([]string, 0, len(historyMap))
+	for name := range historyMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,16 @@
+package task
+
+import (
+	metric_exporter "github.com/liuhailove/gmiter/exporter/metric"
+)
+
+var (
+	runCounter = metric_exporter.NewCounter(
+		"task_run_total",
+		"Total scheduled task run count",
+		[]string{"task", "result"})
+)
+
+func init() {
+	metric_exporter.Register(runCounter)
+}
@@ -0,0 +1,66 @@
+package task
+
+import (
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+const (
+	// DefaultHistorySize 每个任务默认保存的执行记录数
+	DefaultHistorySize = 20
+)
+
+type (
+	Option func(*options)
+
+	options struct {
+		resourceName  string
+		timeout       time.Duration
+		blockFallback func(string, *base.BlockError)
+		historySize   int
+	}
+)
+
+// WithResourceName sets the resource name of the task, 默认资源名称为 task:任务名称
+func WithResourceName(resource string) Option {
+	return func(opts *options) {
+		opts.resourceName = resource
+	}
+}
+
+// WithTimeout 设置任务单次执行的超时时间，超时后取消传入任务的ctx
+func WithTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
+// WithBlockFallback sets the handler called when the task run is blocked,
+// 参数为任务名称及阻断错误，如上次执行未结束(isolation)或任务已被熔断停用(circuit breaker)
+func WithBlockFallback(fn func(string, *base.BlockError)) Option {
+	return func(opts *options) {
+		opts.blockFallback = fn
+	}
+}
+
+// WithHistorySize 设置任务保存的执行记录数，默认为DefaultHistorySize
+func WithHistorySize(size int) Option {
+	return func(opts *options) {
+		opts.historySize = size
+	}
+}
+
+func evaluateOptions(name string, opts []Option) *options {
+	optCopy := &options{
+		resourceName: ResourceName(name),
+		historySize:  DefaultHistorySize,
+	}
+	for _, o := range opts {
+		o(optCopy)
+	}
+	if optCopy.historySize <= 0 {
+		optCopy.historySize = DefaultHistorySize
+	}
+	return optCopy
+}
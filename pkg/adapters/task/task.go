@@ -0,0 +1,193 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	sea "github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/isolation"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	// ResultSuccess 执行成功
+	ResultSuccess = "success"
+	// ResultError 执行失败
+	ResultError = "error"
+	// ResultSkipped 上次执行未结束，被isolation规则阻断
+	ResultSkipped = "skipped"
+	// ResultDisabled 任务被熔断停用
+	ResultDisabled = "disabled"
+	// ResultBlocked 被其他规则阻断
+	ResultBlocked = "blocked"
+	// ResultMock 被mock规则拦截，任务未执行
+	ResultMock = "mock"
+)
+
+// Job 调度任务的执行函数
+type Job func(ctx context.Context) error
+
+// Runner 使用sea保护的调度任务
+type Runner struct {
+	name    string
+	job     Job
+	opts    *options
+	history *history
+}
+
+// Wrap returns a Runner executing job with sea entry, 资源类型为base.ResTypeTask。
+// 同名任务共享执行记录
+func Wrap(name string, job Job, seaOpts ...Option) *Runner {
+	opts := evaluateOptions(name, seaOpts)
+	return &Runner{
+		name:    name,
+		job:     job,
+		opts:    opts,
+		history: historyOf(name, opts.historySize),
+	}
+}
+
+// ResourceName returns the default resource name of the task
+func ResourceName(name string) string {
+	return "task:" + name
+}
+
+// NoOverlapRule 返回防止任务重叠执行的isolation规则，clusterMode为true时跨实例互斥，
+// TokenService不可用时回退为本地互斥。
+// 集群模式下maxRunDuration必须大于0，作为集群Token的最长持有时间，超过后Token被回收、其他实例可再次执行，
+// 因此Runner需通过WithTimeout设置小于maxRunDuration的超时时间，并在ctx取消后尽快返回
+func NoOverlapRule(id, resource string, clusterMode bool, maxRunDuration time.Duration) (*isolation.Rule, error) {
+	rule := &isolation.Rule{
+		ID:         id,
+		Resource:   resource,
+		MetricType: isolation.Concurrency,
+		Threshold:  1,
+	}
+	if clusterMode {
+		ttlMs := maxRunDuration.Milliseconds()
+		if ttlMs <= 0 {
+			return nil, errors.New("max run duration of cluster mode no overlap rule must be positive")
+		}
+		rule.ClusterMode = true
+		rule.ClusterConfig = &isolation.ClusterConfig{
+			FallbackToLocalWhenFail: true,
+			ResourceTimeout:         ttlMs,
+			ResourceTimeoutStrategy: 1,
+			ClientOfflineTime:       ttlMs,
+		}
+	}
+	return rule, nil
+}
+
+// Name returns the task name
+func (r *Runner) Name() string {
+	return r.name
+}
+
+// Run 执行任务，实现了cron.Job接口，执行失败或被阻断时仅记录日志
+func (r *Runner) Run() {
+	if err := r.RunContext(context.Background()); err != nil {
+		logging.Warn("[task] Task run failed", "task", r.name, "err", err.Error())
+	}
+}
+
+// RunContext 执行任务，被阻断时返回*base.BlockError，任务panic时转换为错误返回
+func (r *Runner) RunContext(ctx context.Context) error {
+	start := util.CurrentTimeMillis()
+	if config.CloseAll() {
+		err := r.execute(ctx, r.opts.timeout)
+		r.record(start, runResult(err), nil, err)
+		return err
+	}
+	entry, blockErr := sea.Entry(
+		r.opts.resourceName,
+		sea.WithResourceType(base.ResTypeTask),
+		sea.WithTrafficType(base.Inbound))
+	if blockErr != nil {
+		switch blockErr.BlockType() {
+		case base.BlockTypeMock:
+			r.record(start, ResultMock, blockErr, nil)
+			return nil
+		case base.BlockTypeMockError:
+			var err error = blockErr
+			if strVal, ok := blockErr.TriggeredValue().(string); ok {
+				err = errors.New(strVal)
+			}
+			r.record(start, ResultMock, blockErr, err)
+			return err
+		case base.BlockTypeMockCtxTimeout:
+			if ctxTimeout, ok := blockErr.TriggeredValue().(int64); ok {
+				err := r.execute(ctx, time.Duration(ctxTimeout)*time.Millisecond)
+				r.record(start, runResult(err), nil, err)
+				return err
+			}
+		}
+		r.record(start, blockResult(blockErr), blockErr, nil)
+		if r.opts.blockFallback != nil {
+			r.opts.blockFallback(r.name, blockErr)
+		}
+		return blockErr
+	}
+	defer entry.Exit()
+	err := r.execute(ctx, r.opts.timeout)
+	if err != nil {
+		sea.TraceError(entry, err)
+	}
+	r.record(start, runResult(err), nil, err)
+	return err
+}
+
+func (r *Runner) execute(ctx context.Context, timeout time.Duration) (err error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Errorf("task %s panic: %v", r.name, p)
+		}
+	}()
+	return r.job(ctx)
+}
+
+func (r *Runner) record(start uint64, result string, blockErr *base.BlockError, err error) {
+	rec := Record{
+		Name:      r.name,
+		Resource:  r.opts.resourceName,
+		StartTime: start,
+		CostMs:    util.CurrentTimeMillis() - start,
+		Result:    result,
+	}
+	if blockErr != nil {
+		rec.BlockType = blockErr.BlockType().String()
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	r.history.add(rec)
+	runCounter.Add(1, r.name, result)
+}
+
+func runResult(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+func blockResult(blockErr *base.BlockError) string {
+	switch blockErr.BlockType() {
+	case base.BlockTypeIsolation:
+		return ResultSkipped
+	case base.BlockTypeCircuitBreaking:
+		return ResultDisabled
+	default:
+		return ResultBlocked
+	}
+}
@@ -0,0 +1,250 @@
+package task
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/isolation"
+	"github.com/liuhailove/gmiter/spi"
+	"github.com/liuhailove/gmiter/transport/common/command"
+)
+
+func initSea() {
+	conf := config.NewDefaultConfig()
+	conf.Conf.CloseAll = false
+	config.ResetGlobalConfig(conf)
+}
+
+func results(name string) []string {
+	var ret []string
+	for _, rec := range History(name) {
+		ret = append(ret, rec.Result)
+	}
+	return ret
+}
+
+func TestRunContext(t *testing.T) {
+	initSea()
+	fail := false
+	runner := Wrap("run", func(ctx context.Context) error {
+		if fail {
+			return errors.New("sync failed")
+		}
+		return nil
+	})
+	assert.Equal(t, "run", runner.Name())
+	assert.Nil(t, runner.RunContext(context.Background()))
+	fail = true
+	assert.EqualError(t, runner.RunContext(context.Background()), "sync failed")
+
+	history := History("run")
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, ResultError, history[0].Result)
+	assert.Equal(t, "sync failed", history[0].Error)
+	assert.Equal(t, ResultSuccess, history[1].Result)
+	assert.Equal(t, "task:run", history[1].Resource)
+
+	t.Run("Panic", func(t *testing.T) {
+		runner := Wrap("panic", func(ctx context.Context) error {
+			panic("boom")
+		})
+		assert.EqualError(t, runner.RunContext(context.Background()), "task panic panic: boom")
+		assert.Equal(t, []string{ResultError}, results("panic"))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		runner := Wrap("timeout", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, WithTimeout(10*time.Millisecond))
+		assert.Equal(t, context.DeadlineExceeded, runner.RunContext(context.Background()))
+	})
+}
+
+func TestNoOverlap(t *testing.T) {
+	initSea()
+	rule, err := NoOverlapRule("1", ResourceName("overlap"), false, 0)
+	assert.Nil(t, err)
+	_, err = isolation.LoadRules([]*isolation.Rule{rule})
+	assert.Nil(t, err)
+	defer isolation.ClearRules()
+
+	var (
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		wg       sync.WaitGroup
+		fallback string
+	)
+	runner := Wrap("overlap", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}, WithBlockFallback(func(name string, blockErr *base.BlockError) {
+		fallback = name
+	}))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Nil(t, runner.RunContext(context.Background()))
+	}()
+	<-started
+	// 上次执行未结束，本次执行被跳过
+	err = runner.RunContext(context.Background())
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeIsolation, blockErr.BlockType())
+	assert.Equal(t, "overlap", fallback)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []string{ResultSuccess, ResultSkipped}, results("overlap"))
+}
+
+// ttlTokenService 模拟RedisTokenService，Token超过规则的最长持有时间后被回收
+type ttlTokenService struct {
+	base.TokenService
+	mux      sync.Mutex
+	seq      int64
+	expireAt map[string]time.Time
+	ttlMs    []int64
+}
+
+func (s *ttlTokenService) RequestConcurrentToken(rule string, acquireCount uint32) *base.TokResult {
+	var r isolation.Rule
+	if err := jsoniter.Unmarshal([]byte(rule), &r); err != nil || r.ClusterConfig == nil {
+		return base.BadResult
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	for tokenId, expireAt := range s.expireAt {
+		if now.After(expireAt) {
+			delete(s.expireAt, tokenId)
+		}
+	}
+	if uint32(len(s.expireAt))+acquireCount > r.Threshold {
+		return base.BlockedResult
+	}
+	s.seq++
+	s.ttlMs = append(s.ttlMs, r.ClusterConfig.ClientOfflineTime)
+	s.expireAt[strconv.FormatInt(s.seq, 10)] = now.Add(time.Duration(r.ClusterConfig.ClientOfflineTime) * time.Millisecond)
+	return &base.TokResult{Status: int(base.TokResultStatusOk), TokenId: s.seq}
+}
+
+func (s *ttlTokenService) ReleaseConcurrentToken(rule string, tokenId string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.expireAt, tokenId)
+}
+
+type ttlTokenServiceInitFunc struct {
+	tokenService *ttlTokenService
+}
+
+func (f *ttlTokenServiceInitFunc) Initial() error             { return nil }
+func (f *ttlTokenServiceInitFunc) Order() int                 { return 1000 }
+func (f *ttlTokenServiceInitFunc) ImmediatelyLoadOnce() error { return nil }
+func (f *ttlTokenServiceInitFunc) GetRegisterType() constants.RegisterType {
+	return constants.RedisTokenServiceType
+}
+func (f *ttlTokenServiceInitFunc) GetTokenService() base.TokenService { return f.tokenService }
+func (f *ttlTokenServiceInitFunc) ReInitial() error                   { return nil }
+
+// 集群模式下Token的最长持有时间取任务的最长执行时间，执行时间会超过Token有效期的任务在超时后被取消，
+// 执行期间其他实例的执行被跳过
+func TestNoOverlapCluster(t *testing.T) {
+	initSea()
+	_, err := NoOverlapRule("2", ResourceName("clusterOverlap"), true, 0)
+	assert.NotNil(t, err)
+
+	tokenService := &ttlTokenService{expireAt: make(map[string]time.Time)}
+	spi.Register(&ttlTokenServiceInitFunc{tokenService: tokenService})
+	rule, err := NoOverlapRule("2", ResourceName("clusterOverlap"), true, 200*time.Millisecond)
+	assert.Nil(t, err)
+	_, err = isolation.LoadRules([]*isolation.Rule{rule})
+	assert.Nil(t, err)
+	defer isolation.ClearRules()
+
+	var (
+		started = make(chan struct{})
+		done    = make(chan error)
+	)
+	// 不设置超时时任务会一直执行，超过Token的有效期
+	runner := Wrap("clusterOverlap", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(100*time.Millisecond))
+	go func() {
+		done <- runner.RunContext(context.Background())
+	}()
+	<-started
+	// 模拟其他实例执行同一任务，集群Token未释放，本次执行被跳过
+	other := Wrap("clusterOverlap", func(ctx context.Context) error {
+		return nil
+	})
+	err = other.RunContext(context.Background())
+	blockErr, ok := err.(*base.BlockError)
+	assert.True(t, ok)
+	assert.Equal(t, base.BlockTypeIsolation, blockErr.BlockType())
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+	// Token已释放，其他实例可以执行
+	assert.Nil(t, other.RunContext(context.Background()))
+	assert.Equal(t, []int64{200, 200}, tokenService.ttlMs)
+	assert.Equal(t, []string{ResultSuccess, ResultError, ResultSkipped}, results("clusterOverlap"))
+}
+
+func TestCircuitBreakerDisable(t *testing.T) {
+	initSea()
+	_, err := circuitbreaker.LoadRules([]*circuitbreaker.Rule{{
+		Resource:         ResourceName("failing"),
+		Strategy:         circuitbreaker.ErrorCount,
+		RetryTimeoutMs:   60000,
+		MinRequestAmount: 1,
+		StatIntervalMs:   10000,
+		Threshold:        2,
+	}})
+	assert.Nil(t, err)
+	defer circuitbreaker.ClearRules()
+
+	calls := 0
+	runner := Wrap("failing", func(ctx context.Context) error {
+		calls++
+		return errors.New("failed")
+	})
+	for i := 0; i < 5; i++ {
+		_ = runner.RunContext(context.Background())
+	}
+	// 连续失败后任务被熔断停用
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, ResultDisabled, History("failing")[0].Result)
+}
+
+func TestHistory(t *testing.T) {
+	initSea()
+	runner := Wrap("ring", func(ctx context.Context) error {
+		return nil
+	}, WithHistorySize(2))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, runner.RunContext(context.Background()))
+	}
+	assert.Equal(t, 2, len(History("ring")))
+	assert.Nil(t, History("none"))
+	assert.Contains(t, Tasks(), "ring")
+
+	request := command.NewRequest()
+	assert.Nil(t, request.AddParam("name", "ring"))
+	response := taskHistoryCommandHandlerInst.Handle(*request)
+	assert.True(t, response.IsSuccess())
+	assert.Contains(t, response.GetResult(), `"ring":[{"name":"ring","resource":"task:ring"`)
+}
@@ -0,0 +1,446 @@
+package token_server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+const (
+	// DefaultPoolSize 每个TokenServer的连接数
+	DefaultPoolSize = 4
+	// DefaultMaxBatchSize 单次批量请求最多携带的Token请求数
+	DefaultMaxBatchSize = 128
+	// DefaultRequestTimeout 请求Token的超时时间，超时返回失败并由调用方降级
+	DefaultRequestTimeout = 100 * time.Millisecond
+	// DefaultConnectTimeout 建立连接的超时时间
+	DefaultConnectTimeout = time.Second
+	// DefaultReconnectInterval 建立连接失败后，间隔多久才允许再次建立连接
+	DefaultReconnectInterval = time.Second
+
+	pendingQueueSize = 4096
+)
+
+var (
+	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	errConnClosed = errors.New("token client connection closed")
+)
+
+// ClientOption TokenClient的配置项
+type ClientOption func(*TokenClient)
+
+// WithPoolSize 设置每个TokenServer的连接数
+func WithPoolSize(poolSize int) ClientOption {
+	return func(c *TokenClient) {
+		if poolSize > 0 {
+			c.poolSize = poolSize
+		}
+	}
+}
+
+// WithMaxBatchSize 设置单次批量请求最多携带的Token请求数，不超过MaxEntries
+func WithMaxBatchSize(maxBatchSize int) ClientOption {
+	return func(c *TokenClient) {
+		if maxBatchSize > MaxEntries {
+			maxBatchSize = MaxEntries
+		}
+		if maxBatchSize > 0 {
+			c.maxBatchSize = maxBatchSize
+		}
+	}
+}
+
+// WithRequestTimeout 设置请求Token的超时时间
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *TokenClient) {
+		if timeout > 0 {
+			c.requestTimeout = timeout
+		}
+	}
+}
+
+// WithClientSecret 设置与TokenServer之间的共享密钥，默认为config.TokenServerSecret()
+func WithClientSecret(secret string) ClientOption {
+	return func(c *TokenClient) {
+		c.secret = secret
+	}
+}
+
+// TokenClient 基于TCP长连接的flow.ClusterTokenClient实现，
+// 同一连接上并发的Token请求会被合并为一次批量请求
+type TokenClient struct {
+	poolSize       int
+	maxBatchSize   int
+	requestTimeout time.Duration
+	connectTimeout time.Duration
+	secret         string
+
+	// pools TokenServer地址 -> 连接池
+	pools sync.Map
+}
+
+// NewTokenClient 创建TokenClient
+func NewTokenClient(opts ...ClientOption) *TokenClient {
+	c := &TokenClient{
+		poolSize:       DefaultPoolSize,
+		maxBatchSize:   DefaultMaxBatchSize,
+		requestTimeout: DefaultRequestTimeout,
+		connectTimeout: DefaultConnectTimeout,
+		secret:         config.TokenServerSecret(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RequestToken 从TokenServer请求tokens。
+// 独立TokenServer模式下连接tokenServerIp:tokenServerPort，
+// 选主模式下tokenServerPort为master的HTTP端口，此时连接master内嵌TokenServer的config.TokenServerPort()。
+// 请求只携带规则ID及版本号，独立TokenServer缺少该版本的规则时先同步规则再重试一次
+func (c *TokenClient) RequestToken(tokenServerIp string, tokenServerPort int32, ruleId string, acquireCount uint32, prioritized int32) *base.TokResult {
+	if ruleId == "" || acquireCount <= 0 {
+		return base.BadResult
+	}
+	rule, ruleData, version := flow.ClusterRuleOf(ruleId)
+	if rule == nil || rule.ClusterConfig == nil {
+		return base.NoRuleExistsResult
+	}
+	var independent = flow.TokenServerStrategy(rule.ClusterConfig.TokenServerStrategy) == flow.TokenServerStrategyIndependentTokenServer
+	if !independent {
+		tokenServerPort = config.TokenServerPort()
+	}
+	var entry = &TokenRequest{
+		App:          appOf(rule),
+		Resource:     rule.Resource,
+		RuleId:       rule.ID,
+		Version:      version,
+		AcquireCount: acquireCount,
+		Prioritized:  prioritized,
+	}
+	// 自身即为master时直接使用内嵌TokenServer，不需要再经过网络
+	if server := getEmbeddedServer(); server != nil && tokenServerIp == util.GetIP() && tokenServerPort == config.TokenServerPort() {
+		return server.handleEntry(MsgTypeFlow, config.Namespace(), entry)
+	}
+	var address = net.JoinHostPort(tokenServerIp, strconv.Itoa(int(tokenServerPort)))
+	conn, err := c.poolOf(address).get()
+	if err != nil {
+		logging.Warn("[TokenClient] Fail to connect token server", "address", address, "err", err)
+		return base.FailResult
+	}
+	var result = conn.request(MsgTypeFlow, entry, c.requestTimeout)
+	if !independent || result.Status != int(base.TokResultStatusNoRuleExists) {
+		return result
+	}
+	var ruleSync = &TokenRequest{App: entry.App, Resource: entry.Resource, RuleId: entry.RuleId, Version: version, Rule: ruleData}
+	if syncResult := conn.request(MsgTypeRule, ruleSync, c.requestTimeout); syncResult.Status != int(base.TokResultStatusOk) {
+		logging.Warn("[TokenClient] Fail to sync rule to token server", "address", address, "ruleId", ruleId, "status", syncResult.Status)
+		return syncResult
+	}
+	return conn.request(MsgTypeFlow, entry, c.requestTimeout)
+}
+
+// appOf 规则所属的应用，规则未指定时为当前应用
+func appOf(rule *flow.Rule) string {
+	if rule.App != "" {
+		return rule.App
+	}
+	return config.AppName()
+}
+
+// Close 关闭所有连接
+func (c *TokenClient) Close() {
+	c.pools.Range(func(key, value interface{}) bool {
+		value.(*connPool).close()
+		c.pools.Delete(key)
+		return true
+	})
+}
+
+func (c *TokenClient) poolOf(address string) *connPool {
+	if pool, ok := c.pools.Load(address); ok {
+		return pool.(*connPool)
+	}
+	pool, _ := c.pools.LoadOrStore(address, &connPool{client: c, address: address, conns: make([]*clientConn, c.poolSize)})
+	return pool.(*connPool)
+}
+
+// connPool 单个TokenServer的连接池，连接断开后在下次使用时重建
+type connPool struct {
+	client  *TokenClient
+	address string
+	conns   []*clientConn
+	next    uint32
+	mux     sync.Mutex
+	// lastDialFailure 上次建立连接失败的时间(纳秒)，避免TokenServer不可用时每次请求都阻塞在建立连接上
+	lastDialFailure int64
+}
+
+func (p *connPool) get() (*clientConn, error) {
+	idx := int(atomic.AddUint32(&p.next, 1) % uint32(len(p.conns)))
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if conn := p.conns[idx]; conn != nil && !conn.closed.Get() {
+		return conn, nil
+	}
+	if p.lastDialFailure > 0 && time.Duration(util.CurrentTimeNano()-uint64(p.lastDialFailure)) < DefaultReconnectInterval {
+		return nil, errConnClosed
+	}
+	netConn, err := net.DialTimeout("tcp", p.address, p.client.connectTimeout)
+	if err != nil {
+		p.lastDialFailure = int64(util.CurrentTimeNano())
+		return nil, err
+	}
+	p.lastDialFailure = 0
+	conn := newClientConn(netConn, p.client.maxBatchSize, p.client.secret)
+	p.conns[idx] = conn
+	return conn, nil
+}
+
+func (p *connPool) close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, conn := range p.conns {
+		if conn != nil {
+			conn.close(errConnClosed)
+		}
+	}
+}
+
+// call 一次等待响应的Token请求
+type call struct {
+	// msgType 请求类型，同一批量中的请求类型相同
+	msgType uint8
+	entry   *TokenRequest
+	result  chan *base.TokResult
+	// xid 请求发送时所在批量的xid，canceled 请求已超时，二者均由pendingMux保护
+	xid      uint32
+	canceled bool
+}
+
+// clientConn 单个TCP连接，写协程合并队列中的请求批量发送，读协程按照xid分发响应
+type clientConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	writer       *bufio.Writer
+	maxBatchSize int
+	secret       string
+
+	queue chan *call
+	done  chan struct{}
+	// pending xid -> 等待响应的批量请求，已超时的请求置为nil
+	pending    map[uint32][]*call
+	pendingMux sync.Mutex
+	xid        uint32
+	closed     util.AtomicBool
+}
+
+func newClientConn(conn net.Conn, maxBatchSize int, secret string) *clientConn {
+	c := &clientConn{
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		writer:       bufio.NewWriter(conn),
+		maxBatchSize: maxBatchSize,
+		secret:       secret,
+		queue:        make(chan *call, pendingQueueSize),
+		done:         make(chan struct{}),
+		pending:      make(map[uint32][]*call),
+	}
+	go c.writeLoop()
+	go c.readLoop()
+	return c
+}
+
+func (c *clientConn) request(msgType uint8, entry *TokenRequest, timeout time.Duration) *base.TokResult {
+	cl := &call{msgType: msgType, entry: entry, result: make(chan *base.TokResult, 1)}
+	select {
+	case c.queue <- cl:
+	case <-c.done:
+		return base.FailResult
+	default:
+		// 队列已满，说明TokenServer处理不过来
+		return base.FailResult
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-cl.result:
+		return result
+	case <-timer.C:
+		c.cancel(cl)
+		return base.FailResult
+	}
+}
+
+// cancel 将超时的请求从等待列表中移除，批量中的请求全部超时后删除对应的xid，
+// 避免TokenServer无响应时等待列表无限增长
+func (c *clientConn) cancel(cl *call) {
+	c.pendingMux.Lock()
+	defer c.pendingMux.Unlock()
+	cl.canceled = true
+	calls, ok := c.pending[cl.xid]
+	if !ok {
+		return
+	}
+	var remaining = 0
+	for i, pending := range calls {
+		if pending == cl {
+			calls[i] = nil
+		} else if pending != nil {
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		delete(c.pending, cl.xid)
+	}
+}
+
+func (c *clientConn) writeLoop() {
+	batch := make([]*call, 0, c.maxBatchSize)
+	// carry 与上一批量类型不同的请求，放到下一批量中发送
+	var carry *call
+	for {
+		if carry != nil {
+			batch = append(batch[:0], carry)
+			carry = nil
+		} else {
+			select {
+			case cl := <-c.queue:
+				batch = append(batch[:0], cl)
+			case <-c.done:
+				return
+			}
+		}
+		// 合并队列中已有的同类型请求
+	drain:
+		for len(batch) < c.maxBatchSize {
+			select {
+			case cl := <-c.queue:
+				if cl.msgType != batch[0].msgType {
+					carry = cl
+					break drain
+				}
+				batch = append(batch, cl)
+			default:
+				break drain
+			}
+		}
+		calls := make([]*call, len(batch))
+		copy(calls, batch)
+		req := &Request{Type: calls[0].msgType, Namespace: config.Namespace(), Secret: c.secret, Entries: make([]*TokenRequest, 0, len(calls))}
+		for _, cl := range calls {
+			req.Entries = append(req.Entries, cl.entry)
+		}
+		c.pendingMux.Lock()
+		c.xid++
+		req.Xid = c.xid
+		var remaining = 0
+		for i, cl := range calls {
+			cl.xid = req.Xid
+			if cl.canceled {
+				// 在队列中已超时的请求不再等待响应
+				calls[i] = nil
+			} else {
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			c.pending[req.Xid] = calls
+		}
+		c.pendingMux.Unlock()
+
+		payload, err := encodeRequest(req)
+		if err != nil {
+			// 请求本身不合法，只让本批量的请求失败，连接继续使用
+			logging.Warn("[TokenClient] Encode request error", "address", c.conn.RemoteAddr().String(), "err", err)
+			c.fail(req.Xid)
+			continue
+		}
+		err = writeFrame(c.writer, payload)
+		if err == nil {
+			err = c.writer.Flush()
+		}
+		if err != nil {
+			logging.Warn("[TokenClient] Write request error, close connection", "address", c.conn.RemoteAddr().String(), "err", err)
+			c.close(err)
+			return
+		}
+	}
+}
+
+// fail 批量中等待响应的请求全部返回失败
+func (c *clientConn) fail(xid uint32) {
+	c.pendingMux.Lock()
+	calls := c.pending[xid]
+	delete(c.pending, xid)
+	c.pendingMux.Unlock()
+	for _, cl := range calls {
+		if cl != nil {
+			cl.result <- base.BadResult
+		}
+	}
+}
+
+func (c *clientConn) readLoop() {
+	for {
+		payload, err := readFrame(c.reader)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		resp, err := decodeResponse(payload)
+		if err != nil {
+			logging.Warn("[TokenClient] Decode response error, close connection", "address", c.conn.RemoteAddr().String(), "err", err)
+			c.close(err)
+			return
+		}
+		c.pendingMux.Lock()
+		calls := c.pending[resp.Xid]
+		delete(c.pending, resp.Xid)
+		c.pendingMux.Unlock()
+		for i, cl := range calls {
+			if cl == nil {
+				continue
+			}
+			if i < len(resp.Results) {
+				cl.result <- resp.Results[i]
+			} else {
+				cl.result <- base.FailResult
+			}
+		}
+	}
+}
+
+// close 关闭连接，等待中的请求全部返回失败
+func (c *clientConn) close(err error) {
+	if !c.closed.CompareAndSet(false, true) {
+		return
+	}
+	close(c.done)
+	_ = c.conn.Close()
+	c.pendingMux.Lock()
+	pending := c.pending
+	c.pending = make(map[uint32][]*call)
+	c.pendingMux.Unlock()
+	for _, calls := range pending {
+		for _, cl := range calls {
+			if cl != nil {
+				cl.result <- base.FailResult
+			}
+		}
+	}
+	logging.Debug("[TokenClient] Connection closed", "err", err)
+}
@@ -0,0 +1,18 @@
+// Package token_server 提供基于TCP长连接的集群流控TokenServer及客户端。
+//
+// 相比通过HTTP请求SimpleHttpCommandCenter的/acquireClusterToken，TokenServer使用长度前缀的二进制协议，
+// 客户端维护连接池并将并发的Token请求合并为批量请求发送。
+//
+// TokenServer支持两种运行模式：
+//   - 内嵌模式：选主产生的master应用调用StartEmbeddedServer启动，监听config.TokenServerPort()
+//   - 独立模式：单独部署，规则的TokenServerStrategy为TokenServerStrategyIndependentTokenServer，客户端连接TokenServerAddress
+//
+// Token请求只携带规则的应用、资源、ID及版本号。独立模式下TokenServer按照应用+资源+ID缓存客户端同步的规则，
+// 缺少规则或版本不一致时返回NoRuleExists，客户端通过MsgTypeRule同步规则json后重试。
+// 同步的规则以内部资源"应用|资源"加载，不同应用的同名资源及相同的规则ID使用各自的规则及集群统计。
+//
+// TokenServer只接受config.Namespace()命名空间的请求，配置了config.TokenServerSecret()时还要求客户端携带一致的密钥；
+// 独立模式下加载的客户端规则数受WithMaxRules限制，长时间没有请求的规则会被卸载。
+//
+// 使用时通过flow.SetClusterTokenClient(token_server.NewTokenClient())替换默认的HTTP客户端。
+package token_server
@@ -0,0 +1,26 @@
+package token_server
+
+import (
+	"github.com/liuhailove/gmiter/core/election"
+	"github.com/liuhailove/gmiter/logging"
+)
+
+// StartElection 启动选主，当前实例成为master时启动内嵌TokenServer，不再是master时停止
+func StartElection(backend election.Backend, opts ...election.Option) error {
+	return election.Start(backend, append(opts, election.WithListener(onRoleChange))...)
+}
+
+// StopElection 停止选主，自身为master时主动放弃并停止内嵌TokenServer
+func StopElection() {
+	election.Stop()
+}
+
+func onRoleChange(isMaster bool, master election.Member) {
+	if !isMaster {
+		StopEmbeddedServer()
+		return
+	}
+	if err := StartEmbeddedServer(); err != nil && err != ErrServerStarted {
+		logging.Error(err, "[TokenServer] Fail to start embedded token server after elected as master", "master", master.String())
+	}
+}
@@ -0,0 +1,21 @@
+package token_server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/election"
+)
+
+func TestStartElection(t *testing.T) {
+	self := election.Member{Host: "127.0.0.1", Port: 8719}
+	assert.Nil(t, StartElection(election.NewStaticBackend(self), election.WithSelf(self)))
+	// 成为master后启动内嵌TokenServer
+	assert.NotNil(t, getEmbeddedServer())
+	assert.True(t, election.GetElector().IsMaster())
+
+	StopElection()
+	assert.Nil(t, getEmbeddedServer())
+	assert.Nil(t, election.GetElector())
+}
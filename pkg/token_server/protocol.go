@@ -0,0 +1,267 @@
+package token_server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+// 帧格式：4字节大端长度 + 消息体
+//
+// 请求消息体：
+// xid(uint32) | type(uint8) | namespaceLen(uint16) | namespace | secretLen(uint16) | secret | count(uint16) |
+// count个 { acquireCount(uint32) | prioritized(int32) | appLen(uint16) | app | resourceLen(uint16) | resource |
+// ruleIdLen(uint16) | ruleId | version(uint64) | ruleLen(uint32) | rule }
+//
+// 响应消息体：
+// xid(uint32) | type(uint8) | count(uint16) | count个 { status(int32) | waitInMs(int32) | tokenId(int64) }
+//
+// 一个请求帧可以携带多个Token请求(批量)，响应按照请求中的顺序返回结果。
+// Token请求只携带规则的app、resource、ID及版本号，TokenServer缺少规则或版本不一致时返回NoRuleExists，
+// 客户端通过MsgTypeRule同步规则json后重试
+
+const (
+	// MsgTypeFlow 流控Token请求
+	MsgTypeFlow uint8 = 1
+	// MsgTypeRule 同步规则，Token请求中携带规则json，只有独立模式的TokenServer接受
+	MsgTypeRule uint8 = 2
+
+	// MaxFrameLength 单帧最大长度，超过则认为数据异常并关闭连接
+	MaxFrameLength = 4 * 1024 * 1024
+	// MaxEntries 单帧最多携带的Token请求数
+	MaxEntries = math.MaxUint16
+
+	frameHeaderLength = 4
+	resultLength      = 16
+)
+
+var (
+	ErrFrameTooLarge  = errors.New("token server frame too large")
+	ErrMalformedFrame = errors.New("malformed token server frame")
+	ErrTooManyEntries = errors.New("too many token requests in one frame")
+	ErrFieldTooLong   = errors.New("token server frame field too long")
+)
+
+// TokenRequest 单个Token请求
+type TokenRequest struct {
+	// App、Resource、RuleId 规则所属的应用、资源及规则ID，三者共同确定TokenServer中的规则
+	App      string
+	Resource string
+	RuleId   string
+	// Version 规则的版本号，见flow.RuleVersion
+	Version uint64
+	// Rule 规则json，只在MsgTypeRule时携带
+	Rule string
+	// AcquireCount 请求token的数量
+	AcquireCount uint32
+	// Prioritized 请求是否需要优先处理
+	Prioritized int32
+}
+
+// Request 一次批量请求
+type Request struct {
+	Xid       uint32
+	Type      uint8
+	Namespace string
+	// Secret 客户端与TokenServer之间的共享密钥
+	Secret  string
+	Entries []*TokenRequest
+}
+
+// Response 批量请求的响应，Results与请求的Entries一一对应
+type Response struct {
+	Xid     uint32
+	Type    uint8
+	Results []*base.TokResult
+}
+
+// writeFrame 写入长度前缀及消息体
+func writeFrame(w *bufio.Writer, payload []byte) error {
+	var header [frameHeaderLength]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame 读取一帧的消息体
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var header [frameHeaderLength]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length > MaxFrameLength {
+		return nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func encodeRequest(req *Request) ([]byte, error) {
+	if len(req.Entries) > MaxEntries {
+		return nil, ErrTooManyEntries
+	}
+	if len(req.Namespace) > math.MaxUint16 || len(req.Secret) > math.MaxUint16 {
+		return nil, ErrFieldTooLong
+	}
+	size := 4 + 1 + 2 + len(req.Namespace) + 2 + len(req.Secret) + 2
+	for _, entry := range req.Entries {
+		if len(entry.App) > math.MaxUint16 || len(entry.Resource) > math.MaxUint16 || len(entry.RuleId) > math.MaxUint16 {
+			return nil, ErrFieldTooLong
+		}
+		size += 4 + 4 + 2 + len(entry.App) + 2 + len(entry.Resource) + 2 + len(entry.RuleId) + 8 + 4 + len(entry.Rule)
+	}
+	if size > MaxFrameLength {
+		return nil, ErrFrameTooLarge
+	}
+	buf := make([]byte, 0, size)
+	buf = appendUint32(buf, req.Xid)
+	buf = append(buf, req.Type)
+	buf = appendString16(buf, req.Namespace)
+	buf = appendString16(buf, req.Secret)
+	buf = appendUint16(buf, uint16(len(req.Entries)))
+	for _, entry := range req.Entries {
+		buf = appendUint32(buf, entry.AcquireCount)
+		buf = appendUint32(buf, uint32(entry.Prioritized))
+		buf = appendString16(buf, entry.App)
+		buf = appendString16(buf, entry.Resource)
+		buf = appendString16(buf, entry.RuleId)
+		buf = appendUint64(buf, entry.Version)
+		buf = appendUint32(buf, uint32(len(entry.Rule)))
+		buf = append(buf, entry.Rule...)
+	}
+	return buf, nil
+}
+
+func decodeRequest(payload []byte) (*Request, error) {
+	d := decoder{buf: payload}
+	req := &Request{Xid: d.uint32(), Type: d.uint8()}
+	req.Namespace = string(d.bytes(int(d.uint16())))
+	req.Secret = string(d.bytes(int(d.uint16())))
+	count := int(d.uint16())
+	if d.err != nil {
+		return nil, d.err
+	}
+	req.Entries = make([]*TokenRequest, 0, count)
+	for i := 0; i < count; i++ {
+		entry := &TokenRequest{AcquireCount: d.uint32(), Prioritized: int32(d.uint32())}
+		entry.App = string(d.bytes(int(d.uint16())))
+		entry.Resource = string(d.bytes(int(d.uint16())))
+		entry.RuleId = string(d.bytes(int(d.uint16())))
+		entry.Version = d.uint64()
+		entry.Rule = string(d.bytes(int(d.uint32())))
+		if d.err != nil {
+			return nil, d.err
+		}
+		req.Entries = append(req.Entries, entry)
+	}
+	return req, nil
+}
+
+func encodeResponse(resp *Response) []byte {
+	buf := make([]byte, 0, 4+1+2+len(resp.Results)*resultLength)
+	buf = appendUint32(buf, resp.Xid)
+	buf = append(buf, resp.Type)
+	buf = appendUint16(buf, uint16(len(resp.Results)))
+	for _, result := range resp.Results {
+		buf = appendUint32(buf, uint32(int32(result.Status)))
+		buf = appendUint32(buf, uint32(int32(result.WaitInMs)))
+		buf = appendUint64(buf, uint64(result.TokenId))
+	}
+	return buf
+}
+
+func decodeResponse(payload []byte) (*Response, error) {
+	d := decoder{buf: payload}
+	resp := &Response{Xid: d.uint32(), Type: d.uint8()}
+	count := int(d.uint16())
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.buf) != count*resultLength {
+		return nil, ErrMalformedFrame
+	}
+	resp.Results = make([]*base.TokResult, 0, count)
+	for i := 0; i < count; i++ {
+		resp.Results = append(resp.Results, &base.TokResult{
+			Status:   int(int32(d.uint32())),
+			WaitInMs: int(int32(d.uint32())),
+			TokenId:  int64(d.uint64()),
+		})
+	}
+	return resp, nil
+}
+
+// decoder 顺序读取消息体，越界时记录错误并返回零值
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || n < 0 || len(d.buf) < n {
+		d.err = ErrMalformedFrame
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) bytes(n int) []byte {
+	return d.next(n)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+func appendString16(buf []byte, s string) []byte {
+	return append(appendUint16(buf, uint16(len(s))), s...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,81 @@
+package token_server
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+)
+
+func TestRequestCodec(t *testing.T) {
+	req := &Request{
+		Xid:       7,
+		Type:      MsgTypeFlow,
+		Namespace: "ns",
+		Secret:    "secret",
+		Entries: []*TokenRequest{
+			{App: "app", Resource: "res", RuleId: "1", Version: 11, AcquireCount: 1, Prioritized: 0},
+			{App: "app", Resource: "res", RuleId: "2", Version: 12, Rule: `{"id":"2"}`, AcquireCount: 3, Prioritized: 1},
+		},
+	}
+	payload, err := encodeRequest(req)
+	assert.Nil(t, err)
+	decoded, err := decodeRequest(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, req, decoded)
+
+	// 截断的消息体
+	_, err = decodeRequest(payload[:len(payload)-1])
+	assert.Equal(t, ErrMalformedFrame, err)
+
+	// 请求数超过uint16范围
+	req.Entries = make([]*TokenRequest, MaxEntries+1)
+	_, err = encodeRequest(req)
+	assert.Equal(t, ErrTooManyEntries, err)
+
+	req.Entries = []*TokenRequest{{RuleId: strings.Repeat("a", MaxEntries+1)}}
+	_, err = encodeRequest(req)
+	assert.Equal(t, ErrFieldTooLong, err)
+}
+
+func TestResponseCodec(t *testing.T) {
+	resp := &Response{
+		Xid:  7,
+		Type: MsgTypeFlow,
+		Results: []*base.TokResult{
+			{Status: int(base.TokResultStatusOk)},
+			{Status: int(base.TokResultStatusShouldWait), WaitInMs: 20},
+			{Status: int(base.TokResultStatusBadRequest), TokenId: 9},
+		},
+	}
+	decoded, err := decodeResponse(encodeResponse(resp))
+	assert.Nil(t, err)
+	assert.Equal(t, resp, decoded)
+
+	_, err = decodeResponse(encodeResponse(resp)[:10])
+	assert.Equal(t, ErrMalformedFrame, err)
+}
+
+func TestFrame(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	assert.Nil(t, writeFrame(w, []byte("abc")))
+	assert.Nil(t, writeFrame(w, []byte{}))
+	assert.Nil(t, w.Flush())
+
+	r := bufio.NewReader(&buf)
+	payload, err := readFrame(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("abc"), payload)
+	payload, err = readFrame(r)
+	assert.Nil(t, err)
+	assert.Empty(t, payload)
+
+	r = bufio.NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	_, err = readFrame(r)
+	assert.Equal(t, ErrFrameTooLarge, err)
+}
@@ -0,0 +1,463 @@
+package token_server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
+)
+
+// Mode TokenServer的运行模式
+type Mode int32
+
+const (
+	// ModeEmbedded 内嵌模式，TokenServer运行在选主产生的master应用进程中，使用应用自身加载的规则
+	ModeEmbedded Mode = iota
+	// ModeStandalone 独立模式，TokenServer单独部署(TokenServerStrategyIndependentTokenServer)，
+	// 规则由客户端通过MsgTypeRule同步，Token请求只携带规则ID及版本号
+	ModeStandalone
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeEmbedded:
+		return "Embedded"
+	case ModeStandalone:
+		return "Standalone"
+	default:
+		return "Undefined"
+	}
+}
+
+const (
+	// DefaultIdleTimeout 连接空闲超时时间，超时未收到请求则关闭连接
+	DefaultIdleTimeout = 10 * time.Minute
+	// DefaultMaxRules 独立模式下最多加载的客户端规则数
+	DefaultMaxRules = 1000
+	// DefaultRuleExpiration 独立模式下客户端规则的过期时间，超过该时间没有请求的规则会被卸载
+	DefaultRuleExpiration = 10 * time.Minute
+)
+
+var (
+	ErrServerStarted = errors.New("token server already started")
+
+	embeddedServer *TokenServer
+	embeddedMux    = new(sync.Mutex)
+)
+
+// TokenServer 基于TCP长连接的TokenServer
+type TokenServer struct {
+	addr         string
+	mode         Mode
+	tokenService base.TokenService
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	connsMux sync.Mutex
+	closed   util.AtomicBool
+	wg       sync.WaitGroup
+
+	// secret 共享密钥，为空时不校验
+	secret string
+	// namespaces 允许访问的命名空间，为空时不校验
+	namespaces map[string]struct{}
+	// maxRules、ruleExpiration 独立模式下加载的规则数上限及过期时间
+	maxRules       int
+	ruleExpiration time.Duration
+
+	// rules 独立模式下已加载的规则，ruleKey(app, resource, ruleId) -> 按应用隔离后的规则
+	rules    map[string]*loadedRule
+	rulesMux sync.RWMutex
+}
+
+// loadedRule 独立模式下由客户端同步并加载的规则
+type loadedRule struct {
+	key     string
+	rule    *flow.Rule
+	json    string
+	version uint64
+	// lastAccessMs 最近一次请求的时间，原子读写
+	lastAccessMs uint64
+}
+
+// ruleKey 独立模式下规则的唯一标识，不同应用的规则ID可能相同
+func ruleKey(app, resource, ruleId string) string {
+	return app + "|" + resource + "|" + ruleId
+}
+
+// appResource 独立模式下应用资源在规则管理器中的内部资源名称
+func appResource(app, resource string) string {
+	return app + "|" + resource
+}
+
+// appRule 独立模式下按应用隔离规则：规则以内部资源app|resource及ID ruleKey(app, resource, ruleId)加载，
+// 不同应用的同名资源使用各自的集群统计，相同的规则ID也不会互相覆盖
+func appRule(app string, rule *flow.Rule) *flow.Rule {
+	var r = *rule
+	r.ID = ruleKey(app, rule.Resource, rule.ID)
+	r.Resource = appResource(app, rule.Resource)
+	if r.RelationStrategy == flow.AssociatedResource {
+		r.RefResource = appResource(app, rule.RefResource)
+	}
+	return &r
+}
+
+// ServerOption TokenServer的配置项
+type ServerOption func(*TokenServer)
+
+// WithServerSecret 设置共享密钥，默认为config.TokenServerSecret()
+func WithServerSecret(secret string) ServerOption {
+	return func(s *TokenServer) {
+		s.secret = secret
+	}
+}
+
+// WithAllowedNamespaces 设置允许访问的命名空间，默认只允许config.Namespace()
+func WithAllowedNamespaces(namespaces ...string) ServerOption {
+	return func(s *TokenServer) {
+		s.namespaces = make(map[string]struct{}, len(namespaces))
+		for _, ns := range namespaces {
+			s.namespaces[ns] = struct{}{}
+		}
+	}
+}
+
+// WithMaxRules 设置独立模式下最多加载的客户端规则数
+func WithMaxRules(maxRules int) ServerOption {
+	return func(s *TokenServer) {
+		if maxRules > 0 {
+			s.maxRules = maxRules
+		}
+	}
+}
+
+// WithRuleExpiration 设置独立模式下客户端规则的过期时间
+func WithRuleExpiration(expiration time.Duration) ServerOption {
+	return func(s *TokenServer) {
+		if expiration > 0 {
+			s.ruleExpiration = expiration
+		}
+	}
+}
+
+// NewTokenServer 创建TokenServer，tokenService为空时使用flow的默认实现
+func NewTokenServer(addr string, mode Mode, tokenService base.TokenService, opts ...ServerOption) *TokenServer {
+	if tokenService == nil {
+		tokenService = flow.NewDefaultTokenService()
+	}
+	s := &TokenServer{
+		addr:           addr,
+		mode:           mode,
+		tokenService:   tokenService,
+		conns:          make(map[net.Conn]struct{}),
+		secret:         config.TokenServerSecret(),
+		namespaces:     map[string]struct{}{config.Namespace(): {}},
+		maxRules:       DefaultMaxRules,
+		ruleExpiration: DefaultRuleExpiration,
+		rules:          make(map[string]*loadedRule),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// StartEmbeddedServer 在当前应用进程中启动内嵌TokenServer，监听config.TokenServerPort()
+func StartEmbeddedServer() error {
+	embeddedMux.Lock()
+	defer embeddedMux.Unlock()
+	if embeddedServer != nil {
+		return ErrServerStarted
+	}
+	server := NewTokenServer(":"+strconv.Itoa(int(config.TokenServerPort())), ModeEmbedded, nil)
+	if err := server.Start(); err != nil {
+		return err
+	}
+	embeddedServer = server
+	return nil
+}
+
+// StopEmbeddedServer 停止内嵌TokenServer
+func StopEmbeddedServer() {
+	embeddedMux.Lock()
+	defer embeddedMux.Unlock()
+	if embeddedServer != nil {
+		embeddedServer.Stop()
+		embeddedServer = nil
+	}
+}
+
+func getEmbeddedServer() *TokenServer {
+	embeddedMux.Lock()
+	defer embeddedMux.Unlock()
+	return embeddedServer
+}
+
+// Start 监听地址并开始处理连接
+func (s *TokenServer) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	s.wg.Add(1)
+	go s.serve()
+	logging.Info("[TokenServer] Token server started", "addr", ln.Addr().String(), "mode", s.mode.String())
+	return nil
+}
+
+// Addr 实际监听的地址
+func (s *TokenServer) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop 关闭监听及所有连接
+func (s *TokenServer) Stop() {
+	if !s.closed.CompareAndSet(false, true) {
+		return
+	}
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	s.connsMux.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMux.Unlock()
+	s.wg.Wait()
+	logging.Info("[TokenServer] Token server stopped", "addr", s.addr)
+}
+
+func (s *TokenServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.closed.Get() {
+				return
+			}
+			logging.Warn("[TokenServer] Accept error", "err", err)
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		s.connsMux.Lock()
+		if s.closed.Get() {
+			s.connsMux.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.connsMux.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+func (s *TokenServer) handleConn(conn net.Conn) {
+	defer func() {
+		if err := recover(); err != nil {
+			logging.Error(errors.New("panic"), "[TokenServer] Panic in handleConn()", "err", err)
+		}
+		_ = conn.Close()
+		s.connsMux.Lock()
+		delete(s.conns, conn)
+		s.connsMux.Unlock()
+		s.wg.Done()
+	}()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(DefaultIdleTimeout))
+		payload, err := readFrame(reader)
+		if err != nil {
+			if err != io.EOF && !s.closed.Get() {
+				logging.Warn("[TokenServer] Read frame error, close connection", "remote", conn.RemoteAddr().String(), "err", err)
+			}
+			return
+		}
+		req, err := decodeRequest(payload)
+		if err != nil {
+			logging.Warn("[TokenServer] Decode request error, close connection", "remote", conn.RemoteAddr().String(), "err", err)
+			return
+		}
+		if !s.isAuthorized(req) {
+			// 未授权的连接直接关闭，不再处理后续请求
+			logging.Warn("[TokenServer] Unauthorized request, close connection", "remote", conn.RemoteAddr().String(), "namespace", req.Namespace)
+			if err = writeFrame(writer, encodeResponse(rejectAll(req))); err == nil {
+				_ = writer.Flush()
+			}
+			return
+		}
+		resp := s.handle(req)
+		if err = writeFrame(writer, encodeResponse(resp)); err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			logging.Warn("[TokenServer] Write response error, close connection", "remote", conn.RemoteAddr().String(), "err", err)
+			return
+		}
+	}
+}
+
+// isAuthorized 校验请求的密钥及命名空间
+func (s *TokenServer) isAuthorized(req *Request) bool {
+	if len(s.secret) > 0 && subtle.ConstantTimeCompare([]byte(s.secret), []byte(req.Secret)) != 1 {
+		return false
+	}
+	if len(s.namespaces) > 0 {
+		if _, ok := s.namespaces[req.Namespace]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rejectAll 对批量请求中的每个Token请求返回BadResult
+func rejectAll(req *Request) *Response {
+	resp := &Response{Xid: req.Xid, Type: req.Type, Results: make([]*base.TokResult, 0, len(req.Entries))}
+	for range req.Entries {
+		resp.Results = append(resp.Results, base.BadResult)
+	}
+	return resp
+}
+
+// handle 处理一次批量请求，每个Token请求都经过命名空间的GlobalTryPass保护
+func (s *TokenServer) handle(req *Request) *Response {
+	resp := &Response{Xid: req.Xid, Type: req.Type, Results: make([]*base.TokResult, 0, len(req.Entries))}
+	for _, entry := range req.Entries {
+		resp.Results = append(resp.Results, s.handleEntry(req.Type, req.Namespace, entry))
+	}
+	return resp
+}
+
+func (s *TokenServer) handleEntry(msgType uint8, namespace string, entry *TokenRequest) *base.TokResult {
+	switch msgType {
+	case MsgTypeFlow:
+		if !flow.GlobalTryPass(namespace) {
+			return base.TooManyRequestResult
+		}
+		rule, ruleData, ok := s.ruleOf(entry)
+		if !ok {
+			return base.NoRuleExistsResult
+		}
+		return flow.RequestTokenWithRule(s.tokenService, rule, ruleData, entry.AcquireCount, entry.Prioritized)
+	case MsgTypeRule:
+		if s.mode != ModeStandalone || !s.loadRule(entry) {
+			return base.BadResult
+		}
+		return base.StatusOkResult
+	default:
+		return base.BadResult
+	}
+}
+
+// ruleOf 查找Token请求对应的规则。内嵌模式下使用应用自身加载的规则；
+// 独立模式下使用客户端同步的规则，不存在或版本不一致时需要客户端重新同步
+func (s *TokenServer) ruleOf(entry *TokenRequest) (*flow.Rule, string, bool) {
+	if s.mode != ModeStandalone {
+		rule, ruleData, _ := flow.ClusterRuleOf(entry.RuleId)
+		return rule, ruleData, rule != nil
+	}
+	s.rulesMux.RLock()
+	loaded, ok := s.rules[ruleKey(entry.App, entry.Resource, entry.RuleId)]
+	s.rulesMux.RUnlock()
+	if !ok || loaded.version != entry.Version {
+		return nil, "", false
+	}
+	atomic.StoreUint64(&loaded.lastAccessMs, util.CurrentTimeMillis())
+	return loaded.rule, loaded.json, true
+}
+
+// loadRule 独立模式下将客户端同步的规则按应用隔离后加载到规则管理器，以便建立集群统计。
+// 加载的规则数不超过maxRules，超过ruleExpiration没有请求的规则在加载新规则时卸载
+func (s *TokenServer) loadRule(entry *TokenRequest) bool {
+	var now = util.CurrentTimeMillis()
+	var key = ruleKey(entry.App, entry.Resource, entry.RuleId)
+	var version = flow.RuleVersion(entry.Rule)
+	s.rulesMux.RLock()
+	loaded, ok := s.rules[key]
+	s.rulesMux.RUnlock()
+	if ok && loaded.version == version {
+		atomic.StoreUint64(&loaded.lastAccessMs, now)
+		return true
+	}
+	var rule = new(flow.Rule)
+	if err := jsonTraffic.Unmarshal([]byte(entry.Rule), rule); err != nil || rule.ID == "" ||
+		rule.ID != entry.RuleId || rule.Resource != entry.Resource {
+		return false
+	}
+	rule = appRule(entry.App, rule)
+	s.rulesMux.Lock()
+	defer s.rulesMux.Unlock()
+	if old, ok := s.rules[key]; ok && old.version == version {
+		atomic.StoreUint64(&old.lastAccessMs, now)
+		return true
+	}
+	if _, ok := s.rules[key]; !ok && len(s.rules) >= s.maxRules {
+		s.unloadExpiredRules(now)
+		if len(s.rules) >= s.maxRules {
+			logging.Warn("[TokenServer] Too many rules loaded from clients, reject new rule", "ruleId", rule.ID, "maxRules", s.maxRules)
+			return false
+		}
+	}
+	var rules = make([]*flow.Rule, 0)
+	for _, r := range flow.GetRulesOfResource(rule.Resource) {
+		if r.ID != rule.ID {
+			var old = r
+			rules = append(rules, &old)
+		}
+	}
+	rules = append(rules, rule)
+	if _, err := flow.LoadRulesOfResource(rule.Resource, rules); err != nil {
+		logging.Warn("[TokenServer] Fail to load rule from client", "rule", entry.Rule, "err", err)
+		return false
+	}
+	s.rules[key] = &loadedRule{key: key, rule: rule, json: entry.Rule, version: version, lastAccessMs: now}
+	return true
+}
+
+// unloadExpiredRules 卸载过期的规则，调用方需持有rulesMux写锁
+func (s *TokenServer) unloadExpiredRules(now uint64) {
+	var expired = make(map[string][]*loadedRule)
+	for _, r := range s.rules {
+		if now-atomic.LoadUint64(&r.lastAccessMs) < uint64(s.ruleExpiration.Milliseconds()) {
+			continue
+		}
+		expired[r.rule.Resource] = append(expired[r.rule.Resource], r)
+	}
+	for resource, loadedRules := range expired {
+		var ids = make([]string, 0, len(loadedRules))
+		for _, r := range loadedRules {
+			ids = append(ids, r.rule.ID)
+		}
+		var rules = make([]*flow.Rule, 0)
+		for _, r := range flow.GetRulesOfResource(resource) {
+			if !util.Contains(r.ID, ids) {
+				var old = r
+				rules = append(rules, &old)
+			}
+		}
+		if _, err := flow.LoadRulesOfResource(resource, rules); err != nil {
+			logging.Warn("[TokenServer] Fail to unload expired rules", "resource", resource, "ruleIds", ids, "err", err)
+			continue
+		}
+		for _, r := range loadedRules {
+			delete(s.rules, r.key)
+		}
+		logging.Info("[TokenServer] Unload expired rules", "resource", resource, "ruleIds", ids)
+	}
+}
@@ -0,0 +1,336 @@
+package token_server
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+)
+
+// fakeTokenService 按照请求的token数返回不同的结果
+type fakeTokenService struct {
+	base.TokenService
+	mux   sync.Mutex
+	count int
+}
+
+func (f *fakeTokenService) RequestToken(rule string, acquireCount uint32, prioritized int32) *base.TokResult {
+	f.mux.Lock()
+	f.count++
+	f.mux.Unlock()
+	switch acquireCount {
+	case 1:
+		return base.StatusOkResult
+	case 2:
+		return &base.TokResult{Status: int(base.TokResultStatusShouldWait), WaitInMs: 50}
+	default:
+		return base.BlockedResult
+	}
+}
+
+func newClusterRule(id string, address string) *flow.Rule {
+	return &flow.Rule{
+		ID:                     id,
+		Resource:               "token-server-" + id,
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              100,
+		StatIntervalInMs:       1000,
+		ClusterMode:            true,
+		ClusterConfig: &flow.ClusterConfig{
+			ClusterStrategy:     int32(flow.ThresholdGlobal),
+			GlobalThreshold:     100,
+			TokenServerStrategy: int32(flow.TokenServerStrategyIndependentTokenServer),
+			TokenServerAddress:  address,
+		},
+	}
+}
+
+func TestTokenClient_RequestToken(t *testing.T) {
+	tokenService := &fakeTokenService{}
+	server := NewTokenServer("127.0.0.1:0", ModeEmbedded, tokenService)
+	assert.Nil(t, server.Start())
+	defer server.Stop()
+
+	addr := server.Addr().(*net.TCPAddr)
+	_, err := flow.LoadRules([]*flow.Rule{newClusterRule("tcp-1", addr.String())})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	client := NewTokenClient(WithPoolSize(2))
+	defer client.Close()
+
+	assert.Equal(t, base.NoRuleExistsResult, client.RequestToken("127.0.0.1", int32(addr.Port), "absent", 1, 0))
+	assert.Equal(t, base.BadResult, client.RequestToken("127.0.0.1", int32(addr.Port), "tcp-1", 0, 0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func(acquireCount uint32) {
+			defer wg.Done()
+			result := client.RequestToken("127.0.0.1", int32(addr.Port), "tcp-1", acquireCount, 0)
+			switch acquireCount {
+			case 1:
+				assert.Equal(t, int(base.TokResultStatusOk), result.Status)
+			case 2:
+				assert.Equal(t, int(base.TokResultStatusShouldWait), result.Status)
+				assert.Equal(t, 50, result.WaitInMs)
+			default:
+				assert.Equal(t, int(base.TokResultStatusBlocked), result.Status)
+			}
+		}(uint32(i%3 + 1))
+	}
+	wg.Wait()
+	assert.Equal(t, 300, tokenService.count)
+
+	t.Run("ServerDown", func(t *testing.T) {
+		server.Stop()
+		assert.Equal(t, base.FailResult, client.RequestToken("127.0.0.1", int32(addr.Port), "tcp-1", 1, 0))
+	})
+}
+
+func TestClientConn_Batch(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	defer serverSide.Close()
+	conn := &clientConn{
+		conn:         clientSide,
+		reader:       bufio.NewReader(clientSide),
+		writer:       bufio.NewWriter(clientSide),
+		maxBatchSize: 2,
+		queue:        make(chan *call, 4),
+		done:         make(chan struct{}),
+		pending:      make(map[uint32][]*call),
+	}
+	defer conn.close(errConnClosed)
+
+	calls := make([]*call, 0, 3)
+	for i := 1; i <= 3; i++ {
+		cl := &call{msgType: MsgTypeFlow, entry: &TokenRequest{RuleId: "r", AcquireCount: uint32(i)}, result: make(chan *base.TokResult, 1)}
+		conn.queue <- cl
+		calls = append(calls, cl)
+	}
+	go conn.writeLoop()
+	go conn.readLoop()
+
+	// 队列中的请求按照最大批量合并发送
+	reader := bufio.NewReader(serverSide)
+	writer := bufio.NewWriter(serverSide)
+	for _, expected := range [][]uint32{{1, 2}, {3}} {
+		payload, err := readFrame(reader)
+		assert.Nil(t, err)
+		req, err := decodeRequest(payload)
+		assert.Nil(t, err)
+		assert.Equal(t, config.Namespace(), req.Namespace)
+		resp := &Response{Xid: req.Xid, Type: req.Type}
+		for i, entry := range req.Entries {
+			assert.Equal(t, expected[i], entry.AcquireCount)
+			resp.Results = append(resp.Results, &base.TokResult{Status: int(base.TokResultStatusShouldWait), WaitInMs: int(entry.AcquireCount)})
+		}
+		assert.Nil(t, writeFrame(writer, encodeResponse(resp)))
+		assert.Nil(t, writer.Flush())
+	}
+	for i, cl := range calls {
+		select {
+		case result := <-cl.result:
+			assert.Equal(t, i+1, result.WaitInMs)
+		case <-time.After(time.Second):
+			t.Fatal("response not delivered")
+		}
+	}
+}
+
+func TestClientConn_RequestTimeout(t *testing.T) {
+	clientSide, serverSide := net.Pipe()
+	defer serverSide.Close()
+	conn := newClientConn(clientSide, 2, "")
+	defer conn.close(errConnClosed)
+
+	// TokenServer读取请求后不响应，超时的请求从等待列表中移除
+	reader := bufio.NewReader(serverSide)
+	go func() {
+		for {
+			if _, err := readFrame(reader); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, base.FailResult, conn.request(MsgTypeFlow, &TokenRequest{RuleId: "r", AcquireCount: 1}, 20*time.Millisecond))
+	}
+	conn.pendingMux.Lock()
+	assert.Empty(t, conn.pending)
+	conn.pendingMux.Unlock()
+}
+
+func TestTokenServer_GlobalTryPass(t *testing.T) {
+	server := NewTokenServer("127.0.0.1:0", ModeEmbedded, &fakeTokenService{})
+	assert.Nil(t, flow.GlobalInitIfAbsent("token-server-ns"))
+	assert.Nil(t, flow.ApplyGlobalMaxQpsChange(2))
+	defer flow.ApplyGlobalMaxQpsChange(config.DefaultMaxAllowQps)
+	_, err := flow.LoadRules([]*flow.Rule{newClusterRule("r", "127.0.0.1:18730")})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	resp := server.handle(&Request{Xid: 1, Type: MsgTypeFlow, Namespace: "token-server-ns", Entries: []*TokenRequest{
+		{RuleId: "r", AcquireCount: 1}, {RuleId: "r", AcquireCount: 1}, {RuleId: "r", AcquireCount: 1},
+	}})
+	assert.Equal(t, uint32(1), resp.Xid)
+	assert.Equal(t, []*base.TokResult{base.StatusOkResult, base.StatusOkResult, base.TooManyRequestResult}, resp.Results)
+
+	resp = server.handle(&Request{Xid: 2, Type: 0, Namespace: "other-ns", Entries: []*TokenRequest{{RuleId: "r", AcquireCount: 1}}})
+	assert.Equal(t, []*base.TokResult{base.BadResult}, resp.Results)
+
+	// 内嵌模式使用应用自身的规则，不接受同步
+	resp = server.handle(&Request{Xid: 3, Type: MsgTypeRule, Namespace: "token-server-ns", Entries: []*TokenRequest{{RuleId: "r", Rule: "{}"}}})
+	assert.Equal(t, []*base.TokResult{base.BadResult}, resp.Results)
+}
+
+// syncEntry 构造同步规则的请求
+func syncEntry(t *testing.T, app string, rule *flow.Rule) *TokenRequest {
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+	return &TokenRequest{App: app, Resource: rule.Resource, RuleId: rule.ID, Version: flow.RuleVersion(string(data)), Rule: string(data)}
+}
+
+// flowEntry 构造与同步请求对应的Token请求
+func flowEntry(sync *TokenRequest) *TokenRequest {
+	return &TokenRequest{App: sync.App, Resource: sync.Resource, RuleId: sync.RuleId, Version: sync.Version, AcquireCount: 1}
+}
+
+func TestTokenServer_Standalone(t *testing.T) {
+	defer flow.ClearRules()
+	tokenService := &fakeTokenService{}
+	server := NewTokenServer("127.0.0.1:0", ModeStandalone, tokenService)
+
+	rule := newClusterRule("standalone-1", "127.0.0.1:18730")
+	sync := syncEntry(t, "app-a", rule)
+	// 未同步的规则需要客户端先同步
+	assert.Equal(t, base.NoRuleExistsResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(sync)))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeRule, "ns", sync))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(sync)))
+	// 同步的规则被加载，以便建立集群统计
+	loaded := flow.GetRuleOfId(ruleKey("app-a", rule.Resource, "standalone-1"))
+	assert.NotNil(t, loaded)
+	assert.Equal(t, float64(100), loaded.ClusterConfig.GlobalThreshold)
+
+	// 规则变化后，旧版本的请求需要重新同步
+	rule.ClusterConfig.GlobalThreshold = 10
+	newSync := syncEntry(t, "app-a", rule)
+	assert.NotEqual(t, sync.Version, newSync.Version)
+	assert.Equal(t, base.NoRuleExistsResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(newSync)))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeRule, "ns", newSync))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(newSync)))
+	assert.Equal(t, float64(10), flow.GetRuleOfId(ruleKey("app-a", rule.Resource, "standalone-1")).ClusterConfig.GlobalThreshold)
+	assert.Equal(t, base.NoRuleExistsResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(sync)))
+
+	// 不同应用的同名规则分别缓存
+	assert.Equal(t, base.NoRuleExistsResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncEntry(t, "app-b", rule))))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeRule, "ns", syncEntry(t, "app-b", rule)))
+	assert.Len(t, server.rules, 2)
+
+	// 规则json与请求不一致时拒绝同步
+	assert.Equal(t, base.BadResult, server.handleEntry(MsgTypeRule, "ns", &TokenRequest{RuleId: "standalone-1", Resource: rule.Resource, Rule: "{"}))
+	bad := syncEntry(t, "app-a", rule)
+	bad.RuleId = "other"
+	assert.Equal(t, base.BadResult, server.handleEntry(MsgTypeRule, "ns", bad))
+	assert.Equal(t, 2, tokenService.count)
+}
+
+// 不同应用的同名资源及相同的规则ID使用各自的规则及集群统计
+func TestTokenServer_StandaloneAppIsolation(t *testing.T) {
+	defer flow.ClearRules()
+	server := NewTokenServer("127.0.0.1:0", ModeStandalone, flow.NewDefaultTokenService())
+
+	ruleA := newClusterRule("shared", "127.0.0.1:18730")
+	ruleA.ClusterConfig.GlobalThreshold = 1
+	ruleB := newClusterRule("shared", "127.0.0.1:18730")
+	ruleB.ClusterConfig.GlobalThreshold = 2
+	syncA, syncB := syncEntry(t, "app-a", ruleA), syncEntry(t, "app-b", ruleB)
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeRule, "ns", syncA))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeRule, "ns", syncB))
+	assert.Equal(t, float64(1), flow.GetRuleOfId(ruleKey("app-a", ruleA.Resource, "shared")).ClusterConfig.GlobalThreshold)
+	assert.Equal(t, float64(2), flow.GetRuleOfId(ruleKey("app-b", ruleB.Resource, "shared")).ClusterConfig.GlobalThreshold)
+
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncA)))
+	assert.Equal(t, base.BlockedResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncA)))
+	// app-a的请求不占用app-b的阈值
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncB)))
+	assert.Equal(t, base.StatusOkResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncB)))
+	assert.Equal(t, base.BlockedResult, server.handleEntry(MsgTypeFlow, "ns", flowEntry(syncB)))
+}
+
+func TestTokenClient_StandaloneSync(t *testing.T) {
+	tokenService := &fakeTokenService{}
+	server := NewTokenServer("127.0.0.1:0", ModeStandalone, tokenService)
+	assert.Nil(t, server.Start())
+	defer server.Stop()
+
+	addr := server.Addr().(*net.TCPAddr)
+	_, err := flow.LoadRules([]*flow.Rule{newClusterRule("sync-1", addr.String())})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	client := NewTokenClient()
+	defer client.Close()
+	// 首次请求时同步规则，之后只携带规则ID及版本号
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, base.StatusOkResult, client.RequestToken("127.0.0.1", int32(addr.Port), "sync-1", 1, 0))
+	}
+	assert.Equal(t, 3, tokenService.count)
+	server.rulesMux.RLock()
+	assert.Len(t, server.rules, 1)
+	server.rulesMux.RUnlock()
+}
+
+func TestTokenServer_Authorization(t *testing.T) {
+	server := NewTokenServer("127.0.0.1:0", ModeEmbedded, &fakeTokenService{}, WithServerSecret("s1"))
+	assert.Nil(t, server.Start())
+	defer server.Stop()
+
+	addr := server.Addr().(*net.TCPAddr)
+	_, err := flow.LoadRules([]*flow.Rule{newClusterRule("auth-1", addr.String())})
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	client := NewTokenClient(WithClientSecret("s1"))
+	defer client.Close()
+	assert.Equal(t, base.StatusOkResult, client.RequestToken("127.0.0.1", int32(addr.Port), "auth-1", 1, 0))
+
+	// 密钥不一致时拒绝请求
+	badClient := NewTokenClient(WithClientSecret("s2"))
+	defer badClient.Close()
+	assert.Equal(t, base.BadResult, badClient.RequestToken("127.0.0.1", int32(addr.Port), "auth-1", 1, 0))
+
+	// 命名空间不在允许范围内时拒绝请求
+	assert.True(t, server.isAuthorized(&Request{Namespace: config.Namespace(), Secret: "s1"}))
+	assert.False(t, server.isAuthorized(&Request{Namespace: "other-ns", Secret: "s1"}))
+}
+
+func TestTokenServer_StandaloneRuleLimit(t *testing.T) {
+	defer flow.ClearRules()
+	server := NewTokenServer("127.0.0.1:0", ModeStandalone, &fakeTokenService{}, WithMaxRules(1), WithRuleExpiration(time.Minute))
+
+	loadRule := func(id string) *base.TokResult {
+		return server.handleEntry(MsgTypeRule, "ns", syncEntry(t, "app", newClusterRule(id, "127.0.0.1:18730")))
+	}
+	assert.Equal(t, base.StatusOkResult, loadRule("limit-1"))
+	// 达到上限后拒绝新规则
+	assert.Equal(t, base.BadResult, loadRule("limit-2"))
+	assert.Nil(t, flow.GetRuleOfId(ruleKey("app", "token-server-limit-2", "limit-2")))
+
+	// 过期的规则被卸载，为新规则腾出空间
+	server.rulesMux.Lock()
+	server.rules[ruleKey("app", "token-server-limit-1", "limit-1")].lastAccessMs -= uint64(time.Minute.Milliseconds())
+	server.rulesMux.Unlock()
+	assert.Equal(t, base.StatusOkResult, loadRule("limit-2"))
+	assert.Nil(t, flow.GetRuleOfId(ruleKey("app", "token-server-limit-1", "limit-1")))
+	assert.NotNil(t, flow.GetRuleOfId(ruleKey("app", "token-server-limit-2", "limit-2")))
+	assert.Len(t, server.rules, 1)
+}
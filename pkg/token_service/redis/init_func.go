@@ -54,6 +54,10 @@ func (r *redisTokenServiceInitFunc) GetRegisterType() constants.RegisterType {
 
 // GetTokenService 获取TokenService服务
 func (r *redisTokenServiceInitFunc) GetTokenService() base.TokenService {
+	// 未初始化成功时返回nil接口，避免调用方拿到持有nil指针的接口
+	if r.tokenService == nil {
+		return nil
+	}
 	return r.tokenService
 }
 
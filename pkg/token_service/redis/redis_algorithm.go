@@ -0,0 +1,277 @@
+package redis
+
+import (
+	redisv8 "github.com/go-redis/redis/v8"
+
+	"github.com/liuhailove/gmiter/core/flow"
+)
+
+// 所有脚本约定：
+// KEYS[1] 限流key
+// ARGV[1] 统计窗口内的全局阈值
+// ARGV[2] 本次请求的token数
+// ARGV[3] 统计窗口大小，单位毫秒
+// ARGV[4] 最大排队时间，单位毫秒，小于0表示不排队(Reject)
+// ARGV[5] 本次请求的唯一ID，仅滑动日志使用
+// 返回 {是否通过(1/0), 需要等待的毫秒数}
+// 时间统一取Redis服务端的TIME，避免各客户端时钟不一致
+
+// scriptNow 获取Redis服务端的当前时间(毫秒)。
+// 低版本Redis在脚本中调用TIME后写数据需要开启命令复制
+const scriptNow = `
+		if redis.replicate_commands then
+			redis.replicate_commands()
+		end
+		local t = redis.call('time')
+		local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+`
+
+// fixedWindowScript 固定窗口计数，单个计数key无法预占下一窗口的容量，因此不支持排队，
+// Throttling规则在校验时被拒绝，ARGV[4]被忽略
+var fixedWindowScript = `
+		local resourceName = KEYS[1]
+		local globalThreshold = tonumber(ARGV[1])
+		local acquireCount = tonumber(ARGV[2])
+		local window = tonumber(ARGV[3])
+		local current = tonumber(redis.call('get', resourceName) or "0")
+		local ttl = tonumber(redis.call('pttl', resourceName) or "-1")
+		-- 如果TTL大于窗口，则说明系统时钟出现了问题，此处重新设置Key的过期时间
+		if ttl > window then
+			redis.call('pexpire', resourceName, window)
+		end
+		if current + acquireCount > globalThreshold then
+			return {0, 0}
+		else
+			redis.call('incrby', resourceName, acquireCount)
+			if ttl < 0 then
+				redis.call('pexpire', resourceName, window)
+			end
+			return {1, 0}
+		end
+	`
+
+// slidingLogScript 滑动日志，ZSET中每个成员为一个token，成员格式为{requestId}:{序号}，分值为请求时间。
+// 过期成员通过zremrangebyscore裁剪，窗口内的token数即为ZSET的基数，避免每次遍历整个窗口。
+// 排队的请求以预计执行时间作为分值写入，从而占用未来窗口的容量
+var slidingLogScript = scriptNow + `
+		local key = KEYS[1]
+		local globalThreshold = tonumber(ARGV[1])
+		local acquireCount = tonumber(ARGV[2])
+		local window = tonumber(ARGV[3])
+		local maxWait = tonumber(ARGV[4])
+		redis.call('zremrangebyscore', key, '-inf', now - window)
+		local current = redis.call('zcard', key)
+		local wait = 0
+		if current + acquireCount > globalThreshold then
+			if maxWait < 0 or acquireCount > globalThreshold then
+				return {0, 0}
+			end
+			-- 等待最早的若干token滑出窗口
+			local oldest = redis.call('zrange', key, current + acquireCount - globalThreshold - 1,
+				current + acquireCount - globalThreshold - 1, 'withscores')
+			if #oldest < 2 then
+				return {0, 0}
+			end
+			wait = tonumber(oldest[2]) + window - now
+			if wait > maxWait then
+				return {0, 0}
+			end
+		end
+		for i = 1, acquireCount do
+			redis.call('zadd', key, now + wait, ARGV[5] .. ':' .. i)
+		end
+		redis.call('pexpire', key, window + wait)
+		return {1, wait}
+	`
+
+// slidingWindowScript 滑动窗口计数，HASH中保存当前窗口序号及当前、上一窗口的计数，
+// 估算值 = 上一窗口计数 * 上一窗口在滑动窗口内的剩余占比 + 当前窗口计数
+var slidingWindowScript = scriptNow + `
+		local key = KEYS[1]
+		local globalThreshold = tonumber(ARGV[1])
+		local acquireCount = tonumber(ARGV[2])
+		local window = tonumber(ARGV[3])
+		local maxWait = tonumber(ARGV[4])
+		local idx = math.floor(now / window)
+		local data = redis.call('hmget', key, 'idx', 'cur', 'prev')
+		local lastIdx = tonumber(data[1]) or idx
+		local cur = tonumber(data[2]) or 0
+		local prev = tonumber(data[3]) or 0
+		if idx == lastIdx + 1 then
+			prev = cur
+			cur = 0
+		elseif idx > lastIdx + 1 then
+			prev = 0
+			cur = 0
+		elseif idx < lastIdx then
+			-- 时钟回拨，沿用原窗口
+			idx = lastIdx
+		end
+		local elapsed = now % window
+		local wait = 0
+		if prev * (window - elapsed) / window + cur + acquireCount > globalThreshold then
+			local remaining = globalThreshold - cur - acquireCount
+			if maxWait < 0 or prev <= 0 or remaining < 0 then
+				return {0, 0}
+			end
+			-- 等待上一窗口的占比衰减到剩余容量以内
+			wait = math.ceil(window - elapsed - remaining * window / prev)
+			if wait > maxWait then
+				return {0, 0}
+			end
+		end
+		redis.call('hmset', key, 'idx', idx, 'cur', cur + acquireCount, 'prev', prev)
+		redis.call('pexpire', key, window * 2)
+		return {1, wait}
+	`
+
+// tokenBucketScript GCRA令牌桶，key中保存理论到达时间(TAT)。
+// Reject时桶容量为统计窗口内的阈值，允许突发；Throttling时不允许突发，请求按照固定间隔匀速通过
+var tokenBucketScript = scriptNow + `
+		local key = KEYS[1]
+		local globalThreshold = tonumber(ARGV[1])
+		local acquireCount = tonumber(ARGV[2])
+		local window = tonumber(ARGV[3])
+		local maxWait = tonumber(ARGV[4])
+		local increment = window / globalThreshold * acquireCount
+		local tat = tonumber(redis.call('get', key) or now)
+		if tat < now then
+			tat = now
+		end
+		local newTat = tat + increment
+		local wait = 0
+		if maxWait < 0 then
+			if newTat - window > now then
+				return {0, 0}
+			end
+		else
+			wait = math.ceil(tat - now)
+			if wait > maxWait then
+				return {0, 0}
+			end
+		end
+		redis.call('set', key, string.format('%.3f', newTat), 'px', math.max(1, math.ceil(newTat - now)))
+		return {1, wait}
+	`
+
+// paramTokenBucketScript 热点参数的GCRA令牌桶，每个参数值对应一个key，在一个脚本中检查全部参数值，
+// 全部通过后才更新令牌桶，任一参数值被阻塞时不消耗任何令牌。
+// key均带有相同的hash tag，集群模式下位于同一个slot
+// KEYS 各参数值的key
+// ARGV[1] 本次请求的token数
+// ARGV[2] 统计窗口大小，单位毫秒
+// ARGV[3] 最大排队时间，单位毫秒，小于0表示不排队(Reject)
+// ARGV[4] 突发数，仅Reject时生效，桶容量为阈值+突发数
+// ARGV[4+i] KEYS[i]的阈值
+// 返回 {是否通过(1/0), 需要等待的毫秒数(各参数值中的最大值)}
+var paramTokenBucketScript = scriptNow + `
+		local acquireCount = tonumber(ARGV[1])
+		local window = tonumber(ARGV[2])
+		local maxWait = tonumber(ARGV[3])
+		local burst = tonumber(ARGV[4])
+		local newTats = {}
+		local wait = 0
+		for i, key in ipairs(KEYS) do
+			local threshold = tonumber(ARGV[4 + i])
+			if threshold <= 0 then
+				return {0, 0}
+			end
+			local interval = window / threshold
+			local tat = tonumber(redis.call('get', key) or now)
+			if tat < now then
+				tat = now
+			end
+			local newTat = tat + interval * acquireCount
+			if maxWait < 0 then
+				if newTat - window - interval * burst > now then
+					return {0, 0}
+				end
+			else
+				local keyWait = math.ceil(tat - now)
+				if keyWait > maxWait then
+					return {0, 0}
+				end
+				wait = math.max(wait, keyWait)
+			end
+			newTats[i] = newTat
+		end
+		for i, key in ipairs(KEYS) do
+			redis.call('set', key, string.format('%.3f', newTats[i]), 'px', math.max(1, math.ceil(newTats[i] - now)))
+		end
+		return {1, wait}
+	`
+
+// acquireConcurrentScript 申请并发Token。
+// KEYS[1] ZSET，成员为tokenId，分值为Token的过期时间，过期的Token视为泄漏，申请时回收
+// KEYS[2] HASH，保存每个tokenId持有的并发数，以及total(当前总并发数)和seq(tokenId序号)
+// ARGV[1] 全局并发阈值
+// ARGV[2] 本次申请的并发数
+// ARGV[3] Token的最长持有时间，单位毫秒
+// 返回 {是否通过(1/0), tokenId}
+var acquireConcurrentScript = scriptNow + `
+		local tokenKey = KEYS[1]
+		local countKey = KEYS[2]
+		local globalThreshold = tonumber(ARGV[1])
+		local acquireCount = tonumber(ARGV[2])
+		local ttl = tonumber(ARGV[3])
+		local expired = redis.call('zrangebyscore', tokenKey, '-inf', now)
+		if #expired > 0 then
+			local freed = 0
+			for _, tokenId in ipairs(expired) do
+				freed = freed + tonumber(redis.call('hget', countKey, tokenId) or "0")
+				redis.call('hdel', countKey, tokenId)
+			end
+			redis.call('zremrangebyscore', tokenKey, '-inf', now)
+			redis.call('hincrby', countKey, 'total', -freed)
+		end
+		local total = tonumber(redis.call('hget', countKey, 'total') or "0")
+		if total + acquireCount > globalThreshold then
+			return {0, 0}
+		end
+		local tokenId = redis.call('hincrby', countKey, 'seq', 1)
+		redis.call('hset', countKey, tokenId, acquireCount)
+		redis.call('hincrby', countKey, 'total', acquireCount)
+		redis.call('zadd', tokenKey, now + ttl, tokenId)
+		redis.call('pexpire', tokenKey, ttl)
+		redis.call('pexpire', countKey, ttl)
+		return {1, tokenId}
+	`
+
+// releaseConcurrentScript 释放并发Token，Token已被回收时忽略。
+// KEYS与acquireConcurrentScript相同，ARGV[1]为tokenId
+var releaseConcurrentScript = `
+		local tokenKey = KEYS[1]
+		local countKey = KEYS[2]
+		local tokenId = ARGV[1]
+		local count = redis.call('hget', countKey, tokenId)
+		if not count then
+			return 0
+		end
+		redis.call('hdel', countKey, tokenId)
+		redis.call('hincrby', countKey, 'total', -tonumber(count))
+		redis.call('zrem', tokenKey, tokenId)
+		return 1
+	`
+
+// redisScripts 各算法对应的脚本
+var redisScripts = map[flow.RedisAlgorithm]*redisv8.Script{
+	flow.RedisFixedWindow:   redisv8.NewScript(fixedWindowScript),
+	flow.RedisSlidingLog:    redisv8.NewScript(slidingLogScript),
+	flow.RedisSlidingWindow: redisv8.NewScript(slidingWindowScript),
+	flow.RedisTokenBucket:   redisv8.NewScript(tokenBucketScript),
+}
+
+// 并发Token及热点参数相关的脚本
+var (
+	acquireParamToken = redisv8.NewScript(paramTokenBucketScript)
+	acquireConcurrent = redisv8.NewScript(acquireConcurrentScript)
+	releaseConcurrent = redisv8.NewScript(releaseConcurrentScript)
+)
+
+// scriptOf 获取算法对应的脚本，未知算法回退为固定窗口
+func scriptOf(algorithm flow.RedisAlgorithm) *redisv8.Script {
+	if script, ok := redisScripts[algorithm]; ok {
+		return script
+	}
+	return redisScripts[flow.RedisFixedWindow]
+}
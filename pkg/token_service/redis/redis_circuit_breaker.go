@@ -0,0 +1,244 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+
+	redisv8 "github.com/go-redis/redis/v8"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/core/config"
+)
+
+// 集群熔断脚本约定：
+// KEYS[1] 熔断状态的key，hash结构，字段包括：
+//
+//	state 熔断状态(0-Closed，1-HalfOpen，2-Open)，nextRetry 允许探测的时间，
+//	probeDeadline 探测者的探测截止时间，probes 已成功的探测数，level 退避级别，closedAt 最近一次恢复的时间，
+//	winStart、total、errors 关闭状态下当前统计窗口的开始时间、请求数及错误数
+//
+// ARGV[1] 统计窗口大小，单位毫秒
+// ARGV[2] 熔断时长，单位毫秒
+// ARGV[3] 熔断时长的上限，大于ARGV[2]时开启指数退避
+// ARGV[4] 熔断时长的增长倍数
+// ARGV[5] 恢复后持续健康超过该时长时重置退避级别
+// ARGV[6] 探测者的最长探测时间，单位毫秒
+// ARGV[7] key的过期时间，单位毫秒
+var circuitBreakerScriptPrefix = scriptNow + `
+		local key = KEYS[1]
+		local interval = tonumber(ARGV[1])
+		local retryTimeout = tonumber(ARGV[2])
+		local maxRetryTimeout = tonumber(ARGV[3])
+		local multiplier = tonumber(ARGV[4])
+		local resetMs = tonumber(ARGV[5])
+		local probeTimeout = tonumber(ARGV[6])
+		local ttl = tonumber(ARGV[7])
+		local state = tonumber(redis.call('hget', key, 'state') or '0')
+		local nextRetry = tonumber(redis.call('hget', key, 'nextRetry') or '0')
+		-- open 切换为打开状态，开启指数退避时熔断时长为retryTimeout*multiplier^level
+		local function open(level)
+			local timeout = retryTimeout
+			if maxRetryTimeout > retryTimeout then
+				timeout = math.min(retryTimeout * math.pow(multiplier, level), maxRetryTimeout)
+			end
+			state = 2
+			nextRetry = now + math.floor(timeout)
+			redis.call('hmset', key, 'state', state, 'nextRetry', nextRetry, 'level', level, 'probes', 0)
+		end
+`
+
+// syncCircuitBreakerScript 上报统计并返回集群熔断状态
+// ARGV[8] 请求数，ARGV[9] 错误数，ARGV[10] 是否按照错误数判断(1/0)，否则按照错误比例判断，
+// ARGV[11] 阈值，ARGV[12] 最小请求数
+// 返回 {熔断状态, 允许探测的时间}
+var syncCircuitBreakerScript = circuitBreakerScriptPrefix + `
+		if state == 0 then
+			local winStart = tonumber(redis.call('hget', key, 'winStart') or '0')
+			if now - winStart >= interval then
+				redis.call('hmset', key, 'winStart', now, 'total', 0, 'errors', 0)
+			end
+			local total = redis.call('hincrby', key, 'total', ARGV[8])
+			local errors = redis.call('hincrby', key, 'errors', ARGV[9])
+			local threshold = tonumber(ARGV[11])
+			if total > 0 and total >= tonumber(ARGV[12]) then
+				local value = errors
+				if tonumber(ARGV[10]) ~= 1 then
+					value = errors / total
+				end
+				if value >= threshold then
+					local level = tonumber(redis.call('hget', key, 'level') or '0')
+					local closedAt = tonumber(redis.call('hget', key, 'closedAt') or '0')
+					if now >= closedAt + resetMs then
+						level = 0
+					end
+					open(level)
+				end
+			end
+			redis.call('pexpire', key, ttl)
+		elseif state == 1 then
+			-- 探测者超时未上报探测结果时按照打开状态返回，由各实例重新竞选
+			local deadline = tonumber(redis.call('hget', key, 'probeDeadline') or '0')
+			if now >= deadline then
+				return {2, deadline}
+			end
+		end
+		return {state, nextRetry}
+	`
+
+// acquireCircuitBreakerProbeScript 竞选探测者，打开状态到达重试时间或者上一个探测者超时时当选
+// 返回 是否当选(1/0)
+var acquireCircuitBreakerProbeScript = circuitBreakerScriptPrefix + `
+		if state == 2 then
+			if now < nextRetry then
+				return 0
+			end
+		elseif state == 1 then
+			local deadline = tonumber(redis.call('hget', key, 'probeDeadline') or '0')
+			if now < deadline then
+				return 0
+			end
+		else
+			return 0
+		end
+		redis.call('hmset', key, 'state', 1, 'probeDeadline', now + probeTimeout, 'probes', 0)
+		redis.call('pexpire', key, ttl)
+		return 1
+	`
+
+// reportCircuitBreakerProbeScript 上报探测结果
+// ARGV[8] 探测是否成功(1/0)，ARGV[9] 关闭熔断器需要的探测成功数
+// 返回 {熔断状态, 允许探测的时间}
+var reportCircuitBreakerProbeScript = circuitBreakerScriptPrefix + `
+		if state ~= 1 then
+			return {state, nextRetry}
+		end
+		if tonumber(ARGV[8]) == 1 then
+			local probes = redis.call('hincrby', key, 'probes', 1)
+			if probes >= tonumber(ARGV[9]) then
+				state = 0
+				redis.call('hmset', key, 'state', state, 'closedAt', now, 'winStart', now, 'total', 0, 'errors', 0, 'probes', 0)
+			else
+				redis.call('hset', key, 'probeDeadline', now + probeTimeout)
+			end
+		else
+			-- 探测失败，退避级别加1后重新打开
+			open(tonumber(redis.call('hget', key, 'level') or '0') + 1)
+		end
+		redis.call('pexpire', key, ttl)
+		return {state, nextRetry}
+	`
+
+// 集群熔断相关的脚本
+var (
+	syncCircuitBreaker           = redisv8.NewScript(syncCircuitBreakerScript)
+	acquireCircuitBreakerProbe   = redisv8.NewScript(acquireCircuitBreakerProbeScript)
+	reportCircuitBreakerProbe    = redisv8.NewScript(reportCircuitBreakerProbeScript)
+	errInvalidCircuitBreakerRule = errors.New("invalid cluster circuit breaking rule")
+)
+
+// SyncCircuitBreaker 上报本地的请求数及错误数，返回集群熔断状态
+func (r *RedisClusterTokenService) SyncCircuitBreaker(rule string, totalCount, errorCount uint64) (*base.CircuitBreakerStatus, error) {
+	ru, err := parseCircuitBreakerRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	var countMode = 0
+	if ru.Strategy == circuitbreaker.ErrorCount {
+		countMode = 1
+	}
+	var args = append(circuitBreakerArgs(ru), totalCount, errorCount, countMode, ru.Threshold, ru.MinRequestAmount)
+	result, err := r.runScript(r.client, syncCircuitBreaker, []string{circuitBreakerKey(ru)}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return toCircuitBreakerStatus(result)
+}
+
+// AcquireCircuitBreakerProbe 竞选半开状态的探测者，集群内只有一个实例能够当选
+func (r *RedisClusterTokenService) AcquireCircuitBreakerProbe(rule string) (bool, error) {
+	ru, err := parseCircuitBreakerRule(rule)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.runScript(r.client, acquireCircuitBreakerProbe, []string{circuitBreakerKey(ru)}, circuitBreakerArgs(ru)...)
+	if err != nil {
+		return false, err
+	}
+	elected, _ := result.(int64)
+	return elected == 1, nil
+}
+
+// ReportCircuitBreakerProbe 上报探测结果
+func (r *RedisClusterTokenService) ReportCircuitBreakerProbe(rule string, success bool) (*base.CircuitBreakerStatus, error) {
+	ru, err := parseCircuitBreakerRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	var succeed = 0
+	if success {
+		succeed = 1
+	}
+	var probeNum = ru.ProbeNum
+	if probeNum == 0 {
+		probeNum = 1
+	}
+	var args = append(circuitBreakerArgs(ru), succeed, probeNum)
+	result, err := r.runScript(r.client, reportCircuitBreakerProbe, []string{circuitBreakerKey(ru)}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return toCircuitBreakerStatus(result)
+}
+
+func parseCircuitBreakerRule(rule string) (*circuitbreaker.Rule, error) {
+	var ru = new(circuitbreaker.Rule)
+	if err := jsonTraffic.Unmarshal([]byte(rule), ru); err != nil {
+		return nil, err
+	}
+	if ru.Id == "" || ru.RetryTimeoutMs == 0 || ru.StatIntervalMs == 0 {
+		return nil, errInvalidCircuitBreakerRule
+	}
+	return ru, nil
+}
+
+// circuitBreakerArgs 脚本公共参数，默认值与本地熔断器保持一致
+func circuitBreakerArgs(rule *circuitbreaker.Rule) []interface{} {
+	var multiplier = rule.RetryTimeoutBackoffMultiplier
+	if multiplier <= 1.0 {
+		multiplier = circuitbreaker.DefaultRetryTimeoutBackoffMultiplier
+	}
+	var resetMs = rule.BackoffResetMs
+	if resetMs == 0 {
+		resetMs = rule.MaxRetryTimeoutMs
+	}
+	var probeTimeoutMs = rule.RetryTimeoutMs
+	if rule.ClusterConfig != nil && rule.ClusterConfig.ProbeTimeoutMs > 0 {
+		probeTimeoutMs = rule.ClusterConfig.ProbeTimeoutMs
+	}
+	// 过期时间覆盖最长的熔断时长、探测时间及退避重置时间，空闲的规则自动清理
+	var ttl = rule.StatIntervalMs
+	for _, v := range []uint32{rule.RetryTimeoutMs, rule.MaxRetryTimeoutMs, resetMs, probeTimeoutMs} {
+		if v > ttl {
+			ttl = v
+		}
+	}
+	return []interface{}{rule.StatIntervalMs, rule.RetryTimeoutMs, rule.MaxRetryTimeoutMs, multiplier,
+		resetMs, probeTimeoutMs, 2 * uint64(ttl)}
+}
+
+// circuitBreakerKey 熔断规则在Redis中的key
+func circuitBreakerKey(rule *circuitbreaker.Rule) string {
+	return DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.Id + "_" + rule.Resource + "_circuit_breaker"
+}
+
+// toCircuitBreakerStatus 将脚本返回的{熔断状态, 允许探测的时间}转换为CircuitBreakerStatus
+func toCircuitBreakerStatus(result interface{}) (*base.CircuitBreakerStatus, error) {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected circuit breaker script result: %v", result)
+	}
+	state, _ := values[0].(int64)
+	nextRetryTimestampMs, _ := values[1].(int64)
+	return &base.CircuitBreakerStatus{State: int32(state), NextRetryTimestampMs: uint64(nextRetryTimestampMs)}, nil
+}
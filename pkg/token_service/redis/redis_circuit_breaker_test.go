@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+)
+
+func newTestCircuitBreakerRule(t *testing.T) string {
+	rule := &circuitbreaker.Rule{
+		Id:                "cb",
+		Resource:          "abc",
+		Strategy:          circuitbreaker.ErrorRatio,
+		RetryTimeoutMs:    1000,
+		MaxRetryTimeoutMs: 4000,
+		MinRequestAmount:  4,
+		StatIntervalMs:    1000,
+		Threshold:         0.5,
+		ProbeNum:          2,
+		ClusterMode:       true,
+		ClusterConfig:     &circuitbreaker.ClusterConfig{ProbeTimeoutMs: 500},
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestRedisClusterTokenService_CircuitBreaker(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	now := time.Unix(1000, 0)
+	mr.SetTime(now)
+	rule := newTestCircuitBreakerRule(t)
+	nowMs := uint64(now.UnixNano() / int64(time.Millisecond))
+
+	// 未达到最小请求数时不熔断
+	status, err := service.SyncCircuitBreaker(rule, 3, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, &base.CircuitBreakerStatus{State: int32(circuitbreaker.Closed)}, status)
+	// 多个实例的统计累加后错误比例达到阈值
+	status, err = service.SyncCircuitBreaker(rule, 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.Open), status.State)
+	assert.Equal(t, nowMs+1000, status.NextRetryTimestampMs)
+
+	// 到达重试时间前不能竞选探测者
+	elected, err := service.AcquireCircuitBreakerProbe(rule)
+	assert.Nil(t, err)
+	assert.False(t, elected)
+
+	// 只有一个实例当选为探测者
+	mr.SetTime(now.Add(time.Second))
+	elected, err = service.AcquireCircuitBreakerProbe(rule)
+	assert.Nil(t, err)
+	assert.True(t, elected)
+	elected, err = service.AcquireCircuitBreakerProbe(rule)
+	assert.Nil(t, err)
+	assert.False(t, elected)
+	status, err = service.SyncCircuitBreaker(rule, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.HalfOpen), status.State)
+
+	// 探测失败后重新打开，熔断时长指数增长
+	status, err = service.ReportCircuitBreakerProbe(rule, false)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.Open), status.State)
+	assert.Equal(t, nowMs+1000+2000, status.NextRetryTimestampMs)
+
+	// 探测者超时未上报结果时重新竞选
+	mr.SetTime(now.Add(3 * time.Second))
+	elected, err = service.AcquireCircuitBreakerProbe(rule)
+	assert.Nil(t, err)
+	assert.True(t, elected)
+	mr.SetTime(now.Add(3*time.Second + 500*time.Millisecond))
+	status, err = service.SyncCircuitBreaker(rule, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.Open), status.State)
+	elected, err = service.AcquireCircuitBreakerProbe(rule)
+	assert.Nil(t, err)
+	assert.True(t, elected)
+
+	// 探测成功数达到ProbeNum后关闭，统计重新开始
+	status, err = service.ReportCircuitBreakerProbe(rule, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.HalfOpen), status.State)
+	status, err = service.ReportCircuitBreakerProbe(rule, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.Closed), status.State)
+	status, err = service.SyncCircuitBreaker(rule, 3, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(circuitbreaker.Closed), status.State)
+}
+
+func TestRedisClusterTokenService_InvalidCircuitBreakerRule(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	_, err := service.SyncCircuitBreaker(`{"resource":"abc"}`, 1, 1)
+	assert.NotNil(t, err)
+	_, err = service.AcquireCircuitBreakerProbe("invalid")
+	assert.NotNil(t, err)
+
+	// RedisClusterTokenService实现集群熔断接口
+	var tokenService base.TokenService = service
+	_, ok := tokenService.(base.CircuitBreakerTokenService)
+	assert.True(t, ok)
+}
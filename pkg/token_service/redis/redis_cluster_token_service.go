@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	redisv8 "github.com/go-redis/redis/v8"
@@ -15,47 +17,52 @@ import (
 	"github.com/liuhailove/gmiter/core/base"
 	"github.com/liuhailove/gmiter/core/config"
 	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/hotspot"
 	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/util"
 )
 
-// 定义Lua脚本
-var luaScript = `
-		local resourceName = KEYS[1]
-		local globalThreshold = tonumber(ARGV[1])
-		local acquireCount = tonumber(ARGV[2])
-		local current = tonumber(redis.call('get', resourceName) or "0")
-		local ttl = tonumber(redis.call('pttl', resourceName) or "-1")
-		-- 如果TTL大于1000，则说明系统时钟出现了问题，此处重新设置Key的过期时间
-		if ttl > 1000 then
-			redis.call('pexpire', resourceName, 1000)
-		end
-		if current + acquireCount > globalThreshold then
-			return 0
-		else
-			redis.call('incrby', resourceName, acquireCount)
-			if ttl < 0 then
-				redis.call('pexpire', resourceName, 1000)
-			end
-			return 1
-		end
-	`
-
 var (
 	jsonTraffic = jsoniter.ConfigCompatibleWithStandardLibrary
 )
 
 const (
 	DefaultSeaPrefix = "{Conf}"
+	// DefaultStatIntervalInMs 规则未设置统计窗口时的默认窗口大小
+	DefaultStatIntervalInMs = 1000
 )
 
 // RedisClusterTokenService redis集群Token服务
 type RedisClusterTokenService struct {
 	base.TokenService
-	client    redisv8.UniversalClient
-	scriptSha string
+	client redisv8.UniversalClient
+	// requestSeq 请求序号，用于生成滑动日志中的请求ID
+	requestSeq uint64
 }
 
 func NewRedisClient(conf *config.RedisClusterConfig) (*RedisClusterTokenService, error) {
+	redisClient, err := newUniversalClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	// 将Lua脚本加载到Redis中，加载失败时执行脚本会回退为EVAL
+	for algorithm, script := range redisScripts {
+		if err := script.Load(context.Background(), redisClient).Err(); err != nil {
+			logging.Error(err, "redis cluster load script error", "algorithm", algorithm.String())
+		}
+	}
+	for _, script := range []*redisv8.Script{acquireParamToken, acquireConcurrent, releaseConcurrent,
+		syncCircuitBreaker, acquireCircuitBreakerProbe, reportCircuitBreakerProbe} {
+		if err := script.Load(context.Background(), redisClient).Err(); err != nil {
+			logging.Error(err, "redis cluster load script error")
+		}
+	}
+	// 流控规则(无论什么时候对象都要存在，要不然引用时会存在空指针)
+	return &RedisClusterTokenService{client: redisClient}, nil
+}
+
+// newUniversalClient 根据配置创建Redis客户端
+func newUniversalClient(conf *config.RedisClusterConfig) (redisv8.UniversalClient, error) {
 	// redis客户端地址不可以为空
 	if len(conf.Host) <= 0 {
 		logging.Error(errors.New("redis cluster host cannot be empty"), "redis host  cannot be empty")
@@ -96,16 +103,7 @@ func NewRedisClient(conf *config.RedisClusterConfig) (*RedisClusterTokenService,
 			WriteTimeout: 1 * time.Second,
 		})
 	}
-	// 将Lua脚本加载到Redis中
-	scriptSha, err := redisv8.NewScript(luaScript).Load(context.Background(), redisClient).Result()
-	// 流控规则(无论什么时候对象都要存在，要不然引用时会存在空指针)
-	tokenServiceClient := &RedisClusterTokenService{client: redisClient, scriptSha: scriptSha}
-	if err != nil {
-		logging.Error(err, "redis cluster load script error")
-		//Lua 脚本上传失败 scriptSha置为空""，后续直接通过脚本调用
-		tokenServiceClient.scriptSha = ""
-	}
-	return tokenServiceClient, nil
+	return redisClient, nil
 }
 
 // RequestToken 从远程TokenServer请求tokens
@@ -133,8 +131,15 @@ func (r *RedisClusterTokenService) RequestToken(rule string, acquireCount uint32
 // @param params 参数列表
 // @return token请求处理结果
 func (r *RedisClusterTokenService) RequestParamToken(rule string, acquireCount uint32, params []interface{}) *base.TokResult {
-	//TODO implement me
-	panic("implement me")
+	var ru = new(paramRule)
+	var err = jsonTraffic.Unmarshal([]byte(rule), ru)
+	if err != nil {
+		return base.BadResult
+	}
+	if r.notValidRequestSimple(ru.ID, acquireCount) || len(params) == 0 || ru.ClusterConfig == nil {
+		return base.BadResult
+	}
+	return r.acquireParamToken(r.client, ru, acquireCount, params)
 }
 
 // RequestConcurrentToken 从远程TokenServer获取的并发token数
@@ -143,8 +148,29 @@ func (r *RedisClusterTokenService) RequestParamToken(rule string, acquireCount u
 // @param acquireCount 并发获取的token数
 // @return token请求处理结果
 func (r *RedisClusterTokenService) RequestConcurrentToken(rule string, acquireCount uint32) *base.TokResult {
-	//TODO implement me
-	panic("implement me")
+	var ru = new(concurrentRule)
+	var err = jsonTraffic.Unmarshal([]byte(rule), ru)
+	if err != nil {
+		return base.BadResult
+	}
+	if r.notValidRequestSimple(ru.ID, acquireCount) || ru.ClusterConfig == nil {
+		return base.BadResult
+	}
+	tokenKey, countKey := concurrentKeys(ru)
+	result, err := r.runScript(r.client, acquireConcurrent, []string{tokenKey, countKey}, ru.threshold(), acquireCount, ru.tokenTTLInMs())
+	if err != nil {
+		return base.FailResult
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		logging.Warn("[RedisClusterTokenService] unexpected script result", "result", result)
+		return base.FailResult
+	}
+	if passed, _ := values[0].(int64); passed != 1 {
+		return base.BlockedResult
+	}
+	tokenId, _ := values[1].(int64)
+	return &base.TokResult{Status: int(base.TokResultStatusOk), TokenId: tokenId}
 }
 
 // ReleaseConcurrentToken 远程TokenServer异步释放Token数
@@ -152,8 +178,13 @@ func (r *RedisClusterTokenService) RequestConcurrentToken(rule string, acquireCo
 // @param rule 规则信息
 // @param tokenId 全局唯一tokenId
 func (r *RedisClusterTokenService) ReleaseConcurrentToken(rule string, tokenId string) {
-	//TODO implement me
-	panic("implement me")
+	var ru = new(concurrentRule)
+	if err := jsonTraffic.Unmarshal([]byte(rule), ru); err != nil || ru.ID == "" || tokenId == "" {
+		logging.Warn("[RedisClusterTokenService] invalid release request", "rule", rule, "tokenId", tokenId)
+		return
+	}
+	tokenKey, countKey := concurrentKeys(ru)
+	_, _ = r.runScript(r.client, releaseConcurrent, []string{tokenKey, countKey}, tokenId)
 }
 
 func (r *RedisClusterTokenService) notValidRequestSimple(id string, count uint32) bool {
@@ -165,30 +196,105 @@ func (r *RedisClusterTokenService) notValidRequest(address string, id string, co
 }
 
 func (r *RedisClusterTokenService) acquireClusterToken(client redisv8.UniversalClient, rule *flow.Rule, acquireCount uint32, prioritized int32) *base.TokResult {
-	var result interface{}
-	var err error
-	if r.scriptSha == "" {
-		result, err = client.Eval(context.Background(), luaScript, []string{DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.ID + "_" + rule.Resource}, rule.ClusterConfig.GlobalThreshold, acquireCount).Result()
-	} else {
-		result, err = client.EvalSha(context.Background(), r.scriptSha, []string{DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.ID + "_" + rule.Resource}, rule.ClusterConfig.GlobalThreshold, acquireCount).Result()
+	if rule.ClusterConfig == nil {
+		return base.BadResult
+	}
+	var algorithm = rule.ClusterConfig.RedisAlgorithm
+	var statIntervalInMs = int64(rule.StatIntervalInMs)
+	if statIntervalInMs <= 0 {
+		statIntervalInMs = DefaultStatIntervalInMs
+	}
+	// 线性限流时允许排队，否则直接拒绝
+	var maxQueueingTimeMs int64 = -1
+	if prioritized > 0 || rule.ControlBehavior == flow.Throttling {
+		maxQueueingTimeMs = int64(rule.MaxQueueingTimeMs)
+	}
+	var requestId = strconv.FormatUint(atomic.AddUint64(&r.requestSeq, 1), 36) + "-" + util.RandStr(8)
+	result, err := r.runScript(client, scriptOf(algorithm), []string{redisKey(rule)},
+		rule.ClusterConfig.GlobalThreshold, acquireCount, statIntervalInMs, maxQueueingTimeMs, requestId)
+	if err != nil {
+		return base.FailResult
+	}
+	return toTokResult(result)
+}
 
+// acquireParamToken 按照参数值申请Token，每个参数值对应一个令牌桶，有一个参数值被阻塞则整体阻塞，
+// 全部参数值在一个脚本中检查，阻塞时不会消耗其他参数值的令牌
+func (r *RedisClusterTokenService) acquireParamToken(client redisv8.UniversalClient, rule *paramRule, acquireCount uint32, params []interface{}) *base.TokResult {
+	var durationInMs = rule.DurationInSec * 1000
+	if durationInMs <= 0 {
+		durationInMs = DefaultStatIntervalInMs
+	}
+	// 与本地热点限流一致，突发数只在Reject时生效
+	var maxQueueingTimeMs int64 = -1
+	var burstCount = rule.BurstCount
+	if rule.ControlBehavior == hotspot.Throttling {
+		maxQueueingTimeMs = rule.MaxQueueingTimeMs
+		burstCount = 0
+	}
+	var keys = make([]string, 0, len(params))
+	var thresholds = make([]interface{}, 0, len(params))
+	var seen = make(map[string]struct{}, len(params))
+	for _, param := range params {
+		var threshold = rule.thresholdOf(param)
+		if threshold <= 0 {
+			return base.BlockedResult
+		}
+		var key = paramKey(rule, param)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+		thresholds = append(thresholds, threshold)
 	}
+	var args = append([]interface{}{acquireCount, durationInMs, maxQueueingTimeMs, burstCount}, thresholds...)
+	result, err := r.runScript(client, acquireParamToken, keys, args...)
+	if err != nil {
+		return base.FailResult
+	}
+	return toTokResult(result)
+}
+
+// runScript 执行脚本，连接异常时重建客户端，等待下次恢复
+func (r *RedisClusterTokenService) runScript(client redisv8.UniversalClient, script *redisv8.Script, keys []string, args ...interface{}) (interface{}, error) {
+	result, err := script.Run(context.Background(), client, keys, args...).Result()
 	if err != nil {
 		var e *net.OpError
 		if errors.As(err, &e) && strings.Contains(e.Err.Error(), "connect") {
 			// 重建，等待下次恢复
 			_ = redisTokenServiceInst.ReInitial()
-		} else if strings.HasPrefix(err.Error(), "NOSCRIPT ") {
-			// 重建，等待下次恢复
-			_ = redisTokenServiceInst.ReInitial()
 		}
-		logging.Error(err, "redis cluster load script error")
+		logging.Error(err, "redis cluster eval script error", "keys", keys)
+	}
+	return result, err
+}
+
+// redisKey 规则在Redis中的key，不同算法使用的数据结构不同，非固定窗口的key追加算法名称
+func redisKey(rule *flow.Rule) string {
+	var key = DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.ID + "_" + rule.Resource
+	if rule.ClusterConfig.RedisAlgorithm == flow.RedisFixedWindow {
+		return key
+	}
+	return key + "_" + rule.ClusterConfig.RedisAlgorithm.String()
+}
+
+// toTokResult 将脚本返回的{是否通过, 等待毫秒数}转换为TokResult
+func toTokResult(result interface{}) *base.TokResult {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		logging.Warn("[RedisClusterTokenService] unexpected script result", "result", result)
 		return base.FailResult
 	}
-	if result.(int64) == 1 {
-		return base.StatusOkResult
+	passed, _ := values[0].(int64)
+	waitInMs, _ := values[1].(int64)
+	if passed != 1 {
+		return base.BlockedResult
+	}
+	if waitInMs > 0 {
+		return &base.TokResult{Status: int(base.TokResultStatusShouldWait), WaitInMs: int(waitInMs)}
 	}
-	return base.BlockedResult
+	return base.StatusOkResult
 }
 
 func (r *RedisClusterTokenService) Destroy() {
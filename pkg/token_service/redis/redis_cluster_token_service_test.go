@@ -0,0 +1,305 @@
+package redis
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+func newTestTokenService(t *testing.T) (*RedisClusterTokenService, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	assert.Nil(t, err)
+	service, err := NewRedisClient(&config.RedisClusterConfig{Host: mr.Addr()})
+	assert.Nil(t, err)
+	return service, mr
+}
+
+func newTestRule(id string, algorithm flow.RedisAlgorithm, threshold float64) *flow.Rule {
+	return &flow.Rule{
+		ID:               id,
+		Resource:         "abc",
+		StatIntervalInMs: 1000,
+		ClusterMode:      true,
+		ClusterConfig: &flow.ClusterConfig{
+			ClusterStrategy: int32(flow.ThresholdGlobalRedis),
+			GlobalThreshold: threshold,
+			RedisAlgorithm:  algorithm,
+		},
+	}
+}
+
+func requestToken(t *testing.T, service *RedisClusterTokenService, rule *flow.Rule) *base.TokResult {
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+	return service.RequestToken(string(data), 1, 0)
+}
+
+func TestRedisClusterTokenService_FixedWindow(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	rule := newTestRule("fixed", flow.RedisFixedWindow, 3)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+	}
+	assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+
+	// 窗口过期后恢复
+	mr.FastForward(time.Second)
+	assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+}
+
+func TestRedisClusterTokenService_SlidingWindow(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	now := time.Unix(1000, 0)
+	mr.SetTime(now)
+	rule := newTestRule("sliding-window", flow.RedisSlidingWindow, 10)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+	}
+	assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+
+	// 进入下一窗口的一半，上一窗口的10个请求按50%计入，只能再通过5个
+	mr.SetTime(now.Add(1500 * time.Millisecond))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+	}
+	assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+
+	t.Run("Throttling", func(t *testing.T) {
+		rule.ControlBehavior = flow.Throttling
+		rule.MaxQueueingTimeMs = 500
+		// 等待上一窗口的占比再衰减10%
+		result := requestToken(t, service, rule)
+		assert.Equal(t, int(base.TokResultStatusShouldWait), result.Status)
+		assert.Equal(t, 100, result.WaitInMs)
+	})
+}
+
+func TestRedisClusterTokenService_SlidingLog(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	now := time.Unix(1000, 0)
+	mr.SetTime(now)
+	rule := newTestRule("sliding-log", flow.RedisSlidingLog, 2)
+	assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+	assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+	assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+
+	mr.SetTime(now.Add(999 * time.Millisecond))
+	assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+
+	mr.SetTime(now.Add(1000 * time.Millisecond))
+	assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+
+	t.Run("Throttling", func(t *testing.T) {
+		rule.ControlBehavior = flow.Throttling
+		rule.MaxQueueingTimeMs = 500
+		// t+0的请求已滑出窗口，排队的请求需等待t+1000的请求滑出窗口
+		mr.SetTime(now.Add(1600 * time.Millisecond))
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+		result := requestToken(t, service, rule)
+		assert.Equal(t, int(base.TokResultStatusShouldWait), result.Status)
+		assert.Equal(t, 400, result.WaitInMs)
+		// 超过最大排队时间
+		assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+	})
+
+	t.Run("AcquireCount", func(t *testing.T) {
+		rule := newTestRule("sliding-log-count", flow.RedisSlidingLog, 5)
+		data, err := jsonTraffic.Marshal(rule)
+		assert.Nil(t, err)
+		mr.SetTime(now)
+		assert.Equal(t, base.StatusOkResult, service.RequestToken(string(data), 3, 0))
+		// 每个token单独计数
+		assert.Equal(t, base.BlockedResult, service.RequestToken(string(data), 3, 0))
+		assert.Equal(t, base.StatusOkResult, service.RequestToken(string(data), 2, 0))
+		members, err := mr.ZMembers(redisKey(rule))
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(members))
+	})
+}
+
+func TestRedisClusterTokenService_TokenBucket(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	now := time.Unix(1000, 0)
+	mr.SetTime(now)
+
+	t.Run("Reject", func(t *testing.T) {
+		rule := newTestRule("token-bucket", flow.RedisTokenBucket, 10)
+		// 允许突发到阈值
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+		}
+		assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+		// 每100ms恢复一个令牌
+		mr.SetTime(now.Add(100 * time.Millisecond))
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+		assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+	})
+
+	t.Run("Throttling", func(t *testing.T) {
+		mr.SetTime(now)
+		rule := newTestRule("token-bucket-throttling", flow.RedisTokenBucket, 10)
+		rule.ControlBehavior = flow.Throttling
+		rule.MaxQueueingTimeMs = 150
+		assert.Equal(t, base.StatusOkResult, requestToken(t, service, rule))
+		result := requestToken(t, service, rule)
+		assert.Equal(t, int(base.TokResultStatusShouldWait), result.Status)
+		assert.Equal(t, 100, result.WaitInMs)
+		assert.Equal(t, base.BlockedResult, requestToken(t, service, rule))
+	})
+}
+
+func TestRedisClusterTokenService_RequestParamToken(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	mr.SetTime(time.Unix(1000, 0))
+	rule := &hotspot.Rule{
+		ID:            "param",
+		Resource:      "abc",
+		MetricType:    hotspot.QPS,
+		Threshold:     100,
+		DurationInSec: 1,
+		SpecificItems: map[interface{}]int64{"vip": 3},
+		ClusterMode:   true,
+		ClusterConfig: &hotspot.ClusterConfig{
+			ClusterStrategy: int32(hotspot.ThresholdGlobalRedis),
+			GlobalThreshold: 2,
+		},
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+
+	// 不同参数值分别计数
+	for _, param := range []interface{}{"a", "b"} {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, base.StatusOkResult, service.RequestParamToken(string(data), 1, []interface{}{param}))
+		}
+		assert.Equal(t, base.BlockedResult, service.RequestParamToken(string(data), 1, []interface{}{param}))
+	}
+	// 特定参数值使用特定阈值
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, base.StatusOkResult, service.RequestParamToken(string(data), 1, []interface{}{"vip"}))
+	}
+	assert.Equal(t, base.BlockedResult, service.RequestParamToken(string(data), 1, []interface{}{"vip"}))
+
+	assert.Equal(t, base.BadResult, service.RequestParamToken(string(data), 1, nil))
+}
+
+func TestRedisClusterTokenService_RequestParamTokenOfMultiParams(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	mr.SetTime(time.Unix(1000, 0))
+	rule := &hotspot.Rule{
+		ID:            "multi",
+		Resource:      "abc",
+		MetricType:    hotspot.QPS,
+		DurationInSec: 1,
+		SpecificItems: map[interface{}]int64{"vip": 1},
+		ClusterMode:   true,
+		ClusterConfig: &hotspot.ClusterConfig{
+			ClusterStrategy: int32(hotspot.ThresholdGlobalRedis),
+			GlobalThreshold: 3,
+		},
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+
+	assert.Equal(t, base.StatusOkResult, service.RequestParamToken(string(data), 1, []interface{}{"a", "vip"}))
+	// vip被阻塞时不消耗a的令牌
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, base.BlockedResult, service.RequestParamToken(string(data), 1, []interface{}{"a", "vip"}))
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, base.StatusOkResult, service.RequestParamToken(string(data), 1, []interface{}{"a"}))
+	}
+	assert.Equal(t, base.BlockedResult, service.RequestParamToken(string(data), 1, []interface{}{"a"}))
+}
+
+func TestRedisClusterTokenService_RequestParamTokenWithBurst(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	mr.SetTime(time.Unix(1000, 0))
+	rule := &hotspot.Rule{
+		ID:            "burst",
+		Resource:      "abc",
+		MetricType:    hotspot.QPS,
+		DurationInSec: 1,
+		BurstCount:    2,
+		ClusterMode:   true,
+		ClusterConfig: &hotspot.ClusterConfig{
+			ClusterStrategy: int32(hotspot.ThresholdGlobalRedis),
+			GlobalThreshold: 2,
+		},
+	}
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+
+	// 桶容量为阈值+突发数
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, base.StatusOkResult, service.RequestParamToken(string(data), 1, []interface{}{"a"}))
+	}
+	assert.Equal(t, base.BlockedResult, service.RequestParamToken(string(data), 1, []interface{}{"a"}))
+}
+
+func TestRedisClusterTokenService_ConcurrentToken(t *testing.T) {
+	service, mr := newTestTokenService(t)
+	defer mr.Close()
+	defer service.Destroy()
+
+	now := time.Unix(1000, 0)
+	mr.SetTime(now)
+	rule := newTestRule("concurrent", flow.RedisFixedWindow, 2)
+	rule.ClusterConfig.ResourceTimeout = 500
+	rule.ClusterConfig.ResourceTimeoutStrategy = 1
+	data, err := jsonTraffic.Marshal(rule)
+	assert.Nil(t, err)
+
+	first := service.RequestConcurrentToken(string(data), 1)
+	assert.Equal(t, int(base.TokResultStatusOk), first.Status)
+	second := service.RequestConcurrentToken(string(data), 1)
+	assert.Equal(t, int(base.TokResultStatusOk), second.Status)
+	assert.NotEqual(t, first.TokenId, second.TokenId)
+	assert.Equal(t, base.BlockedResult, service.RequestConcurrentToken(string(data), 1))
+
+	t.Run("Release", func(t *testing.T) {
+		service.ReleaseConcurrentToken(string(data), strconv.FormatInt(first.TokenId, 10))
+		// 重复释放不影响计数
+		service.ReleaseConcurrentToken(string(data), strconv.FormatInt(first.TokenId, 10))
+		third := service.RequestConcurrentToken(string(data), 1)
+		assert.Equal(t, int(base.TokResultStatusOk), third.Status)
+		assert.Equal(t, base.BlockedResult, service.RequestConcurrentToken(string(data), 1))
+	})
+
+	t.Run("ReclaimLeakedToken", func(t *testing.T) {
+		// 超过ResourceTimeout未释放的Token被回收
+		mr.SetTime(now.Add(600 * time.Millisecond))
+		assert.Equal(t, int(base.TokResultStatusOk), service.RequestConcurrentToken(string(data), 1).Status)
+		assert.Equal(t, int(base.TokResultStatusOk), service.RequestConcurrentToken(string(data), 1).Status)
+		assert.Equal(t, base.BlockedResult, service.RequestConcurrentToken(string(data), 1))
+	})
+}
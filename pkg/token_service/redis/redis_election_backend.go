@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	redisv8 "github.com/go-redis/redis/v8"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/election"
+)
+
+// campaignScript 竞选master，key不存在时写入自身并设置过期时间，自身为master时续约，返回当前master
+// KEYS[1] 选主key
+// ARGV[1] 自身
+// ARGV[2] 过期时间，单位毫秒
+var campaignScript = redisv8.NewScript(`
+		local master = redis.call('get', KEYS[1])
+		if not master then
+			redis.call('set', KEYS[1], ARGV[1], 'px', ARGV[2])
+			return ARGV[1]
+		end
+		if master == ARGV[1] then
+			redis.call('pexpire', KEYS[1], ARGV[2])
+		end
+		return master
+	`)
+
+// resignScript 自身为master时删除选主key
+var resignScript = redisv8.NewScript(`
+		if redis.call('get', KEYS[1]) == ARGV[1] then
+			return redis.call('del', KEYS[1])
+		end
+		return 0
+	`)
+
+// ElectionBackend 基于Redis锁的选主后端
+type ElectionBackend struct {
+	client redisv8.UniversalClient
+}
+
+// NewElectionBackend 创建Redis选主后端
+func NewElectionBackend(conf *config.RedisClusterConfig) (*ElectionBackend, error) {
+	client, err := newUniversalClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &ElectionBackend{client: client}, nil
+}
+
+func electionKey(namespace string) string {
+	return DefaultSeaPrefix + "_election_" + namespace
+}
+
+func (e *ElectionBackend) Campaign(ctx context.Context, namespace string, self election.Member, ttl time.Duration) (election.Member, error) {
+	value, err := jsonTraffic.Marshal(self)
+	if err != nil {
+		return election.Member{}, err
+	}
+	master, err := campaignScript.Run(ctx, e.client, []string{electionKey(namespace)}, string(value), ttl.Milliseconds()).Text()
+	if err != nil {
+		return election.Member{}, err
+	}
+	return decodeMember(master)
+}
+
+func (e *ElectionBackend) Master(ctx context.Context, namespace string) (election.Member, error) {
+	master, err := e.client.Get(ctx, electionKey(namespace)).Result()
+	if err == redisv8.Nil {
+		return election.Member{}, nil
+	}
+	if err != nil {
+		return election.Member{}, err
+	}
+	return decodeMember(master)
+}
+
+func (e *ElectionBackend) Resign(ctx context.Context, namespace string, self election.Member) error {
+	value, err := jsonTraffic.Marshal(self)
+	if err != nil {
+		return err
+	}
+	return resignScript.Run(ctx, e.client, []string{electionKey(namespace)}, string(value)).Err()
+}
+
+func (e *ElectionBackend) Close() error {
+	return e.client.Close()
+}
+
+func decodeMember(data string) (election.Member, error) {
+	var member election.Member
+	if err := jsonTraffic.Unmarshal([]byte(data), &member); err != nil {
+		return election.Member{}, err
+	}
+	return member, nil
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/election"
+)
+
+func TestElectionBackend(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.Nil(t, err)
+	defer mr.Close()
+	backend, err := NewElectionBackend(&config.RedisClusterConfig{Host: mr.Addr()})
+	assert.Nil(t, err)
+	defer backend.Close()
+
+	ctx := context.Background()
+	a := election.Member{Host: "10.0.0.1", Port: 8719}
+	b := election.Member{Host: "10.0.0.2", Port: 8719}
+
+	master, err := backend.Master(ctx, "ns")
+	assert.Nil(t, err)
+	assert.True(t, master.IsEmpty())
+
+	master, err = backend.Campaign(ctx, "ns", a, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+	master, err = backend.Campaign(ctx, "ns", b, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+
+	// master续约后不会过期
+	mr.FastForward(2 * time.Second)
+	_, _ = backend.Campaign(ctx, "ns", a, 3*time.Second)
+	mr.FastForward(2 * time.Second)
+	master, err = backend.Master(ctx, "ns")
+	assert.Nil(t, err)
+	assert.Equal(t, a, master)
+
+	// master失联超过TTL后由其他实例接管
+	mr.FastForward(3 * time.Second)
+	master, err = backend.Campaign(ctx, "ns", b, 3*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, b, master)
+
+	// 只有master可以放弃
+	assert.Nil(t, backend.Resign(ctx, "ns", a))
+	master, _ = backend.Master(ctx, "ns")
+	assert.Equal(t, b, master)
+	assert.Nil(t, backend.Resign(ctx, "ns", b))
+	master, _ = backend.Master(ctx, "ns")
+	assert.True(t, master.IsEmpty())
+}
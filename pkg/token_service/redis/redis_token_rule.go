@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/hotspot"
+)
+
+const (
+	// DefaultConcurrentTokenTTLInMs 并发Token未配置超时时间时的最长持有时间
+	DefaultConcurrentTokenTTLInMs = 60 * 1000
+)
+
+// paramRule 热点参数规则中Redis全局限流需要的字段，
+// hotspot.Rule的SpecificItems的key序列化后为字符串，无法直接反序列化为hotspot.Rule
+type paramRule struct {
+	ID                string                  `json:"id"`
+	Resource          string                  `json:"resource"`
+	ControlBehavior   hotspot.ControlBehavior `json:"controlBehavior"`
+	Threshold         float64                 `json:"threshold"`
+	MaxQueueingTimeMs int64                   `json:"maxQueueingTimeMs"`
+	BurstCount        int64                   `json:"burstCount"`
+	DurationInSec     int64                   `json:"durationInSec"`
+	SpecificItems     map[string]int64        `json:"specificItems"`
+	ClusterConfig     *hotspot.ClusterConfig  `json:"clusterConfig"`
+}
+
+// thresholdOf 参数值的全局阈值，优先取SpecificItems中的特定阈值
+func (r *paramRule) thresholdOf(param interface{}) float64 {
+	if threshold, ok := r.SpecificItems[fmt.Sprint(param)]; ok {
+		return float64(threshold)
+	}
+	if r.ClusterConfig.GlobalThreshold > 0 {
+		return r.ClusterConfig.GlobalThreshold
+	}
+	return r.Threshold
+}
+
+// paramKey 参数值对应的key，参数值取hash，避免key过长
+func paramKey(rule *paramRule, param interface{}) string {
+	var h = fnv.New64a()
+	_, _ = h.Write([]byte(fmt.Sprint(param)))
+	return DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.ID + "_" + rule.Resource + "_param_" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+// concurrentRule 并发Token需要的规则字段，兼容流控规则及热点规则
+type concurrentRule struct {
+	ID            string              `json:"id"`
+	Resource      string              `json:"resource"`
+	Threshold     float64             `json:"threshold"`
+	ClusterConfig *flow.ClusterConfig `json:"clusterConfig"`
+}
+
+func (r *concurrentRule) threshold() float64 {
+	if r.ClusterConfig.GlobalThreshold > 0 {
+		return r.ClusterConfig.GlobalThreshold
+	}
+	return r.Threshold
+}
+
+// tokenTTLInMs 并发Token的最长持有时间，超过后视为泄漏并被回收。
+// 资源超时策略为释放Token时取ResourceTimeout，否则在客户端下线(ClientOfflineTime)后回收
+func (r *concurrentRule) tokenTTLInMs() int64 {
+	var ttl = r.ClusterConfig.ClientOfflineTime
+	if r.ClusterConfig.ResourceTimeoutStrategy == 1 && r.ClusterConfig.ResourceTimeout > 0 &&
+		(ttl <= 0 || r.ClusterConfig.ResourceTimeout < ttl) {
+		ttl = r.ClusterConfig.ResourceTimeout
+	}
+	if ttl <= 0 {
+		ttl = DefaultConcurrentTokenTTLInMs
+	}
+	return ttl
+}
+
+// concurrentKeys 并发Token的key，分别保存Token的过期时间和持有的并发数
+func concurrentKeys(rule *concurrentRule) (string, string) {
+	var key = DefaultSeaPrefix + "_" + config.AppName() + "_" + rule.ID + "_" + rule.Resource + "_concurrent"
+	return key + "_tokens", key + "_counts"
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"github.com/liuhailove/gmiter/api"
 	"github.com/liuhailove/gmiter/core/base"
@@ -185,3 +186,59 @@ func BenchmarkFlowParam(b *testing.B) {
 func TestInitWithConfig(t *testing.T) {
 	config.InitConfigWithYaml("./sea.yml")
 }
+
+func TestChainFlowControl(t *testing.T) {
+	initsea()
+	util.SetClock(util.NewMockClock())
+
+	dao := "chain-dao"
+	export := "chain-bulk-export"
+	checkout := "chain-checkout"
+	ok, err := flow.LoadRules([]*flow.Rule{{
+		Resource:               dao,
+		TokenCalculateStrategy: flow.Direct,
+		ControlBehavior:        flow.Reject,
+		Threshold:              2,
+		StatIntervalInMs:       1000,
+		RelationStrategy:       flow.ChainResource,
+		RefResource:            export,
+	}})
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	defer flow.ClearRules()
+
+	callDao := func(entrance string) *base.BlockError {
+		parent, blockError := api.Entry(entrance, api.WithTrafficType(base.Inbound))
+		assert.Nil(t, blockError)
+		defer parent.Exit()
+		ctx := api.ContextWithEntry(context.Background(), parent)
+		entry, blockError := api.Entry(dao, api.WithParentContext(ctx))
+		if blockError != nil {
+			return blockError
+		}
+		assert.Equal(t, export, entry.Entrance())
+		entry.Exit()
+		return nil
+	}
+	// 经由导出接口的调用受限
+	assert.Nil(t, callDao(export))
+	assert.Nil(t, callDao(export))
+	blockError := callDao(export)
+	assert.NotNil(t, blockError)
+	assert.Equal(t, base.BlockTypeFlow, blockError.BlockType())
+
+	// 经由下单接口的调用不受限
+	for i := 0; i < 5; i++ {
+		parent, blockError := api.Entry(checkout, api.WithTrafficType(base.Inbound))
+		assert.Nil(t, blockError)
+		entry, blockError := api.Entry(dao, api.WithParentEntry(parent))
+		assert.Nil(t, blockError)
+		assert.Equal(t, checkout, entry.Entrance())
+		entry.Exit()
+		parent.Exit()
+	}
+	// 无入口的直接调用不受限
+	entry, blockError := api.Entry(dao)
+	assert.Nil(t, blockError)
+	entry.Exit()
+}
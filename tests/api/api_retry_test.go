@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/liuhailove/gmiter/api"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/retry"
+	"github.com/liuhailove/gmiter/core/retry/rule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntryWithRetry(t *testing.T) {
+	initsea()
+	rs := "retry-res"
+	_, err := rule.LoadRules([]*rule.Rule{{
+		Resource:         rs,
+		RetryPolicy:      rule.MaxAttemptsRetryPolicy,
+		RetryMaxAttempts: 3,
+		BackoffPolicy:    rule.NoBackOffPolicy,
+		ErrorMatcher:     rule.AnyMatch,
+	}})
+	assert.Nil(t, err)
+	defer rule.ClearRules()
+
+	t.Run("SuccessAfterRetry", func(t *testing.T) {
+		calls := 0
+		result, err := api.EntryWithRetry(rs, func(ctx retry.RtyContext) (interface{}, error) {
+			calls++
+			if ctx.GetRetryCount() < 2 {
+				return nil, errors.New("temporary error")
+			}
+			return "ok", nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", result)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		calls := 0
+		_, err := api.EntryWithRetry(rs, func(ctx retry.RtyContext) (interface{}, error) {
+			calls++
+			return nil, errors.New("always error")
+		})
+		assert.EqualError(t, err, "always error")
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("BlockedNoRetry", func(t *testing.T) {
+		_, err := flow.LoadRules([]*flow.Rule{{
+			Resource:               rs,
+			TokenCalculateStrategy: flow.Direct,
+			ControlBehavior:        flow.Reject,
+			Threshold:              0,
+			StatIntervalInMs:       1000,
+		}})
+		assert.Nil(t, err)
+		defer flow.ClearRules()
+
+		calls := 0
+		_, err = api.EntryWithRetry(rs, func(ctx retry.RtyContext) (interface{}, error) {
+			calls++
+			return nil, nil
+		})
+		var blockErr *base.BlockError
+		assert.True(t, errors.As(err, &blockErr))
+		assert.Equal(t, base.BlockTypeFlow, blockErr.BlockType())
+		assert.Equal(t, 0, calls)
+	})
+}
+
+func TestEntryWithRetry_NoRule(t *testing.T) {
+	initsea()
+	calls := 0
+	_, err := api.EntryWithRetry("retry-res-no-rule", func(ctx retry.RtyContext) (interface{}, error) {
+		calls++
+		return nil, errors.New("error")
+	})
+	assert.EqualError(t, err, "error")
+	assert.Equal(t, 1, calls)
+}
@@ -3,7 +3,9 @@ package handler
 import (
 	"errors"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/liuhailove/gmiter/core/authority"
 	"github.com/liuhailove/gmiter/core/flow"
+	"github.com/liuhailove/gmiter/core/gateway"
 	"github.com/liuhailove/gmiter/core/system"
 	"github.com/liuhailove/gmiter/ext/datasource"
 	"github.com/liuhailove/gmiter/logging"
@@ -43,7 +45,17 @@ func (f fetchActiveRuleCommandHandler) Handle(request command.Request) *command.
 		rulesBytes, _ := json.Marshal(rules)
 		return command.OfSuccess(string(rulesBytes))
 	} else if strings.EqualFold("authority", typ) {
-		return nil
+		rules := authority.GetRules()
+		rulesBytes, _ := json.Marshal(rules)
+		return command.OfSuccess(string(rulesBytes))
+	} else if strings.EqualFold("gateway", typ) {
+		rules := gateway.GetRules()
+		rulesBytes, _ := json.Marshal(rules)
+		return command.OfSuccess(string(rulesBytes))
+	} else if strings.EqualFold("gatewayApi", typ) {
+		apis := gateway.GetApiDefinitions()
+		apisBytes, _ := json.Marshal(apis)
+		return command.OfSuccess(string(apisBytes))
 	} else if strings.EqualFold("system", typ) {
 		data, err := datasource.SystemRuleTrans(system.GetRules())
 		if err != nil {
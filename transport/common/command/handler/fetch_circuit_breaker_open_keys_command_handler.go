@@ -0,0 +1,39 @@
+package handler
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"github.com/liuhailove/gmiter/core/circuitbreaker"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/transport/common/command"
+)
+
+var (
+	fetchCircuitBreakerOpenKeysCommandHandlerInst = new(fetchCircuitBreakerOpenKeysCommandHandler)
+)
+
+func init() {
+	command.RegisterHandler(fetchCircuitBreakerOpenKeysCommandHandlerInst.Name(), fetchCircuitBreakerOpenKeysCommandHandlerInst)
+}
+
+// fetchCircuitBreakerOpenKeysCommandHandler 获取按键熔断器中处于打开或者半开状态的键
+type fetchCircuitBreakerOpenKeysCommandHandler struct {
+}
+
+func (f fetchCircuitBreakerOpenKeysCommandHandler) Name() string {
+	return "getCircuitBreakerOpenKeys"
+}
+
+func (f fetchCircuitBreakerOpenKeysCommandHandler) Desc() string {
+	return "get open keys of keyed circuit breakers, request param: resource={resourceName}(optional)"
+}
+
+func (f fetchCircuitBreakerOpenKeysCommandHandler) Handle(request command.Request) *command.Response {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	keys := circuitbreaker.GetOpenKeys(request.GetParam("resource"))
+	keysBytes, err := json.Marshal(keys)
+	if err != nil {
+		logging.Error(err, "[fetchCircuitBreakerOpenKeysCommandHandler] handler error")
+		return command.OfFailure(err)
+	}
+	return command.OfSuccess(string(keysBytes))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/core/election"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/transport/common/command"
+)
+
+var (
+	fetchClusterMasterCommandHandlerInst = new(fetchClusterMasterCommandHandler)
+)
+
+func init() {
+	command.RegisterHandler(fetchClusterMasterCommandHandlerInst.Name(), fetchClusterMasterCommandHandlerInst)
+}
+
+// clusterMasterVo 集群选主信息
+type clusterMasterVo struct {
+	Namespace string           `json:"namespace"`
+	Electing  bool             `json:"electing"`
+	Master    *election.Member `json:"master,omitempty"`
+	Self      *election.Member `json:"self,omitempty"`
+	IsMaster  bool             `json:"isMaster"`
+}
+
+// fetchClusterMasterCommandHandler 获取当前选举出的集群流控master
+type fetchClusterMasterCommandHandler struct {
+}
+
+func (f fetchClusterMasterCommandHandler) Name() string {
+	return "clusterMaster"
+}
+
+func (f fetchClusterMasterCommandHandler) Desc() string {
+	return "get current elected cluster token server master"
+}
+
+func (f fetchClusterMasterCommandHandler) Handle(request command.Request) *command.Response {
+	var vo = clusterMasterVo{Namespace: config.Namespace()}
+	if elector := election.GetElector(); elector != nil {
+		var self = elector.Self()
+		vo.Namespace = elector.Namespace()
+		vo.Electing = true
+		vo.Self = &self
+		vo.IsMaster = elector.IsMaster()
+		if master, ok := elector.Master(); ok {
+			vo.Master = &master
+		}
+	}
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(vo)
+	if err != nil {
+		logging.Error(err, "[fetchClusterMasterCommandHandler] handler error")
+		return command.OfFailure(err)
+	}
+	return command.OfSuccess(string(data))
+}
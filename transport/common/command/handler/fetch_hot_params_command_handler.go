@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/liuhailove/gmiter/core/hotspot"
+	"github.com/liuhailove/gmiter/logging"
+	"github.com/liuhailove/gmiter/transport/common/command"
+)
+
+var (
+	fetchHotParamsCommandHandlerInst = new(fetchHotParamsCommandHandler)
+)
+
+func init() {
+	command.RegisterHandler(fetchHotParamsCommandHandlerInst.Name(), fetchHotParamsCommandHandlerInst)
+}
+
+// fetchHotParamsCommandHandler 获取热点规则最近一个统计周期内通过数及阻塞数最大的参数值，
+// 快照由热点参数值快照任务周期性刷新，每条规则最多保留stat.hotParams.topN个参数值
+type fetchHotParamsCommandHandler struct {
+}
+
+func (f fetchHotParamsCommandHandler) Name() string {
+	return "getHotParams"
+}
+
+func (f fetchHotParamsCommandHandler) Desc() string {
+	return "get top hot parameter values of param flow rules in last period, request param: resource={resourceName}(optional)&n={topN}(optional)"
+}
+
+func (f fetchHotParamsCommandHandler) Handle(request command.Request) *command.Response {
+	// 默认返回快照中的全部参数值
+	var n int
+	if nStr := strings.TrimSpace(request.GetParam("n")); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n <= 0 {
+			return command.OfFailure(errors.New("invalid param n: " + nStr))
+		}
+	}
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	params := hotspot.GetTopParams(request.GetParam("resource"), n)
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		logging.Error(err, "[fetchHotParamsCommandHandler] handler error")
+		return command.OfFailure(err)
+	}
+	return command.OfSuccess(string(paramsBytes))
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"github.com/liuhailove/gmiter/core/authority"
 	"github.com/liuhailove/gmiter/core/isolation"
 	"github.com/liuhailove/gmiter/core/system"
 	"github.com/liuhailove/gmiter/core/weight_router"
@@ -64,7 +65,26 @@ func (m modifyRulesCommandHandler) Handle(request command.Request) *command.Resp
 		}
 		return command.OfSuccess(result)
 	} else if strings.EqualFold(AuthorityRuleType, typ) {
-		// TODO
+		authorityRulesInf, err := datasource.AuthorityRuleJsonArrayParser([]byte(data))
+		if err != nil {
+			logging.Warn("[modifyRulesCommandHandler] unmarshall error", "data", data, "err", err)
+			return command.OfFailure(err)
+		}
+		var authorityRules []*authority.Rule
+		var ok bool
+		if authorityRules, ok = authorityRulesInf.([]*authority.Rule); !ok {
+			logging.Warn("[modifyAuthorityRulesCommandHandler] assert to AuthorityRulesUpdater error", "data", data)
+			err = fmt.Errorf("[modifyAuthorityRulesCommandHandler] assert to AuthorityRulesUpdater error")
+			return command.OfFailure(err)
+		}
+		err = datasource.AuthorityRulesUpdater(authorityRules)
+		if err != nil {
+			logging.Warn("[modifyAuthorityRulesCommandHandler] AuthorityRulesUpdater error", "data", data, "err", err)
+			return command.OfFailure(err)
+		}
+		if !m.writeToDataSource(util.GetAuthorityDataSource(), []byte(data)) {
+			result = WriteDsFailureMsg
+		}
 		return command.OfSuccess(result)
 	} else if strings.EqualFold(DegradeRuleType, typ) {
 		rules, err := datasource.CircuitBreakerRuleJsonArrayParser([]byte(data))
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/liuhailove/gmiter/constants"
+	"github.com/liuhailove/gmiter/core/base"
+	"github.com/liuhailove/gmiter/core/flow"
 	"github.com/liuhailove/gmiter/logging"
 	"github.com/liuhailove/gmiter/spi"
 	"github.com/liuhailove/gmiter/transport/common/command"
@@ -126,7 +128,23 @@ func (s SimpleHttpCommandCenter) acquireClusterToken(writer http.ResponseWriter,
 	if parameterMap["prioritized"] != nil && parameterMap["prioritized"][0] != "" {
 		prioritized, _ = strconv.ParseInt(parameterMap["prioritized"][0], 10, 64)
 	}
-	var tokenResult = spi.GetRegisterTokenServiceInst(constants.DefaultTokenServiceType).GetTokenService().RequestToken(rule, uint32(acquireCount), int32(prioritized))
+	var tokenService = spi.GetRegisterTokenServiceInst(constants.DefaultTokenServiceType).GetTokenService()
+	var tokenResult *base.TokResult
+	if parameterMap["ruleId"] != nil && parameterMap["ruleId"][0] != "" {
+		// 客户端只携带规则ID及版本号，本地不存在该版本的规则时由客户端携带规则json重试
+		var version uint64
+		if parameterMap["version"] != nil {
+			version, _ = strconv.ParseUint(parameterMap["version"][0], 10, 64)
+		}
+		localRule, ruleData, localVersion := flow.ClusterRuleOf(parameterMap["ruleId"][0])
+		if localRule == nil || localVersion != version {
+			tokenResult = base.NoRuleExistsResult
+		} else {
+			tokenResult = flow.RequestTokenWithRule(tokenService, localRule, ruleData, uint32(acquireCount), int32(prioritized))
+		}
+	} else {
+		tokenResult = tokenService.RequestToken(rule, uint32(acquireCount), int32(prioritized))
+	}
 	writer.WriteHeader(http.StatusOK)
 	// Here we directly use `toString` to encode the result to plain text.
 	writer.Write([]byte(tokenResult.TokenToThinString()))
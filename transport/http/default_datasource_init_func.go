@@ -3,7 +3,12 @@ package http
 import (
 	"github.com/liuhailove/gmiter/constants"
 	"github.com/liuhailove/gmiter/core/config"
+	"github.com/liuhailove/gmiter/ext/datasource/apollo"
+	"github.com/liuhailove/gmiter/ext/datasource/consul"
+	"github.com/liuhailove/gmiter/ext/datasource/db"
+	"github.com/liuhailove/gmiter/ext/datasource/etcdv3"
 	"github.com/liuhailove/gmiter/ext/datasource/file"
+	"github.com/liuhailove/gmiter/ext/datasource/k8s"
 	"github.com/liuhailove/gmiter/logging"
 	"github.com/liuhailove/gmiter/util"
 )
@@ -24,8 +29,21 @@ func (d defaultDatasourceInitFunc) Initial() error {
 		logging.Warn("[defaultDatasourceInitFunc] WARN: Sdk closeAll is set true")
 		return nil
 	}
-	// 默认持久化加载
-	file.Initialize()
+	switch config.RuleConsistentModeType() {
+	case config.EtcdMode:
+		etcdv3.Initialize()
+	case config.ApolloMode:
+		apollo.Initialize()
+	case config.ConsulMode:
+		consul.Initialize()
+	case config.K8sMode:
+		k8s.Initialize()
+	case config.DbMode:
+		db.Initialize()
+	default:
+		// 默认持久化加载
+		file.Initialize()
+	}
 	return nil
 }
 
@@ -77,7 +77,7 @@ func NewSimpleHttpRuleSender() *simpleHttpRuleSender {
 }
 
 // SetRuleTypeCurrentVersion 设置规则的当前版本
-func (s simpleHttpRuleSender) SetRuleTypeCurrentVersion(ruleType int32, version string) {
+func SetRuleTypeCurrentVersion(ruleType int32, version string) {
 	versionMux.Lock()
 	defer versionMux.Unlock()
 
@@ -107,7 +107,7 @@ func (s simpleHttpRuleSender) SetRuleTypeCurrentVersion(ruleType int32, version
 }
 
 // GetRuleTypeCurrentVersion 获取规则的当前版本
-func (s simpleHttpRuleSender) GetRuleTypeCurrentVersion(ruleType int32) string {
+func GetRuleTypeCurrentVersion(ruleType int32) string {
 	versionMux.Lock()
 	defer versionMux.Unlock()
 
@@ -137,6 +137,16 @@ func (s simpleHttpRuleSender) GetRuleTypeCurrentVersion(ruleType int32) string {
 	}
 }
 
+// SetRuleTypeCurrentVersion 设置规则的当前版本
+func (s simpleHttpRuleSender) SetRuleTypeCurrentVersion(ruleType int32, version string) {
+	SetRuleTypeCurrentVersion(ruleType, version)
+}
+
+// GetRuleTypeCurrentVersion 获取规则的当前版本
+func (s simpleHttpRuleSender) GetRuleTypeCurrentVersion(ruleType int32) string {
+	return GetRuleTypeCurrentVersion(ruleType)
+}
+
 // GetRulesCurrentVersionStr
 //
 // 获取当前业务中规则的版本号
@@ -176,7 +186,7 @@ func (s simpleHttpRuleSender) Rest2ImpossibleVersion() {
 
 // RuleTypes 返回规则类
 func (s simpleHttpRuleSender) RuleTypes() []int32 {
-	return []int32{FlowRuleType, DegradeRuleType, HotParamRuleType, MockRuleType, SystemRuleType, RetryRuleType, GrayRuleType, IsolationRuleType, WeightRouterRuleType}
+	return []int32{FlowRuleType, DegradeRuleType, HotParamRuleType, MockRuleType, SystemRuleType, AuthorityRuleType, RetryRuleType, GrayRuleType, IsolationRuleType, WeightRouterRuleType}
 }
 
 func (s simpleHttpRuleSender) RuleTypeStr() string {
@@ -0,0 +1,501 @@
+// Package etcd 提供规则数据源及选主共用的 etcd v3 客户端
+package etcd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+const (
+	rangePath       = "/v3/kv/range"
+	putPath         = "/v3/kv/put"
+	watchPath       = "/v3/watch"
+	leaseGrantPath  = "/v3/lease/grant"
+	keepAlivePath   = "/v3/lease/keepalive"
+	leaseRevokePath = "/v3/lease/revoke"
+	txnPath         = "/v3/kv/txn"
+
+	// EventTypeDelete etcd 删除事件，PUT 为枚举默认值，网关序列化时会省略
+	EventTypeDelete = "DELETE"
+
+	DefaultRequestTimeout = 3 * time.Second
+)
+
+var (
+	ErrNoEndpoints  = errors.New("etcdv3 endpoints is empty")
+	ErrLeaseExpired = errors.New("etcdv3 lease expired")
+	ErrCompacted    = errors.New("etcdv3 watch revision has been compacted")
+)
+
+// int64Str etcd 网关会将 int64 序列化为字符串，这里同时兼容数字和字符串两种格式
+type int64Str int64
+
+func (i *int64Str) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), "\"")
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64Str(v)
+	return nil
+}
+
+type responseHeader struct {
+	Revision int64Str `json:"revision"`
+}
+
+// KeyValue etcd 中的一个键值对
+type KeyValue struct {
+	Key         []byte   `json:"key"`
+	Value       []byte   `json:"value"`
+	ModRevision int64Str `json:"mod_revision"`
+	Version     int64Str `json:"version"`
+}
+
+type rangeRequest struct {
+	Key []byte `json:"key"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []*KeyValue    `json:"kvs"`
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	Lease int64  `json:"lease,omitempty"`
+}
+
+type putResponse struct {
+	Header responseHeader `json:"header"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte `json:"key"`
+	StartRevision int64  `json:"start_revision,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest watchCreateRequest `json:"create_request"`
+}
+
+// Event 一次 watch 回调中的单个变更事件
+type Event struct {
+	Type string    `json:"type"`
+	Kv   *KeyValue `json:"kv"`
+}
+
+type watchResult struct {
+	Header          responseHeader `json:"header"`
+	Created         bool           `json:"created"`
+	Canceled        bool           `json:"canceled"`
+	CompactRevision int64Str       `json:"compact_revision"`
+	CancelReason    string         `json:"cancel_reason"`
+	Events          []*Event       `json:"events"`
+}
+
+type watchResponse struct {
+	Result *watchResult `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type leaseGrantRequest struct {
+	TTL int64 `json:"TTL"`
+}
+
+type leaseGrantResponse struct {
+	ID  int64Str `json:"ID"`
+	TTL int64Str `json:"TTL"`
+}
+
+type keepAliveRequest struct {
+	ID int64 `json:"ID"`
+}
+
+type leaseRevokeRequest struct {
+	ID int64 `json:"ID"`
+}
+
+type leaseRevokeResponse struct {
+	Header responseHeader `json:"header"`
+}
+
+type keepAliveResponse struct {
+	Result *struct {
+		ID  int64Str `json:"ID"`
+		TTL int64Str `json:"TTL"`
+	} `json:"result"`
+}
+
+type txnCompare struct {
+	Key            []byte `json:"key"`
+	Result         string `json:"result"`
+	Target         string `json:"target"`
+	CreateRevision *int64 `json:"create_revision,omitempty"`
+	Value          []byte `json:"value,omitempty"`
+}
+
+type requestOp struct {
+	RequestPut         *putRequest   `json:"request_put,omitempty"`
+	RequestRange       *rangeRequest `json:"request_range,omitempty"`
+	RequestDeleteRange *rangeRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []*txnCompare `json:"compare"`
+	Success []*requestOp  `json:"success"`
+	Failure []*requestOp  `json:"failure,omitempty"`
+}
+
+type txnResponse struct {
+	Header    responseHeader `json:"header"`
+	Succeeded bool           `json:"succeeded"`
+	Responses []*struct {
+		ResponseRange *rangeResponse `json:"response_range"`
+	} `json:"responses"`
+}
+
+// Client 基于 etcd v3 gRPC-gateway(JSON over HTTP) 的轻量客户端，
+// 多个 endpoint 之间在请求失败时轮转
+type Client struct {
+	endpoints  []string
+	current    int
+	mux        sync.Mutex
+	httpClient *http.Client
+
+	// session 客户端共享的会话，见 AcquireSession
+	session    *Session
+	sessionMux sync.Mutex
+}
+
+// NewClient 创建客户端，endpoints 为逗号分隔的地址列表，未指定协议时默认为 http
+func NewClient(endpoints string) (*Client, error) {
+	var eps []string
+	for _, ep := range strings.Split(endpoints, ",") {
+		ep = strings.TrimSpace(ep)
+		if ep == "" {
+			continue
+		}
+		if !strings.HasPrefix(ep, "http://") && !strings.HasPrefix(ep, "https://") {
+			ep = "http://" + ep
+		}
+		eps = append(eps, strings.TrimRight(ep, "/"))
+	}
+	if len(eps) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	return &Client{
+		endpoints:  eps,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+func (c *Client) endpoint() string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.endpoints[c.current%len(c.endpoints)]
+}
+
+// failover 切换到下一个 endpoint
+func (c *Client) failover() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.current = (c.current + 1) % len(c.endpoints)
+}
+
+func (c *Client) post(ctx context.Context, path string, req, rsp interface{}) error {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := 0; i < len(c.endpoints); i++ {
+		lastErr = c.doPost(ctx, c.endpoint()+path, body, rsp)
+		if lastErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return lastErr
+		}
+		c.failover()
+	}
+	return lastErr
+}
+
+func (c *Client) doPost(ctx context.Context, url string, body []byte, rsp interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpRsp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRsp.Body.Close()
+	if httpRsp.StatusCode != http.StatusOK {
+		return errors.Errorf("etcdv3 request %s failed, status code: %d", url, httpRsp.StatusCode)
+	}
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	return json.NewDecoder(httpRsp.Body).Decode(rsp)
+}
+
+// Get 读取 key 对应的值，key 不存在时返回 nil
+func (c *Client) Get(ctx context.Context, key string) (*KeyValue, int64, error) {
+	var rsp rangeResponse
+	if err := c.post(ctx, rangePath, &rangeRequest{Key: []byte(key)}, &rsp); err != nil {
+		return nil, 0, err
+	}
+	if len(rsp.Kvs) == 0 {
+		return nil, int64(rsp.Header.Revision), nil
+	}
+	return rsp.Kvs[0], int64(rsp.Header.Revision), nil
+}
+
+// Put 写入 key，leaseId 为 0 时不绑定租约
+func (c *Client) Put(ctx context.Context, key string, value []byte, leaseId int64) (int64, error) {
+	var rsp putResponse
+	if err := c.post(ctx, putPath, &putRequest{Key: []byte(key), Value: value, Lease: leaseId}, &rsp); err != nil {
+		return 0, err
+	}
+	return int64(rsp.Header.Revision), nil
+}
+
+// Grant 申请一个 ttl 秒的租约，返回租约ID
+func (c *Client) Grant(ctx context.Context, ttl int64) (int64, error) {
+	var rsp leaseGrantResponse
+	if err := c.post(ctx, leaseGrantPath, &leaseGrantRequest{TTL: ttl}, &rsp); err != nil {
+		return 0, err
+	}
+	return int64(rsp.ID), nil
+}
+
+// KeepAliveOnce 续约一次，租约已过期时返回 ErrLeaseExpired
+func (c *Client) KeepAliveOnce(ctx context.Context, leaseId int64) error {
+	var rsp keepAliveResponse
+	if err := c.post(ctx, keepAlivePath, &keepAliveRequest{ID: leaseId}, &rsp); err != nil {
+		return err
+	}
+	if rsp.Result == nil || rsp.Result.TTL <= 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// Revoke 撤销租约
+func (c *Client) Revoke(ctx context.Context, leaseId int64) error {
+	var rsp leaseRevokeResponse
+	return c.post(ctx, leaseRevokePath, &leaseRevokeRequest{ID: leaseId}, &rsp)
+}
+
+// PutIfAbsent key 不存在时写入并返回 true，否则返回 false 及当前的值
+func (c *Client) PutIfAbsent(ctx context.Context, key string, value []byte, leaseId int64) (bool, *KeyValue, error) {
+	var createRevision int64
+	var rsp txnResponse
+	req := &txnRequest{
+		Compare: []*txnCompare{{Key: []byte(key), Result: "EQUAL", Target: "CREATE", CreateRevision: &createRevision}},
+		Success: []*requestOp{{RequestPut: &putRequest{Key: []byte(key), Value: value, Lease: leaseId}}},
+		Failure: []*requestOp{{RequestRange: &rangeRequest{Key: []byte(key)}}},
+	}
+	if err := c.post(ctx, txnPath, req, &rsp); err != nil {
+		return false, nil, err
+	}
+	if rsp.Succeeded {
+		return true, nil, nil
+	}
+	for _, op := range rsp.Responses {
+		if op.ResponseRange != nil && len(op.ResponseRange.Kvs) > 0 {
+			return false, op.ResponseRange.Kvs[0], nil
+		}
+	}
+	return false, nil, nil
+}
+
+// DeleteIfEqual key 的值等于 value 时删除，返回是否删除
+func (c *Client) DeleteIfEqual(ctx context.Context, key string, value []byte) (bool, error) {
+	var rsp txnResponse
+	req := &txnRequest{
+		Compare: []*txnCompare{{Key: []byte(key), Result: "EQUAL", Target: "VALUE", Value: value}},
+		Success: []*requestOp{{RequestDeleteRange: &rangeRequest{Key: []byte(key)}}},
+	}
+	if err := c.post(ctx, txnPath, req, &rsp); err != nil {
+		return false, err
+	}
+	return rsp.Succeeded, nil
+}
+
+// Watch 从 startRevision 开始监听 key 的变更，每批事件回调一次 fn；
+// 连接断开、ctx 取消或服务端取消 watch 时返回
+func (c *Client) Watch(ctx context.Context, key string, startRevision int64, fn func(events []*Event, revision int64)) error {
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	body, err := json.Marshal(&watchRequest{CreateRequest: watchCreateRequest{Key: []byte(key), StartRevision: startRevision}})
+	if err != nil {
+		return err
+	}
+	url := c.endpoint() + watchPath
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpRsp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		c.failover()
+		return err
+	}
+	defer httpRsp.Body.Close()
+	if httpRsp.StatusCode != http.StatusOK {
+		c.failover()
+		return errors.Errorf("etcdv3 watch %s failed, status code: %d", url, httpRsp.StatusCode)
+	}
+	scanner := bufio.NewScanner(httpRsp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rsp watchResponse
+		if err = json.Unmarshal(line, &rsp); err != nil {
+			return errors.Wrap(err, "etcdv3 fail to decode watch response")
+		}
+		if rsp.Error != nil {
+			return errors.New(rsp.Error.Message)
+		}
+		if rsp.Result == nil {
+			continue
+		}
+		if rsp.Result.CompactRevision > 0 {
+			return ErrCompacted
+		}
+		if rsp.Result.Canceled {
+			return errors.Errorf("etcdv3 watch canceled, reason: %s", rsp.Result.CancelReason)
+		}
+		if len(rsp.Result.Events) > 0 {
+			fn(rsp.Result.Events, int64(rsp.Result.Header.Revision))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("etcdv3 watch stream closed")
+}
+
+// Session 同一客户端的使用方共享的会话，持有一个租约并周期性续约。
+// 租约不绑定任何 key，只用于及时发现与 etcd 的连接丢失(如网络分区超过TTL)，
+// 续约失败时 Done 关闭，使用方应重建 watch 并全量读取
+type Session struct {
+	leaseId int64
+	refs    int
+	done    chan struct{}
+	err     error
+	cancel  context.CancelFunc
+}
+
+// LeaseId 会话的租约ID
+func (s *Session) LeaseId() int64 {
+	return s.leaseId
+}
+
+// Done 会话丢失或最后一个使用方释放会话时关闭
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err Done 关闭后返回会话丢失的原因，正常释放时为 nil
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// AcquireSession 获取客户端共享的会话，当前没有可用会话时申请 ttl 秒的租约创建新会话，
+// ttl 以创建会话的调用为准。使用完毕后需调用 ReleaseSession
+func (c *Client) AcquireSession(ctx context.Context, ttl int64) (*Session, error) {
+	c.sessionMux.Lock()
+	defer c.sessionMux.Unlock()
+	if s := c.session; s != nil {
+		select {
+		case <-s.done:
+		default:
+			s.refs++
+			return s, nil
+		}
+	}
+	leaseId, err := c.Grant(ctx, ttl)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to grant session lease")
+	}
+	kaCtx, cancel := context.WithCancel(context.Background())
+	s := &Session{leaseId: leaseId, refs: 1, done: make(chan struct{}), cancel: cancel}
+	c.session = s
+	go func() {
+		s.err = c.keepAlive(kaCtx, leaseId, time.Duration(ttl)*time.Second/3)
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// ReleaseSession 释放会话，最后一个使用方释放时停止续约并撤销租约，返回撤销租约的错误
+func (c *Client) ReleaseSession(s *Session) error {
+	c.sessionMux.Lock()
+	s.refs--
+	last := s.refs == 0
+	if last && c.session == s {
+		c.session = nil
+	}
+	c.sessionMux.Unlock()
+	if !last {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	// 已过期的租约无需撤销
+	if s.err == ErrLeaseExpired {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return c.Revoke(ctx, s.leaseId)
+}
+
+// keepAlive 每隔 interval 续约一次，直到 ctx 取消或续约失败
+func (c *Client) keepAlive(ctx context.Context, leaseId int64, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.KeepAliveOnce(ctx, leaseId); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
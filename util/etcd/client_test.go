@@ -0,0 +1,132 @@
+package etcd
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient(" , ")
+	assert.Equal(t, ErrNoEndpoints, err)
+
+	c, err := NewClient("127.0.0.1:2379, https://10.0.0.1:2379/")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"http://127.0.0.1:2379", "https://10.0.0.1:2379"}, c.endpoints)
+}
+
+// TestClient_Etcd 在真实的 etcd 上校验网关的 JSON 格式，需要通过环境变量 ETCD_ENDPOINTS 指定地址，
+// 例如：ETCD_ENDPOINTS=127.0.0.1:2379 go test ./util/etcd/
+func TestClient_Etcd(t *testing.T) {
+	endpoints := os.Getenv("ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("ETCD_ENDPOINTS is not set")
+	}
+	c, err := NewClient(endpoints)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	key := "/gmiter/test/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	t.Run("GetPut", func(t *testing.T) {
+		kv, revision, err := c.Get(ctx, key)
+		assert.Nil(t, err)
+		assert.Nil(t, kv)
+		assert.True(t, revision > 0)
+
+		putRevision, err := c.Put(ctx, key, []byte("[1]"), 0)
+		assert.Nil(t, err)
+		assert.True(t, putRevision > revision)
+		kv, revision, err = c.Get(ctx, key)
+		assert.Nil(t, err)
+		if assert.NotNil(t, kv) {
+			assert.Equal(t, key, string(kv.Key))
+			assert.Equal(t, "[1]", string(kv.Value))
+			assert.Equal(t, putRevision, int64(kv.ModRevision))
+			assert.Equal(t, int64(1), int64(kv.Version))
+		}
+		assert.Equal(t, putRevision, revision)
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		_, revision, err := c.Get(ctx, key)
+		assert.Nil(t, err)
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		events := make(chan *Event, 2)
+		go func() {
+			_ = c.Watch(watchCtx, key, revision+1, func(evs []*Event, _ int64) {
+				for _, ev := range evs {
+					events <- ev
+				}
+			})
+		}()
+		_, err = c.Put(ctx, key, []byte("[2]"), 0)
+		assert.Nil(t, err)
+		ok, err := c.DeleteIfEqual(ctx, key, []byte("[2]"))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		for _, expected := range []struct{ typ, value string }{{"", "[2]"}, {EventTypeDelete, ""}} {
+			select {
+			case ev := <-events:
+				assert.Equal(t, expected.typ, ev.Type)
+				if assert.NotNil(t, ev.Kv) {
+					assert.Equal(t, key, string(ev.Kv.Key))
+					assert.Equal(t, expected.value, string(ev.Kv.Value))
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("watch event timeout")
+			}
+		}
+	})
+
+	t.Run("PutIfAbsent", func(t *testing.T) {
+		ok, kv, err := c.PutIfAbsent(ctx, key, []byte("a"), 0)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, kv)
+		ok, kv, err = c.PutIfAbsent(ctx, key, []byte("b"), 0)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		if assert.NotNil(t, kv) {
+			assert.Equal(t, "a", string(kv.Value))
+		}
+		ok, err = c.DeleteIfEqual(ctx, key, []byte("b"))
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		ok, err = c.DeleteIfEqual(ctx, key, []byte("a"))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("Lease", func(t *testing.T) {
+		leaseId, err := c.Grant(ctx, 5)
+		assert.Nil(t, err)
+		assert.NotZero(t, leaseId)
+		_, err = c.Put(ctx, key, []byte("lease"), leaseId)
+		assert.Nil(t, err)
+		assert.Nil(t, c.KeepAliveOnce(ctx, leaseId))
+		assert.Nil(t, c.Revoke(ctx, leaseId))
+		// 撤销租约后绑定的key被删除，续约返回 ErrLeaseExpired
+		kv, _, err := c.Get(ctx, key)
+		assert.Nil(t, err)
+		assert.Nil(t, kv)
+		assert.Equal(t, ErrLeaseExpired, c.KeepAliveOnce(ctx, leaseId))
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		s1, err := c.AcquireSession(ctx, 5)
+		assert.Nil(t, err)
+		s2, err := c.AcquireSession(ctx, 5)
+		assert.Nil(t, err)
+		assert.Equal(t, s1, s2)
+		assert.Nil(t, c.ReleaseSession(s1))
+		assert.Nil(t, c.KeepAliveOnce(ctx, s2.LeaseId()))
+		assert.Nil(t, c.ReleaseSession(s2))
+		assert.Equal(t, ErrLeaseExpired, c.KeepAliveOnce(ctx, s2.LeaseId()))
+		assert.Nil(t, s2.Err())
+	})
+}